@@ -0,0 +1,71 @@
+package logger
+
+import "time"
+
+// AccessLogSchema names the fields of the access logs of the middleware
+// shipped with the logger, such as ginmw, so the access logs of services
+// using the same schema share their dashboards.
+type AccessLogSchema struct {
+	Method string
+	// Path is the path of the request, followed by its query unless Query is
+	// set.
+	Path         string
+	Query        string
+	Status       string
+	Duration     string
+	ClientIP     string
+	UserAgent    string
+	ResponseSize string
+	// Error is the error of a recovered panic, Errors the errors a handler
+	// attached to the request.
+	Error        string
+	Errors       string
+	RequestBody  string
+	ResponseBody string
+	// DurationNanos logs the duration in nanoseconds, rather than as a
+	// string such as 1.5ms.
+	DurationNanos bool
+}
+
+// DefaultAccessLogSchema is the schema of the access logs of the middleware
+// unless set otherwise.
+var DefaultAccessLogSchema = AccessLogSchema{
+	Method:       "method",
+	Path:         "path",
+	Status:       "status",
+	Duration:     "latency",
+	ClientIP:     "client_ip",
+	UserAgent:    "user_agent",
+	ResponseSize: "size",
+	Error:        "error",
+	Errors:       "errors",
+	RequestBody:  "request_body",
+	ResponseBody: "response_body",
+}
+
+// ECSAccessLogSchema names the fields of the access logs after the Elastic
+// Common Schema, which log pipelines and dashboards understand out of the
+// box.
+var ECSAccessLogSchema = AccessLogSchema{
+	Method:        "http.request.method",
+	Path:          "url.path",
+	Query:         "url.query",
+	Status:        "http.response.status_code",
+	Duration:      "event.duration",
+	ClientIP:      "client.ip",
+	UserAgent:     "user_agent.original",
+	ResponseSize:  "http.response.body.bytes",
+	Error:         "error.message",
+	Errors:        "error.message",
+	RequestBody:   "http.request.body.content",
+	ResponseBody:  "http.response.body.content",
+	DurationNanos: true,
+}
+
+// DurationValue returns the value of the Duration field for d.
+func (s AccessLogSchema) DurationValue(d time.Duration) interface{} {
+	if s.DurationNanos {
+		return d.Nanoseconds()
+	}
+	return d.String()
+}
@@ -0,0 +1,75 @@
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nextmicro/logger"
+	"github.com/nextmicro/logger/logtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// saramaStdLogger is sarama.StdLogger.
+type saramaStdLogger interface {
+	Print(v ...interface{})
+	Printf(format string, v ...interface{})
+	Println(v ...interface{})
+}
+
+// natsLogger is server.Logger of nats-server.
+type natsLogger interface {
+	Noticef(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+	Fatalf(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+	Debugf(format string, v ...interface{})
+	Tracef(format string, v ...interface{})
+}
+
+var (
+	_ saramaStdLogger = (*Sarama)(nil)
+	_ natsLogger      = (*NATS)(nil)
+)
+
+func TestSarama(t *testing.T) {
+	var buf bytes.Buffer
+	var s saramaStdLogger = NewSarama(logger.New(logger.WithWriter(&buf)))
+	s.Print("client/metadata ", "fetching metadata")
+	s.Printf("connected to broker at %s\n", "localhost:9092")
+	s.Println("consumer", "stopped")
+
+	logged := logtest.Entries(t, buf.Bytes())
+	assert.Len(t, logged, 3)
+	assert.Equal(t, "client/metadata fetching metadata", logged[0]["msg"])
+	assert.Equal(t, "connected to broker at localhost:9092", logged[1]["msg"])
+	assert.Equal(t, "consumer stopped", logged[2]["msg"])
+	for _, e := range logged {
+		assert.Equal(t, "info", e["level"])
+		assert.Equal(t, "sarama", e[componentKey])
+		assert.Contains(t, e["caller"], "adapter_test.go")
+	}
+}
+
+func TestNATS(t *testing.T) {
+	var buf bytes.Buffer
+	var n natsLogger = NewNATS(logger.New(
+		logger.WithWriter(&buf),
+		logger.WithLevel(logger.DebugLevel),
+		logger.WithVerbosity(1),
+	))
+	n.Noticef("server is ready")
+	n.Warnf("slow consumer detected")
+	n.Errorf("error reading from client: %s", "EOF")
+	n.Debugf("client connection created")
+	n.Tracef("<<- [PING]")
+
+	logged := logtest.Entries(t, buf.Bytes())
+	assert.Len(t, logged, 5)
+	for i, level := range []string{"info", "warn", "error", "debug", "debug"} {
+		assert.Equal(t, level, logged[i]["level"])
+		assert.Equal(t, "nats", logged[i][componentKey])
+		assert.Contains(t, logged[i]["caller"], "adapter_test.go")
+	}
+	assert.Equal(t, "error reading from client: EOF", logged[2]["msg"])
+	assert.Equal(t, float64(1), logged[4]["v"])
+}
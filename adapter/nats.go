@@ -0,0 +1,48 @@
+package adapter
+
+import (
+	"github.com/nextmicro/logger"
+)
+
+const componentKey = "component"
+
+// A NATS logs the messages of the NATS server, embedded or run in process.
+// It satisfies server.Logger:
+//
+//	s.SetLoggerV2(adapter.NewNATS(logger.Default()), debug, trace, false)
+//
+// Notices are logged at InfoLevel and traces as V(1) entries, the other
+// methods log at their level. Fatalf exits like the logger does. Entries have
+// a component field set to nats.
+type NATS struct {
+	l logger.Logger
+}
+
+// NewNATS returns a NATS logging to l.
+func NewNATS(l logger.Logger) *NATS {
+	return &NATS{l: l.WithCallDepth(1).WithFields(map[string]any{componentKey: "nats"})}
+}
+
+func (n *NATS) Noticef(format string, v ...interface{}) {
+	n.l.Infof(format, v...)
+}
+
+func (n *NATS) Warnf(format string, v ...interface{}) {
+	n.l.Warnf(format, v...)
+}
+
+func (n *NATS) Fatalf(format string, v ...interface{}) {
+	n.l.Fatalf(format, v...)
+}
+
+func (n *NATS) Errorf(format string, v ...interface{}) {
+	n.l.Errorf(format, v...)
+}
+
+func (n *NATS) Debugf(format string, v ...interface{}) {
+	n.l.Debugf(format, v...)
+}
+
+func (n *NATS) Tracef(format string, v ...interface{}) {
+	n.l.V(1).Debugf(format, v...)
+}
@@ -0,0 +1,40 @@
+// Package adapter lets client libraries log through the logger package, with
+// levels and fields, instead of writing to stderr. The adapters satisfy the
+// logger interfaces of the libraries structurally, so this package doesn't
+// depend on them.
+package adapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nextmicro/logger"
+)
+
+// A Sarama logs the messages of the sarama kafka client. It satisfies
+// sarama.StdLogger:
+//
+//	sarama.Logger = adapter.NewSarama(logger.Default())
+//
+// Sarama doesn't tell the level of its messages, they are logged at InfoLevel
+// with a component field set to sarama.
+type Sarama struct {
+	l logger.Logger
+}
+
+// NewSarama returns a Sarama logging to l.
+func NewSarama(l logger.Logger) *Sarama {
+	return &Sarama{l: l.WithCallDepth(1).WithFields(map[string]any{componentKey: "sarama"})}
+}
+
+func (s *Sarama) Print(v ...interface{}) {
+	s.l.Info(v...)
+}
+
+func (s *Sarama) Printf(format string, v ...interface{}) {
+	s.l.Infof(strings.TrimSuffix(format, "\n"), v...)
+}
+
+func (s *Sarama) Println(v ...interface{}) {
+	s.l.Info(strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry is a logged entry handed to the hooks of the logger.
+type Entry struct {
+	Level   Level     `json:"level"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"msg"`
+	// Caller is the file and line of the call site, empty if unknown.
+	Caller string `json:"caller,omitempty"`
+	// Fields are the context and call site fields of the entry.
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// newEntry returns the Entry of ent with the context fields of a core and
+// the fields of the call site.
+func newEntry(ent zapcore.Entry, ctxFields, fields []zapcore.Field) Entry {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range ctxFields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	e := Entry{
+		Level:   marshalZapLevel(ent.Level),
+		Time:    ent.Time,
+		Message: ent.Message,
+		Fields:  enc.Fields,
+	}
+	if ent.Caller.Defined {
+		e.Caller = ent.Caller.TrimmedPath()
+	}
+	return e
+}
+
+// errorAlert calls fn when n error entries are logged within window.
+type errorAlert struct {
+	n      int
+	window time.Duration
+	fn     func(sample []Entry)
+
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func newErrorAlert(n int, window time.Duration, fn func(sample []Entry)) *errorAlert {
+	return &errorAlert{n: n, window: window, fn: fn, entries: make([]Entry, 0, n)}
+}
+
+// add records e and calls fn in its own goroutine with the entries of the
+// window once there are n of them, then starts a new window, so a burst
+// raises one alert rather than one per entry.
+func (a *errorAlert) add(e Entry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	start := e.Time.Add(-a.window)
+	i := 0
+	for i < len(a.entries) && !a.entries[i].Time.After(start) {
+		i++
+	}
+	a.entries = append(a.entries[:0], a.entries[i:]...)
+	a.entries = append(a.entries, e)
+	if len(a.entries) < a.n {
+		return
+	}
+
+	sample := a.entries
+	a.entries = make([]Entry, 0, a.n)
+	go a.fn(sample)
+}
+
+// alertCore feeds the error entries to an errorAlert.
+type alertCore struct {
+	alert  *errorAlert
+	fields []zapcore.Field
+}
+
+func newAlertCore(alert *errorAlert) zapcore.Core {
+	return &alertCore{alert: alert}
+}
+
+func (c *alertCore) Enabled(lvl zapcore.Level) bool {
+	return lvl >= zapcore.ErrorLevel
+}
+
+func (c *alertCore) With(fields []zapcore.Field) zapcore.Core {
+	return &alertCore{alert: c.alert, fields: append(c.fields[:len(c.fields):len(c.fields)], fields...)}
+}
+
+func (c *alertCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *alertCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.alert.add(newEntry(ent, c.fields, fields))
+	return nil
+}
+
+func (c *alertCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithErrorAlert(t *testing.T) {
+	alerts := make(chan []Entry, 1)
+	l := New(WithWriter(io.Discard), WithErrorAlert(3, time.Minute, func(sample []Entry) {
+		alerts <- sample
+	}))
+
+	db := l.With("component", "db")
+	db.Errorw("query failed", "attempt", 1)
+	db.Warn("not counted")
+	db.Errorw("query failed", "attempt", 2)
+	select {
+	case <-alerts:
+		t.Fatal("alert below the threshold")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	db.Errorw("query failed", "attempt", 3)
+	select {
+	case sample := <-alerts:
+		assert.Len(t, sample, 3)
+		assert.Equal(t, Level(ErrorLevel), sample[2].Level)
+		assert.Equal(t, "query failed", sample[2].Message)
+		assert.Equal(t, "db", sample[2].Fields["component"])
+		assert.EqualValues(t, 3, sample[2].Fields["attempt"])
+		assert.Contains(t, sample[2].Caller, "alert_test.go")
+	case <-time.After(time.Second):
+		t.Fatal("no alert")
+	}
+
+	assert.ErrorIs(t, newOptions(WithErrorAlert(0, time.Minute, func([]Entry) {})).validate(), ErrInvalidOptions)
+	assert.ErrorIs(t, newOptions(WithErrorAlert(1, 0, func([]Entry) {})).validate(), ErrInvalidOptions)
+}
+
+func TestErrorAlertWindow(t *testing.T) {
+	alerts := make(chan []Entry, 1)
+	a := newErrorAlert(2, time.Minute, func(sample []Entry) { alerts <- sample })
+
+	now := time.Now()
+	a.add(Entry{Time: now.Add(-2 * time.Minute), Message: "old"})
+	a.add(Entry{Time: now, Message: "new"})
+	select {
+	case <-alerts:
+		t.Fatal("entry out of the window counted")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	a.add(Entry{Time: now.Add(time.Second), Message: "newer"})
+	sample := <-alerts
+	assert.Equal(t, "new", sample[0].Message)
+	assert.Equal(t, "newer", sample[1].Message)
+	assert.Empty(t, a.entries)
+}
@@ -0,0 +1,29 @@
+//go:build !race
+
+package logger_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nextmicro/logger"
+)
+
+// TestInfowAllocs guards the allocation free Infow path. It calls the
+// *Logging methods, the arguments of calls through the Logger interface
+// escape. The race detector makes sync.Pool drop items, it runs without it.
+func TestInfowAllocs(t *testing.T) {
+	l := logger.New(logger.WithMode(logger.FileMode), logger.WithPath(t.TempDir()), logger.WithFilename("allocs.log"))
+	defer l.Sync()
+	traced := l.WithTrace("4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7").(*logger.Logging)
+
+	for name, lg := range map[string]*logger.Logging{"plain": l, "traced": traced} {
+		allocs := testing.AllocsPerRun(1000, func() {
+			lg.Infow("request served", "path", "/api/users", "method", "GET", "status", 200, "bytes", 512,
+				"latency", 15*time.Millisecond, "attempt", 2, "cached", true, "ratio", 0.5)
+		})
+		if allocs != 0 {
+			t.Errorf("%s: Infow allocates %v times, want 0", name, allocs)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package logger
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	encryptedExt = ".enc"
+	// archiveMagic starts every encrypted archive, it names the format version.
+	archiveMagic     = "NMLOGENC1"
+	archiveChunkSize = 64 << 10
+)
+
+// ErrArchiveCorrupted is returned when an encrypted archive fails to decrypt,
+// because it was truncated, modified or the key is wrong.
+var ErrArchiveCorrupted = errors.New("encrypted archive is corrupted")
+
+// DecryptArchive decrypts an archive written with WithArchiveEncryption from
+// r to w. The result is the gzipped backup.
+func DecryptArchive(w io.Writer, r io.Reader, key []byte) error {
+	aead, err := newArchiveAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, len(archiveMagic)+aead.NonceSize())
+	if _, err = io.ReadFull(r, header); err != nil || string(header[:len(archiveMagic)]) != archiveMagic {
+		return ErrArchiveCorrupted
+	}
+	nonce := header[len(archiveMagic):]
+
+	var (
+		size  [4]byte
+		chunk []byte
+	)
+	for counter := uint64(0); ; counter++ {
+		if _, err = io.ReadFull(r, size[:]); err != nil {
+			// the archive ended without its final chunk.
+			return ErrArchiveCorrupted
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n > archiveChunkSize+uint32(aead.Overhead()) {
+			return ErrArchiveCorrupted
+		}
+		if cap(chunk) < int(n) {
+			chunk = make([]byte, n)
+		}
+		chunk = chunk[:n]
+		if _, err = io.ReadFull(r, chunk); err != nil {
+			return ErrArchiveCorrupted
+		}
+
+		final := false
+		plain, err := aead.Open(nil, chunkNonce(nonce, counter), chunk, chunkAD(final))
+		if err != nil {
+			final = true
+			if plain, err = aead.Open(nil, chunkNonce(nonce, counter), chunk, chunkAD(final)); err != nil {
+				return ErrArchiveCorrupted
+			}
+		}
+		if _, err = w.Write(plain); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+func newArchiveAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("archive encryption: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce of the chunk counter of an archive, the base
+// nonce with the counter xored into its last 8 bytes.
+func chunkNonce(base []byte, counter uint64) []byte {
+	nonce := append([]byte(nil), base...)
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)^counter)
+	return nonce
+}
+
+// chunkAD marks the last chunk, so a truncated archive fails to decrypt.
+func chunkAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// encryptWriter seals what is written to it with AES-GCM in chunks of
+// archiveChunkSize. Close seals the final chunk, it doesn't close w.
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint64
+	buf     []byte
+	err     error
+}
+
+func newEncryptWriter(w io.Writer, key []byte) (*encryptWriter, error) {
+	aead, err := newArchiveAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	if _, err = io.WriteString(w, archiveMagic); err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(nonce); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, nonce: nonce, buf: make([]byte, 0, archiveChunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 && e.err == nil {
+		n := copy(e.buf[len(e.buf):cap(e.buf)], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+		if len(e.buf) == cap(e.buf) {
+			e.seal(false)
+		}
+	}
+	return written, e.err
+}
+
+func (e *encryptWriter) Close() error {
+	if e.err == nil {
+		e.seal(true)
+	}
+	return e.err
+}
+
+func (e *encryptWriter) seal(final bool) {
+	sealed := e.aead.Seal(nil, chunkNonce(e.nonce, e.counter), e.buf, chunkAD(final))
+	e.counter++
+	e.buf = e.buf[:0]
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(sealed)))
+	if _, e.err = e.w.Write(size[:]); e.err == nil {
+		_, e.err = e.w.Write(sealed)
+	}
+}
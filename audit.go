@@ -0,0 +1,300 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	prevHashKey   = "prev_hash"
+	auditFilename = "audit.log"
+)
+
+// ErrAuditChainBroken is returned when an audit log fails hash chain verification.
+var ErrAuditChainBroken = errors.New("audit hash chain is broken")
+
+// NewAudit returns an append-only audit logger that writes to `audit.log` under
+// the configured path, or to the writer set with WithWriter. Every entry
+// records the sha256 of the previous entry in the `prev_hash` field, empty for
+// the first entry of the chain, and rotated files are finalized with a digest
+// sidecar. The chain continues across rotations and restarts, from the last
+// entry of the file or of its newest backup; a writer continues it from the
+// head set with WithAuditHead. The head, see AuditHead, is anchored outside
+// the files so that rewriting a whole file is detected too.
+func NewAudit(opts ...Option) *Logging {
+	opts = append([]Option{WithMode(FileMode)}, opts...)
+	opts = append(opts, WithEncoder(JsonEncoder), func(o *Options) {
+		if o.writer == nil && o.filename == "" {
+			o.filename = auditFilename
+		}
+		o.audit = true
+		o.unsampled = true
+	})
+	return New(opts...)
+}
+
+// buildAudit build hash chained audit file.
+func (l *Logging) buildAudit() ([]zapcore.Core, error) {
+	if l.opt.writer != nil {
+		l.chain = &hashChain{prev: l.opt.auditHead}
+		return []zapcore.Core{&hashChainCore{
+			LevelEnabler: zapcore.DebugLevel,
+			enc:          l.newEncoder(),
+			out:          zapcore.AddSync(l.customWriter()),
+			chain:        l.chain,
+		}}, nil
+	}
+
+	filename := path.Join(l.opt.path, l.opt.filename)
+	prev, err := lastLineHash(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	syncer, err := l.createOutput(filename)
+	if err != nil {
+		return nil, err
+	}
+	l._rollingFiles = append(l._rollingFiles, syncer)
+	if prev == "" {
+		// the file is empty or was rotated before the restart, the chain
+		// continues from the newest backup.
+		if prev, err = l.backupHead(); err != nil {
+			return nil, err
+		}
+	}
+	if prev == "" {
+		prev = l.opt.auditHead
+	}
+	l.chain = &hashChain{prev: prev}
+	return []zapcore.Core{&hashChainCore{
+		LevelEnabler: zapcore.DebugLevel,
+		enc:          l.newEncoder(),
+		out:          syncer,
+		chain:        l.chain,
+	}}, nil
+}
+
+// AuditHead returns the hash of the last entry written by the audit logger,
+// the prev_hash of the next one, to be stored outside the audit files, e.g.
+// periodically or on shutdown, and checked against VerifyAuditChain. It is
+// empty for the other loggers.
+func (l *Logging) AuditHead() string {
+	if l.chain == nil {
+		return ""
+	}
+	l.chain.mu.Lock()
+	defer l.chain.mu.Unlock()
+	return l.chain.prev
+}
+
+type hashChain struct {
+	mu   sync.Mutex
+	prev string
+}
+
+// hashChainCore is a zapcore.Core that links every entry to its predecessor.
+// The fields added with With are encoded with each entry, after prev_hash,
+// so that a namespace among them doesn't nest prev_hash.
+type hashChainCore struct {
+	zapcore.LevelEnabler
+	enc    zapcore.Encoder
+	fields []zapcore.Field
+	out    zapcore.WriteSyncer
+	chain  *hashChain
+}
+
+func (c *hashChainCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	return &clone
+}
+
+func (c *hashChainCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *hashChainCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.chain.mu.Lock()
+	defer c.chain.mu.Unlock()
+
+	enc := c.enc.Clone()
+	zap.String(prevHashKey, c.chain.prev).AddTo(enc)
+	for i := range c.fields {
+		c.fields[i].AddTo(enc)
+	}
+	buf, err := enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	// the chain only moves on once the entry is written, so the next one
+	// doesn't link to an entry missing from the file.
+	if _, err = c.out.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	c.chain.prev = lineHash(buf.Bytes())
+	if ent.Level > zapcore.ErrorLevel {
+		return c.out.Sync()
+	}
+	return nil
+}
+
+func (c *hashChainCore) Sync() error {
+	return c.out.Sync()
+}
+
+// VerifyAuditChain reads audit entries from r and checks that every entry
+// carries the hash of its predecessor, the first one prev: empty for the
+// first file of the chain, the head returned for the previous file
+// otherwise. It returns the head of the chain, the hash of the last entry,
+// to verify the next file or to compare with an anchored AuditHead.
+func VerifyAuditChain(r io.Reader, prev string) (string, error) {
+	var line int
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*megaBytes)
+	for scanner.Scan() {
+		line++
+		var entry map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return "", fmt.Errorf("%w: line %d: %v", ErrAuditChainBroken, line, err)
+		}
+
+		if got, _ := entry[prevHashKey].(string); got != prev {
+			return "", fmt.Errorf("%w: line %d", ErrAuditChainBroken, line)
+		}
+		prev = lineHash(scanner.Bytes())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return prev, nil
+}
+
+// lastLineHash returns the hash of the last entry in file, used to continue the
+// chain after a restart. The file is read backwards from its end, one block at
+// a time, until the start of the last entry.
+func lastLineHash(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	const blockSize = 4 * 1024
+	var line []byte
+	for off := info.Size(); off > 0; {
+		n := int64(blockSize)
+		if off < n {
+			n = off
+		}
+		off -= n
+		block := make([]byte, n)
+		if _, err = f.ReadAt(block, off); err != nil {
+			return "", err
+		}
+		line = append(block, line...)
+
+		trimmed := bytes.TrimRight(line, "\r\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return lineHash(trimmed[i+1:]), nil
+		}
+	}
+
+	if line = bytes.TrimRight(line, "\r\n"); len(line) == 0 {
+		return "", nil
+	}
+	return lineHash(line), nil
+}
+
+// backupHead returns the hash of the last entry of the newest backup of the
+// audit file, empty when there is none.
+func (l *Logging) backupHead() (string, error) {
+	if len(l._rotateLoggers) == 0 {
+		return "", nil
+	}
+	backups, err := l._rotateLoggers[len(l._rotateLoggers)-1].Backups()
+	if err != nil {
+		return "", err
+	}
+	if len(backups) == 0 {
+		return "", nil
+	}
+
+	backup := backups[len(backups)-1]
+	if !backup.Compressed {
+		return lastLineHash(backup.Name)
+	}
+	return l.archiveHead(backup.Name)
+}
+
+// archiveHead returns the hash of the last entry of an archived backup, read
+// to its end as gzip doesn't allow reading it backwards.
+func (l *Logging) archiveHead(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(file, encryptedExt) {
+		pr, pw := io.Pipe()
+		defer pr.Close()
+		go func() {
+			pw.CloseWithError(DecryptArchive(pw, f, l.opt.archiveKey))
+		}()
+		r = pr
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", file, err)
+	}
+	defer zr.Close()
+
+	var last []byte
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*megaBytes)
+	for scanner.Scan() {
+		if line := bytes.TrimRight(scanner.Bytes(), "\r"); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return "", fmt.Errorf("read %s: %w", file, err)
+	}
+	if len(last) == 0 {
+		return "", nil
+	}
+	return lineHash(last), nil
+}
+
+func lineHash(line []byte) string {
+	sum := sha256.Sum256(bytes.TrimRight(line, "\r\n"))
+	return hex.EncodeToString(sum[:])
+}
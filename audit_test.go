@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+// failingSyncer fails the writes while fail is set.
+type failingSyncer struct {
+	bytes.Buffer
+	fail bool
+}
+
+func (s *failingSyncer) Write(p []byte) (int, error) {
+	if s.fail {
+		return 0, errors.New("disk full")
+	}
+	return s.Buffer.Write(p)
+}
+
+func (s *failingSyncer) Sync() error {
+	return nil
+}
+
+func TestHashChainFailedWrite(t *testing.T) {
+	out := &failingSyncer{}
+	core := &hashChainCore{
+		LevelEnabler: zapcore.DebugLevel,
+		enc:          zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"}),
+		out:          out,
+		chain:        &hashChain{},
+	}
+
+	assert.NoError(t, core.Write(zapcore.Entry{Message: "first"}, nil))
+	head := core.chain.prev
+	out.fail = true
+	assert.Error(t, core.Write(zapcore.Entry{Message: "lost"}, nil))
+	assert.Equal(t, head, core.chain.prev)
+
+	// the entry after the failed one links to the last written entry.
+	out.fail = false
+	assert.NoError(t, core.Write(zapcore.Entry{Message: "second"}, nil))
+	got, err := VerifyAuditChain(bytes.NewReader(out.Bytes()), "")
+	assert.NoError(t, err)
+	assert.Equal(t, core.chain.prev, got)
+}
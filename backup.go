@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BackupInfo describes a rotated backup of a rolling log file.
+type BackupInfo struct {
+	// Name is the path of the backup.
+	Name string
+	// Size is the size of the backup in bytes.
+	Size int64
+	// Time is the time in the name of the backup, when it was rotated.
+	Time time.Time
+	// Compressed is set for the archived backups, compressed and possibly
+	// encrypted.
+	Compressed bool
+}
+
+// Backups returns the backups of the file of l, oldest first.
+func (l *RotateLogger) Backups() ([]BackupInfo, error) {
+	filename, _ := l.names()
+	archived, pending := l.backups()
+
+	var (
+		backups []BackupInfo
+		errs    []error
+	)
+	for _, file := range append(archived, pending...) {
+		info, err := os.Stat(file)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			// compressed or removed since listed.
+			continue
+		}
+		t, _ := l.backupTime(file, filepath.Clean(filename))
+		backups = append(backups, BackupInfo{
+			Name:       file,
+			Size:       info.Size(),
+			Time:       t,
+			Compressed: strings.HasSuffix(file, gzipExt) || strings.HasSuffix(file, encryptedExt),
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].Time.Equal(backups[j].Time) {
+			return backups[i].Time.Before(backups[j].Time)
+		}
+		return backups[i].Name < backups[j].Name
+	})
+	return backups, errors.Join(errs...)
+}
+
+// PurgeBackups removes the backups of the file of l rotated before
+// olderThan, with their sidecars, whatever the retention.
+func (l *RotateLogger) PurgeBackups(olderThan time.Time) error {
+	l.retainMu.Lock()
+	defer l.retainMu.Unlock()
+
+	backups, err := l.Backups()
+	errs := []error{err}
+	for _, backup := range backups {
+		if backup.Time.Before(olderThan) {
+			errs = append(errs, removeBackup(backup.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Backups returns the backups of every rolling file of l, oldest first per
+// file. It is empty when l logs to the console only.
+func (l *Logging) Backups() ([]BackupInfo, error) {
+	var (
+		backups []BackupInfo
+		errs    []error
+	)
+	for _, rl := range l._rotateLoggers {
+		b, err := rl.Backups()
+		backups = append(backups, b...)
+		errs = append(errs, err)
+	}
+	return backups, errors.Join(errs...)
+}
+
+// PurgeBackups removes the backups of every rolling file of l rotated before
+// olderThan, e.g. for admin tooling cleaning up outside of the retention.
+func (l *Logging) PurgeBackups(olderThan time.Time) error {
+	var errs []error
+	for _, rl := range l._rotateLoggers {
+		errs = append(errs, rl.PurgeBackups(olderThan))
+	}
+	return errors.Join(errs...)
+}
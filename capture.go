@@ -0,0 +1,151 @@
+package logger
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const defaultCaptureSize = 256
+
+type captureKey struct{}
+
+// WithDebugCapture returns a copy of ctx that captures debug entries. Loggers
+// from WithContext(ctx) keep the last size debug entries the logger level
+// drops, and write them out before the first error entry, so failed requests
+// get their debug detail while successful ones don't. Captured entries are
+// discarded with ctx. A size of 0 or less uses 256.
+func WithDebugCapture(ctx context.Context, size int) context.Context {
+	if size <= 0 {
+		size = defaultCaptureSize
+	}
+	return context.WithValue(ctx, captureKey{}, &captureBuffer{entries: make([]capturedEntry, 0, size)})
+}
+
+func captureFromContext(ctx context.Context) *captureBuffer {
+	buf, _ := ctx.Value(captureKey{}).(*captureBuffer)
+	return buf
+}
+
+type capturedEntry struct {
+	core   zapcore.Core
+	ent    zapcore.Entry
+	fields []zapcore.Field
+}
+
+// captureBuffer is a ring of the last captured entries.
+type captureBuffer struct {
+	mu      sync.Mutex
+	entries []capturedEntry
+	next    int
+}
+
+func (b *captureBuffer) add(e capturedEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.entries) < cap(b.entries) {
+		b.entries = append(b.entries, e)
+		return
+	}
+	b.entries[b.next] = e
+	b.next = (b.next + 1) % len(b.entries)
+}
+
+// drain returns the captured entries oldest first and empties b.
+func (b *captureBuffer) drain() []capturedEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := make([]capturedEntry, 0, len(b.entries))
+	entries = append(entries, b.entries[b.next:]...)
+	entries = append(entries, b.entries[:b.next]...)
+	for i := range b.entries {
+		b.entries[i] = capturedEntry{}
+	}
+	b.entries = b.entries[:0]
+	b.next = 0
+	return entries
+}
+
+// captureCore buffers the debug entries its wrapped core drops, and writes
+// them before the next error entry.
+type captureCore struct {
+	zapcore.Core
+	buf *captureBuffer
+}
+
+// withCapture returns a zap option capturing dropped debug entries in buf.
+func withCapture(buf *captureBuffer) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &captureCore{Core: core, buf: buf}
+	})
+}
+
+func (c *captureCore) Enabled(lvl zapcore.Level) bool {
+	return lvl == zapcore.DebugLevel || c.Core.Enabled(lvl)
+}
+
+// Level implements zapcore.LevelOf.
+func (c *captureCore) Level() zapcore.Level {
+	if lvl := zapcore.LevelOf(c.Core); lvl < zapcore.DebugLevel {
+		return lvl
+	}
+	return zapcore.DebugLevel
+}
+
+func (c *captureCore) With(fields []zapcore.Field) zapcore.Core {
+	return &captureCore{Core: c.Core.With(fields), buf: c.buf}
+}
+
+func (c *captureCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Enabled(ent.Level) {
+		if ent.Level < zapcore.ErrorLevel {
+			return c.Core.Check(ent, ce)
+		}
+		return ce.AddCore(ent, c)
+	}
+	if ent.Level == zapcore.DebugLevel {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *captureCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level < zapcore.ErrorLevel {
+		c.buf.add(capturedEntry{
+			core:   c.Core,
+			ent:    ent,
+			fields: append([]zapcore.Field(nil), fields...),
+		})
+		return nil
+	}
+
+	// the entries are checked again, so the level and sampling of each sink
+	// apply; only the level gate of the logger is lifted for the captured ones.
+	for _, e := range c.buf.drain() {
+		if ce := ungate(e.core).Check(e.ent, nil); ce != nil {
+			ce.Write(e.fields...)
+		}
+	}
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+// ungater is a core whose level gate can be lifted.
+type ungater interface {
+	// ungated returns the core without its level gate.
+	ungated() zapcore.Core
+}
+
+// ungate returns core without the level gate of its logger, if it has one.
+func ungate(core zapcore.Core) zapcore.Core {
+	if u, ok := core.(ungater); ok {
+		return u.ungated()
+	}
+	return core
+}
@@ -0,0 +1,480 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// CBOR major types, RFC 8949 section 3.1.
+const (
+	cborUint   = 0 << 5
+	cborNegInt = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborTag    = 6 << 5
+
+	cborFalse      = 0xf4
+	cborTrue       = 0xf5
+	cborNull       = 0xf6
+	cborFloat32    = 0xfa
+	cborFloat64    = 0xfb
+	cborIndefinite = 0x1f
+	cborBreak      = 0xff
+
+	cborTagEpochTime = 1
+	cborTagBignum    = 2
+	cborTagNegBignum = 3
+)
+
+// cborPool recycles the buffers of the encoded entries.
+var cborPool = buffer.NewPool()
+
+// cborEncoder encodes entries as CBOR maps, RFC 8949, one per entry without
+// a line ending. Maps and arrays are of indefinite length, so they are
+// written as they come, like the JSON encoder does. Integers keep their 64
+// bits, byte strings stay bytes and big.Int values are bignums.
+type cborEncoder struct {
+	cfg *zapcore.EncoderConfig
+	buf []byte
+	// openNamespaces is the number of maps opened by OpenNamespace.
+	openNamespaces int
+	// n is the number of values appended, as an array encoder.
+	n int
+}
+
+func newCBOREncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &cborEncoder{cfg: &cfg}
+}
+
+func (enc *cborEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	enc.addKey(key)
+	return enc.AppendArray(arr)
+}
+
+func (enc *cborEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	enc.addKey(key)
+	return enc.AppendObject(obj)
+}
+
+func (enc *cborEncoder) AddBinary(key string, value []byte) {
+	enc.addKey(key)
+	enc.buf = appendCBORBytes(enc.buf, cborBytes, value)
+}
+
+func (enc *cborEncoder) AddByteString(key string, value []byte) {
+	enc.addKey(key)
+	enc.AppendByteString(value)
+}
+
+func (enc *cborEncoder) AddBool(key string, value bool) {
+	enc.addKey(key)
+	enc.AppendBool(value)
+}
+
+func (enc *cborEncoder) AddComplex128(key string, value complex128) {
+	enc.addKey(key)
+	enc.AppendComplex128(value)
+}
+
+func (enc *cborEncoder) AddComplex64(key string, value complex64) {
+	enc.AddComplex128(key, complex128(value))
+}
+
+func (enc *cborEncoder) AddDuration(key string, value time.Duration) {
+	enc.addKey(key)
+	enc.AppendDuration(value)
+}
+
+func (enc *cborEncoder) AddFloat64(key string, value float64) {
+	enc.addKey(key)
+	enc.AppendFloat64(value)
+}
+
+func (enc *cborEncoder) AddFloat32(key string, value float32) {
+	enc.addKey(key)
+	enc.AppendFloat32(value)
+}
+
+func (enc *cborEncoder) AddInt(key string, value int)     { enc.AddInt64(key, int64(value)) }
+func (enc *cborEncoder) AddInt32(key string, value int32) { enc.AddInt64(key, int64(value)) }
+func (enc *cborEncoder) AddInt16(key string, value int16) { enc.AddInt64(key, int64(value)) }
+func (enc *cborEncoder) AddInt8(key string, value int8)   { enc.AddInt64(key, int64(value)) }
+
+func (enc *cborEncoder) AddInt64(key string, value int64) {
+	enc.addKey(key)
+	enc.AppendInt64(value)
+}
+
+func (enc *cborEncoder) AddString(key, value string) {
+	enc.addKey(key)
+	enc.AppendString(value)
+}
+
+func (enc *cborEncoder) AddTime(key string, value time.Time) {
+	enc.addKey(key)
+	enc.AppendTime(value)
+}
+
+func (enc *cborEncoder) AddUint(key string, value uint)       { enc.AddUint64(key, uint64(value)) }
+func (enc *cborEncoder) AddUint32(key string, value uint32)   { enc.AddUint64(key, uint64(value)) }
+func (enc *cborEncoder) AddUint16(key string, value uint16)   { enc.AddUint64(key, uint64(value)) }
+func (enc *cborEncoder) AddUint8(key string, value uint8)     { enc.AddUint64(key, uint64(value)) }
+func (enc *cborEncoder) AddUintptr(key string, value uintptr) { enc.AddUint64(key, uint64(value)) }
+
+func (enc *cborEncoder) AddUint64(key string, value uint64) {
+	enc.addKey(key)
+	enc.AppendUint64(value)
+}
+
+func (enc *cborEncoder) AddReflected(key string, value interface{}) error {
+	enc.addKey(key)
+	return enc.AppendReflected(value)
+}
+
+func (enc *cborEncoder) OpenNamespace(key string) {
+	enc.addKey(key)
+	enc.buf = append(enc.buf, cborMap|cborIndefinite)
+	enc.openNamespaces++
+}
+
+func (enc *cborEncoder) addKey(key string) {
+	enc.buf = appendCBORText(enc.buf, key)
+}
+
+func (enc *cborEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	enc.n++
+	sub := &cborEncoder{cfg: enc.cfg, buf: append(enc.buf, cborArray|cborIndefinite)}
+	err := arr.MarshalLogArray(sub)
+	enc.buf = append(sub.buf, cborBreak)
+	return err
+}
+
+func (enc *cborEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	enc.n++
+	sub := &cborEncoder{cfg: enc.cfg, buf: append(enc.buf, cborMap|cborIndefinite)}
+	err := obj.MarshalLogObject(sub)
+	sub.closeNamespaces()
+	enc.buf = append(sub.buf, cborBreak)
+	return err
+}
+
+func (enc *cborEncoder) AppendReflected(value interface{}) error {
+	enc.n++
+	var err error
+	enc.buf, err = appendCBORReflected(enc.buf, value)
+	return err
+}
+
+func (enc *cborEncoder) AppendBool(value bool) {
+	enc.n++
+	if value {
+		enc.buf = append(enc.buf, cborTrue)
+	} else {
+		enc.buf = append(enc.buf, cborFalse)
+	}
+}
+
+func (enc *cborEncoder) AppendByteString(value []byte) {
+	enc.n++
+	enc.buf = appendCBORText(enc.buf, string(value))
+}
+
+func (enc *cborEncoder) AppendComplex128(value complex128) {
+	enc.n++
+	enc.buf = appendCBORText(enc.buf, formatComplex(value))
+}
+
+func (enc *cborEncoder) AppendComplex64(value complex64) { enc.AppendComplex128(complex128(value)) }
+
+func (enc *cborEncoder) AppendDuration(value time.Duration) {
+	enc.appendEncoded(func(arr zapcore.PrimitiveArrayEncoder) bool {
+		if enc.cfg == nil || enc.cfg.EncodeDuration == nil {
+			return false
+		}
+		enc.cfg.EncodeDuration(value, arr)
+		return true
+	}, func() {
+		enc.AppendInt64(int64(value))
+	})
+}
+
+func (enc *cborEncoder) AppendFloat64(value float64) {
+	enc.n++
+	enc.buf = appendUint64(append(enc.buf, cborFloat64), math.Float64bits(value))
+}
+
+func (enc *cborEncoder) AppendFloat32(value float32) {
+	enc.n++
+	enc.buf = appendUint32(append(enc.buf, cborFloat32), math.Float32bits(value))
+}
+
+func (enc *cborEncoder) AppendInt(value int)     { enc.AppendInt64(int64(value)) }
+func (enc *cborEncoder) AppendInt32(value int32) { enc.AppendInt64(int64(value)) }
+func (enc *cborEncoder) AppendInt16(value int16) { enc.AppendInt64(int64(value)) }
+func (enc *cborEncoder) AppendInt8(value int8)   { enc.AppendInt64(int64(value)) }
+
+func (enc *cborEncoder) AppendInt64(value int64) {
+	enc.n++
+	if value < 0 {
+		// -1-value doesn't overflow, unlike -value.
+		enc.buf = appendCBORHead(enc.buf, cborNegInt, uint64(-1-value))
+		return
+	}
+	enc.buf = appendCBORHead(enc.buf, cborUint, uint64(value))
+}
+
+func (enc *cborEncoder) AppendString(value string) {
+	enc.n++
+	enc.buf = appendCBORText(enc.buf, value)
+}
+
+func (enc *cborEncoder) AppendTime(value time.Time) {
+	enc.appendEncoded(func(arr zapcore.PrimitiveArrayEncoder) bool {
+		if enc.cfg == nil || enc.cfg.EncodeTime == nil {
+			return false
+		}
+		enc.cfg.EncodeTime(value, arr)
+		return true
+	}, func() {
+		enc.appendEpochTime(value)
+	})
+}
+
+func (enc *cborEncoder) AppendUint(value uint)       { enc.AppendUint64(uint64(value)) }
+func (enc *cborEncoder) AppendUint32(value uint32)   { enc.AppendUint64(uint64(value)) }
+func (enc *cborEncoder) AppendUint16(value uint16)   { enc.AppendUint64(uint64(value)) }
+func (enc *cborEncoder) AppendUint8(value uint8)     { enc.AppendUint64(uint64(value)) }
+func (enc *cborEncoder) AppendUintptr(value uintptr) { enc.AppendUint64(uint64(value)) }
+
+func (enc *cborEncoder) AppendUint64(value uint64) {
+	enc.n++
+	enc.buf = appendCBORHead(enc.buf, cborUint, value)
+}
+
+// appendEpochTime appends t as an epoch-based date time, tag 1, in seconds,
+// an integer if t has no fraction of a second.
+func (enc *cborEncoder) appendEpochTime(t time.Time) {
+	enc.buf = appendCBORHead(enc.buf, cborTag, cborTagEpochTime)
+	if t.Nanosecond() == 0 {
+		enc.AppendInt64(t.Unix())
+		return
+	}
+	enc.AppendFloat64(float64(t.UnixNano()) / float64(time.Second))
+}
+
+// appendEncoded appends the one value encode appends, like the time or the
+// level encoders of the config. It appends the value of fallback if encode
+// appends none, and an array if it appends several.
+func (enc *cborEncoder) appendEncoded(encode func(zapcore.PrimitiveArrayEncoder) bool, fallback func()) {
+	sub := &cborEncoder{cfg: enc.cfg}
+	if !encode(sub) || sub.n == 0 {
+		fallback()
+		return
+	}
+	enc.n++
+	if sub.n > 1 {
+		enc.buf = appendCBORHead(enc.buf, cborArray, uint64(sub.n))
+	}
+	enc.buf = append(enc.buf, sub.buf...)
+}
+
+func (enc *cborEncoder) closeNamespaces() {
+	for ; enc.openNamespaces > 0; enc.openNamespaces-- {
+		enc.buf = append(enc.buf, cborBreak)
+	}
+}
+
+func (enc *cborEncoder) Clone() zapcore.Encoder {
+	return &cborEncoder{cfg: enc.cfg, buf: append([]byte(nil), enc.buf...), openNamespaces: enc.openNamespaces}
+}
+
+func (enc *cborEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	cfg := enc.cfg
+	final := &cborEncoder{cfg: cfg, buf: []byte{cborMap | cborIndefinite}}
+	if cfg.TimeKey != "" {
+		final.addKey(cfg.TimeKey)
+		final.AppendTime(ent.Time)
+	}
+	if cfg.LevelKey != "" {
+		final.addKey(cfg.LevelKey)
+		final.appendEncoded(func(arr zapcore.PrimitiveArrayEncoder) bool {
+			if cfg.EncodeLevel == nil {
+				return false
+			}
+			cfg.EncodeLevel(ent.Level, arr)
+			return true
+		}, func() {
+			final.AppendString(ent.Level.String())
+		})
+	}
+	if ent.LoggerName != "" && cfg.NameKey != "" {
+		final.addKey(cfg.NameKey)
+		final.appendEncoded(func(arr zapcore.PrimitiveArrayEncoder) bool {
+			if cfg.EncodeName == nil {
+				return false
+			}
+			cfg.EncodeName(ent.LoggerName, arr)
+			return true
+		}, func() {
+			final.AppendString(ent.LoggerName)
+		})
+	}
+	if ent.Caller.Defined {
+		if cfg.CallerKey != "" {
+			final.addKey(cfg.CallerKey)
+			final.appendEncoded(func(arr zapcore.PrimitiveArrayEncoder) bool {
+				if cfg.EncodeCaller == nil {
+					return false
+				}
+				cfg.EncodeCaller(ent.Caller, arr)
+				return true
+			}, func() {
+				final.AppendString(ent.Caller.String())
+			})
+		}
+		if cfg.FunctionKey != "" {
+			final.AddString(cfg.FunctionKey, ent.Caller.Function)
+		}
+	}
+	if cfg.MessageKey != "" {
+		final.AddString(cfg.MessageKey, ent.Message)
+	}
+
+	// the context fields, in the namespaces they opened.
+	final.buf = append(final.buf, enc.buf...)
+	final.openNamespaces = enc.openNamespaces
+	for i := range fields {
+		fields[i].AddTo(final)
+	}
+	final.closeNamespaces()
+	if ent.Stack != "" && cfg.StacktraceKey != "" {
+		final.AddString(cfg.StacktraceKey, ent.Stack)
+	}
+
+	out := cborPool.Get()
+	out.Write(append(final.buf, cborBreak))
+	return out, nil
+}
+
+// appendCBORHead appends the head of a data item of major type with the
+// argument v, in its shortest form.
+func appendCBORHead(b []byte, major byte, v uint64) []byte {
+	switch {
+	case v < 24:
+		return append(b, major|byte(v))
+	case v <= math.MaxUint8:
+		return append(b, major|24, byte(v))
+	case v <= math.MaxUint16:
+		return appendUint16(append(b, major|25), uint16(v))
+	case v <= math.MaxUint32:
+		return appendUint32(append(b, major|26), uint32(v))
+	default:
+		return appendUint64(append(b, major|27), v)
+	}
+}
+
+func appendCBORBytes(b []byte, major byte, v []byte) []byte {
+	return append(appendCBORHead(b, major, uint64(len(v))), v...)
+}
+
+// appendCBORText appends s as a text string, invalid UTF-8 replaced as the
+// format requires valid text.
+func appendCBORText(b []byte, s string) []byte {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+	return append(appendCBORHead(b, cborText, uint64(len(s))), s...)
+}
+
+// appendCBORBignum appends v as an integer if it fits in 64 bits, as a bignum
+// otherwise.
+func appendCBORBignum(b []byte, v *big.Int) []byte {
+	if v.IsUint64() {
+		return appendCBORHead(b, cborUint, v.Uint64())
+	}
+	if v.Sign() < 0 {
+		// negative bignums hold -1-v.
+		n := new(big.Int).Neg(v)
+		n.Sub(n, big.NewInt(1))
+		if n.IsUint64() {
+			return appendCBORHead(b, cborNegInt, n.Uint64())
+		}
+		return appendCBORBytes(appendCBORHead(b, cborTag, cborTagNegBignum), cborBytes, n.Bytes())
+	}
+	return appendCBORBytes(appendCBORHead(b, cborTag, cborTagBignum), cborBytes, v.Bytes())
+}
+
+// appendCBORReflected appends value as its JSON form does, with big.Int as
+// bignums and numbers kept as integers when they are.
+func appendCBORReflected(b []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		if v == nil {
+			return append(b, cborNull), nil
+		}
+		return appendCBORBignum(b, v), nil
+	case big.Int:
+		return appendCBORBignum(b, &v), nil
+	case []byte:
+		return appendCBORBytes(b, cborBytes, v), nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return appendCBORText(b, fmt.Sprintf("%v", value)), err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err = dec.Decode(&v); err != nil {
+		return appendCBORText(b, string(data)), err
+	}
+	return appendCBORValue(b, v), nil
+}
+
+func appendCBORValue(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, cborNull)
+	case bool:
+		if v {
+			return append(b, cborTrue)
+		}
+		return append(b, cborFalse)
+	case string:
+		return appendCBORText(b, v)
+	case json.Number:
+		if n, ok := new(big.Int).SetString(v.String(), 10); ok {
+			return appendCBORBignum(b, n)
+		}
+		if f, err := v.Float64(); err == nil {
+			return appendUint64(append(b, cborFloat64), math.Float64bits(f))
+		}
+		return appendCBORText(b, v.String())
+	case []interface{}:
+		b = appendCBORHead(b, cborArray, uint64(len(v)))
+		for _, e := range v {
+			b = appendCBORValue(b, e)
+		}
+		return b
+	case map[string]interface{}:
+		b = appendCBORHead(b, cborMap, uint64(len(v)))
+		for k, e := range v {
+			b = appendCBORValue(appendCBORText(b, k), e)
+		}
+		return b
+	default:
+		return appendCBORText(b, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,203 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// decodeCBOR decodes the data item at the start of r, with integers as
+// int64, or uint64 above it, epoch times as time.Time and bignums as
+// *big.Int.
+func decodeCBOR(r *bytes.Reader) (interface{}, error) {
+	head, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := head&0xe0, head&0x1f
+	if head == cborBreak {
+		return nil, errCBORBreak
+	}
+
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		b := make([]byte, 1<<(info-24))
+		if _, err = io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		for _, x := range b {
+			arg = arg<<8 | uint64(x)
+		}
+	case info != cborIndefinite:
+		return nil, errors.New("unexpected additional information")
+	}
+
+	switch major {
+	case cborUint:
+		if arg > math.MaxInt64 {
+			return arg, nil
+		}
+		return int64(arg), nil
+	case cborNegInt:
+		return -1 - int64(arg), nil
+	case cborBytes, cborText:
+		b := make([]byte, arg)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(b), nil
+		}
+		return b, nil
+	case cborArray:
+		var values []interface{}
+		for i := uint64(0); info == cborIndefinite || i < arg; i++ {
+			v, err := decodeCBOR(r)
+			if err == errCBORBreak && info == cborIndefinite {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case cborMap:
+		m := make(map[string]interface{})
+		for i := uint64(0); info == cborIndefinite || i < arg; i++ {
+			k, err := decodeCBOR(r)
+			if err == errCBORBreak && info == cborIndefinite {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeCBOR(r)
+			if err != nil {
+				return nil, err
+			}
+			m[k.(string)] = v
+		}
+		return m, nil
+	case cborTag:
+		v, err := decodeCBOR(r)
+		if err != nil {
+			return nil, err
+		}
+		switch arg {
+		case cborTagEpochTime:
+			if f, ok := v.(float64); ok {
+				return time.Unix(0, int64(math.Round(f*float64(time.Second)))), nil
+			}
+			return time.Unix(v.(int64), 0), nil
+		case cborTagBignum:
+			return new(big.Int).SetBytes(v.([]byte)), nil
+		case cborTagNegBignum:
+			n := new(big.Int).SetBytes(v.([]byte))
+			return n.Sub(n.Neg(n), big.NewInt(1)), nil
+		}
+		return nil, errors.New("unexpected tag")
+	}
+
+	switch head {
+	case cborFalse, cborTrue:
+		return head == cborTrue, nil
+	case cborNull:
+		return nil, nil
+	case cborFloat32:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case cborFloat64:
+		return math.Float64frombits(arg), nil
+	}
+	return nil, errors.New("unexpected simple value")
+}
+
+var errCBORBreak = errors.New("break")
+
+func TestCBOREncoder(t *testing.T) {
+	cfg := newOptions().encoderConfig
+	cfg.EncodeTime = nil
+	enc := newCBOREncoder(cfg)
+	enc.AddString("app", "test")
+	enc.OpenNamespace("ctx")
+	enc.AddInt("depth", 1)
+
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	negative := new(big.Int).Neg(huge)
+	now := time.Unix(1700000000, 0)
+	buf, err := enc.EncodeEntry(zapcore.Entry{
+		Level:   zapcore.WarnLevel,
+		Time:    now,
+		Message: "something happened",
+		Caller:  zapcore.NewEntryCaller(0, "/src/app/main.go", 42, true),
+		Stack:   "main.main()",
+	}, []zapcore.Field{
+		zap.Int64("min", math.MinInt64),
+		zap.Uint64("max", math.MaxUint64),
+		zap.Float64("ratio", 0.5),
+		zap.Bool("ok", true),
+		zap.Binary("raw", []byte{1, 2}),
+		zap.Duration("took", 1500*time.Millisecond),
+		zap.Object("user", msgpackUser{Name: "alice", Tags: []string{"a", "b"}}),
+		zap.Reflect("huge", huge),
+		zap.Any("account", struct{ Balance *big.Int }{negative}),
+		zap.Any("meta", map[string]int{"n": 1}),
+		zap.Time("at", time.Unix(1700000000, 500000000)),
+	})
+	assert.Nil(t, err)
+	defer buf.Free()
+
+	r := bytes.NewReader(buf.Bytes())
+	v, err := decodeCBOR(r)
+	assert.Nil(t, err)
+	assert.Zero(t, r.Len())
+	assert.Equal(t, map[string]interface{}{
+		"ts":     now,
+		"level":  "warn",
+		"caller": "app/main.go:42",
+		"msg":    "something happened",
+		"app":    "test",
+		"ctx": map[string]interface{}{
+			"depth":   int64(1),
+			"min":     int64(math.MinInt64),
+			"max":     uint64(math.MaxUint64),
+			"ratio":   0.5,
+			"ok":      true,
+			"raw":     []byte{1, 2},
+			"took":    "1.5s",
+			"user":    map[string]interface{}{"name": "alice", "tags": []interface{}{"a", "b"}},
+			"huge":    huge,
+			"account": map[string]interface{}{"Balance": negative},
+			"meta":    map[string]interface{}{"n": int64(1)},
+			"at":      time.Unix(1700000000, 500000000),
+		},
+		"stack": "main.main()",
+	}, v)
+}
+
+func TestCBOREncoderOption(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(WithWriter(&buf), WithEncoder(CBOREncoder))
+	l.Infow("first", "n", 1)
+	l.Infow("second", "n", 2)
+
+	r := bytes.NewReader(buf.Bytes())
+	for _, msg := range []string{"first", "second"} {
+		v, err := decodeCBOR(r)
+		assert.Nil(t, err)
+		assert.Equal(t, msg, v.(map[string]interface{})["msg"])
+	}
+	assert.Zero(t, r.Len())
+	assert.Nil(t, newOptions(WithEncoder(CBOREncoder)).validate())
+}
@@ -0,0 +1,105 @@
+package logger
+
+import (
+	"sync"
+)
+
+// channel is a lazily built logger dedicated to one kind of entries,
+// such as stat or slow logs, with its own output and rotation settings.
+type channel struct {
+	mu     sync.RWMutex
+	logger Logger
+	// helper is logger with the caller skip of the package helpers, derived
+	// once instead of on every entry.
+	helper Logger
+	// owned is set when logger was built by the channel, which closes it
+	// once replaced, rather than passed to set.
+	owned    bool
+	defaults []Option
+}
+
+func newChannel(defaults ...Option) *channel {
+	return &channel{defaults: defaults}
+}
+
+// get returns the channel logger, building it from the defaults on first use.
+func (c *channel) get() Logger {
+	l, _ := c.load()
+	return l
+}
+
+// skipped returns the channel logger for the package helpers logging to the
+// channel, which skips their frame.
+func (c *channel) skipped() Logger {
+	_, helper := c.load()
+	return helper
+}
+
+func (c *channel) load() (Logger, Logger) {
+	c.mu.RLock()
+	l, helper := c.logger, c.helper
+	c.mu.RUnlock()
+	if l != nil {
+		return l, helper
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.logger == nil {
+		c.logger = New(c.defaults...)
+		c.helper, c.owned = c.logger.WithCallDepth(callerSkipOffset), true
+	}
+	return c.logger, c.helper
+}
+
+// init rebuilds the channel logger with opts applied over the defaults.
+func (c *channel) init(opts ...Option) {
+	c.replace(New(append(append([]Option{}, c.defaults...), opts...)...), true)
+}
+
+// set replaces the channel logger with l, which stays owned by the caller.
+// A nil l resets the channel, whose logger is built from the defaults on
+// next use.
+func (c *channel) set(l Logger) {
+	c.replace(l, false)
+}
+
+// replace replaces the channel logger, closing the previous one if the
+// channel built it.
+func (c *channel) replace(l Logger, owned bool) {
+	var helper Logger
+	if l != nil {
+		helper = l.WithCallDepth(callerSkipOffset)
+	}
+	c.mu.Lock()
+	prev, prevOwned := c.logger, c.owned
+	c.logger, c.helper, c.owned = l, helper, owned
+	c.mu.Unlock()
+
+	if prevOwned && prev != l {
+		closeLogger(prev)
+	}
+}
+
+// current returns the channel logger, nil if it wasn't built.
+func (c *channel) current() Logger {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.logger
+}
+
+func (c *channel) sync() error {
+	l := c.current()
+	if l == nil {
+		return nil
+	}
+	return l.Sync()
+}
+
+func (c *channel) healthy() error {
+	l := c.current()
+	if l == nil {
+		return nil
+	}
+	return healthOf(l)
+}
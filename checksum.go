@@ -0,0 +1,49 @@
+package logger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const checksumExt = ".sha256"
+
+// ErrChecksumMismatch is returned by Verify when a file doesn't match its
+// checksum sidecar.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Verify checks file against its `.sha256` sidecar written by WithChecksum.
+// The sidecar is in sha256sum format, so `sha256sum -c` checks it as well.
+func Verify(file string) error {
+	data, err := os.ReadFile(file + checksumExt)
+	if err != nil {
+		return err
+	}
+	want, _, ok := strings.Cut(strings.TrimSpace(string(data)), " ")
+	if !ok {
+		return fmt.Errorf("invalid checksum file: %s", file+checksumExt)
+	}
+
+	h := sha256.New()
+	if err = hashFile(file, h); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, file)
+	}
+	return nil
+}
+
+// writeChecksum writes the sha256 of file to its `.sha256` sidecar.
+func writeChecksum(file string) error {
+	h := sha256.New()
+	if err := hashFile(file, h); err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%s  %s\n", hex.EncodeToString(h.Sum(nil)), filepath.Base(file))
+	return os.WriteFile(file+checksumExt, []byte(line), defaultFileMode)
+}
@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// ColorAuto colors the console output when it is a terminal and the
+	// NO_COLOR environment variable is empty.
+	ColorAuto = "auto"
+	// ColorAlways colors the console output.
+	ColorAlways = "always"
+	// ColorNever doesn't color the console output, the default.
+	ColorNever = "never"
+
+	// noColorEnv disables the colors of ColorAuto when set, see no-color.org.
+	noColorEnv = "NO_COLOR"
+)
+
+// The SGR parameters of the colors.
+const (
+	colorRed     = "31"
+	colorYellow  = "33"
+	colorBlue    = "34"
+	colorMagenta = "35"
+	colorCyan    = "36"
+)
+
+// colorMarker stands for the escape character in the colored values until
+// the entry is encoded: the JSON encoder of the console fields would escape
+// it, and it's a private use character nothing else logs.
+const colorMarker = "\ue000"
+
+var colorEscape = []byte{0x1b}
+
+// colored reports whether the console output is colored.
+func (l *Logging) colored() bool {
+	if l.opt.mode != ConsoleMode || !l.opt.encoder.IsConsole() {
+		return false
+	}
+	switch l.opt.color {
+	case ColorAlways:
+		return true
+	case ColorAuto:
+		if os.Getenv(noColorEnv) != "" {
+			return false
+		}
+		if l.opt.writer != nil {
+			return isTerminal(l.opt.writer)
+		}
+		return isTerminal(os.Stdout)
+	default:
+		return false
+	}
+}
+
+// isTerminal reports whether w is a terminal, a character device.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorEncoder is a console encoder coloring the levels and the durations
+// formatted as strings.
+type colorEncoder struct {
+	zapcore.Encoder
+}
+
+func newColorEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	if level := cfg.EncodeLevel; level != nil {
+		cfg.EncodeLevel = func(lvl zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+			level(lvl, colorArray{PrimitiveArrayEncoder: enc, color: levelColor(lvl)})
+		}
+	}
+	if duration := cfg.EncodeDuration; duration != nil {
+		cfg.EncodeDuration = func(d time.Duration, enc zapcore.PrimitiveArrayEncoder) {
+			duration(d, colorArray{PrimitiveArrayEncoder: enc, color: colorCyan})
+		}
+	}
+	return &colorEncoder{Encoder: zapcore.NewConsoleEncoder(cfg)}
+}
+
+func (enc *colorEncoder) Clone() zapcore.Encoder {
+	return &colorEncoder{Encoder: enc.Encoder.Clone()}
+}
+
+func (enc *colorEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf, err := enc.Encoder.EncodeEntry(ent, fields)
+	if err != nil || !bytes.Contains(buf.Bytes(), []byte(colorMarker)) {
+		return buf, err
+	}
+	colored := bytes.ReplaceAll(buf.Bytes(), []byte(colorMarker), colorEscape)
+	buf.Reset()
+	_, _ = buf.Write(colored)
+	return buf, nil
+}
+
+// colorArray colors the strings appended to the array.
+type colorArray struct {
+	zapcore.PrimitiveArrayEncoder
+	color string
+}
+
+func (a colorArray) AppendString(s string) {
+	a.PrimitiveArrayEncoder.AppendString(colorMarker + "[" + a.color + "m" + s + colorMarker + "[0m")
+}
+
+func (a colorArray) AppendByteString(b []byte) {
+	a.AppendString(string(b))
+}
+
+func levelColor(lvl zapcore.Level) string {
+	switch lvl {
+	case zapcore.DebugLevel:
+		return colorMagenta
+	case zapcore.InfoLevel:
+		return colorBlue
+	case zapcore.WarnLevel:
+		return colorYellow
+	default:
+		return colorRed
+	}
+}
@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithColor(t *testing.T) {
+	t.Setenv(noColorEnv, "1")
+
+	var buf bytes.Buffer
+	l := New(WithWriter(&buf), WithEncoder(ConsoleEncoder), WithColor(ColorAlways))
+	l.Infow("colored", "took", 1500*time.Millisecond)
+	l.Warn("warned")
+	assert.Contains(t, buf.String(), "\x1b[34minfo\x1b[0m")
+	assert.Contains(t, buf.String(), `{"took": "`+"\x1b[36m1.5s\x1b[0m"+`"}`)
+	assert.Contains(t, buf.String(), "\x1b[33mwarn\x1b[0m")
+	assert.NotContains(t, buf.String(), colorMarker)
+
+	for _, color := range []string{"", ColorNever, ColorAuto} {
+		buf.Reset()
+		l = New(WithWriter(&buf), WithEncoder(ConsoleEncoder), WithColor(color))
+		l.Infow("plain", "took", time.Second)
+		assert.NotContains(t, buf.String(), "\x1b", color)
+	}
+}
+
+func TestColored(t *testing.T) {
+	t.Setenv(noColorEnv, "")
+	var buf bytes.Buffer
+
+	colored := func(opts ...Option) bool {
+		o := newOptions(opts...)
+		return (&Logging{opt: &o}).colored()
+	}
+	assert.True(t, colored(WithEncoder(ConsoleEncoder), WithColor(ColorAlways)))
+	assert.False(t, colored(WithWriter(&buf), WithEncoder(ConsoleEncoder), WithColor(ColorAuto)))
+	// files are never colored, nor json.
+	assert.False(t, colored(WithMode(FileMode), WithEncoder(ConsoleEncoder), WithColor(ColorAlways)))
+	assert.False(t, colored(WithColor(ColorAlways)))
+
+	assert.ErrorIs(t, newOptions(WithColor("rainbow")).validate(), ErrInvalidOptions)
+}
@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"io"
+	"time"
+)
+
+const (
+	defaultCompressionWorkers = 1
+	defaultCompressionBacklog = 64
+)
+
+// defaultCompressor archives the backups of the rolling files that have no
+// compressor of their own, so the rolling files of a process share a worker.
+var defaultCompressor = newCompressor(defaultCompressionWorkers, defaultCompressionBacklog)
+
+// A compressor runs the archiving of the rotated backups on a bounded number
+// of workers, so simultaneous rotations don't compress all at once. The
+// workers are started on demand and exit once the backlog is empty.
+type compressor struct {
+	jobs    chan func()
+	workers chan struct{}
+}
+
+// newCompressor returns a compressor running up to workers jobs at a time,
+// with up to backlog jobs waiting.
+func newCompressor(workers, backlog int) *compressor {
+	if workers <= 0 {
+		workers = defaultCompressionWorkers
+	}
+	if backlog <= 0 {
+		backlog = defaultCompressionBacklog
+	}
+	return &compressor{
+		jobs:    make(chan func(), backlog),
+		workers: make(chan struct{}, workers),
+	}
+}
+
+// submit queues job, it returns false if the backlog is full.
+func (c *compressor) submit(job func()) bool {
+	select {
+	case c.jobs <- job:
+	default:
+		return false
+	}
+
+	c.startWorker()
+	return true
+}
+
+func (c *compressor) startWorker() {
+	select {
+	case c.workers <- struct{}{}:
+		go c.work()
+	default:
+		// all the workers are running, one takes the job.
+	}
+}
+
+func (c *compressor) work() {
+	for {
+		select {
+		case job := <-c.jobs:
+			job()
+		default:
+			<-c.workers
+			// a job queued while leaving found all the workers running.
+			if len(c.jobs) > 0 {
+				c.startWorker()
+			}
+			return
+		}
+	}
+}
+
+// rateReader reads from r at up to rate bytes per second.
+type rateReader struct {
+	r     io.Reader
+	rate  int
+	start time.Time
+	read  int64
+}
+
+func newRateReader(r io.Reader, rate int) *rateReader {
+	return &rateReader{r: r, rate: rate, start: time.Now()}
+}
+
+func (r *rateReader) Read(p []byte) (int, error) {
+	if len(p) > r.rate {
+		p = p[:r.rate]
+	}
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	due := time.Duration(float64(r.read) / float64(r.rate) * float64(time.Second))
+	if wait := due - time.Since(r.start); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressorBacklog(t *testing.T) {
+	c := newCompressor(1, 1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan int, 2)
+
+	assert.True(t, c.submit(func() {
+		close(started)
+		<-release
+		done <- 1
+	}))
+	<-started
+	assert.True(t, c.submit(func() { done <- 2 }))
+	assert.False(t, c.submit(func() { done <- 3 }), "backlog is full")
+
+	close(release)
+	assert.Equal(t, 1, <-done)
+	assert.Equal(t, 2, <-done)
+
+	// the worker exited once idle, a new job starts another one.
+	assert.Eventually(t, func() bool { return len(c.workers) == 0 }, time.Second, time.Millisecond)
+	assert.True(t, c.submit(func() { done <- 4 }))
+	assert.Equal(t, 4, <-done)
+}
+
+func TestRateReader(t *testing.T) {
+	start := time.Now()
+	data, err := io.ReadAll(newRateReader(bytes.NewReader(make([]byte, 2000)), 10000))
+	assert.Nil(t, err)
+	assert.Len(t, data, 2000)
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	assert.ErrorIs(t, newOptions(WithCompressionLevel(10)).validate(), ErrInvalidOptions)
+}
@@ -0,0 +1,200 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Config is the serializable configuration of a logger, typically loaded from
+// a config file. Zero values keep the defaults.
+type Config struct {
+	// Level is the logging level, one of debug, info, warn, error and fatal.
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+	// Mode is the logging mode, console or file.
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	// Encoder is the encoder, json or console.
+	Encoder string `json:"encoder,omitempty" yaml:"encoder,omitempty"`
+	// Color colors the console output, auto, always or never.
+	Color string `json:"color,omitempty" yaml:"color,omitempty"`
+	// Path is the log file path.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// Filename is the log filename.
+	Filename string `json:"filename,omitempty" yaml:"filename,omitempty"`
+	// Rotation is the rotation rule, day, hour or size.
+	Rotation string `json:"rotation,omitempty" yaml:"rotation,omitempty"`
+	// MaxSize is the maximum size in MB of a log file before rotation.
+	MaxSize int `json:"max_size,omitempty" yaml:"max_size,omitempty"`
+	// RotateOnMaxSize rotates the daily and hourly files early at MaxSize.
+	RotateOnMaxSize bool `json:"rotate_on_max_size,omitempty" yaml:"rotate_on_max_size,omitempty"`
+	// MaxBackups is the maximum number of backups to keep.
+	MaxBackups int `json:"max_backups,omitempty" yaml:"max_backups,omitempty"`
+	// KeepDays is the number of days to keep backups.
+	KeepDays int `json:"keep_days,omitempty" yaml:"keep_days,omitempty"`
+	// KeepHours is the number of hours to keep backups.
+	KeepHours int `json:"keep_hours,omitempty" yaml:"keep_hours,omitempty"`
+	// Compress enables gzip compression of backups.
+	Compress bool `json:"compress,omitempty" yaml:"compress,omitempty"`
+	// RollingBackend rotates the files, default or lumberjack.
+	RollingBackend string `json:"rolling_backend,omitempty" yaml:"rolling_backend,omitempty"`
+	// Sampling keeps one of every rate entries of a level, keyed by level
+	// name, e.g. {"debug": 1000, "info": 100}. Only debug and info can be
+	// sampled.
+	Sampling map[string]int `json:"sampling,omitempty" yaml:"sampling,omitempty"`
+	// Stats publishes the counters of the logger under the logger.stats expvar.
+	Stats bool `json:"stats,omitempty" yaml:"stats,omitempty"`
+	// DurationMillis logs the durations in milliseconds too, as <key>_ms.
+	DurationMillis bool `json:"duration_millis,omitempty" yaml:"duration_millis,omitempty"`
+	// HumanBytes are the keys of the byte counts logged in human units too,
+	// as <key>_human.
+	HumanBytes []string `json:"human_bytes,omitempty" yaml:"human_bytes,omitempty"`
+}
+
+// Options converts c to the equivalent options.
+func (c Config) Options() []Option {
+	var opts []Option
+	if c.Level != "" {
+		opts = append(opts, WithLevel(ParseLevel(c.Level)))
+	}
+	if c.Mode != "" {
+		opts = append(opts, WithMode(c.Mode))
+	}
+	if c.Encoder != "" {
+		opts = append(opts, WithEncoder(Encoder(c.Encoder)))
+	}
+	if c.Color != "" {
+		opts = append(opts, WithColor(c.Color))
+	}
+	if c.Path != "" {
+		opts = append(opts, WithPath(c.Path))
+	}
+	if c.Filename != "" {
+		opts = append(opts, WithFilename(c.Filename))
+	}
+	if c.Rotation != "" {
+		opts = append(opts, WithRotation(c.Rotation))
+	}
+	if c.MaxSize > 0 {
+		opts = append(opts, WithMaxSize(c.MaxSize))
+	}
+	if c.RotateOnMaxSize {
+		opts = append(opts, WithRotateOnMaxSize(c.RotateOnMaxSize))
+	}
+	if c.MaxBackups > 0 {
+		opts = append(opts, WithMaxBackups(c.MaxBackups))
+	}
+	if c.KeepDays > 0 {
+		opts = append(opts, WithKeepDays(c.KeepDays))
+	}
+	if c.KeepHours > 0 {
+		opts = append(opts, WithKeepHours(c.KeepHours))
+	}
+	if c.Compress {
+		opts = append(opts, WithCompress(c.Compress))
+	}
+	if c.RollingBackend != "" {
+		opts = append(opts, WithRollingBackend(c.RollingBackend))
+	}
+	if len(c.Sampling) > 0 {
+		rates := make(map[Level]int, len(c.Sampling))
+		for name, rate := range c.Sampling {
+			var lv Level
+			// an unknown name leaves the invalid level 0, reported by the
+			// validation of the options.
+			_ = lv.UnmarshalText([]byte(name))
+			rates[lv] = rate
+		}
+		opts = append(opts, WithLevelSampling(rates))
+	}
+	if c.Stats {
+		opts = append(opts, WithStats())
+	}
+	if c.DurationMillis {
+		opts = append(opts, WithDurationMillis())
+	}
+	if len(c.HumanBytes) > 0 {
+		opts = append(opts, WithHumanBytes(c.HumanBytes...))
+	}
+	return opts
+}
+
+// EffectiveConfig returns the configuration l runs with, the defaults
+// resolved: the current level, the daily rotation for an unset rotation and
+// the color as decided for the output. Options without a Config field, like
+// writers and hooks, aren't reported.
+func (l *Logging) EffectiveConfig() Config {
+	level, _ := l.Level().MarshalText()
+	rotation := l.opt.rotation
+	if rotation == "" {
+		rotation = dailyRotationRule
+	}
+	backend := l.opt.rollingBackend
+	if backend == "" {
+		backend = RollingBackendDefault
+	}
+	color := ColorNever
+	if l.colored() {
+		color = ColorAlways
+	}
+	return Config{
+		Level:           string(level),
+		Mode:            l.opt.mode,
+		Encoder:         string(l.opt.encoder),
+		Color:           color,
+		Path:            l.opt.path,
+		Filename:        l.opt.filename,
+		Rotation:        rotation,
+		MaxSize:         l.opt.maxSize,
+		RotateOnMaxSize: l.opt.rotateOnMaxSize,
+		MaxBackups:      l.opt.maxBackups,
+		KeepDays:        l.opt.keepDays,
+		KeepHours:       l.opt.keepHours,
+		Compress:        l.opt.compress,
+		RollingBackend:  backend,
+		Sampling:        l.samplingConfig(),
+		Stats:           l.opt.stats,
+		DurationMillis:  l.opt.durationMillis,
+		HumanBytes:      l.opt.byteKeys,
+	}
+}
+
+// samplingConfig returns the rates of WithLevelSampling keyed by level name,
+// nil if none.
+func (l *Logging) samplingConfig() map[string]int {
+	if len(l.opt.levelSampling) == 0 {
+		return nil
+	}
+	rates := make(map[string]int, len(l.opt.levelSampling))
+	for lv, rate := range l.opt.levelSampling {
+		if name, err := lv.MarshalText(); err == nil {
+			rates[string(name)] = rate
+		}
+	}
+	return rates
+}
+
+// ConfigHandler returns an http.Handler rendering the EffectiveConfig of the
+// default logger as JSON, or of the logger registered under the `name` query
+// parameter, to tell why a logger behaves the way it does.
+//
+//	curl 'localhost:8080/debug/logger/config?name=db'
+func ConfigHandler() http.Handler {
+	return http.HandlerFunc(serveConfig)
+}
+
+func serveConfig(w http.ResponseWriter, r *http.Request) {
+	l := Default()
+	if name := r.URL.Query().Get("name"); name != "" {
+		l = Get(name)
+	}
+	logging, ok := l.(*Logging)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no configuration for %T", l), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(logging.EffectiveConfig())
+}
@@ -0,0 +1,27 @@
+package logger
+
+import "context"
+
+type fieldsKey struct{}
+
+// PushFields returns a copy of ctx carrying keysAndValues, alternating keys
+// and values or zap Fields, after the fields ctx already carries. Loggers
+// from WithContext add them to every entry, so a middleware can attach the
+// fields of a request once for all the code it calls.
+func PushFields(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	if len(keysAndValues) == 0 {
+		return ctx
+	}
+	parent := FieldsFromContext(ctx)
+	fields := make([]interface{}, 0, len(parent)+len(keysAndValues))
+	fields = append(fields, parent...)
+	fields = append(fields, keysAndValues...)
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// FieldsFromContext returns the fields pushed to ctx by PushFields. The
+// returned slice must not be modified.
+func FieldsFromContext(ctx context.Context) []interface{} {
+	fields, _ := ctx.Value(fieldsKey{}).([]interface{})
+	return fields
+}
@@ -0,0 +1,241 @@
+package logger
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+var _ Logger = (*coreLogger)(nil)
+
+// coreLogger is the Logger of FromCore.
+type coreLogger struct {
+	core CoreLogger
+	// fields are the key-value pairs added to every entry.
+	fields []interface{}
+	// minLevel is the level set by WithLevel, 0 for none.
+	minLevel Level
+	// verbose logs the entries below ErrorLevel at DebugLevel, for V.
+	verbose bool
+	noTrace bool
+}
+
+// FromCore returns a Logger around core, so third parties implement the few
+// methods of CoreLogger instead of all of Logger. The capabilities core
+// implements, Leveler, ContextAware, CallerSkipper and Syncer, are used; the
+// others are provided on top of core or are no-ops: without ContextAware,
+// the span_id and trace_id of the contexts are added as fields, without
+// Leveler the levels can't change. Fatal entries exit the process once core
+// logged them. FromCore returns core itself if it is a Logger.
+func FromCore(core CoreLogger) Logger {
+	if l, ok := core.(Logger); ok {
+		return l
+	}
+	return &coreLogger{core: core}
+}
+
+// withCore returns a copy of l logging to core.
+func (l *coreLogger) withCore(core CoreLogger) *coreLogger {
+	c := *l
+	c.core = core
+	return &c
+}
+
+func (l *coreLogger) log(level Level, msg string, keysAndValues []interface{}) {
+	if level < l.minLevel {
+		return
+	}
+	if l.verbose && level < ErrorLevel {
+		level = DebugLevel
+	}
+	if len(l.fields) > 0 {
+		keysAndValues = append(l.fields[:len(l.fields):len(l.fields)], keysAndValues...)
+	}
+	l.core.Logw(level, msg, keysAndValues...)
+	if level == FatalLevel {
+		os.Exit(1)
+	}
+}
+
+func (l *coreLogger) Logw(level Level, msg string, keysAndValues ...interface{}) {
+	l.log(level, msg, keysAndValues)
+}
+
+func (l *coreLogger) Log(level Level, args ...interface{}) {
+	l.log(level, formatMessage("", args), nil)
+}
+
+func (l *coreLogger) Logf(level Level, template string, args ...interface{}) {
+	l.log(level, formatMessage(template, args), nil)
+}
+
+func (l *coreLogger) Debug(args ...interface{}) { l.Log(DebugLevel, args...) }
+
+func (l *coreLogger) Info(args ...interface{}) { l.Log(InfoLevel, args...) }
+
+func (l *coreLogger) Warn(args ...interface{}) { l.Log(WarnLevel, args...) }
+
+func (l *coreLogger) Error(args ...interface{}) { l.Log(ErrorLevel, args...) }
+
+func (l *coreLogger) Fatal(args ...interface{}) { l.Log(FatalLevel, args...) }
+
+func (l *coreLogger) Debugf(template string, args ...interface{}) {
+	l.Logf(DebugLevel, template, args...)
+}
+
+func (l *coreLogger) Infof(template string, args ...interface{}) {
+	l.Logf(InfoLevel, template, args...)
+}
+
+func (l *coreLogger) Warnf(template string, args ...interface{}) {
+	l.Logf(WarnLevel, template, args...)
+}
+
+func (l *coreLogger) Errorf(template string, args ...interface{}) {
+	l.Logf(ErrorLevel, template, args...)
+}
+
+func (l *coreLogger) Fatalf(template string, args ...interface{}) {
+	l.Logf(FatalLevel, template, args...)
+}
+
+func (l *coreLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.log(DebugLevel, msg, keysAndValues)
+}
+
+func (l *coreLogger) Infow(msg string, keysAndValues ...interface{}) {
+	l.log(InfoLevel, msg, keysAndValues)
+}
+
+func (l *coreLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.log(WarnLevel, msg, keysAndValues)
+}
+
+func (l *coreLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.log(ErrorLevel, msg, keysAndValues)
+}
+
+func (l *coreLogger) Fatalw(msg string, keysAndValues ...interface{}) {
+	l.log(FatalLevel, msg, keysAndValues)
+}
+
+func (l *coreLogger) With(args ...any) Logger {
+	if len(args) == 0 {
+		return l
+	}
+	c := *l
+	c.fields = append(l.fields[:len(l.fields):len(l.fields)], args...)
+	return &c
+}
+
+func (l *coreLogger) WithFields(fields map[string]any) Logger {
+	return l.With(SortedFields(fields)...)
+}
+
+func (l *coreLogger) Namespace(name string) Logger {
+	return l.With(zap.Namespace(name))
+}
+
+// WithLevel returns a copy of l dropping the entries below lv, never more
+// verbose than l.
+func (l *coreLogger) WithLevel(lv Level) Logger {
+	c := *l
+	if lv > c.minLevel {
+		c.minLevel = lv
+	}
+	return &c
+}
+
+// V returns a copy of l logging its entries below ErrorLevel at DebugLevel,
+// core has no verbosity to discard them.
+func (l *coreLogger) V(int) Logger {
+	c := *l
+	c.verbose = true
+	return &c
+}
+
+func (l *coreLogger) SetLevel(lv Level) {
+	if lr, ok := l.core.(Leveler); ok {
+		lr.SetLevel(lv)
+	}
+}
+
+func (l *coreLogger) SetGlobalLevel(lv Level) {
+	if lr, ok := l.core.(Leveler); ok {
+		lr.SetGlobalLevel(lv)
+	}
+}
+
+func (l *coreLogger) WithContext(ctx context.Context) Logger {
+	if ca, ok := l.core.(ContextAware); ok {
+		return l.withCore(ca.WithContext(ctx))
+	}
+	var lg Logger = l
+	if span := trace.SpanContextFromContext(ctx); !l.noTrace && (span.HasSpanID() || span.HasTraceID()) {
+		lg = l.WithTrace(TraceID(ctx), SpanID(ctx))
+	}
+	if fields := FieldsFromContext(ctx); len(fields) > 0 {
+		lg = lg.With(fields...)
+	}
+	return lg
+}
+
+func (l *coreLogger) WithTrace(traceID, spanID string) Logger {
+	if ca, ok := l.core.(ContextAware); ok {
+		return l.withCore(ca.WithTrace(traceID, spanID))
+	}
+	var fields []interface{}
+	if spanID != "" {
+		fields = append(fields, spanKey, spanID)
+	}
+	if traceID != "" {
+		fields = append(fields, traceKey, traceID)
+	}
+	return l.With(fields...)
+}
+
+func (l *coreLogger) WithoutTrace() Logger {
+	if ca, ok := l.core.(ContextAware); ok {
+		return l.withCore(ca.WithoutTrace())
+	}
+	c := *l
+	c.noTrace = true
+	return &c
+}
+
+func (l *coreLogger) WithCallDepth(callDepth int) Logger {
+	if cs, ok := l.core.(CallerSkipper); ok {
+		return l.withCore(cs.WithCallDepth(callDepth))
+	}
+	return l
+}
+
+func (l *coreLogger) WithCallDepthDelta(delta int) Logger {
+	if cs, ok := l.core.(CallerSkipper); ok {
+		return l.withCore(cs.WithCallDepthDelta(delta))
+	}
+	return l
+}
+
+func (l *coreLogger) WithCallDepthSet(depth int) Logger {
+	if cs, ok := l.core.(CallerSkipper); ok {
+		return l.withCore(cs.WithCallDepthSet(depth))
+	}
+	return l
+}
+
+func (l *coreLogger) CallDepth() int {
+	if cs, ok := l.core.(CallerSkipper); ok {
+		return cs.CallDepth()
+	}
+	return 0
+}
+
+func (l *coreLogger) Sync() error {
+	if s, ok := l.core.(Syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type correlationKey struct{}
+
+// ContextWithCorrelationID returns a copy of ctx carrying a random
+// correlation ID, which loggers built with WithCorrelationIDs log as the
+// trace_id of the untraced contexts. Call it once per request, e.g. in a
+// middleware, so all the lines of the request share the ID. ctx is returned
+// as is when it has a span or a correlation ID already.
+func ContextWithCorrelationID(ctx context.Context) context.Context {
+	if trace.SpanContextFromContext(ctx).HasTraceID() || CorrelationID(ctx) != "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationKey{}, newCorrelationID())
+}
+
+// CorrelationID returns the correlation ID of ctx, or "" if it has none.
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// newCorrelationID returns a random ID in the format of the trace IDs, 32
+// hex digits, so it fits where trace IDs are expected.
+func newCorrelationID() string {
+	var id trace.TraceID
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
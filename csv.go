@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// csvPool recycles the buffers of the encoded rows.
+var csvPool = buffer.NewPool()
+
+// csvEncoder encodes entries as rows of a fixed set of columns, RFC 4180
+// quoted, for channels loaded into spreadsheets or DuckDB. Entries are
+// encoded by the JSON encoder, so the values are formatted as in JSON files,
+// then every column takes the value of its key, nested keys joined with dots,
+// objects and arrays as compact JSON and missing keys as empty cells. The
+// fields without a column are dropped.
+type csvEncoder struct {
+	zapcore.Encoder
+	columns []string
+	comma   rune
+}
+
+func newCSVEncoder(cfg zapcore.EncoderConfig, columns []string, comma rune) zapcore.Encoder {
+	return &csvEncoder{Encoder: zapcore.NewJSONEncoder(cfg), columns: columns, comma: comma}
+}
+
+func (enc *csvEncoder) Clone() zapcore.Encoder {
+	return &csvEncoder{Encoder: enc.Encoder.Clone(), columns: enc.columns, comma: enc.comma}
+}
+
+func (enc *csvEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf, err := enc.Encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Free()
+
+	dec := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.UseNumber()
+	var entry map[string]interface{}
+	if err = dec.Decode(&entry); err != nil {
+		return nil, err
+	}
+
+	row := make([]string, len(enc.columns))
+	for i, column := range enc.columns {
+		row[i] = csvCell(lookupColumn(entry, column))
+	}
+
+	out := csvPool.Get()
+	if err = writeCSVRow(out, enc.comma, row); err != nil {
+		out.Free()
+		return nil, err
+	}
+	return out, nil
+}
+
+// csvHeader returns the header row of columns.
+func csvHeader(columns []string, comma rune) []byte {
+	var b bytes.Buffer
+	_ = writeCSVRow(&b, comma, columns)
+	return b.Bytes()
+}
+
+func writeCSVRow(out io.Writer, comma rune, row []string) error {
+	w := csv.NewWriter(out)
+	w.Comma = comma
+	if err := w.Write(row); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// lookupColumn returns the value of column in entry, a key of entry or keys
+// of nested objects joined with dots.
+func lookupColumn(entry map[string]interface{}, column string) interface{} {
+	if v, ok := entry[column]; ok {
+		return v
+	}
+	for i := strings.IndexByte(column, '.'); i >= 0; i = nextDot(column, i) {
+		if inner, ok := entry[column[:i]].(map[string]interface{}); ok {
+			if v := lookupColumn(inner, column[i+1:]); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+func nextDot(s string, i int) int {
+	j := strings.IndexByte(s[i+1:], '.')
+	if j < 0 {
+		return -1
+	}
+	return i + 1 + j
+}
+
+// csvCell returns the text of the value of a cell.
+func csvCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	default:
+		var b bytes.Buffer
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return ""
+		}
+		return strings.TrimSuffix(b.String(), "\n")
+	}
+}
+
+// defaultColumns returns the columns of the entry metadata set in cfg, the
+// time, level, caller and message.
+func defaultColumns(cfg zapcore.EncoderConfig) []string {
+	var columns []string
+	for _, key := range []string{cfg.TimeKey, cfg.LevelKey, cfg.CallerKey, cfg.MessageKey} {
+		if key != "" {
+			columns = append(columns, key)
+		}
+	}
+	return columns
+}
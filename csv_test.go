@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestCSVEncoder(t *testing.T) {
+	cfg := newOptions().encoderConfig
+	enc := newCSVEncoder(cfg, []string{"ts", "level", "msg", "app", "ctx.depth", "user.name", "user", "took", "missing"}, ',')
+	enc.AddString("app", "test, with a comma")
+	enc.OpenNamespace("ctx")
+	enc.AddInt("depth", 1)
+
+	buf, err := enc.EncodeEntry(zapcore.Entry{
+		Level:   zapcore.WarnLevel,
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Message: `say "hi"`,
+	}, []zapcore.Field{
+		zap.Object("user", msgpackUser{Name: "alice", Tags: []string{"a", "b"}}),
+		zap.Duration("took", 1500*time.Millisecond),
+		zap.String("dropped", "x"),
+	})
+	assert.Nil(t, err)
+	defer buf.Free()
+	assert.Equal(t, `2024-01-02T03:04:05.000Z,warn,"say ""hi""","test, with a comma",1,,,,`+"\n", buf.String())
+
+	enc = newCSVEncoder(cfg, []string{"msg", "user.name", "user", "took"}, '\t')
+	buf, err = enc.EncodeEntry(zapcore.Entry{Message: "tabs"}, []zapcore.Field{
+		zap.Object("user", msgpackUser{Name: "alice", Tags: []string{"a", "b"}}),
+		zap.Duration("took", 1500*time.Millisecond),
+	})
+	assert.Nil(t, err)
+	defer buf.Free()
+	assert.Equal(t, "tabs\talice\t\"{\"\"name\"\":\"\"alice\"\",\"\"tags\"\":[\"\"a\"\",\"\"b\"\"]}\"\t1.5s\n", buf.String())
+}
+
+func TestCSVEncoderHeader(t *testing.T) {
+	dir := t.TempDir()
+	l := New(
+		WithMode(FileMode),
+		WithPath(dir),
+		WithFilename("stat.log"),
+		WithEncoder(TSVEncoder),
+		WithColumns("msg", "latency"),
+	)
+	l.Infow("first", "latency", 10)
+	l.Infow("second", "latency", 20)
+	assert.Nil(t, l._rotateLoggers[0].Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "stat.log"))
+	assert.Nil(t, err)
+	assert.Equal(t, "msg\tlatency\nfirst\t10\nsecond\t20\n", string(data))
+
+	// reopening a file with entries doesn't repeat the header.
+	l = New(
+		WithMode(FileMode),
+		WithPath(dir),
+		WithFilename("stat.log"),
+		WithEncoder(TSVEncoder),
+		WithColumns("msg", "latency"),
+	)
+	l.Infow("third", "latency", 30)
+	assert.Nil(t, l._rotateLoggers[0].Close())
+
+	data, err = os.ReadFile(filepath.Join(dir, "stat.log"))
+	assert.Nil(t, err)
+	assert.Equal(t, "msg\tlatency\nfirst\t10\nsecond\t20\nthird\t30\n", string(data))
+}
+
+func TestCSVEncoderOptions(t *testing.T) {
+	assert.Nil(t, newOptions(WithEncoder(CSVEncoder), WithColumns("msg")).validate())
+	assert.ErrorIs(t, newOptions(WithColumns("msg")).validate(), ErrInvalidOptions)
+
+	o := newOptions(WithEncoder(TSVEncoder))
+	o.audit = true
+	assert.ErrorIs(t, o.validate(), ErrInvalidOptions)
+	assert.Equal(t, []string{"ts", "level", "caller", "msg"}, defaultColumns(newOptions().encoderConfig))
+}
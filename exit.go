@@ -0,0 +1,175 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// exitFlusher closes the loggers built with WithFlushOnExit when the process
+// receives SIGINT or SIGTERM, or only syncs them when the application handles
+// these signals, Shutdown then closes them.
+var exitFlusher = &flusher{}
+
+// panicFlusher syncs the loggers built with WithPanicFlush when FlushOnPanic
+// catches a panic.
+var panicFlusher = &flusher{}
+
+// exitSignalsHandled is set by SetExitSignalsHandled.
+var exitSignalsHandled atomic.Bool
+
+// SetExitSignalsHandled tells the handler installed by WithFlushOnExit that
+// the application handles SIGINT and SIGTERM itself. The handler then only
+// syncs the loggers on these signals and leaves the shutdown to the
+// application, which closes them with Shutdown. Otherwise the handler closes
+// the loggers and delivers the signal again, so the process exits as it
+// would have without it.
+func SetExitSignalsHandled(handled bool) {
+	exitSignalsHandled.Store(handled)
+}
+
+type flusher struct {
+	once    sync.Once
+	mu      sync.Mutex
+	loggers []*Logging
+}
+
+// register adds l to the loggers flushed on exit, installing the signal
+// handler on first use.
+func (f *flusher) register(l *Logging) {
+	f.add(l)
+	f.once.Do(func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			for sig := range ch {
+				if exitSignalsHandled.Load() {
+					// the application got the signal too, and may still log
+					// while it shuts down.
+					f.sync()
+					continue
+				}
+				f.close()
+
+				// stop the handler and deliver the signal again, so the
+				// process exits as it would have without it.
+				signal.Stop(ch)
+				if p, err := os.FindProcess(os.Getpid()); err == nil && p.Signal(sig) == nil {
+					return
+				}
+				if s, ok := sig.(syscall.Signal); ok {
+					os.Exit(128 + int(s))
+				}
+				os.Exit(1)
+			}
+		}()
+	})
+}
+
+// add adds l to the registered loggers.
+func (f *flusher) add(l *Logging) {
+	f.mu.Lock()
+	f.loggers = append(f.loggers, l)
+	f.mu.Unlock()
+}
+
+// remove removes l from the registered loggers, once it is closed.
+func (f *flusher) remove(l *Logging) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, r := range f.loggers {
+		if r == l {
+			f.loggers = append(f.loggers[:i], f.loggers[i+1:]...)
+			return
+		}
+	}
+}
+
+// close closes and removes every registered logger.
+func (f *flusher) close() {
+	for _, l := range f.take() {
+		_ = l.Close()
+	}
+}
+
+// sync syncs every registered logger, which stay usable.
+func (f *flusher) sync() {
+	f.mu.Lock()
+	loggers := f.loggers
+	f.mu.Unlock()
+
+	for _, l := range loggers {
+		_ = l.Sync()
+	}
+}
+
+// take removes and returns the registered loggers.
+func (f *flusher) take() []*Logging {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	loggers := f.loggers
+	f.loggers = nil
+	return loggers
+}
+
+// Shutdown closes the default logger, the stat, slow and severe loggers, the
+// registered loggers and the loggers built with WithFlushOnExit or
+// WithPanicFlush, bounding the last sends of their network sinks by ctx. It
+// is called last when the application shuts down, typically after its own
+// signal handling, as the entries logged afterwards are dropped:
+//
+//	<-quit
+//	srv.Shutdown(ctx)
+//	logger.Shutdown(ctx)
+func Shutdown(ctx context.Context) error {
+	loggers := []Logger{Default(), statChannel.current(), slowChannel.current(), severeChannel.current()}
+	loggers = append(loggers, registry.all()...)
+	for _, l := range append(exitFlusher.take(), panicFlusher.take()...) {
+		loggers = append(loggers, l)
+	}
+
+	var errs []error
+	for _, l := range loggers {
+		errs = append(errs, shutdownLogger(ctx, l))
+	}
+	return errors.Join(errs...)
+}
+
+// shutdownLogger closes l, bounded by ctx if it supports it, or only syncs
+// it if it can't be closed.
+func shutdownLogger(ctx context.Context, l Logger) error {
+	switch v := l.(type) {
+	case nil:
+		return nil
+	case interface{ Shutdown(context.Context) error }:
+		return v.Shutdown(ctx)
+	case io.Closer:
+		return v.Close()
+	default:
+		return v.Sync()
+	}
+}
+
+// FlushOnPanic syncs the loggers built with WithPanicFlush if the goroutine
+// is panicking, then panics again with the same value. Deferred first thing
+// in main, it writes the entries logged up to a crash before the runtime
+// prints the stack and exits, which still shows where the panic happened:
+//
+//	func main() {
+//		defer logger.FlushOnPanic()
+//		...
+//	}
+//
+// Go can't intercept the panics of other goroutines, which need their own
+// deferred FlushOnPanic.
+func FlushOnPanic() {
+	if r := recover(); r != nil {
+		panicFlusher.sync()
+		panic(r)
+	}
+}
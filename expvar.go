@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"expvar"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// statsName is the expvar name of the counters published by WithStats.
+const statsName = "logger.stats"
+
+// The counters published under statsName. They are process wide, like
+// expvar, and only counted once a logger with WithStats is built.
+var (
+	statsOnce    sync.Once
+	statsEnabled atomic.Bool
+
+	// statEntries counts the entries written per level.
+	statEntries = new(expvar.Map).Init()
+	// statBytes counts the bytes written to the rolling files.
+	statBytes = new(expvar.Int)
+	// statRotations counts the rotations of the rolling files.
+	statRotations = new(expvar.Int)
+	// statDrops counts the entries sampled out, and the writes to the
+	// rolling files and the webhook entries that were lost.
+	statDrops = new(expvar.Int)
+	// statInternalDrops counts the errors of the logger itself dropped by
+	// the rate limit of SetInternalOutput.
+	statInternalDrops = new(expvar.Int)
+	// statCompression is the total time spent compressing backups, in
+	// nanoseconds.
+	statCompression = new(expvar.Int)
+	// statErrorExemplar is the last traced entry counted as an error, so a
+	// dashboard can jump from an error spike to an example trace.
+	statErrorExemplar atomic.Pointer[errorExemplar]
+)
+
+// errorExemplar is the trace of an entry counted as an error.
+type errorExemplar struct {
+	TraceID string    `json:"trace_id"`
+	SpanID  string    `json:"span_id,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// enableStats publishes the counters under statsName and starts counting.
+// It leaves the var alone if something else already published statsName.
+func enableStats() {
+	statsOnce.Do(func() {
+		if expvar.Get(statsName) == nil {
+			m := expvar.NewMap(statsName)
+			m.Set("entries", statEntries)
+			m.Set("bytes_written", statBytes)
+			m.Set("rotations", statRotations)
+			m.Set("drops", statDrops)
+			m.Set("internal_drops", statInternalDrops)
+			m.Set("compression_ns", statCompression)
+			m.Set("error_exemplar", expvar.Func(func() any { return statErrorExemplar.Load() }))
+		}
+		statsEnabled.Store(true)
+	})
+}
+
+// addStat adds delta to v when stats are enabled.
+func addStat(v *expvar.Int, delta int64) {
+	if statsEnabled.Load() {
+		v.Add(delta)
+	}
+}
+
+// addCompressionStat adds the time since start to the compression time.
+func addCompressionStat(start time.Time) {
+	addStat(statCompression, int64(time.Since(start)))
+}
+
+// statsCore counts the entries per level, and keeps the trace of the error
+// entries as the exemplar of the error count.
+type statsCore struct {
+	traceID, spanID string
+	// nested is set once the fields go in a namespace, below the trace.
+	nested bool
+}
+
+func newStatsCore() zapcore.Core {
+	return statsCore{}
+}
+
+func (c statsCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c statsCore) With(fields []zapcore.Field) zapcore.Core {
+	c.addTrace(fields)
+	return c
+}
+
+// addTrace reads the trace_id and span_id fields at the root of fields.
+func (c *statsCore) addTrace(fields []zapcore.Field) {
+	for _, f := range fields {
+		switch {
+		case c.nested:
+			return
+		case f.Type == zapcore.NamespaceType:
+			c.nested = true
+		case f.Type == zapcore.StringType && f.Key == traceKey:
+			c.traceID = f.String
+		case f.Type == zapcore.StringType && f.Key == spanKey:
+			c.spanID = f.String
+		}
+	}
+}
+
+func (c statsCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+func (c statsCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !statsEnabled.Load() {
+		return nil
+	}
+	statEntries.Add(strings.ToLower(marshalZapLevel(ent.Level).String()), 1)
+	if ent.Level >= zapcore.ErrorLevel {
+		c.addTrace(fields)
+		if c.traceID != "" {
+			statErrorExemplar.Store(&errorExemplar{TraceID: c.traceID, SpanID: c.spanID, Time: ent.Time})
+		}
+	}
+	return nil
+}
+
+func (c statsCore) Sync() error {
+	return nil
+}
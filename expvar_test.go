@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"encoding/json"
+	"expvar"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithStats(t *testing.T) {
+	l := New(WithWriter(io.Discard), WithStats())
+	info := statEntries.Get("info")
+	var before int64
+	if info != nil {
+		before = info.(*expvar.Int).Value()
+	}
+
+	l.Info("counted")
+	l.Infow("counted", "n", 2)
+	l.Debug("filtered out")
+	assert.Equal(t, before+2, statEntries.Get("info").(*expvar.Int).Value())
+
+	var stats map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal([]byte(expvar.Get(statsName).String()), &stats))
+	for _, key := range []string{"entries", "bytes_written", "rotations", "drops", "internal_drops", "compression_ns", "error_exemplar"} {
+		assert.Contains(t, stats, key)
+	}
+
+	l.WithTrace("4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7").Namespace("req").Errorw("failed", traceKey, "nested")
+	exemplar := statErrorExemplar.Load()
+	if assert.NotNil(t, exemplar) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", exemplar.TraceID)
+		assert.Equal(t, "00f067aa0ba902b7", exemplar.SpanID)
+	}
+	l.Warnw("not an error", traceKey, "ignored")
+	assert.Equal(t, exemplar, statErrorExemplar.Load())
+
+	written := statBytes.Value()
+	filename := filepath.Join(t.TempDir(), "stats.log")
+	rl, err := newRotateLogger(filename, DefaultRotateRule(filename, "-", 1, false), rotateConfig{pool: bpool})
+	assert.NoError(t, err)
+	_, _ = rl.Write([]byte("entry\n"))
+	assert.NoError(t, rl.Sync())
+	assert.GreaterOrEqual(t, statBytes.Value(), written+6)
+
+	drops := statDrops.Value()
+	assert.NoError(t, rl.Close())
+	_, err = rl.Write([]byte("entry\n"))
+	assert.ErrorIs(t, err, ErrClosedRollingFile)
+	assert.GreaterOrEqual(t, statDrops.Value(), drops+1)
+}
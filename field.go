@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Field is a typed key-value pair, passed among the keysAndValues of Infow
+// and the like, With and PushFields. Unlike the plain values, fields are
+// encoded without reflection. They are the fields of zap, so callers don't
+// need to import zap for them.
+type Field = zapcore.Field
+
+// String returns a Field logging val under key.
+func String(key, val string) Field {
+	return zap.String(key, val)
+}
+
+// Strings returns a Field logging the slice val under key.
+func Strings(key string, val []string) Field {
+	return zap.Strings(key, val)
+}
+
+// Bool returns a Field logging val under key.
+func Bool(key string, val bool) Field {
+	return zap.Bool(key, val)
+}
+
+// Int returns a Field logging val under key.
+func Int(key string, val int) Field {
+	return zap.Int(key, val)
+}
+
+// Int64 returns a Field logging val under key.
+func Int64(key string, val int64) Field {
+	return zap.Int64(key, val)
+}
+
+// Uint64 returns a Field logging val under key.
+func Uint64(key string, val uint64) Field {
+	return zap.Uint64(key, val)
+}
+
+// Float64 returns a Field logging val under key.
+func Float64(key string, val float64) Field {
+	return zap.Float64(key, val)
+}
+
+// Duration returns a Field logging val under key, encoded as configured,
+// e.g. `1.5s`.
+func Duration(key string, val time.Duration) Field {
+	return zap.Duration(key, val)
+}
+
+// Time returns a Field logging val under key, encoded as configured.
+func Time(key string, val time.Time) Field {
+	return zap.Time(key, val)
+}
+
+// Stringer returns a Field logging the String of val under key, called only
+// when the entry is written.
+func Stringer(key string, val fmt.Stringer) Field {
+	return zap.Stringer(key, val)
+}
+
+// Err returns a Field logging the message of err under the `error` key, or
+// a no-op Field if err is nil.
+func Err(err error) Field {
+	return zap.Error(err)
+}
+
+// NamedErr is Err under key.
+func NamedErr(key string, err error) Field {
+	return zap.NamedError(key, err)
+}
+
+// Any returns a Field logging val under key, with the typed Field of its
+// type when it has one, and reflection otherwise.
+func Any(key string, val interface{}) Field {
+	return zap.Any(key, val)
+}
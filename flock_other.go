@@ -0,0 +1,9 @@
+//go:build windows || plan9 || js || wasip1
+
+package logger
+
+// lockFile is a no-op where flock isn't available, rotation is only
+// coordinated within the process.
+func lockFile(string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Attr returns a Field logging value under key with the typed Field of T,
+// e.g. String for the strings and Int64 for the integers, including the
+// named types with such an underlying type, like `type UserID int64`, which
+// zap.Any would encode by reflection. Other types are encoded like Any.
+//
+// The generic constructor is Attr rather than Field, the type of the fields.
+func Attr[T any](key string, value T) Field {
+	switch v := any(value).(type) {
+	case string:
+		return zap.String(key, v)
+	case bool:
+		return zap.Bool(key, v)
+	case int:
+		return zap.Int(key, v)
+	case int64:
+		return zap.Int64(key, v)
+	case int32:
+		return zap.Int32(key, v)
+	case uint:
+		return zap.Uint(key, v)
+	case uint64:
+		return zap.Uint64(key, v)
+	case uint32:
+		return zap.Uint32(key, v)
+	case float64:
+		return zap.Float64(key, v)
+	case float32:
+		return zap.Float32(key, v)
+	case time.Duration:
+		return zap.Duration(key, v)
+	case time.Time:
+		return zap.Time(key, v)
+	case error:
+		return zap.NamedError(key, v)
+	case zapcore.ObjectMarshaler, zapcore.ArrayMarshaler, fmt.Stringer, []byte:
+		return zap.Any(key, v)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return zap.String(key, rv.String())
+	case reflect.Bool:
+		return zap.Bool(key, rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return zap.Int64(key, rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return zap.Uint64(key, rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return zap.Float64(key, rv.Float())
+	}
+	return zap.Any(key, value)
+}
+
+// Slice returns a Field logging values under key as an array, every element
+// encoded as Attr encodes it, so slices of any T avoid the reflection of
+// zap.Any.
+func Slice[T any](key string, values []T) Field {
+	return zap.Array(key, sliceMarshaler[T](values))
+}
+
+// sliceMarshaler encodes a slice with the typed appends of its elements.
+type sliceMarshaler[T any] []T
+
+func (s sliceMarshaler[T]) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, v := range s {
+		if err := appendAttr(enc, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendAttr appends v to enc as the Field of Attr would encode it.
+func appendAttr[T any](enc zapcore.ArrayEncoder, v T) error {
+	switch v := any(v).(type) {
+	case time.Time:
+		enc.AppendTime(v)
+		return nil
+	case error:
+		enc.AppendString(v.Error())
+		return nil
+	}
+
+	f := Attr("", v)
+	switch f.Type {
+	case zapcore.StringType:
+		enc.AppendString(f.String)
+	case zapcore.BoolType:
+		enc.AppendBool(f.Integer == 1)
+	case zapcore.Int64Type, zapcore.Int32Type:
+		enc.AppendInt64(f.Integer)
+	case zapcore.Uint64Type, zapcore.Uint32Type:
+		enc.AppendUint64(uint64(f.Integer))
+	case zapcore.Float64Type:
+		enc.AppendFloat64(math.Float64frombits(uint64(f.Integer)))
+	case zapcore.Float32Type:
+		enc.AppendFloat32(math.Float32frombits(uint32(f.Integer)))
+	case zapcore.DurationType:
+		enc.AppendDuration(time.Duration(f.Integer))
+	case zapcore.StringerType:
+		enc.AppendString(f.Interface.(fmt.Stringer).String())
+	case zapcore.ObjectMarshalerType:
+		return enc.AppendObject(f.Interface.(zapcore.ObjectMarshaler))
+	case zapcore.ArrayMarshalerType:
+		return enc.AppendArray(f.Interface.(zapcore.ArrayMarshaler))
+	default:
+		return enc.AppendReflected(v)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package ginmw
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nextmicro/logger"
+)
+
+// Option configures the middleware returned by Logger and Recovery.
+type Option func(o *options)
+
+type options struct {
+	// bodyLimit is the number of bytes of the bodies logged, 0 logs none.
+	bodyLimit int
+	redact    func(contentType string, body []byte) []byte
+	schema    logger.AccessLogSchema
+}
+
+func newOptions(opts []Option) options {
+	o := options{schema: logger.DefaultAccessLogSchema}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithSchema Setter function to name the logged fields after schema, e.g.
+// logger.ECSAccessLogSchema, default is logger.DefaultAccessLogSchema.
+func WithSchema(schema logger.AccessLogSchema) Option {
+	return func(o *options) {
+		o.schema = schema
+	}
+}
+
+// WithBodies Setter function to log the request and response bodies, as
+// request_body and response_body by default, up to limit bytes each, with
+// request_body_truncated or response_body_truncated set when they are
+// longer. The request body is logged as far as the handler read it. Bodies
+// of binary content types are logged as their type only.
+func WithBodies(limit int) Option {
+	return func(o *options) {
+		o.bodyLimit = limit
+	}
+}
+
+// WithBodyRedactor Setter function to pass the bodies logged by WithBodies
+// through redact first, given their content type, e.g. to mask passwords
+// or tokens. The returned body is logged.
+func WithBodyRedactor(redact func(contentType string, body []byte) []byte) Option {
+	return func(o *options) {
+		o.redact = redact
+	}
+}
+
+// bodyCapture keeps the first limit bytes written to it.
+type bodyCapture struct {
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func (b *bodyCapture) write(p []byte) {
+	if room := b.limit - len(b.buf); len(p) > room {
+		p = p[:room]
+		b.truncated = true
+	}
+	b.buf = append(b.buf, p...)
+}
+
+// captureReader captures the request body as the handler reads it.
+type captureReader struct {
+	io.ReadCloser
+	capture *bodyCapture
+}
+
+func (r captureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.capture.write(p[:n])
+	return n, err
+}
+
+// captureWriter captures the response body as the handler writes it.
+type captureWriter struct {
+	gin.ResponseWriter
+	capture *bodyCapture
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.capture.write(p[:n])
+	return n, err
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.capture.write([]byte(s[:n]))
+	return n, err
+}
+
+// captureBodies makes c capture its bodies, returned to log them once c is
+// served, or nil if o logs no bodies.
+func (o *options) captureBodies(c *gin.Context) (request, response *bodyCapture) {
+	if o.bodyLimit <= 0 {
+		return nil, nil
+	}
+	request, response = &bodyCapture{limit: o.bodyLimit}, &bodyCapture{limit: o.bodyLimit}
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		c.Request.Body = captureReader{ReadCloser: c.Request.Body, capture: request}
+	}
+	c.Writer = &captureWriter{ResponseWriter: c.Writer, capture: response}
+	return request, response
+}
+
+// bodyFields returns the fields logging body as key.
+func (o *options) bodyFields(key, contentType string, body *bodyCapture) []interface{} {
+	if body == nil || len(body.buf) == 0 {
+		return nil
+	}
+	if !textual(contentType) {
+		return []interface{}{key, fmt.Sprintf("<%s>", contentType)}
+	}
+	b := body.buf
+	if o.redact != nil {
+		b = o.redact(contentType, b)
+	}
+	fields := []interface{}{key, string(b)}
+	if body.truncated {
+		fields = append(fields, key+"_truncated", true)
+	}
+	return fields
+}
+
+// textual reports whether contentType is text, so its bodies are logged.
+// Bodies without a content type are assumed textual.
+func textual(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/x-www-form-urlencoded",
+		"application/javascript", "application/graphql", "application/x-ndjson":
+		return true
+	}
+	return false
+}
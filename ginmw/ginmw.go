@@ -0,0 +1,156 @@
+// Package ginmw logs the requests and the recovered panics of a gin engine
+// through the logger package, in place of gin.Logger and gin.Recovery:
+//
+//	r := gin.New()
+//	r.Use(ginmw.Logger(logger.Default()), ginmw.Recovery(logger.Default()))
+//
+// It is a module of its own, so the logger package doesn't depend on gin.
+package ginmw
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nextmicro/logger"
+)
+
+// Logger returns a middleware logging every request to l once it is served,
+// with its method, path, status, latency, client ip and the errors attached
+// to the context. Server errors are logged at ErrorLevel, client errors at
+// WarnLevel and the others at InfoLevel. The trace_id and span_id of the
+// request context are added. The bodies are logged with WithBodies, the
+// fields are named after logger.DefaultAccessLogSchema unless set with
+// WithSchema.
+func Logger(l logger.Logger, opts ...Option) gin.HandlerFunc {
+	o := newOptions(opts)
+	schema := o.schema
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		raw := c.Request.URL.RawQuery
+		if raw != "" && schema.Query == "" {
+			path += "?" + raw
+		}
+		requestBody, responseBody := o.captureBodies(c)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		keysAndValues := []interface{}{
+			schema.Method, c.Request.Method,
+			schema.Path, path,
+		}
+		if raw != "" && schema.Query != "" {
+			keysAndValues = append(keysAndValues, schema.Query, raw)
+		}
+		keysAndValues = append(keysAndValues,
+			schema.Status, status,
+			schema.Duration, schema.DurationValue(time.Since(start)),
+			schema.ClientIP, c.ClientIP(),
+			schema.UserAgent, c.Request.UserAgent(),
+			schema.ResponseSize, c.Writer.Size(),
+		)
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).Errors(); len(errs) > 0 {
+			keysAndValues = append(keysAndValues, schema.Errors, strings.Join(errs, "; "))
+		}
+		keysAndValues = append(keysAndValues, o.bodyFields(schema.RequestBody, c.ContentType(), requestBody)...)
+		keysAndValues = append(keysAndValues, o.bodyFields(schema.ResponseBody, c.Writer.Header().Get("Content-Type"), responseBody)...)
+
+		lg := l.WithContext(c.Request.Context())
+		switch {
+		case status >= http.StatusInternalServerError:
+			lg.Errorw("request served", keysAndValues...)
+		case status >= http.StatusBadRequest:
+			lg.Warnw("request served", keysAndValues...)
+		default:
+			lg.Infow("request served", keysAndValues...)
+		}
+	}
+}
+
+// Recovery returns a middleware recovering the panics of the handlers, which
+// are logged to l at ErrorLevel with the stack and the trace_id of the
+// request, and answered with 500. A panic caused by the client closing the
+// connection is logged without the stack and left unanswered. The fields are
+// named after the schema set with WithSchema.
+func Recovery(l logger.Logger, opts ...Option) gin.HandlerFunc {
+	schema := newOptions(opts).schema
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+
+			lg := l.WithContext(c.Request.Context())
+			keysAndValues := []interface{}{
+				schema.Error, fmt.Sprint(r),
+				schema.Method, c.Request.Method,
+				schema.Path, c.Request.URL.Path,
+			}
+			if brokenPipe(r) {
+				lg.Warnw("connection broken", keysAndValues...)
+				if err, ok := r.(error); ok {
+					_ = c.Error(err)
+				}
+				c.Abort()
+				return
+			}
+
+			lg.Errorw("panic recovered", append(keysAndValues, "stack", string(debug.Stack()))...)
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}()
+
+		c.Next()
+	}
+}
+
+// brokenPipe reports whether r is the error of writing to a connection the
+// client closed, which needs no stack.
+func brokenPipe(r interface{}) bool {
+	err, ok := r.(error)
+	if !ok {
+		return false
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	var sysErr *os.SyscallError
+	if errors.As(opErr, &sysErr) {
+		return errors.Is(sysErr.Err, syscall.EPIPE) || errors.Is(sysErr.Err, syscall.ECONNRESET)
+	}
+	return false
+}
+
+// SetDefaultWriters redirects gin.DefaultWriter and gin.DefaultErrorWriter to
+// l, at InfoLevel and ErrorLevel, so the debug output of gin, such as the
+// routes, goes through the logger. It must be called before the engine is
+// created.
+func SetDefaultWriters(l logger.Logger) {
+	gin.DefaultWriter = lineWriter{log: l.Info}
+	gin.DefaultErrorWriter = lineWriter{log: l.Error}
+}
+
+// lineWriter logs every write as one entry, without the trailing newline.
+type lineWriter struct {
+	log func(args ...interface{})
+}
+
+var _ io.Writer = lineWriter{}
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	if msg := strings.TrimRight(string(p), "\n"); msg != "" {
+		w.log(msg)
+	}
+	return len(p), nil
+}
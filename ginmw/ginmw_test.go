@@ -0,0 +1,150 @@
+package ginmw
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nextmicro/logger"
+	"github.com/nextmicro/logger/logtest"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newEngine(buf *bytes.Buffer) *gin.Engine {
+	l := logger.New(logger.WithWriter(buf))
+	r := gin.New()
+	r.Use(Logger(l), Recovery(l))
+	r.GET("/ok", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/missing", func(c *gin.Context) {
+		_ = c.Error(assert.AnError)
+		c.Status(http.StatusNotFound)
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	return r
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	r := newEngine(&buf)
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/ok?a=1", nil).WithContext(ctx)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	logged := logtest.Entries(t, buf.Bytes())
+	assert.Len(t, logged, 2)
+	assert.Equal(t, "info", logged[0]["level"])
+	assert.Equal(t, "/ok?a=1", logged[0]["path"])
+	assert.Equal(t, float64(http.StatusOK), logged[0]["status"])
+	assert.Equal(t, trace.TraceID{1}.String(), logged[0]["trace_id"])
+	assert.Equal(t, "warn", logged[1]["level"])
+	assert.Equal(t, assert.AnError.Error(), logged[1]["errors"])
+}
+
+func TestRecovery(t *testing.T) {
+	var buf bytes.Buffer
+	r := newEngine(&buf)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	logged := logtest.Entries(t, buf.Bytes())
+	assert.Len(t, logged, 2)
+	assert.Equal(t, "panic recovered", logged[0]["msg"])
+	assert.Equal(t, "boom", logged[0]["error"])
+	assert.Contains(t, logged[0]["stack"], "ginmw_test.go")
+	assert.Equal(t, "error", logged[1]["level"])
+}
+
+func TestSetDefaultWriters(t *testing.T) {
+	w, ew := gin.DefaultWriter, gin.DefaultErrorWriter
+	defer func() {
+		gin.DefaultWriter, gin.DefaultErrorWriter = w, ew
+	}()
+
+	var buf bytes.Buffer
+	SetDefaultWriters(logger.New(logger.WithWriter(&buf)))
+	_, err := gin.DefaultWriter.Write([]byte("[GIN-debug] GET /ok\n"))
+	assert.Nil(t, err)
+	_, err = gin.DefaultErrorWriter.Write([]byte("[GIN-debug] [WARNING] running in debug mode\n"))
+	assert.Nil(t, err)
+
+	logged := logtest.Entries(t, buf.Bytes())
+	assert.Len(t, logged, 2)
+	assert.Equal(t, "info", logged[0]["level"])
+	assert.Equal(t, "[GIN-debug] GET /ok", logged[0]["msg"])
+	assert.Equal(t, "error", logged[1]["level"])
+}
+
+func TestLoggerBodies(t *testing.T) {
+	var buf bytes.Buffer
+	r := gin.New()
+	r.Use(Logger(logger.New(logger.WithWriter(&buf)), WithBodies(16), WithBodyRedactor(func(contentType string, body []byte) []byte {
+		return bytes.ReplaceAll(body, []byte("hunter2"), []byte("***"))
+	})))
+	r.POST("/login", func(c *gin.Context) {
+		_, _ = io.ReadAll(c.Request.Body)
+		c.JSON(http.StatusOK, gin.H{"token": "0123456789abcdef0123"})
+	})
+	r.GET("/image", func(c *gin.Context) {
+		c.Data(http.StatusOK, "image/png", []byte{0x89, 'P', 'N', 'G'})
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"pw":"hunter2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/image", nil))
+
+	logged := logtest.Entries(t, buf.Bytes())
+	assert.Len(t, logged, 2)
+	assert.Equal(t, `{"pw":"***"}`, logged[0]["request_body"])
+	assert.Nil(t, logged[0]["request_body_truncated"])
+	assert.Equal(t, `{"token":"012345`, logged[0]["response_body"])
+	assert.Equal(t, true, logged[0]["response_body_truncated"])
+	assert.Nil(t, logged[1]["request_body"])
+	assert.Equal(t, "<image/png>", logged[1]["response_body"])
+}
+
+func TestLoggerECSSchema(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf))
+	r := gin.New()
+	r.Use(Logger(l, WithSchema(logger.ECSAccessLogSchema)), Recovery(l, WithSchema(logger.ECSAccessLogSchema)))
+	r.GET("/ok", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok?a=1", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	logged := logtest.Entries(t, buf.Bytes())
+	assert.Len(t, logged, 3)
+	assert.Equal(t, http.MethodGet, logged[0]["http.request.method"])
+	assert.Equal(t, "/ok", logged[0]["url.path"])
+	assert.Equal(t, "a=1", logged[0]["url.query"])
+	assert.Equal(t, float64(http.StatusOK), logged[0]["http.response.status_code"])
+	assert.IsType(t, float64(0), logged[0]["event.duration"])
+	assert.Equal(t, "boom", logged[1]["error.message"])
+	assert.Equal(t, "/panic", logged[1]["url.path"])
+}
@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Healthy reports whether the default logger, the dedicated channels and the
+// named loggers can write their entries: the workers of their rolling files
+// are alive, their buffers drain, and the last write and sync succeeded. It
+// suits readiness probes.
+func Healthy() error {
+	return errors.Join(healthOf(Default()), statChannel.healthy(), slowChannel.healthy(),
+		severeChannel.healthy(), registry.healthy())
+}
+
+// Healthy reports whether the rolling files of l can be written, see the
+// package Healthy. It is nil for a logger without files.
+func (l *Logging) Healthy() error {
+	var errs []error
+	for _, rl := range l._rotateLoggers {
+		errs = append(errs, rl.Healthy())
+	}
+	return errors.Join(errs...)
+}
+
+// Healthy reports whether l can write its file: it isn't closed, its worker
+// runs and drains the pages, and the last write and sync succeeded.
+func (l *RotateLogger) Healthy() error {
+	filename, _ := l.names()
+	if atomic.LoadInt32(&l.closed) == 1 {
+		return fmt.Errorf("%s: %w", filename, ErrClosedRollingFile)
+	}
+
+	if time.Since(time.Unix(0, l.beat.Load())) > workerStallTimeout {
+		if len(l.pages) == cap(l.pages) {
+			return fmt.Errorf("%s: %w", filename, ErrBufferFull)
+		}
+		return fmt.Errorf("%s: %w", filename, ErrWorkerStalled)
+	}
+
+	var errs []error
+	if err := l.writeErr.Load(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: last write failed: %w", filename, *err))
+	}
+	if err := l.syncErr.Load(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: last sync failed: %w", filename, *err))
+	}
+	return errors.Join(errs...)
+}
+
+// healthOf returns the health of l, nil if it can't tell.
+func healthOf(l Logger) error {
+	if h, ok := l.(interface{ Healthy() error }); ok {
+		return h.Healthy()
+	}
+	return nil
+}
+
+// storeErr stores err in p, clearing it on nil.
+func storeErr(p *atomic.Pointer[error], err error) {
+	if err == nil {
+		if p.Load() != nil {
+			p.Store(nil)
+		}
+		return
+	}
+	p.Store(&err)
+}
@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// durationMillisSuffix is appended to the key of a duration for its
+	// value in milliseconds, logged with WithDurationMillis.
+	durationMillisSuffix = "_ms"
+	// humanBytesSuffix is appended to the key of a byte count for its human
+	// value, logged with WithHumanBytes.
+	humanBytesSuffix = "_human"
+)
+
+// humanEncoder adds the milliseconds of the durations and the human value of
+// the byte counts next to the fields encoded by Encoder.
+type humanEncoder struct {
+	zapcore.Encoder
+	durationMillis bool
+	// byteKeys are the keys of the integer fields holding byte counts.
+	byteKeys map[string]struct{}
+}
+
+func newHumanEncoder(enc zapcore.Encoder, durationMillis bool, byteKeys []string) zapcore.Encoder {
+	if !durationMillis && len(byteKeys) == 0 {
+		return enc
+	}
+	keys := make(map[string]struct{}, len(byteKeys))
+	for _, k := range byteKeys {
+		keys[k] = struct{}{}
+	}
+	return &humanEncoder{Encoder: enc, durationMillis: durationMillis, byteKeys: keys}
+}
+
+func (e *humanEncoder) Clone() zapcore.Encoder {
+	return &humanEncoder{Encoder: e.Encoder.Clone(), durationMillis: e.durationMillis, byteKeys: e.byteKeys}
+}
+
+// EncodeEntry adds the fields of the durations and byte counts of fields,
+// which the wrapped encoder encodes by itself.
+func (e *humanEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	var expanded []zapcore.Field
+	for i, f := range fields {
+		extra, ok := e.extra(f)
+		if !ok {
+			if expanded != nil {
+				expanded = append(expanded, f)
+			}
+			continue
+		}
+		if expanded == nil {
+			expanded = append(make([]zapcore.Field, 0, len(fields)+1), fields[:i]...)
+		}
+		expanded = append(expanded, f, extra)
+	}
+	if expanded != nil {
+		fields = expanded
+	}
+	return e.Encoder.EncodeEntry(ent, fields)
+}
+
+// extra returns the field logged next to f, if any.
+func (e *humanEncoder) extra(f zapcore.Field) (zapcore.Field, bool) {
+	switch f.Type {
+	case zapcore.DurationType:
+		if e.durationMillis {
+			return zap.Float64(f.Key+durationMillisSuffix, millis(time.Duration(f.Integer))), true
+		}
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Uint64Type, zapcore.Uint32Type:
+		// Integer holds the bits of the uint64 values.
+		if _, ok := e.byteKeys[f.Key]; ok && (f.Integer >= 0 || f.Type == zapcore.Uint64Type) {
+			return zap.String(f.Key+humanBytesSuffix, humanBytes(uint64(f.Integer))), true
+		}
+	}
+	return zapcore.Field{}, false
+}
+
+func (e *humanEncoder) AddDuration(key string, d time.Duration) {
+	e.Encoder.AddDuration(key, d)
+	if e.durationMillis {
+		e.Encoder.AddFloat64(key+durationMillisSuffix, millis(d))
+	}
+}
+
+func (e *humanEncoder) AddInt64(key string, v int64) {
+	e.Encoder.AddInt64(key, v)
+	e.addBytes(key, v)
+}
+
+func (e *humanEncoder) AddInt32(key string, v int32) {
+	e.Encoder.AddInt32(key, v)
+	e.addBytes(key, int64(v))
+}
+
+func (e *humanEncoder) AddUint64(key string, v uint64) {
+	e.Encoder.AddUint64(key, v)
+	if _, ok := e.byteKeys[key]; ok {
+		e.Encoder.AddString(key+humanBytesSuffix, humanBytes(v))
+	}
+}
+
+func (e *humanEncoder) AddUint32(key string, v uint32) {
+	e.Encoder.AddUint32(key, v)
+	e.addBytes(key, int64(v))
+}
+
+func (e *humanEncoder) addBytes(key string, v int64) {
+	if _, ok := e.byteKeys[key]; ok && v >= 0 {
+		e.Encoder.AddString(key+humanBytesSuffix, humanBytes(uint64(v)))
+	}
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// humanBytes returns n in the largest binary unit it fills, with a decimal,
+// e.g. `1.2MB`.
+func humanBytes(n uint64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return strconv.FormatUint(n, 10) + "B"
+	}
+	v, i := float64(n)/1024, 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64) + units[i:i+1] + "B"
+}
@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// defaultInternalRate is the number of internal entries per message logged
+// every second, the next ones are dropped.
+const defaultInternalRate = 10
+
+// internalLog logs the failures of the logger itself, such as a rotation or a
+// webhook post failing. It writes JSON lines to a plain writer rather than to
+// a Logging, whose outputs may be what failed.
+var internalLog atomic.Pointer[zap.SugaredLogger]
+
+func init() {
+	internalLog.Store(newInternalLogger(os.Stderr, defaultInternalRate))
+}
+
+// SetInternalOutput sets the writer of the logger's own errors, default is
+// stderr. They are JSON lines under the `logger` name, at most rate per
+// message every second, the others are dropped and counted as
+// `internal_drops` by WithStats. A nil w turns them off, a rate <= 0 keeps
+// the default of 10.
+func SetInternalOutput(w io.Writer, rate int) {
+	if rate <= 0 {
+		rate = defaultInternalRate
+	}
+	internalLog.Store(newInternalLogger(w, rate))
+}
+
+func newInternalLogger(w io.Writer, rate int) *zap.SugaredLogger {
+	if w == nil {
+		return zap.NewNop().Sugar()
+	}
+
+	cfg := zap.NewProductionEncoderConfig()
+	cfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(cfg), zapcore.Lock(zapcore.AddSync(w)), zapcore.DebugLevel)
+	core = zapcore.NewSamplerWithOptions(core, time.Second, rate, 0,
+		zapcore.SamplerHook(func(_ zapcore.Entry, dec zapcore.SamplingDecision) {
+			if dec&zapcore.LogDropped != 0 {
+				addStat(statInternalDrops, 1)
+			}
+		}))
+	return zap.New(core).Named("logger").Sugar()
+}
+
+// internalLogger logs the failures of a component of the logger, to the
+// writer of WithInternalWriter, or to the output of SetInternalOutput when
+// lg is nil, as for the zero internalLogger.
+type internalLogger struct {
+	lg *zap.SugaredLogger
+}
+
+func (i internalLogger) get() *zap.SugaredLogger {
+	if i.lg != nil {
+		return i.lg
+	}
+	return internalLog.Load()
+}
+
+func (i internalLogger) error(msg string, keysAndValues ...interface{}) {
+	i.get().Errorw(msg, keysAndValues...)
+}
+
+func (i internalLogger) warn(msg string, keysAndValues ...interface{}) {
+	i.get().Warnw(msg, keysAndValues...)
+}
+
+func (i internalLogger) info(msg string, keysAndValues ...interface{}) {
+	i.get().Infow(msg, keysAndValues...)
+}
+
+// internalError logs a failure of the logger itself.
+func internalError(msg string, keysAndValues ...interface{}) {
+	internalLog.Load().Errorw(msg, keysAndValues...)
+}
+
+// internalInfo logs an event of the logger itself.
+func internalInfo(msg string, keysAndValues ...interface{}) {
+	internalLog.Load().Infow(msg, keysAndValues...)
+}
+
+// internalWarn logs a degradation of the logger itself, such as dropped
+// entries.
+func internalWarn(msg string, keysAndValues ...interface{}) {
+	internalLog.Load().Warnw(msg, keysAndValues...)
+}
@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetInternalOutput(t *testing.T) {
+	var buf bytes.Buffer
+	SetInternalOutput(&buf, 2)
+	defer SetInternalOutput(os.Stderr, 0)
+
+	for i := 0; i < 5; i++ {
+		internalError("failed to write log file", "file", "app.log", "error", errors.New("disk full"))
+	}
+	internalInfo("compressed log file", "file", "app.log")
+
+	var lines []map[string]interface{}
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var m map[string]interface{}
+		assert.NoError(t, json.Unmarshal(sc.Bytes(), &m))
+		lines = append(lines, m)
+	}
+	// the repeated message is limited to 2 per second, the other passes.
+	if assert.Len(t, lines, 3) {
+		assert.Equal(t, "failed to write log file", lines[0]["msg"])
+		assert.Equal(t, "error", lines[0]["level"])
+		assert.Equal(t, "logger", lines[0]["logger"])
+		assert.Equal(t, "disk full", lines[0]["error"])
+		assert.Equal(t, "compressed log file", lines[2]["msg"])
+	}
+
+	buf.Reset()
+	SetInternalOutput(nil, 0)
+	internalError("off")
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestWithInternalWriter(t *testing.T) {
+	var global, buf bytes.Buffer
+	SetInternalOutput(&global, 0)
+	defer SetInternalOutput(os.Stderr, 0)
+
+	l := New(WithWriter(io.Discard), WithSQLSink(nil, InfoLevel), WithInternalWriter(&buf))
+	defer l.Close()
+	assert.Contains(t, buf.String(), `"msg":"invalid options"`)
+	assert.Contains(t, buf.String(), "sql sink requires a database")
+	assert.Equal(t, 0, global.Len())
+}
+
+// lockedBuffer is a bytes.Buffer written by the goroutines of the loggers
+// while the tests read it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// layoutFilename returns the file of l for t, its name under the directory
+// formatted from the path layout.
+func (l *RotateLogger) layoutFilename(t time.Time) string {
+	return filepath.Join(l.layoutBase, t.Format(l.pathLayout), l.layoutName)
+}
+
+// maybeSwitchLayout moves l to the directory of the current time when the path
+// layout formats to another one. The previous file stays in its directory,
+// which acts as its backup, and is archived like a rotated file.
+func (l *RotateLogger) maybeSwitchLayout() {
+	if l.pathLayout == "" {
+		return
+	}
+	filename := l.layoutFilename(time.Now())
+	if filename == l.filename {
+		return
+	}
+
+	if err := l.close(); err != nil {
+		l.internal.error("failed to close log file", "file", l.filename, "error", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), l.dirPerm); err != nil {
+		l.internal.error("failed to create log directory", "dir", filepath.Dir(filename), "error", err)
+		storeErr(&l.writeErr, err)
+		return
+	}
+	fp, err := l.openFile(filename)
+	if err != nil {
+		l.internal.error("failed to open log file", "file", filename, "error", err)
+		storeErr(&l.writeErr, err)
+		return
+	}
+
+	l.nameMu.Lock()
+	previous := l.filename
+	l.filename = filename
+	l.rule = l.newRule(filename)
+	l.nameMu.Unlock()
+
+	l.backup = l.rule.BackupFileName()
+	l.fp = fp
+	l.currentSize = 0
+	l.rotatedAt.Store(time.Now().UnixNano())
+	l.finalizeDigest(previous)
+	l.postRotate(previous)
+}
+
+// deleteOutdatedLayoutFiles removes the files of l in the layout directories
+// older than the retention, then the directories left empty.
+func (l *RotateLogger) deleteOutdatedLayoutFiles() {
+	if l.pathLayout == "" || l.layoutKeep <= 0 {
+		return
+	}
+
+	// a directory holds the entries up to the start of the next one.
+	filename, _ := l.names()
+	boundary := time.Now().Add(-l.layoutKeep - layoutPeriod(l.pathLayout))
+	var dirs []string
+	err := filepath.WalkDir(l.layoutBase, func(dir string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || dir == l.layoutBase {
+			return nil
+		}
+		rel, err := filepath.Rel(l.layoutBase, dir)
+		if err != nil {
+			return nil
+		}
+		t, err := time.ParseInLocation(l.pathLayout, filepath.ToSlash(rel), time.Local)
+		if err == nil && t.Before(boundary) && dir != filepath.Dir(filename) {
+			dirs = append(dirs, dir)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		l.internal.error("failed to list outdated log directories", "error", err)
+		return
+	}
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		// the file, its backups, archives and sidecars, e.g. app.log-2006-01-02.gz
+		// or app-2006-01-02T15:04:05Z.log.
+		sizePrefix := strings.TrimSuffix(l.layoutName, filepath.Ext(l.layoutName)) + l.delimiter
+		for _, entry := range entries {
+			if name := entry.Name(); name == l.layoutName || strings.HasPrefix(name, l.layoutName+".") ||
+				strings.HasPrefix(name, l.layoutName+l.delimiter) || strings.HasPrefix(name, sizePrefix) {
+				if err = os.Remove(filepath.Join(dir, name)); err != nil {
+					l.internal.error("failed to remove outdated file", "file", filepath.Join(dir, name), "error", err)
+				}
+			}
+		}
+		// remove the directories left empty, up to the base.
+		for d := dir; d != l.layoutBase && os.Remove(d) == nil; d = filepath.Dir(d) {
+		}
+	}
+}
+
+// layoutPeriod returns the time span of a directory of layout, the smallest
+// step which changes the formatted time.
+func layoutPeriod(layout string) time.Duration {
+	ref := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	for _, d := range []time.Duration{time.Second, time.Minute, time.Hour, hoursPerDay * time.Hour, 31 * hoursPerDay * time.Hour} {
+		if ref.Format(layout) != ref.Add(d).Format(layout) {
+			return d
+		}
+	}
+	return 366 * hoursPerDay * time.Hour
+}
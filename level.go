@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"fmt"
 	"strings"
 
 	"go.uber.org/zap"
@@ -39,21 +40,77 @@ func (l Level) String() string {
 	return ""
 }
 
-// ParseLevel parses a level string into a logger Level value.
+// unsetLevelText is the text of the zero Level.
+const unsetLevelText = "unset"
+
+// valid reports whether l is one of the levels from DebugLevel to FatalLevel.
+func (l Level) valid() bool {
+	return l >= DebugLevel && l <= FatalLevel
+}
+
+// MarshalText marshals the level to its lowercase name, e.g. "warn", as in
+// the entries. The zero Level marshals to "unset".
+func (l Level) MarshalText() ([]byte, error) {
+	if l == 0 {
+		return []byte(unsetLevelText), nil
+	}
+	if !l.valid() {
+		return nil, fmt.Errorf("unrecognized level: %d", l)
+	}
+	return []byte(strings.ToLower(l.String())), nil
+}
+
+// UnmarshalText parses a case insensitive level name, so levels can be read
+// from JSON, YAML and environment variables. "unset" parses as the zero
+// Level, so that every marshaled Level reads back.
+func (l *Level) UnmarshalText(text []byte) error {
+	if strings.EqualFold(string(text), unsetLevelText) {
+		*l = 0
+		return nil
+	}
+	lv, err := parseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = lv
+	return nil
+}
+
+// Set implements flag.Value and pflag.Value.
+func (l *Level) Set(s string) error {
+	return l.UnmarshalText([]byte(s))
+}
+
+// Type implements pflag.Value.
+func (l *Level) Type() string {
+	return "level"
+}
+
+// ParseLevel parses a level string into a logger Level value, unknown levels
+// parse as InfoLevel.
 func ParseLevel(s string) Level {
+	lv, err := parseLevel(s)
+	if err != nil {
+		return InfoLevel
+	}
+	return lv
+}
+
+// parseLevel parses a level string, returning an error for unknown levels.
+func parseLevel(s string) (Level, error) {
 	switch strings.ToUpper(s) {
 	case "DEBUG":
-		return DebugLevel
+		return DebugLevel, nil
 	case "INFO":
-		return InfoLevel
+		return InfoLevel, nil
 	case "WARN":
-		return WarnLevel
+		return WarnLevel, nil
 	case "ERROR":
-		return ErrorLevel
+		return ErrorLevel, nil
 	case "FATAL":
-		return FatalLevel
+		return FatalLevel, nil
 	}
-	return InfoLevel
+	return InfoLevel, fmt.Errorf("unrecognized level: %q", s)
 }
 
 func (l Level) unmarshalZapLevel() zapcore.Level {
@@ -73,6 +130,22 @@ func (l Level) unmarshalZapLevel() zapcore.Level {
 	}
 }
 
+// marshalZapLevel converts a zap level to a logger Level.
+func marshalZapLevel(lvl zapcore.Level) Level {
+	switch {
+	case lvl <= zapcore.DebugLevel:
+		return DebugLevel
+	case lvl == zapcore.InfoLevel:
+		return InfoLevel
+	case lvl == zapcore.WarnLevel:
+		return WarnLevel
+	case lvl == zapcore.ErrorLevel:
+		return ErrorLevel
+	default:
+		return FatalLevel
+	}
+}
+
 // Enabled returns true if the given level is at or above this level.
 func (l Level) Enabled(lvl Level) bool {
 	return lvl >= l
@@ -90,3 +163,37 @@ type LevelEnablerFunc func(zapcore.Level) bool
 func (f LevelEnablerFunc) Enabled(lvl zapcore.Level) bool {
 	return f(lvl)
 }
+
+// LevelFormat encodes the level of the entries, see WithLevelFormat.
+type LevelFormat func(zapcore.Level, zapcore.PrimitiveArrayEncoder)
+
+var (
+	// LevelLowercase encodes the levels in lower case, e.g. `warn`, the default.
+	LevelLowercase = LevelFormat(zapcore.LowercaseLevelEncoder)
+	// LevelCapital encodes the levels in upper case, e.g. `WARN`.
+	LevelCapital = LevelFormat(zapcore.CapitalLevelEncoder)
+	// LevelShort encodes the levels in three letters, e.g. `WRN`.
+	LevelShort = LevelCustom(map[Level]string{
+		DebugLevel: "DBG",
+		InfoLevel:  "INF",
+		WarnLevel:  "WRN",
+		ErrorLevel: "ERR",
+		FatalLevel: "FTL",
+	})
+)
+
+// LevelCustom encodes the levels with the given labels, e.g. localized
+// ones. The levels without a label are encoded in lower case.
+func LevelCustom(labels map[Level]string) LevelFormat {
+	copied := make(map[Level]string, len(labels))
+	for lv, label := range labels {
+		copied[lv] = label
+	}
+	return func(lvl zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+		if label, ok := copied[marshalZapLevel(lvl)]; ok {
+			enc.AppendString(label)
+			return
+		}
+		zapcore.LowercaseLevelEncoder(lvl, enc)
+	}
+}
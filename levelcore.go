@@ -0,0 +1,152 @@
+package logger
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levelFilterCore gates entries on its own LevelEnabler before handing them to
+// the wrapped core. The wrapped cores accept every level, so loggers derived
+// from the same cores can log at different levels.
+type levelFilterCore struct {
+	zapcore.Core
+	enabler zapcore.LevelEnabler
+}
+
+func newLevelFilterCore(core zapcore.Core, enabler zapcore.LevelEnabler) zapcore.Core {
+	if fc, ok := core.(*levelFilterCore); ok {
+		core = fc.Core
+	}
+	return &levelFilterCore{Core: core, enabler: enabler}
+}
+
+func (c *levelFilterCore) Enabled(lvl zapcore.Level) bool {
+	return c.enabler.Enabled(lvl)
+}
+
+// Level implements zapcore.LevelOf.
+func (c *levelFilterCore) Level() zapcore.Level {
+	return zapcore.LevelOf(c.enabler)
+}
+
+func (c *levelFilterCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelFilterCore{Core: c.Core.With(fields), enabler: c.enabler}
+}
+
+func (c *levelFilterCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.enabler.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func (c *levelFilterCore) ungated() zapcore.Core {
+	return ungate(c.Core)
+}
+
+// withLevelEnabler replaces the level gate of a logger built by Logging.
+func withLevelEnabler(enabler zapcore.LevelEnabler) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return newLevelFilterCore(core, enabler)
+	})
+}
+
+// stricterLevel enables the levels enabled by both parent and own, so a
+// logger can be quieter than its parent and still follows its changes.
+type stricterLevel struct {
+	parent, own zapcore.LevelEnabler
+}
+
+func (e stricterLevel) Enabled(lvl zapcore.Level) bool {
+	return e.parent.Enabled(lvl) && e.own.Enabled(lvl)
+}
+
+// Level implements zapcore.LevelOf.
+func (e stricterLevel) Level() zapcore.Level {
+	if parent, own := zapcore.LevelOf(e.parent), zapcore.LevelOf(e.own); parent > own {
+		return parent
+	}
+	return zapcore.LevelOf(e.own)
+}
+
+// levelOutput is a write target of a levelRouterCore and the levels it takes.
+type levelOutput struct {
+	enabler zapcore.LevelEnabler
+	out     zapcore.WriteSyncer
+}
+
+// levelRouterCore writes every entry to the outputs enabled for its level.
+// All outputs share one encoder, so fields are encoded and cloned once rather
+// than once per output.
+type levelRouterCore struct {
+	zapcore.LevelEnabler
+	enc  zapcore.Encoder
+	outs []levelOutput
+}
+
+func newLevelRouterCore(enc zapcore.Encoder, enabler zapcore.LevelEnabler, outs ...levelOutput) zapcore.Core {
+	return &levelRouterCore{LevelEnabler: enabler, enc: enc, outs: outs}
+}
+
+// Level implements zapcore.LevelOf.
+func (c *levelRouterCore) Level() zapcore.Level {
+	return zapcore.LevelOf(c.LevelEnabler)
+}
+
+func (c *levelRouterCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(clone.enc)
+	}
+	return &clone
+}
+
+func (c *levelRouterCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	for _, o := range c.outs {
+		if o.enabler.Enabled(ent.Level) {
+			return ce.AddCore(ent, c)
+		}
+	}
+	return ce
+}
+
+func (c *levelRouterCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	var errs []error
+	for _, o := range c.outs {
+		if !o.enabler.Enabled(ent.Level) {
+			continue
+		}
+		if _, err = o.out.Write(buf.Bytes()); err != nil {
+			errs = append(errs, err)
+		}
+		if ent.Level > zapcore.ErrorLevel {
+			// Since we may be crashing the program, sync the output.
+			if err = o.out.Sync(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *levelRouterCore) Sync() error {
+	var errs []error
+	for _, o := range c.outs {
+		if err := o.out.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
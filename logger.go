@@ -8,18 +8,37 @@ import (
 var (
 	// ErrLogPathNotSet is an error that indicates the log path is not set.
 	ErrLogPathNotSet = errors.New("log path must be set")
+	// ErrInvalidOptions is an error that indicates incompatible options.
+	ErrInvalidOptions = errors.New("invalid logger options")
 )
 
-// Logger is the interface for Logger types
+// Logger is the interface for Logger types, the composite of CoreLogger and
+// the capability interfaces with the helpers built on them. Third parties
+// implementing CoreLogger and the capabilities they support get a Logger
+// from FromCore.
 type Logger interface {
-	// SetLevel set logger level
-	SetLevel(lv Level)
-	// WithContext with context
-	WithContext(ctx context.Context) Logger
+	CoreLogger
+	Leveler
+	ContextAware
+	CallerSkipper
+	Syncer
+
 	// WithFields set fields to always be logged
 	WithFields(fields map[string]any) Logger
-	// WithCallDepth  with logger call depth.
-	WithCallDepth(callDepth int) Logger
+	// With returns a logger adding args to every entry, alternating keys and
+	// values or zap Fields as the keysAndValues of Infow, without building a
+	// map.
+	With(args ...any) Logger
+	// Namespace returns a logger nesting the fields added after it, with
+	// With, WithFields or at the call site, under the key name, like slog's
+	// WithGroup. Namespaces chain.
+	Namespace(name string) Logger
+	// WithLevel returns a logger whose own level can be stricter than the
+	// level of the logger, never more verbose.
+	WithLevel(lv Level) Logger
+	// V returns a logger for verbose entries of V-level n, discarding them
+	// when n exceeds the configured verbosity.
+	V(n int) Logger
 	// Debug uses fmt.Sprint to construct and log a message.
 	Debug(args ...interface{})
 	// Info uses fmt.Sprint to construct and log a message.
@@ -58,6 +77,61 @@ type Logger interface {
 	// Fatalw logs a message with some additional context, then calls os.Exit. The
 	// variadic key-value pairs are treated as they are in With.
 	Fatalw(msg string, keysAndValues ...interface{})
-	// Sync logger sync
+	// Log uses fmt.Sprint to construct and log a message at level.
+	Log(level Level, args ...interface{})
+	// Logf uses fmt.Sprintf to log a templated message at level.
+	Logf(level Level, template string, args ...interface{})
+}
+
+// CoreLogger is the minimal logger: it logs a message at a level with
+// key-value pairs, alternating keys and values or zap Fields.
+type CoreLogger interface {
+	// Logw logs a message at level with some additional context. The variadic
+	// key-value pairs are treated as they are in With.
+	Logw(level Level, msg string, keysAndValues ...interface{})
+}
+
+// Leveler is the capability of changing the level of a logger at runtime.
+type Leveler interface {
+	// SetLevel sets the level of the logger only, not of the logger it was
+	// derived from.
+	SetLevel(lv Level)
+	// SetGlobalLevel sets the level shared by the loggers derived from the
+	// same root logger.
+	SetGlobalLevel(lv Level)
+}
+
+// ContextAware is the capability of adding the trace context, and the
+// fields, of a context to the entries of a logger.
+type ContextAware interface {
+	// WithContext returns a logger adding the trace context of ctx to its
+	// entries.
+	WithContext(ctx context.Context) Logger
+	// WithTrace returns a logger carrying traceID and spanID as the trace
+	// context of its entries, for code without a context.
+	WithTrace(traceID, spanID string) Logger
+	// WithoutTrace returns a logger whose WithContext skips the trace context
+	// lookup.
+	WithoutTrace() Logger
+}
+
+// CallerSkipper is the capability of skipping the frames of the helpers
+// wrapping a logger when reporting the caller.
+type CallerSkipper interface {
+	// WithCallDepth  with logger call depth.
+	WithCallDepth(callDepth int) Logger
+	// WithCallDepthDelta returns a logger skipping delta more frames for the
+	// caller, fewer if negative.
+	WithCallDepthDelta(delta int) Logger
+	// WithCallDepthSet returns a logger skipping depth frames for the caller,
+	// whatever the depth of the logger.
+	WithCallDepthSet(depth int) Logger
+	// CallDepth returns the number of frames skipped for the caller.
+	CallDepth() int
+}
+
+// Syncer is the capability of flushing the buffered entries of a logger.
+type Syncer interface {
+	// Sync flushes the buffered entries.
 	Sync() error
 }
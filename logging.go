@@ -1,27 +1,172 @@
 package logger
 
 import (
-	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path"
-
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 var _ Logger = (*Logging)(nil)
 
 // DefaultLogger is default logger.
+//
+// Deprecated: use Default and SetDefault, assigning DefaultLogger while
+// entries are logged is a data race. Assignments are still honored, but it
+// is write-only: SetDefault doesn't update it, so reading it may return a
+// replaced logger.
 var DefaultLogger Logger = New()
 
+// defaultLogger is the default logger with the logger of the package helpers.
+type defaultLogger struct {
+	logger Logger
+	// helper is logger with the caller skip of the package helpers, derived
+	// once instead of on every entry.
+	helper Logger
+	// assigned is DefaultLogger when it was stored, a different DefaultLogger
+	// was assigned since.
+	assigned Logger
+}
+
+var defaults atomic.Pointer[defaultLogger]
+
+func newDefaultLogger(l, assigned Logger) *defaultLogger {
+	return &defaultLogger{logger: l, helper: l.WithCallDepth(callerSkipOffset), assigned: assigned}
+}
+
+// Default returns the default logger, used by the package helpers.
+func Default() Logger {
+	return loadDefault().logger
+}
+
+// SetDefault replaces the default logger, it is safe to call while entries
+// are logged. The deprecated DefaultLogger isn't updated.
+func SetDefault(l Logger) {
+	defaults.Store(newDefaultLogger(l, DefaultLogger))
+}
+
+// SwapDefault replaces the default logger with l and returns the one it
+// replaced, in one atomic step, so that concurrent swaps each get back the
+// logger they replaced.
+func SwapDefault(l Logger) Logger {
+	next := newDefaultLogger(l, DefaultLogger)
+	for {
+		d := loadDefault()
+		if defaults.CompareAndSwap(d, next) {
+			return d.logger
+		}
+	}
+}
+
+// loadDefault returns the default logger, or DefaultLogger if it was
+// assigned since.
+func loadDefault() *defaultLogger {
+	d := defaults.Load()
+	if l := DefaultLogger; d == nil || !sameLogger(d.assigned, l) {
+		d = newDefaultLogger(l, l)
+		defaults.Store(d)
+	}
+	return d
+}
+
+// skipped returns the default logger with the caller skip of the package
+// helpers.
+func skipped() Logger {
+	return loadDefault().helper
+}
+
+// sameLogger reports whether a and b are the same logger, without panicking
+// on loggers of uncomparable types. It runs on every entry of the package
+// helpers, so the loggers built by New are compared without reflection.
+func sameLogger(a, b Logger) bool {
+	if p, ok := a.(*Logging); ok {
+		q, ok := b.(*Logging)
+		return ok && p == q
+	}
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t != nil && t.Comparable() && a == b
+}
+
 type Logging struct {
-	opt         Options
+	// opt is shared with the loggers derived from l, it must not be modified.
+	opt         *Options
 	atomicLevel zap.AtomicLevel
 	lg          *zap.SugaredLogger
+	// fields are the context key-value pairs added to every entry.
+	fields []interface{}
+	// v is the V-level of a logger returned by V, 0 for other loggers.
+	v int
+	// noTrace makes WithContext skip the trace context of the context.
+	noTrace bool
+	// parentLevel is the level gate of the logger l was derived from by
+	// WithLevel, nil when atomicLevel alone gates l.
+	parentLevel zapcore.LevelEnabler
+	// sharedLevel is the level of the logger built by New, shared by the
+	// loggers derived from it without a level of their own.
+	sharedLevel zap.AtomicLevel
+	// ownLevel is set when atomicLevel belongs to l rather than to the logger
+	// it was derived from.
+	ownLevel bool
+	// leveled replaces lg and atomicLevel once SetLevel gave l a level of its
+	// own, nil until then. lg, atomicLevel and ownLevel don't change once l
+	// is built, so SetLevel can run concurrently with the use of l.
+	leveled atomic.Pointer[levelGate]
+	// callDepth is the number of frames skipped by WithCallDepth and the
+	// like, on top of WithCallerSkip.
+	callDepth int
+
+	_rollingFiles  []zapcore.WriteSyncer
+	_rotateLoggers []*RotateLogger
+	// _lumberjacks are the rolling files of the lumberjack backend.
+	_lumberjacks []*lumberjack.Logger
+	pool         *bufferPool
+	// compressor archives the backups of the rolling files, nil for the
+	// default compressor.
+	compressor *compressor
+	// closer closes the outputs once, it is nil for the loggers derived from
+	// the logger built by New, which don't own the outputs.
+	closer *outputCloser
+	// tenants write the entries of the tenants to their own files, nil
+	// without WithTenantRouting.
+	tenants *tenantLoggers
+	// chain is the hash chain of the audit logger, nil for the others.
+	chain *hashChain
+	// sinks are the network sinks, shut down by Close.
+	sinks []networkSink
+}
+
+// networkSink is a sink sending the entries over the network from its own
+// goroutine, such as a webhook.
+type networkSink interface {
+	// shutdown stops the sink, then sends the queued entries until ctx is
+	// done.
+	shutdown(ctx context.Context) error
+}
+
+// levelGate is the level of its own set on a logger sharing the level of
+// the logger it was derived from, and the zap logger it gates.
+type levelGate struct {
+	lg          *zap.SugaredLogger
+	atomicLevel zap.AtomicLevel
+}
 
-	_rollingFiles []zapcore.WriteSyncer
+// outputCloser holds the result of closing the outputs of a logger.
+type outputCloser struct {
+	once sync.Once
+	err  error
 }
 
 // WrappedWriteSyncer is a helper struct implementing zapcore.WriteSyncer to
@@ -43,8 +188,22 @@ func (mws WrappedWriteSyncer) Sync() error {
 // NonColorable holds writer but removes escape sequence.
 type NonColorable struct {
 	out zapcore.WriteSyncer
+
+	mu    sync.Mutex
+	state ansiState
+	buf   []byte
 }
 
+// ansiState is the position of NonColorable within an escape sequence, kept
+// across writes so sequences split between two writes are still removed.
+type ansiState uint8
+
+const (
+	ansiText ansiState = iota
+	ansiEscape
+	ansiCSI
+)
+
 // NewNonColorable returns new instance of Writer which removes escape sequence from Writer.
 func NewNonColorable(w zapcore.WriteSyncer) io.Writer {
 	return &NonColorable{out: w}
@@ -52,42 +211,41 @@ func NewNonColorable(w zapcore.WriteSyncer) io.Writer {
 
 // Write writes data on console
 func (w *NonColorable) Write(data []byte) (n int, err error) {
-	er := bytes.NewReader(data)
-	var plaintext bytes.Buffer
-loop:
-	for {
-		c1, err := er.ReadByte()
-		if err != nil {
-			plaintext.WriteTo(w.out)
-			break loop
-		}
-		if c1 != 0x1b {
-			plaintext.WriteByte(c1)
-			continue
-		}
-		_, err = plaintext.WriteTo(w.out)
-		if err != nil {
-			break loop
-		}
-		c2, err := er.ReadByte()
-		if err != nil {
-			break loop
-		}
-		if c2 != 0x5b {
-			continue
-		}
-
-		for {
-			c, err := er.ReadByte()
-			if err != nil {
-				break loop
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = w.buf[:0]
+	for _, c := range data {
+		switch w.state {
+		case ansiText:
+			if c == 0x1b {
+				w.state = ansiEscape
+				continue
+			}
+			w.buf = append(w.buf, c)
+		case ansiEscape:
+			// only CSI sequences are removed with their parameters.
+			if c == 0x5b {
+				w.state = ansiCSI
+			} else {
+				w.state = ansiText
 			}
+		case ansiCSI:
 			if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || c == '@' {
-				break
+				w.state = ansiText
 			}
 		}
 	}
 
+	if len(w.buf) > 0 {
+		if _, err = w.out.Write(w.buf); err != nil {
+			return 0, err
+		}
+	}
+	// don't retain the memory of a huge entry.
+	if cap(w.buf) > logPageSize {
+		w.buf = nil
+	}
 	return len(data), nil
 }
 
@@ -96,41 +254,91 @@ func (w *NonColorable) Sync() error {
 	return w.out.Sync()
 }
 
+// New returns a Logging built from opts, it panics if the outputs can't be
+// created. Invalid option combinations are reported to the standard logger.
+// Use NewWithError to handle both errors.
 func New(opts ...Option) *Logging {
 	opt := newOptions(opts...)
-	l := &Logging{
-		opt:         opt,
-		atomicLevel: zap.NewAtomicLevelAt(opt.level.unmarshalZapLevel()),
+	if err := opt.validate(); err != nil {
+		opt.internal.error("invalid options", "error", err)
 	}
-	if err := l.build(); err != nil {
+
+	l, err := newLogging(opt)
+	if err != nil {
 		panic(err)
 	}
 	return l
 }
 
+// NewWithError returns a Logging built from opts, or an error if the options
+// are invalid or the outputs, such as the rolling files, can't be created.
+func NewWithError(opts ...Option) (*Logging, error) {
+	opt := newOptions(opts...)
+	if err := opt.validate(); err != nil {
+		return nil, err
+	}
+	return newLogging(opt)
+}
+
+func newLogging(opt Options) (*Logging, error) {
+	opt.path = expandPlaceholders(opt.path)
+	opt.filename = expandPlaceholders(opt.filename)
+	atomicLevel := zap.NewAtomicLevelAt(opt.level.unmarshalZapLevel())
+	l := &Logging{
+		opt:         &opt,
+		atomicLevel: atomicLevel,
+		sharedLevel: atomicLevel,
+		ownLevel:    true,
+		closer:      &outputCloser{},
+	}
+	if err := l.build(); err != nil {
+		l.closeRotateLoggers()
+		return nil, err
+	}
+	if opt.tenantRouting {
+		l.tenants = newTenantLoggers(l)
+	}
+	if opt.flushOnExit {
+		exitFlusher.register(l)
+	}
+	if opt.panicFlush {
+		panicFlusher.add(l)
+	}
+	return l, nil
+}
+
+// LevelEnablerFunc returns an enabler routing entries of level to a per-level
+// core, fatal and above share one core. The logger level is enforced by the
+// level gate wrapping all cores.
 func (l *Logging) LevelEnablerFunc(level zapcore.Level) LevelEnablerFunc {
 	return func(lvl zapcore.Level) bool {
 		if level == zapcore.FatalLevel {
-			return l.atomicLevel.Level() <= level && lvl >= level
+			return lvl >= level
 		}
-		return l.atomicLevel.Level() <= level && lvl == level
+		return lvl == level
 	}
 }
 
 func (l *Logging) build() error {
 	var (
 		cores []zapcore.Core
+		err   error
 	)
 
 	switch l.opt.mode {
 	case FileMode:
 		var _cores []zapcore.Core
-		if l.opt.writer != nil {
+		if l.opt.audit {
+			_cores, err = l.buildAudit()
+		} else if l.opt.writer != nil {
 			_cores = l.buildCustomWriter()
 		} else if l.opt.filename != "" {
-			_cores = l.buildFile()
+			_cores, err = l.buildFile()
 		} else {
-			_cores = l.buildFiles()
+			_cores, err = l.buildFiles()
+		}
+		if err != nil {
+			return err
 		}
 		if len(_cores) > 0 {
 			cores = append(cores, _cores...)
@@ -142,9 +350,54 @@ func (l *Logging) build() error {
 		}
 	}
 
-	zapLog := zap.New(zapcore.NewTee(cores...), zap.AddCaller(), zap.AddCallerSkip(l.opt.callerSkip)).Sugar()
+	cores = append(cores, newStreamCore(l.opt.encoderConfig))
+	if l.opt.stats {
+		enableStats()
+		cores = append(cores, newStatsCore())
+	}
+	for _, hook := range l.opt.webhooks {
+		if hook.url != "" {
+			c := newWebhookCore(l.opt.sinkContext(), hook, l.opt.writeRetry, l.opt.internal)
+			cores, l.sinks = append(cores, c), append(l.sinks, c.sink)
+		}
+	}
+	for _, sink := range l.opt.sqlSinks {
+		if sink.db == nil {
+			continue
+		}
+		c, err := newSQLCore(l.opt.sinkContext(), sink, l.opt.writeRetry, l.opt.internal)
+		if err != nil {
+			return err
+		}
+		cores, l.sinks = append(cores, c), append(l.sinks, c.sink)
+	}
+	if l.opt.alertFn != nil && l.opt.alertThreshold > 0 && l.opt.alertWindow > 0 {
+		cores = append(cores, newAlertCore(newErrorAlert(l.opt.alertThreshold, l.opt.alertWindow, l.opt.alertFn)))
+	}
+	core := zapcore.NewTee(cores...)
+	if !l.opt.unsampled {
+		core = newSamplingCore(core, globalSampler{})
+	}
+	if len(l.opt.levelSampling) > 0 {
+		core = newSamplingCore(core, newLevelSampler(l.opt.levelSampling))
+	}
+	if l.opt.sampleBudget > 0 {
+		core = newSamplingCore(core, newAdaptiveSampler(l.opt.sampleBudget))
+	}
+	core = newLevelFilterCore(core, l.atomicLevel)
+	zapOpts := []zap.Option{zap.WithCaller(!l.opt.noCaller), zap.AddCallerSkip(l.opt.callerSkip + 1)}
+	if l.opt.fatalHook != nil {
+		zapOpts = append(zapOpts, zap.WithFatalHook(l.opt.fatalHook))
+	}
+	zapLog := zap.New(core, zapOpts...).Sugar()
+	if l.opt.name != "" {
+		zapLog = zapLog.Named(l.opt.name)
+	}
+	if len(l.opt.metaFields) > 0 {
+		zapLog = zapLog.With(l.opt.metaFields...)
+	}
 	if len(l.opt.fields) > 0 {
-		zapLog = zapLog.With(CopyFields(l.opt.fields)...)
+		zapLog = zapLog.With(l.copyFields(l.opt.fields)...)
 	}
 	if l.opt.namespace != "" {
 		zapLog = zapLog.With(zap.Namespace(l.opt.namespace))
@@ -156,113 +409,224 @@ func (l *Logging) build() error {
 
 // buildConsole build console.
 func (l *Logging) buildConsole() []zapcore.Core {
-	var (
-		sync zapcore.WriteSyncer
-		enc  zapcore.Encoder
-	)
-
-	if l.opt.encoder.IsConsole() {
-		enc = zapcore.NewConsoleEncoder(l.opt.encoderConfig)
-	} else {
-		enc = zapcore.NewJSONEncoder(l.opt.encoderConfig)
-	}
-
+	var sync zapcore.WriteSyncer
 	if l.opt.writer != nil {
-		sync = zapcore.AddSync(l.opt.writer)
+		sync = zapcore.AddSync(l.customWriter())
 	} else {
 		sync = zapcore.AddSync(WrappedWriteSyncer{os.Stdout})
 	}
-	return []zapcore.Core{zapcore.NewCore(enc, sync, l.atomicLevel)}
+	if l.opt.consoleBuffer {
+		interval := l.opt.consoleFlushInterval
+		if interval <= 0 {
+			interval = defaultConsoleFlushInterval
+		}
+		sync = &zapcore.BufferedWriteSyncer{WS: sync, Size: l.opt.consoleBufferSize, FlushInterval: interval}
+	}
+	return []zapcore.Core{zapcore.NewCore(l.newEncoder(), sync, zapcore.DebugLevel)}
 }
 
 // buildCustomWriter build custom writer.
 func (l *Logging) buildCustomWriter() []zapcore.Core {
-	syncer := l.opt.writer
-	if syncer == nil {
-		syncer = zapcore.AddSync(WrappedWriteSyncer{os.Stdout})
+	var syncer io.Writer = WrappedWriteSyncer{os.Stdout}
+	if l.opt.writer != nil {
+		syncer = l.customWriter()
 	}
 
-	var enc zapcore.Encoder
-	if l.opt.encoder.IsConsole() {
-		enc = zapcore.NewConsoleEncoder(l.opt.encoderConfig)
-	} else {
-		enc = zapcore.NewJSONEncoder(l.opt.encoderConfig)
-	}
+	return []zapcore.Core{zapcore.NewCore(l.newEncoder(), zapcore.AddSync(syncer), zapcore.DebugLevel)}
+}
 
-	return []zapcore.Core{zapcore.NewCore(enc, zapcore.AddSync(syncer), l.atomicLevel)}
+// customWriter returns the writer set with WithWriter, retrying the failed
+// writes when a retry policy or error handler is set.
+func (l *Logging) customWriter() io.Writer {
+	if l.opt.writeRetry.enabled() {
+		return &retryWriter{w: l.opt.writer, retry: l.opt.writeRetry}
+	}
+	if f, ok := l.opt.writer.(*os.File); ok && isStdStream(f) {
+		return consoleSyncer{f}
+	}
+	return l.opt.writer
 }
 
 // buildFile build rolling file.
-func (l *Logging) buildFile() []zapcore.Core {
+func (l *Logging) buildFile() ([]zapcore.Core, error) {
 	_ = l.Sync()
-	var enc zapcore.Encoder
-	if l.opt.encoder.IsConsole() {
-		enc = zapcore.NewConsoleEncoder(l.opt.encoderConfig)
-	} else {
-		enc = zapcore.NewJSONEncoder(l.opt.encoderConfig)
+	syncerRolling, err := l.createOutput(path.Join(l.opt.path, l.opt.filename))
+	if err != nil {
+		return nil, err
 	}
-
-	syncerRolling := l.createOutput(path.Join(l.opt.path, l.opt.filename))
 	l._rollingFiles = append(l._rollingFiles, []zapcore.WriteSyncer{syncerRolling}...)
-	return []zapcore.Core{zapcore.NewCore(enc, syncerRolling, l.atomicLevel)}
+	return []zapcore.Core{zapcore.NewCore(l.newEncoder(), syncerRolling, zapcore.DebugLevel)}, nil
 }
 
-// buildFiles build rolling files.
-func (l *Logging) buildFiles() []zapcore.Core {
-	var (
-		cores = make([]zapcore.Core, 0, 5)
-		syncerRollingDebug, syncerRollingInfo, syncerRollingWarn,
-		syncerRollingError, syncerRollingFatal zapcore.WriteSyncer
-	)
+// buildFiles build rolling files, one per level. The files share a single
+// encoder, so fields attached to the logger are encoded once.
+func (l *Logging) buildFiles() ([]zapcore.Core, error) {
+	if err := l.Sync(); err != nil {
+		return nil, err
+	}
 
-	var enc zapcore.Encoder
-	if l.opt.encoder.IsConsole() {
-		enc = zapcore.NewConsoleEncoder(l.opt.encoderConfig)
-	} else {
-		enc = zapcore.NewJSONEncoder(l.opt.encoderConfig)
+	files := []struct {
+		level    zapcore.Level
+		filename string
+	}{
+		{zap.DebugLevel, debugFilename},
+		{zap.InfoLevel, infoFilename},
+		{zap.WarnLevel, warnFilename},
+		{zap.ErrorLevel, errorFilename},
+		{zap.FatalLevel, fatalFilename},
 	}
 
-	if err := l.Sync(); err != nil {
-		return nil
+	outs := make([]levelOutput, 0, len(files))
+	for _, file := range files {
+		syncer, err := l.createOutput(path.Join(l.opt.path, file.filename))
+		if err != nil {
+			return nil, err
+		}
+		l._rollingFiles = append(l._rollingFiles, syncer)
+		outs = append(outs, levelOutput{enabler: l.LevelEnablerFunc(file.level), out: syncer})
 	}
 
-	syncerRollingDebug = l.createOutput(path.Join(l.opt.path, debugFilename))
+	return []zapcore.Core{newLevelRouterCore(l.newEncoder(), zapcore.DebugLevel, outs...)}, nil
+}
+
+// newEncoder returns the encoder configured by the options.
+func (l *Logging) newEncoder() zapcore.Encoder {
+	return newHumanEncoder(l.newFormatEncoder(), l.opt.durationMillis, l.opt.byteKeys)
+}
 
-	syncerRollingInfo = l.createOutput(path.Join(l.opt.path, infoFilename))
+// newFormatEncoder returns the encoder of the format of l.
+func (l *Logging) newFormatEncoder() zapcore.Encoder {
+	if l.opt.encoder.IsConsole() {
+		if l.colored() {
+			return newColorEncoder(l.opt.encoderConfig)
+		}
+		return zapcore.NewConsoleEncoder(l.opt.encoderConfig)
+	}
+	if l.opt.encoder.IsMsgpack() {
+		return newMsgpackEncoder(l.opt.encoderConfig)
+	}
+	if l.opt.encoder.IsCBOR() {
+		return newCBOREncoder(l.opt.encoderConfig)
+	}
+	if l.opt.encoder.IsOTLP() {
+		return newOTLPEncoder(l.opt.encoderConfig)
+	}
+	if l.opt.encoder.IsCSV() || l.opt.encoder.IsTSV() {
+		return newCSVEncoder(l.opt.encoderConfig, l.tableColumns(), l.tableComma())
+	}
+	return zapcore.NewJSONEncoder(l.opt.encoderConfig)
+}
 
-	syncerRollingWarn = l.createOutput(path.Join(l.opt.path, warnFilename))
+// tableColumns returns the columns of the csv and tsv encoders.
+func (l *Logging) tableColumns() []string {
+	if len(l.opt.columns) > 0 {
+		return l.opt.columns
+	}
+	return defaultColumns(l.opt.encoderConfig)
+}
 
-	syncerRollingError = l.createOutput(path.Join(l.opt.path, errorFilename))
+// tableComma returns the separator of the columns of the csv and tsv encoders.
+func (l *Logging) tableComma() rune {
+	if l.opt.encoder.IsTSV() {
+		return '\t'
+	}
+	return ','
+}
 
-	syncerRollingFatal = l.createOutput(path.Join(l.opt.path, fatalFilename))
+func (l *Logging) createOutput(filename string) (zapcore.WriteSyncer, error) {
+	if l.opt.rollingBackend == RollingBackendLumberjack {
+		return l.createLumberjackOutput(filename), nil
+	}
+	if l.pool == nil {
+		l.pool = bpool
+		if l.opt.bufferPoolSize > 0 || l.opt.maxBufferCapacity > 0 {
+			l.pool = newBufferPool(l.opt.bufferPoolSize, l.opt.maxBufferCapacity)
+		}
+	}
+	if l.compressor == nil && (l.opt.compressionWorkers > 0 || l.opt.compressionBacklog > 0) {
+		l.compressor = newCompressor(l.opt.compressionWorkers, l.opt.compressionBacklog)
+	}
 
-	cores = append(cores,
-		zapcore.NewCore(enc, syncerRollingDebug, l.LevelEnablerFunc(zap.DebugLevel)),
-		zapcore.NewCore(enc, syncerRollingInfo, l.LevelEnablerFunc(zap.InfoLevel)),
-		zapcore.NewCore(enc, syncerRollingWarn, l.LevelEnablerFunc(zap.WarnLevel)),
-		zapcore.NewCore(enc, syncerRollingError, l.LevelEnablerFunc(zap.ErrorLevel)),
-		zapcore.NewCore(enc, syncerRollingFatal, l.LevelEnablerFunc(zap.FatalLevel)),
-	)
+	cfg := rotateConfig{
+		compress:      l.opt.compress,
+		digest:        l.opt.audit,
+		checksum:      l.opt.checksum,
+		archiveKey:    l.opt.archiveKey,
+		pathLayout:    l.opt.pathLayout,
+		layoutKeep:    time.Duration(l.opt.retentionHours()) * time.Hour,
+		newRule:       l.newRotateRule,
+		pool:          l.pool,
+		mmap:          l.opt.mmap,
+		onRotateError: l.opt.onRotateError,
+		internal:      l.opt.internal,
+		filePerm:      l.opt.filePerm,
+		dirPerm:       l.opt.dirPerm,
+		delimiter:     l.opt.backupDelimiter,
+		compressor:    l.compressor,
+		compressLevel: l.opt.compressionLevel,
+		compressRate:  l.opt.compressionRate,
+	}
+	if l.opt.encoder.IsCSV() || l.opt.encoder.IsTSV() {
+		cfg.header = csvHeader(l.tableColumns(), l.tableComma())
+	}
+
+	log, err := newRotateLogger(filename, l.newRotateRule(filename), cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	l._rollingFiles = append(l._rollingFiles, []zapcore.WriteSyncer{syncerRollingDebug, syncerRollingInfo, syncerRollingWarn, syncerRollingError, syncerRollingFatal}...)
-	return cores
+	l._rotateLoggers = append(l._rotateLoggers, log)
+	// only the console encoder writes colors, the others escape control
+	// characters or are binary.
+	if !l.opt.encoder.IsConsole() {
+		return log, nil
+	}
+	return zapcore.AddSync(NewNonColorable(log)), nil
 }
 
-func (l *Logging) createOutput(filename string) zapcore.WriteSyncer {
-	var rule = DefaultRotateRule(filename, backupFileDelimiter, l.opt.keepDays, l.opt.compress)
+// newRotateRule returns the rotation rule of filename configured by the options.
+func (l *Logging) newRotateRule(filename string) RotateRule {
+	var rule = DefaultRotateRule(filename, l.opt.backupDelimiter, l.opt.keepDays, l.opt.compress)
 	switch l.opt.rotation {
 	case sizeRotationRule:
-		rule = NewSizeLimitRotateRule(filename, backupFileDelimiter, l.opt.keepDays, l.opt.maxSize, l.opt.maxBackups, l.opt.compress)
+		rule = NewSizeLimitRotateRule(filename, l.opt.backupDelimiter, l.opt.keepDays, l.opt.maxSize, l.opt.maxBackups, l.opt.compress)
 	case hourRotationRule:
-		rule = NewHourRotateRule(filename, backupFileDelimiter, l.opt.keepHours, l.opt.compress)
+		rule = NewHourRotateRule(filename, l.opt.backupDelimiter, l.opt.keepHours, l.opt.compress)
 	}
 
-	log, err := NewRotateLogger(filename, rule, l.opt.compress)
-	if err != nil {
-		panic(err)
+	if rr, ok := rule.(retentionRule); ok {
+		rr.setRetention(l.opt.retentionHours(), l.opt.maxBackups)
+	}
+	if l.opt.rotateOnMaxSize && l.opt.maxSize > 0 {
+		if sr, ok := rule.(maxSizeRule); ok {
+			sr.setMaxSize(int64(l.opt.maxSize) * megaBytes)
+		}
 	}
+	if l.opt.archiveKey != nil {
+		if ar, ok := rule.(archiveRule); ok {
+			ar.setArchiveExt(gzipExt + encryptedExt)
+		}
+	}
+	if ir, ok := rule.(internalRule); ok {
+		ir.setInternal(l.opt.internal)
+	}
+	return rule
+}
 
-	return zapcore.AddSync(NewNonColorable(log))
+// closeRotateLoggers closes the rolling files created by l.
+func (l *Logging) closeRotateLoggers() error {
+	var errs []error
+	for _, rl := range l._rotateLoggers {
+		if err := rl.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, lj := range l._lumberjacks {
+		if err := lj.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func CopyFields(fields map[string]interface{}) []interface{} {
@@ -273,209 +637,698 @@ func CopyFields(fields map[string]interface{}) []interface{} {
 	return dst
 }
 
-func (l *Logging) WithContext(ctx context.Context) Logger {
-	spanId := SpanID(ctx)
-	traceId := TraceID(ctx)
-	fields := make([]interface{}, 0, 4)
-	if len(spanId) > 0 {
-		fields = append(fields, spanKey, spanId)
+// SortedFields is CopyFields with the keys in sorted order.
+func SortedFields(fields map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
 	}
-	if len(traceId) > 0 {
-		fields = append(fields, traceKey, traceId)
+	sort.Strings(keys)
+
+	dst := make([]interface{}, 0, len(fields)*2)
+	for _, k := range keys {
+		dst = append(dst, k, fields[k])
+	}
+	return dst
+}
+
+// copyFields returns the key-value pairs of fields, sorted by key if
+// WithSortedFields is set.
+func (l *Logging) copyFields(fields map[string]interface{}) []interface{} {
+	if l.opt.sortedFields {
+		return SortedFields(fields)
 	}
+	return CopyFields(fields)
+}
 
-	logger := &Logging{
-		opt:         l.opt,
-		atomicLevel: l.atomicLevel,
-		lg:          l.lg.With(fields...).WithOptions(zap.AddCallerSkip(0)),
+// WithContext returns a logger carrying the span_id and trace_id, the
+// tenant_id set by WithTenant and the fields pushed by PushFields of ctx, and
+// capturing debug entries if ctx comes from WithDebugCapture, or l itself if
+// ctx has none of them. With WithTenantRouting, the logger writes to the
+// files of the tenant.
+func (l *Logging) WithContext(ctx context.Context) Logger {
+	lg := l
+	if !l.noTrace {
+		span := trace.SpanContextFromContext(ctx)
+		if span.HasSpanID() || span.HasTraceID() {
+			fields := make([]interface{}, 0, 4)
+			if span.HasSpanID() {
+				fields = append(fields, spanKey, span.SpanID().String())
+			}
+			if span.HasTraceID() {
+				fields = append(fields, traceKey, span.TraceID().String())
+			}
+			lg = l.withRootFields(fields)
+		} else if l.opt.correlationIDs {
+			id := CorrelationID(ctx)
+			if id == "" {
+				id = newCorrelationID()
+			}
+			lg = l.withRootFields([]interface{}{traceKey, id})
+		}
+	}
+	if tenant := TenantFromContext(ctx); tenant != "" {
+		lg = lg.withRootFields([]interface{}{tenantKey, tenant})
+		if lg.tenants != nil {
+			lg = lg.withTenant(tenant)
+		}
+	}
+	if fields := FieldsFromContext(ctx); len(fields) > 0 {
+		lg = lg.withFields(fields)
+	}
+	if buf := captureFromContext(ctx); buf != nil {
+		lg = lg.derive(lg.sugar().WithOptions(withCapture(buf)), lg.fields)
 	}
-	return logger
+	return lg
 }
 
 func (l *Logging) WithFields(fields map[string]any) Logger {
-	return &Logging{
-		opt:         l.opt,
-		atomicLevel: l.atomicLevel,
-		lg:          l.lg.With(CopyFields(fields)...).WithOptions(zap.AddCallerSkip(0)),
+	if len(fields) == 0 {
+		return l
 	}
+	return l.withFields(l.copyFields(fields))
 }
 
+// With returns a logger adding args, alternating keys and values or zap
+// Fields, to every entry.
+func (l *Logging) With(args ...any) Logger {
+	if len(args) == 0 {
+		return l
+	}
+	return l.withFields(args)
+}
+
+// WithTrace returns a logger carrying traceID and spanID as the trace context
+// of its entries, for code without a context such as cron jobs. Empty IDs
+// are left out.
+func (l *Logging) WithTrace(traceID, spanID string) Logger {
+	fields := make([]interface{}, 0, 4)
+	if spanID != "" {
+		fields = append(fields, spanKey, spanID)
+	}
+	if traceID != "" {
+		fields = append(fields, traceKey, traceID)
+	}
+	if len(fields) == 0 {
+		return l
+	}
+	return l.withRootFields(fields)
+}
+
+// WithoutTrace returns a logger whose WithContext doesn't look up the trace
+// context, for noisy paths. The trace context already carried is kept.
+func (l *Logging) WithoutTrace() Logger {
+	lg := l.derive(l.sugar(), l.fields)
+	lg.noTrace = true
+	return lg
+}
+
+// Namespace returns a logger nesting the fields added after it under the key
+// name. The trace context and the V-level stay at the top level.
+func (l *Logging) Namespace(name string) Logger {
+	return l.withFields([]interface{}{zap.Namespace(name)})
+}
+
+// WithCallDepth returns a logger skipping callDepth more frames for the
+// caller, same as WithCallDepthDelta.
 func (l *Logging) WithCallDepth(callDepth int) Logger {
+	return l.WithCallDepthDelta(callDepth)
+}
+
+// WithCallDepthDelta returns a logger skipping delta more frames for the
+// caller, or fewer for a negative delta, e.g. for a wrapper undoing the
+// depth of another. The depth stays within 0 and maxCallDepth.
+func (l *Logging) WithCallDepthDelta(delta int) Logger {
+	return l.WithCallDepthSet(l.callDepth + delta)
+}
+
+// WithCallDepthSet returns a logger skipping depth frames for the caller,
+// whatever the depth of l, within 0 and maxCallDepth.
+func (l *Logging) WithCallDepthSet(depth int) Logger {
+	if depth < 0 {
+		depth = 0
+	} else if depth > maxCallDepth {
+		depth = maxCallDepth
+	}
+	if depth == l.callDepth {
+		return l
+	}
+	lg := l.derive(l.sugar().WithOptions(zap.AddCallerSkip(depth-l.callDepth)), l.fields)
+	lg.callDepth = depth
+	return lg
+}
+
+// CallDepth returns the number of frames skipped for the caller by
+// WithCallDepth and the like.
+func (l *Logging) CallDepth() int {
+	return l.callDepth
+}
+
+// V returns a logger for verbose entries of V-level n, klog style. Below
+// ErrorLevel its entries are logged at DebugLevel with a `v` field, and only
+// when n is at most the verbosity set by WithVerbosity, otherwise V returns
+// Nop. As debug entries they are also subject to the logger level. V(0)
+// returns l.
+func (l *Logging) V(n int) Logger {
+	if n <= l.v {
+		return l
+	}
+	if n > l.opt.verbosity {
+		return nop
+	}
+	vl := l.withRootFields([]interface{}{verbosityKey, n})
+	vl.v = n
+	return vl
+}
+
+// withFields returns a logger adding the key-value pairs to every entry.
+// The fields are passed at log time rather than added to the zap logger,
+// which would clone the encoders of every core.
+func (l *Logging) withFields(keysAndValues []interface{}) *Logging {
+	fields := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keysAndValues...)
+	return l.derive(l.sugar(), fields)
+}
+
+// withRootFields is withFields with the key-value pairs ahead of the context
+// fields, out of the namespaces opened by Namespace.
+func (l *Logging) withRootFields(keysAndValues []interface{}) *Logging {
+	fields := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, keysAndValues...)
+	fields = append(fields, l.fields...)
+	return l.derive(l.sugar(), fields)
+}
+
+// derive returns a Logging around lg sharing the options and level of l.
+func (l *Logging) derive(lg *zap.SugaredLogger, fields []interface{}) *Logging {
 	return &Logging{
 		opt:         l.opt,
-		atomicLevel: l.atomicLevel,
-		lg:          l.lg.WithOptions(zap.AddCallerSkip(callDepth)),
+		atomicLevel: l.level(),
+		lg:          lg,
+		fields:      fields,
+		v:           l.v,
+		noTrace:     l.noTrace,
+		parentLevel: l.parentLevel,
+		sharedLevel: l.sharedLevel,
+		callDepth:   l.callDepth,
+		tenants:     l.tenants,
+		chain:       l.chain,
+		// the derived loggers report the rolling files of l, which they
+		// write to, and leave closing them to l.
+		_rotateLoggers: l._rotateLoggers,
+	}
+}
+
+// named returns a shallow copy of l with name appended to its logger name.
+func (l *Logging) named(name string) *Logging {
+	return l.derive(l.sugar().Named(name), l.fields)
+}
+
+// withLevel returns a shallow copy of l gated by its own level.
+func (l *Logging) withLevel(lv Level) *Logging {
+	atomicLevel := zap.NewAtomicLevelAt(lv.unmarshalZapLevel())
+	lg := l.derive(l.sugar().WithOptions(withLevelEnabler(atomicLevel)), l.fields)
+	lg.atomicLevel, lg.parentLevel, lg.ownLevel = atomicLevel, nil, true
+	return lg
+}
+
+// WithLevel returns a logger logging the entries of lv and above that l
+// logs, e.g. to quiet a component. Its SetLevel changes its own level only,
+// and it follows the level changes of l, as it can't be more verbose.
+func (l *Logging) WithLevel(lv Level) Logger {
+	parent := l.levelEnabler()
+	atomicLevel := zap.NewAtomicLevelAt(lv.unmarshalZapLevel())
+	lg := l.derive(l.sugar().WithOptions(withLevelEnabler(stricterLevel{parent: parent, own: atomicLevel})), l.fields)
+	lg.atomicLevel, lg.parentLevel, lg.ownLevel = atomicLevel, parent, true
+	return lg
+}
+
+// levelEnabler returns the level gate of l.
+func (l *Logging) levelEnabler() zapcore.LevelEnabler {
+	if l.parentLevel == nil {
+		return l.level()
+	}
+	return stricterLevel{parent: l.parentLevel, own: l.level()}
+}
+
+// level returns the level of l, its own or the one shared with the logger it
+// was derived from.
+func (l *Logging) level() zap.AtomicLevel {
+	if g := l.leveled.Load(); g != nil {
+		return g.atomicLevel
 	}
+	return l.atomicLevel
 }
 
+// sugar returns the zap logger of l, gated by its own level once SetLevel
+// gave it one.
+func (l *Logging) sugar() *zap.SugaredLogger {
+	if g := l.leveled.Load(); g != nil {
+		return g.lg
+	}
+	return l.lg
+}
+
+// Options returns the options of l, with its current level.
 func (l *Logging) Options() Options {
-	return l.opt
+	opt := *l.opt
+	opt.level = l.Level()
+	return opt
 }
 
+// SetLevel sets the level of l only. The loggers derived from l follow it
+// until they set their own, the logger l was derived from doesn't. A logger
+// derived with With, WithFields, WithContext and the like shares the level
+// of its parent until then, so its first SetLevel gives it a level of its
+// own, also while it logs. See SetGlobalLevel.
 func (l *Logging) SetLevel(lv Level) {
-	l.opt.level = lv
-	l.atomicLevel.SetLevel(lv.unmarshalZapLevel())
+	if l.ownLevel {
+		l.atomicLevel.SetLevel(lv.unmarshalZapLevel())
+		return
+	}
+	for {
+		if g := l.leveled.Load(); g != nil {
+			g.atomicLevel.SetLevel(lv.unmarshalZapLevel())
+			return
+		}
+		// a logger under WithLevel stays gated by the level of its parent.
+		g := &levelGate{atomicLevel: zap.NewAtomicLevelAt(lv.unmarshalZapLevel())}
+		var enabler zapcore.LevelEnabler = g.atomicLevel
+		if l.parentLevel != nil {
+			enabler = stricterLevel{parent: l.parentLevel, own: g.atomicLevel}
+		}
+		g.lg = l.lg.WithOptions(withLevelEnabler(enabler))
+		if l.leveled.CompareAndSwap(nil, g) {
+			return
+		}
+	}
+}
+
+// SetGlobalLevel sets the level shared by the logger built by New and the
+// loggers derived from it without a level of their own, from any of them.
+func (l *Logging) SetGlobalLevel(lv Level) {
+	l.sharedLevel.SetLevel(lv.unmarshalZapLevel())
+}
+
+// Level returns the current logger level, the stricter of its own and its
+// parent's for a logger from WithLevel.
+func (l *Logging) Level() Level {
+	return marshalZapLevel(zapcore.LevelOf(l.levelEnabler()))
 }
 
 func (l *Logging) Clone() *Logging {
-	_copy := *l
-	return &_copy
+	_copy := l.derive(l.sugar(), l.fields)
+	_copy.ownLevel = l.ownLevel || l.leveled.Load() != nil
+	_copy._rollingFiles, _copy._lumberjacks = l._rollingFiles, l._lumberjacks
+	_copy.pool, _copy.compressor, _copy.closer, _copy.sinks = l.pool, l.compressor, l.closer, l.sinks
+	return _copy
 }
 
 func (l *Logging) Debug(args ...interface{}) {
-	l.lg.Debug(args...)
+	l.log(zap.DebugLevel, "", args, nil)
 }
 
 func (l *Logging) Info(args ...interface{}) {
-	l.lg.Info(args...)
+	l.log(zap.InfoLevel, "", args, nil)
 }
 
 func (l *Logging) Warn(args ...interface{}) {
-	l.lg.Warn(args...)
+	l.log(zap.WarnLevel, "", args, nil)
 }
 
 func (l *Logging) Error(args ...interface{}) {
-	l.lg.Error(args...)
+	l.log(zap.ErrorLevel, "", args, nil)
 }
 
 func (l *Logging) Fatal(args ...interface{}) {
-	l.lg.Fatal(args...)
+	l.log(zap.FatalLevel, "", args, nil)
 }
 
 func (l *Logging) Debugf(template string, args ...interface{}) {
-	l.lg.Debugf(template, args...)
+	l.log(zap.DebugLevel, template, args, nil)
 }
 
 func (l *Logging) Infof(template string, args ...interface{}) {
-	l.lg.Infof(template, args...)
+	l.log(zap.InfoLevel, template, args, nil)
 }
 
 func (l *Logging) Warnf(template string, args ...interface{}) {
-	l.lg.Warnf(template, args...)
+	l.log(zap.WarnLevel, template, args, nil)
 }
 
 func (l *Logging) Errorf(template string, args ...interface{}) {
-	l.lg.Errorf(template, args...)
+	l.log(zap.ErrorLevel, template, args, nil)
 }
 
 func (l *Logging) Fatalf(template string, args ...interface{}) {
-	l.lg.Fatalf(template, args...)
+	l.log(zap.FatalLevel, template, args, nil)
 }
 
 func (l *Logging) Debugw(msg string, keysAndValues ...interface{}) {
-	l.lg.Debugw(msg, keysAndValues...)
+	l.log(zap.DebugLevel, msg, nil, keysAndValues)
 }
 
 func (l *Logging) Infow(msg string, keysAndValues ...interface{}) {
-	l.lg.Infow(msg, keysAndValues...)
+	l.log(zap.InfoLevel, msg, nil, keysAndValues)
 }
 
 func (l *Logging) Warnw(msg string, keysAndValues ...interface{}) {
-	l.lg.Warnw(msg, keysAndValues...)
+	l.log(zap.WarnLevel, msg, nil, keysAndValues)
 }
 
 func (l *Logging) Errorw(msg string, keysAndValues ...interface{}) {
-	l.lg.Errorw(msg, keysAndValues...)
+	l.log(zap.ErrorLevel, msg, nil, keysAndValues)
 }
 
 func (l *Logging) Fatalw(msg string, keysAndValues ...interface{}) {
-	l.lg.Fatalw(msg, keysAndValues...)
+	l.log(zap.FatalLevel, msg, nil, keysAndValues)
 }
 
-func (l *Logging) Sync() (err error) {
-	if l.lg == nil {
+func (l *Logging) Log(level Level, args ...interface{}) {
+	l.log(level.unmarshalZapLevel(), "", args, nil)
+}
+
+func (l *Logging) Logf(level Level, template string, args ...interface{}) {
+	l.log(level.unmarshalZapLevel(), template, args, nil)
+}
+
+func (l *Logging) Logw(level Level, msg string, keysAndValues ...interface{}) {
+	l.log(level.unmarshalZapLevel(), msg, nil, keysAndValues)
+}
+
+// log formats the message and logs it with the context fields of l. It must be
+// called directly by the exported methods, the caller skip accounts for both.
+func (l *Logging) log(lvl zapcore.Level, template string, args []interface{}, keysAndValues []interface{}) {
+	if l.v > 0 && lvl < zapcore.ErrorLevel {
+		lvl = zapcore.DebugLevel
+	}
+	lg := l.sugar()
+	enabled := lvl >= zapcore.DPanicLevel || lg.Level().Enabled(lvl)
+	r := recorder.Load()
+	if !enabled && r == nil {
 		return
 	}
 
+	if r == nil && lvl < zapcore.DPanicLevel && l.opt.maxFields <= 0 {
+		l.logFields(lvl, formatMessage(template, args), keysAndValues)
+		return
+	}
+
+	if len(l.fields) > 0 {
+		// the full slice expression makes append copy instead of sharing l.fields.
+		keysAndValues = append(l.fields[:len(l.fields):len(l.fields)], keysAndValues...)
+	}
+	if l.opt.maxFields > 0 {
+		keysAndValues = limitFields(keysAndValues, l.opt.maxFields)
+	}
+	msg := formatMessage(template, args)
+	if r != nil {
+		r.record(l.opt, lvl, msg, keysAndValues)
+		if lvl >= zapcore.FatalLevel {
+			_ = r.dump(os.Stderr)
+		}
+	}
+	if enabled {
+		lg.Logw(lvl, msg, keysAndValues...)
+	}
+}
+
+// logFields logs msg with the context fields of l and keysAndValues, with
+// pooled fields rather than through the sugared logger. It must be called
+// directly by log.
+func (l *Logging) logFields(lvl zapcore.Level, msg string, keysAndValues []interface{}) {
+	p := getFields()
+	defer putFields(p)
+
+	fields, dangling, invalid := sweetenFields(*p, l.fields)
+	fields, dangling2, invalid2 := sweetenFields(fields, keysAndValues)
+	*p = fields
+
+	// the caller of the exported method is 2 frames above log.
+	base, skip, caller := l.sugar().Desugar(), l.opt.callerSkip+2+l.callDepth, !l.opt.noCaller
+	if dangling2 != nil {
+		dangling = dangling2
+	}
+	if dangling != nil {
+		logFields(base, zapcore.ErrorLevel, oddNumberErrMsg, []zapcore.Field{zap.Any("ignored", dangling)}, skip, caller)
+	}
+	if invalid = append(invalid, invalid2...); len(invalid) > 0 {
+		logFields(base, zapcore.ErrorLevel, nonStringKeyErrMsg, []zapcore.Field{zap.Any("invalid", invalid)}, skip, caller)
+	}
+	logFields(base, lvl, msg, fields, skip, caller)
+}
+
+// formatMessage formats the message like zap's sugared logger: fmt.Sprint
+// without a template, fmt.Sprintf with one.
+func formatMessage(template string, args []interface{}) string {
+	if len(args) == 0 {
+		return template
+	}
+	if template != "" {
+		return fmt.Sprintf(template, args...)
+	}
+	if len(args) == 1 {
+		if str, ok := args[0].(string); ok {
+			return str
+		}
+	}
+	return fmt.Sprint(args...)
+}
+
+// limitFields keeps the first limit fields of keysAndValues and replaces the
+// rest with a count under truncatedFieldsKey. A zap.Field is one field, any
+// other element is a key followed by its value.
+func limitFields(keysAndValues []interface{}, limit int) []interface{} {
+	var i, n int
+	for ; i < len(keysAndValues) && n < limit; n++ {
+		i += fieldWidth(keysAndValues[i])
+	}
+	if i >= len(keysAndValues) {
+		return keysAndValues
+	}
+
+	var dropped int
+	for j := i; j < len(keysAndValues); dropped++ {
+		j += fieldWidth(keysAndValues[j])
+	}
+	return append(keysAndValues[:i:i], truncatedFieldsKey, dropped)
+}
+
+func fieldWidth(v interface{}) int {
+	if _, ok := v.(zapcore.Field); ok {
+		return 1
+	}
+	return 2
+}
+
+// Sync flushes the rolling files and the zap logger, returning all errors
+// joined. Errors from syncing stdout or stderr, which can't be synced on
+// most platforms, are ignored.
+func (l *Logging) Sync() error {
+	if l.lg == nil {
+		return nil
+	}
+
+	var errs []error
 	for _, w := range l._rollingFiles {
-		err = w.Sync()
+		if err := w.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := l.sugar().Sync(); err != nil {
+		errs = append(errs, err)
+	}
+	if l.tenants != nil {
+		errs = append(errs, l.tenants.sync())
+	}
+	return errors.Join(errs...)
+}
+
+// Close syncs l and closes its outputs: the rolling files, and the writer set
+// with WithWriter when it is an io.Closer, such as a file or a network
+// connection, so rebuilt loggers don't leak descriptors. The standard output
+// and error are left open. The network sinks, such as webhooks, stop and
+// send their queued entries a last time. It returns all errors joined, and
+// the same errors when called again. Close is a no-op on the loggers derived
+// from l, which share its outputs.
+func (l *Logging) Close() error {
+	return l.close(context.Background())
+}
+
+// Shutdown is Close bounding the last sends of the network sinks by ctx, so
+// an unreachable collector doesn't hang the exit. It gives up when ctx is
+// done, e.g. when a writer blocks on a network connection, Close then goes
+// on in the background.
+func (l *Logging) Shutdown(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- l.close(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Logging) close(ctx context.Context) error {
+	if l.closer == nil {
+		return l.Sync()
+	}
+
+	l.closer.once.Do(func() {
+		exitFlusher.remove(l)
+		panicFlusher.remove(l)
+
+		var errs []error
+		for _, s := range l.sinks {
+			errs = append(errs, s.shutdown(ctx))
+		}
+		errs = append(errs, l.Sync(), l.closeRotateLoggers())
+		if l.tenants != nil {
+			errs = append(errs, l.tenants.close())
+		}
+		if c, ok := l.opt.writer.(io.Closer); ok && !isStdStream(l.opt.writer) {
+			errs = append(errs, c.Close())
+		}
+		l.closer.err = errors.Join(errs...)
+	})
+	return l.closer.err
+}
+
+// isStdStream reports whether w is the standard output or error.
+func isStdStream(w io.Writer) bool {
+	switch v := w.(type) {
+	case *os.File:
+		return v == os.Stdout || v == os.Stderr
+	case WrappedWriteSyncer:
+		return isStdStream(v.file)
 	}
+	return false
+}
 
-	err = l.lg.Sync()
-	return
+// consoleSyncer is the standard output or error set with WithWriter. Syncing
+// a console or a pipe fails, e.g. "sync /dev/stdout: invalid argument", which
+// it ignores, while the other failures are returned.
+type consoleSyncer struct {
+	*os.File
+}
+
+func (c consoleSyncer) Sync() error {
+	err := c.File.Sync()
+	if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY) {
+		return nil
+	}
+	return err
 }
 
 // WithCallDepth returns a shallow copy of l with its caller skip
 func WithCallDepth(callDepth int) Logger {
-	return DefaultLogger.WithCallDepth(callDepth)
+	return Default().WithCallDepth(callDepth)
 }
 
 // WithContext returns a shallow copy of l with its context changed
 // to ctx. The provided ctx must be non-nil.
 func WithContext(ctx context.Context) Logger {
-	return DefaultLogger.WithContext(ctx)
+	return Default().WithContext(ctx)
 }
 
 // WithFields is a helper to create a []interface{} of key-value pairs.
 func WithFields(fields map[string]interface{}) Logger {
-	return DefaultLogger.WithFields(fields)
+	return Default().WithFields(fields)
 }
 
-// SetLevel set logger level
+// With returns the default logger adding args, alternating keys and values
+// or zap Fields, to every entry.
+func With(args ...any) Logger {
+	return Default().With(args...)
+}
+
+// SetLevel sets the global level of the default logger, see SetGlobalLevel.
 func SetLevel(lv Level) {
-	DefaultLogger.SetLevel(lv)
+	Default().SetGlobalLevel(lv)
 }
 
 func Debug(args ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Debug(args...)
+	skipped().Debug(args...)
 }
 
 func Info(args ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Info(args...)
+	skipped().Info(args...)
 }
 
 func Warn(args ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Warn(args...)
+	skipped().Warn(args...)
 }
 
 func Error(args ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Error(args...)
+	skipped().Error(args...)
 }
 
 func Fatal(args ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Fatal(args...)
+	skipped().Fatal(args...)
 }
 
 func Debugf(template string, args ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Debugf(template, args...)
+	skipped().Debugf(template, args...)
 }
 
 func Infof(template string, args ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Infof(template, args...)
+	skipped().Infof(template, args...)
 }
 
 func Warnf(template string, args ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Warnf(template, args...)
+	skipped().Warnf(template, args...)
 }
 
 func Errorf(template string, args ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Errorf(template, args...)
+	skipped().Errorf(template, args...)
 }
 
 func Fatalf(template string, args ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Fatalf(template, args...)
+	skipped().Fatalf(template, args...)
 }
 
 func Debugw(msg string, keysAndValues ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Debugw(msg, keysAndValues...)
+	skipped().Debugw(msg, keysAndValues...)
 }
 
 func Infow(msg string, keysAndValues ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Infow(msg, keysAndValues...)
+	skipped().Infow(msg, keysAndValues...)
 }
 
 func Warnw(msg string, keysAndValues ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Warnw(msg, keysAndValues...)
+	skipped().Warnw(msg, keysAndValues...)
 }
 
 func Errorw(msg string, keysAndValues ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Errorw(msg, keysAndValues...)
+	skipped().Errorw(msg, keysAndValues...)
 }
 
 func Fatalw(msg string, keysAndValues ...interface{}) {
-	DefaultLogger.WithCallDepth(callerSkipOffset).Fatalw(msg, keysAndValues...)
+	skipped().Fatalw(msg, keysAndValues...)
+}
+
+// V returns a logger for verbose entries of V-level n, see Logging.V.
+func V(n int) Logger {
+	return Default().V(n)
+}
+
+func Log(level Level, args ...interface{}) {
+	skipped().Log(level, args...)
+}
+
+func Logf(level Level, template string, args ...interface{}) {
+	skipped().Logf(level, template, args...)
+}
+
+func Logw(level Level, msg string, keysAndValues ...interface{}) {
+	skipped().Logw(level, msg, keysAndValues...)
 }
 
+// Sync flushes the default logger and the dedicated channels.
 func Sync() error {
-	return DefaultLogger.Sync()
+	return errors.Join(Default().Sync(), statChannel.sync(), slowChannel.sync(), severeChannel.sync(), registry.sync())
 }
@@ -1,17 +1,36 @@
 package logger_test
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/nextmicro/logger"
+	"github.com/nextmicro/logger/logtest"
+	"github.com/stretchr/testify/assert"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestMain(t *testing.M) {
@@ -56,6 +75,245 @@ func TestLogging_WithFields(t *testing.T) {
 	}).Info("TestDefault_WithFields")
 }
 
+func TestLogging_With(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf))
+	args := []any{"age", 22, zap.String("name", "alice")}
+	child := l.With(args...)
+	args[1] = 0
+	child.With("order", 100).Infow("TestDefault_With", "extra", true)
+	child.Info("parent")
+
+	dec := json.NewDecoder(&buf)
+	var entry map[string]any
+	assert.NoError(t, dec.Decode(&entry))
+	assert.EqualValues(t, 22, entry["age"])
+	assert.Equal(t, "alice", entry["name"])
+	assert.EqualValues(t, 100, entry["order"])
+	assert.Equal(t, true, entry["extra"])
+
+	entry = nil
+	assert.NoError(t, dec.Decode(&entry))
+	assert.NotContains(t, entry, "order")
+	assert.Equal(t, logger.Logger(l), l.With())
+}
+
+func TestFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf))
+	l.With(logger.String("user", "alice")).Infow("typed",
+		logger.Int("attempt", 2),
+		logger.Bool("cached", true),
+		logger.Duration("took", 1500*time.Millisecond),
+		logger.Err(errors.New("timeout")),
+		logger.Err(nil),
+		logger.Any("tags", []string{"a", "b"}),
+	)
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "alice", entry["user"])
+	assert.EqualValues(t, 2, entry["attempt"])
+	assert.Equal(t, true, entry["cached"])
+	assert.Equal(t, "1.5s", entry["took"])
+	assert.Equal(t, "timeout", entry["error"])
+	assert.Equal(t, []any{"a", "b"}, entry["tags"])
+}
+
+type userID int64
+
+type state string
+
+func TestAttr(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf))
+	l.Infow("generic",
+		logger.Attr("user", userID(42)),
+		logger.Attr("state", state("active")),
+		logger.Attr("took", 2*time.Second),
+		logger.Attr("err", errors.New("timeout")),
+		logger.Attr("ratio", 0.5),
+		logger.Slice("ids", []userID{1, 2}),
+		logger.Slice("errs", []error{errors.New("a")}),
+		logger.Slice("ratios", []float32{0.25}),
+	)
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.EqualValues(t, 42, entry["user"])
+	assert.Equal(t, "active", entry["state"])
+	assert.Equal(t, "2s", entry["took"])
+	assert.Equal(t, "timeout", entry["err"])
+	assert.Equal(t, 0.5, entry["ratio"])
+	assert.Equal(t, []any{1.0, 2.0}, entry["ids"])
+	assert.Equal(t, []any{"a"}, entry["errs"])
+	assert.Equal(t, []any{0.25}, entry["ratios"])
+}
+
+// recordCore is a CoreLogger with the Syncer capability.
+type recordCore struct {
+	entries []string
+	synced  bool
+}
+
+func (c *recordCore) Logw(level logger.Level, msg string, keysAndValues ...interface{}) {
+	c.entries = append(c.entries, fmt.Sprint(level, " ", msg, " ", keysAndValues))
+}
+
+func (c *recordCore) Sync() error {
+	c.synced = true
+	return nil
+}
+
+func TestFromCore(t *testing.T) {
+	core := &recordCore{}
+	l := logger.FromCore(core)
+	l.With("k", "v").Infof("hello %s", "world")
+	l.WithLevel(logger.WarnLevel).Info("dropped")
+	l.V(1).Warnw("verbose")
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	}))
+	l.WithContext(ctx).Error("traced")
+	l.SetLevel(logger.ErrorLevel)
+	assert.NoError(t, l.Sync())
+
+	assert.Equal(t, []string{
+		"INFO hello world [k v]",
+		"DEBUG verbose []",
+		"ERROR traced [span_id " + trace.SpanID{1}.String() + " trace_id " + trace.TraceID{1}.String() + "]",
+	}, core.entries)
+	assert.True(t, core.synced)
+	assert.Equal(t, 0, l.CallDepth())
+
+	full := logger.New(logger.WithWriter(io.Discard))
+	assert.Same(t, full, logger.FromCore(full))
+}
+
+func TestLogging_Namespace(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf), logger.WithLevel(logger.DebugLevel), logger.WithVerbosity(1))
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	}))
+	l.With("app", "test").Namespace("http").With("method", "GET").Namespace("req").
+		WithContext(ctx).V(1).Infow("served", "status", 200)
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "test", entry["app"])
+	assert.NotEmpty(t, entry["trace_id"])
+	assert.NotEmpty(t, entry["span_id"])
+	assert.EqualValues(t, 1, entry["v"])
+	assert.Equal(t, map[string]any{
+		"method": "GET",
+		"req":    map[string]any{"status": float64(200)},
+	}, entry["http"])
+}
+
+func TestLogging_WithTrace(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf))
+
+	l.WithTrace("4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7").Namespace("job").Info("cron")
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entry["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", entry["span_id"])
+	assert.Equal(t, logger.Logger(l), l.WithTrace("", ""))
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	}))
+	buf.Reset()
+	l.WithoutTrace().With("path", "/healthz").WithContext(ctx).Info("noisy")
+	entry = nil
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "/healthz", entry["path"])
+	assert.NotContains(t, entry, "trace_id")
+	assert.NotContains(t, entry, "span_id")
+}
+
+func TestWithCorrelationIDs(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf), logger.WithCorrelationIDs())
+
+	ctx := logger.ContextWithCorrelationID(context.Background())
+	id := logger.CorrelationID(ctx)
+	assert.Regexp(t, "^[0-9a-f]{32}$", id)
+	assert.Equal(t, ctx, logger.ContextWithCorrelationID(ctx))
+
+	l.WithContext(ctx).Info("first")
+	l.WithContext(ctx).Info("second")
+	dec := json.NewDecoder(&buf)
+	for i := 0; i < 2; i++ {
+		var entry map[string]any
+		assert.NoError(t, dec.Decode(&entry))
+		assert.Equal(t, id, entry["trace_id"])
+	}
+
+	// without an ID in the context, the logger gets its own.
+	buf.Reset()
+	l.WithContext(context.Background()).Info("generated")
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Regexp(t, "^[0-9a-f]{32}$", entry["trace_id"])
+	assert.NotEqual(t, id, entry["trace_id"])
+
+	// spans win over correlation IDs.
+	traced := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	}))
+	assert.Equal(t, traced, logger.ContextWithCorrelationID(traced))
+
+	// the option is off by default.
+	buf.Reset()
+	logger.New(logger.WithWriter(&buf)).WithContext(ctx).Info("plain")
+	assert.NotContains(t, buf.String(), "trace_id")
+}
+
+func TestPushFields(t *testing.T) {
+	var buf bytes.Buffer
+	logtest.SwapForTest(t, logger.New(logger.WithWriter(&buf)))
+
+	ctx := logger.PushFields(context.Background(), "request_id", "r-1")
+	child := logger.PushFields(ctx, zap.String("user", "alice"))
+	assert.Equal(t, ctx, logger.PushFields(ctx))
+	assert.Len(t, logger.FieldsFromContext(ctx), 2)
+
+	logger.WithContext(child).Info("handled")
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "r-1", entry["request_id"])
+	assert.Equal(t, "alice", entry["user"])
+
+	buf.Reset()
+	logger.WithContext(ctx).Info("parent")
+	assert.NotContains(t, buf.String(), "alice")
+	assert.Contains(t, buf.String(), "r-1")
+}
+
+func TestWithSortedFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(
+		logger.WithWriter(&buf),
+		logger.WithSortedFields(),
+		logger.Fields(map[string]any{"c": 3, "a": 1, "b": 2}),
+	)
+	for i := 0; i < 10; i++ {
+		buf.Reset()
+		l.WithFields(map[string]any{"z": 26, "x": 24, "y": 25, "w": 23}).With("e", 5, "d", 4).Info("sorted")
+		assert.Contains(t, buf.String(), `"a":1,"b":2,"c":3,"w":23,"x":24,"y":25,"z":26,"e":5,"d":4}`)
+	}
+	assert.Equal(t, []any{"a", 1, "b", 2}, logger.SortedFields(map[string]any{"b": 2, "a": 1}))
+}
+
 func TestLogging_Debug(t *testing.T) {
 	logging := logger.New()
 	logging.WithFields(map[string]interface{}{
@@ -143,8 +401,10 @@ func TestLoggerWithFields(t *testing.T) {
 }
 
 func TestFilename(t *testing.T) {
+	old := logger.DefaultLogger
 	logger.DefaultLogger = logger.New(
 		logger.WithLevel(logger.InfoLevel),
+		logger.WithPath(t.TempDir()),
 		logger.WithFilename("stat.log"),
 		logger.WithRotation("hour"),
 		logger.WithNamespace("metrics"),
@@ -162,20 +422,25 @@ func TestFilename(t *testing.T) {
 		}).Info("test msg")
 	}
 
-	logger.Sync()
+	assert.NoError(t, logger.DefaultLogger.(*logger.Logging).Close())
+	logger.DefaultLogger = old
 }
 
 func TestLogs(t *testing.T) {
+	old := logger.DefaultLogger
 	logger.DefaultLogger = logger.New(
 		logger.WithLevel(logger.InfoLevel),
 		logger.WithMode(logger.FileMode),
+		logger.WithPath(t.TempDir()),
 		logger.WithMaxSize(1),
 		logger.WithMaxBackups(1),
 		logger.WithCompress(false),
 		logger.WithRotation("size"),
 	)
-
-	defer logger.Sync()
+	defer func() {
+		assert.NoError(t, logger.DefaultLogger.(*logger.Logging).Close())
+		logger.DefaultLogger = old
+	}()
 
 	for i := 0; i < 10000; i++ {
 		logger.Debug("test msg")
@@ -185,6 +450,206 @@ func TestLogs(t *testing.T) {
 	}
 }
 
+func TestLogsPerLevelFiles(t *testing.T) {
+	dir := t.TempDir()
+	l := logger.New(
+		logger.WithLevel(logger.DebugLevel),
+		logger.WithMode(logger.FileMode),
+		logger.WithPath(dir),
+		logger.WithEncoder(logger.JsonEncoder),
+		logger.Fields(map[string]any{"app": "test"}),
+	)
+
+	l.Debug("debug msg")
+	l.Info("info msg")
+	l.Warn("warn msg")
+	l.Error("error msg")
+	assert.NoError(t, l.Sync())
+
+	for file, msg := range map[string]string{
+		"debug.log": "debug msg",
+		"info.log":  "info msg",
+		"warn.log":  "warn msg",
+		"error.log": "error msg",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		assert.Len(t, lines, 1, file)
+		assert.Contains(t, lines[0], `"msg":"`+msg+`"`)
+		assert.Contains(t, lines[0], `"app":"test"`)
+	}
+}
+
+func TestFileStatus(t *testing.T) {
+	dir := t.TempDir()
+	l := logger.New(
+		logger.WithMode(logger.FileMode),
+		logger.WithPath(dir),
+		logger.WithFilename("app.log"),
+	)
+
+	l.Info("info msg")
+	assert.NoError(t, l.Sync())
+
+	statuses := l.FileStatus()
+	assert.Len(t, statuses, 1)
+	assert.Equal(t, filepath.Join(dir, "app.log"), statuses[0].Filename)
+	assert.Greater(t, statuses[0].Size, int64(0))
+	assert.Zero(t, statuses[0].Backups)
+
+	// the derived loggers report the files of l.
+	derived := l.With("k", "v").WithFields(map[string]any{"a": 1}).(*logger.Logging).WithLevel(logger.WarnLevel).(*logger.Logging)
+	assert.Equal(t, statuses[0].Filename, derived.FileStatus()[0].Filename)
+	assert.NoError(t, derived.Healthy())
+	backups, err := derived.Backups()
+	assert.NoError(t, err)
+	assert.Empty(t, backups)
+	assert.NoError(t, l.Close())
+	assert.ErrorIs(t, derived.Healthy(), logger.ErrClosedRollingFile)
+
+	assert.Empty(t, logger.New(logger.WithMode(logger.ConsoleMode)).FileStatus())
+}
+
+func TestBackups(t *testing.T) {
+	dir := t.TempDir()
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	files := map[string]string{
+		"app.log-2020-01-01.gz":        "archived",
+		"app.log-2020-01-01.gz.sha256": "sidecar",
+		"app.log-" + yesterday:         "pending",
+		"app.log-access-2020-01-01":    "another log",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+	l := logger.New(
+		logger.WithMode(logger.FileMode),
+		logger.WithPath(dir),
+		logger.WithFilename("app.log"),
+	)
+	defer l.Sync()
+
+	backups, err := l.Backups()
+	assert.NoError(t, err)
+	assert.Len(t, backups, 2)
+	assert.Equal(t, filepath.Join(dir, "app.log-2020-01-01.gz"), backups[0].Name)
+	assert.Equal(t, int64(len("archived")), backups[0].Size)
+	assert.Equal(t, 2020, backups[0].Time.Year())
+	assert.True(t, backups[0].Compressed)
+	assert.Equal(t, filepath.Join(dir, "app.log-"+yesterday), backups[1].Name)
+	assert.False(t, backups[1].Compressed)
+
+	assert.NoError(t, l.PurgeBackups(time.Now().AddDate(0, 0, -2)))
+	backups, err = l.Backups()
+	assert.NoError(t, err)
+	assert.Len(t, backups, 1)
+	assert.Equal(t, filepath.Join(dir, "app.log-"+yesterday), backups[0].Name)
+	for name, exists := range map[string]bool{"app.log-2020-01-01.gz.sha256": false, "app.log-access-2020-01-01": true} {
+		_, err = os.Stat(filepath.Join(dir, name))
+		assert.Equal(t, exists, err == nil, name)
+	}
+}
+
+// closeWriter is a writer recording its Close calls.
+type closeWriter struct {
+	bytes.Buffer
+	closes  int
+	err     error
+	release chan struct{}
+}
+
+func (w *closeWriter) Close() error {
+	if w.release != nil {
+		<-w.release
+	}
+	w.closes++
+	return w.err
+}
+
+func TestClose(t *testing.T) {
+	w := &closeWriter{err: errors.New("connection reset")}
+	l := logger.New(logger.WithWriter(w))
+	l.Info("hello")
+
+	// the loggers derived from l share its writer, they leave it open.
+	assert.NoError(t, l.With("k", "v").(*logger.Logging).Close())
+	assert.Equal(t, 0, w.closes)
+
+	assert.ErrorIs(t, l.Close(), w.err)
+	assert.ErrorIs(t, l.Close(), w.err)
+	assert.Equal(t, 1, w.closes)
+	assert.Contains(t, w.String(), "hello")
+
+	w = &closeWriter{release: make(chan struct{})}
+	defer close(w.release)
+	l = logger.New(logger.WithWriter(w))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.Shutdown(ctx), context.DeadlineExceeded)
+}
+
+func TestWithTenant(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf))
+	l.WithContext(logger.WithTenant(context.Background(), "acme")).Info("tagged")
+	assert.Contains(t, buf.String(), `"tenant_id":"acme"`)
+	assert.Equal(t, "acme", logger.TenantFromContext(logger.WithTenant(context.Background(), "acme")))
+
+	dir := t.TempDir()
+	l = logger.New(
+		logger.WithMode(logger.FileMode),
+		logger.WithPath(dir),
+		logger.WithFilename("app.log"),
+		logger.WithTenantRouting(),
+	)
+	l.Info("shared")
+	l.WithContext(logger.WithTenant(context.Background(), "acme")).Info("acme only")
+	l.WithContext(logger.WithTenant(context.Background(), "../evil")).Info("contained")
+	assert.NoError(t, l.Close())
+
+	shared, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(shared), "shared")
+	assert.NotContains(t, string(shared), "acme only")
+	acme, err := os.ReadFile(filepath.Join(dir, "acme", "app.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(acme), "acme only")
+	assert.Contains(t, string(acme), `"tenant_id":"acme"`)
+	_, err = os.Stat(filepath.Join(dir, "..%2Fevil", "app.log"))
+	assert.NoError(t, err)
+
+	_, err = logger.NewWithError(logger.WithTenantRouting())
+	assert.ErrorIs(t, err, logger.ErrInvalidOptions)
+}
+
+func TestHealthy(t *testing.T) {
+	l := logger.New(
+		logger.WithMode(logger.FileMode),
+		logger.WithPath(t.TempDir()),
+	)
+
+	l.Info("info msg")
+	assert.NoError(t, l.Sync())
+	assert.NoError(t, l.Healthy())
+	assert.NoError(t, logger.Healthy())
+}
+
+func TestFilenamePlaceholders(t *testing.T) {
+	dir := t.TempDir()
+	l := logger.New(
+		logger.WithMode(logger.FileMode),
+		logger.WithPath(filepath.Join(dir, "{hostname}")),
+		logger.WithFilename("app-{pid}.log"),
+	)
+	l.Info("test msg")
+	assert.NoError(t, l.Sync())
+
+	hostname, _ := os.Hostname()
+	_, err := os.Stat(filepath.Join(dir, hostname, fmt.Sprintf("app-%d.log", os.Getpid())))
+	assert.NoError(t, err)
+}
+
 type CustomOutput struct {
 }
 
@@ -216,3 +681,1023 @@ func TestCustomOutput(t *testing.T) {
 func TestInfo(t *testing.T) {
 	logger.Info("test msg")
 }
+
+func TestWithHostFields(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.New(
+		logger.WithWriter(&buf),
+		logger.WithHostFields(),
+		logger.WithServiceInfo("order", "v1.0.0", "prod"),
+	)
+	log.Info("host fields")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unmarshal entry: %v", err)
+	}
+	hostname, _ := os.Hostname()
+	assert.Equal(t, hostname, entry["hostname"])
+	assert.Equal(t, float64(os.Getpid()), entry["pid"])
+	assert.Equal(t, "order", entry["service.name"])
+	assert.Equal(t, "v1.0.0", entry["service.version"])
+	assert.Equal(t, "prod", entry["service.environment"])
+}
+
+func TestWithBuildInfo(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.New(logger.WithWriter(&buf), logger.WithBuildInfo())
+	log.Info("build info")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unmarshal entry: %v", err)
+	}
+	assert.Equal(t, runtime.Version(), entry["go.version"])
+}
+
+func TestNewAudit(t *testing.T) {
+	dir := t.TempDir()
+	audit := logger.NewAudit(logger.WithPath(dir))
+	for i := 0; i < 10; i++ {
+		audit.Infow("user updated", "user_id", i)
+	}
+	// an entry spanning several blocks, read backwards on restart.
+	audit.Infow("user imported", "profile", strings.Repeat("x", 10000))
+	head := audit.AuditHead()
+	assert.NoError(t, audit.Close())
+
+	// the chain continues after a restart.
+	audit = logger.NewAudit(logger.WithPath(dir))
+	audit.Infow("user deleted", "user_id", 1)
+	assert.NoError(t, audit.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "audit.log"))
+	assert.NoError(t, err)
+	got, err := logger.VerifyAuditChain(bytes.NewReader(data), "")
+	assert.NoError(t, err)
+	assert.Equal(t, audit.AuditHead(), got)
+	assert.NotEqual(t, head, got)
+
+	tampered := bytes.Replace(data, []byte(`"user_id":3`), []byte(`"user_id":4`), 1)
+	_, err = logger.VerifyAuditChain(bytes.NewReader(tampered), "")
+	assert.ErrorIs(t, err, logger.ErrAuditChainBroken)
+
+	// the first entry is checked against the head of the previous file, and
+	// the chain can't be truncated at its start.
+	_, err = logger.VerifyAuditChain(bytes.NewReader(data), head)
+	assert.ErrorIs(t, err, logger.ErrAuditChainBroken)
+	_, err = logger.VerifyAuditChain(bytes.NewReader(data[bytes.IndexByte(data, '\n')+1:]), "")
+	assert.ErrorIs(t, err, logger.ErrAuditChainBroken)
+}
+
+func TestAuditNamespace(t *testing.T) {
+	dir := t.TempDir()
+	audit := logger.NewAudit(logger.WithPath(dir), logger.WithNamespace("app"))
+	audit.With("tenant", "acme").Infow("user updated", "user_id", 1)
+	audit.Infow("user deleted", "user_id", 2)
+	assert.NoError(t, audit.Close())
+
+	// prev_hash stays at the top level, out of the namespace.
+	data, err := os.ReadFile(filepath.Join(dir, "audit.log"))
+	assert.NoError(t, err)
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(data[:bytes.IndexByte(data, '\n')], &entry))
+	assert.Equal(t, "", entry["prev_hash"])
+	assert.Equal(t, map[string]any{"tenant": "acme", "user_id": float64(1)}, entry["app"])
+
+	got, err := logger.VerifyAuditChain(bytes.NewReader(data), "")
+	assert.NoError(t, err)
+	assert.Equal(t, audit.AuditHead(), got)
+}
+
+func TestAuditRestartAfterRotation(t *testing.T) {
+	dir := t.TempDir()
+	audit := logger.NewAudit(logger.WithPath(dir))
+	audit.Infow("user updated", "user_id", 1)
+	head := audit.AuditHead()
+	assert.NoError(t, audit.Close())
+
+	// audit.log was rotated and archived before the restart.
+	data, err := os.ReadFile(filepath.Join(dir, "audit.log"))
+	assert.NoError(t, err)
+	var archive bytes.Buffer
+	zw := gzip.NewWriter(&archive)
+	_, _ = zw.Write(data)
+	assert.NoError(t, zw.Close())
+	backup := filepath.Join(dir, "audit.log-"+time.Now().AddDate(0, 0, -1).Format("2006-01-02")+".gz")
+	assert.NoError(t, os.WriteFile(backup, archive.Bytes(), 0o644))
+	assert.NoError(t, os.Truncate(filepath.Join(dir, "audit.log"), 0))
+
+	audit = logger.NewAudit(logger.WithPath(dir))
+	audit.Infow("user deleted", "user_id", 1)
+	assert.NoError(t, audit.Close())
+
+	data, err = os.ReadFile(filepath.Join(dir, "audit.log"))
+	assert.NoError(t, err)
+	got, err := logger.VerifyAuditChain(bytes.NewReader(data), head)
+	assert.NoError(t, err)
+	assert.Equal(t, audit.AuditHead(), got)
+}
+
+func TestAuditWriter(t *testing.T) {
+	var buf bytes.Buffer
+	audit := logger.NewAudit(logger.WithWriter(&buf))
+	audit.Infow("user updated", "user_id", 1)
+	head := audit.AuditHead()
+	assert.NotEmpty(t, head)
+
+	// the chain continues from the anchored head.
+	var next bytes.Buffer
+	audit = logger.NewAudit(logger.WithWriter(&next), logger.WithAuditHead(head))
+	audit.Infow("user deleted", "user_id", 1)
+
+	got, err := logger.VerifyAuditChain(bytes.NewReader(next.Bytes()), head)
+	assert.NoError(t, err)
+	assert.Equal(t, audit.AuditHead(), got)
+}
+
+func TestStat(t *testing.T) {
+	var buf closeWriter
+	logger.SetStatLogger(logger.New(logger.WithWriter(&buf)))
+	t.Cleanup(func() { logger.SetStatLogger(nil) })
+
+	logger.Stat("cpu usage", "cpu", 80)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unmarshal entry: %v", err)
+	}
+	assert.Equal(t, "cpu usage", entry["msg"])
+	assert.Equal(t, float64(80), entry["cpu"])
+	assert.Contains(t, entry["caller"], "logging_test.go")
+
+	// the replaced stat logger is left to its owner.
+	var built closeWriter
+	logger.InitStat(logger.WithWriter(&built))
+	assert.Equal(t, 0, buf.closes)
+
+	// the stat logger built by InitStat is closed once replaced.
+	logger.SetStatLogger(logger.New(logger.WithWriter(io.Discard)))
+	assert.Equal(t, 1, built.closes)
+}
+
+func TestTiming(t *testing.T) {
+	var buf bytes.Buffer
+	logger.SetSlowLogger(logger.New(logger.WithWriter(&buf)))
+	logger.SetSlowThreshold(time.Second)
+	t.Cleanup(func() {
+		logger.SetSlowThreshold(500 * time.Millisecond)
+		logger.SetSlowLogger(nil)
+	})
+
+	logger.Timing(context.Background(), "fast query", time.Now())
+	assert.Empty(t, buf.String())
+
+	logger.Timing(context.Background(), "slow query", time.Now().Add(-2*time.Second))
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unmarshal entry: %v", err)
+	}
+	assert.Equal(t, "slow query", entry["msg"])
+	assert.Equal(t, "warn", entry["level"])
+	assert.Contains(t, entry["caller"], "logging_test.go")
+}
+
+func TestSevere(t *testing.T) {
+	var (
+		buf    bytes.Buffer
+		called string
+	)
+	logger.SetSevereLogger(logger.New(logger.WithWriter(&buf)))
+	logger.SetSevereCallback(func(msg string, keysAndValues ...interface{}) {
+		called = msg
+	})
+	t.Cleanup(func() {
+		logger.SetSevereCallback(nil)
+		logger.SetSevereLogger(nil)
+	})
+
+	logger.Severef("disk %s is full", "/data")
+	assert.Equal(t, "disk /data is full", called)
+	assert.Contains(t, buf.String(), `"msg":"disk /data is full"`)
+}
+
+func TestRegistry(t *testing.T) {
+	var api, worker bytes.Buffer
+	logger.Register("api", logger.WithWriter(&api))
+	logger.Register("worker", logger.WithWriter(&worker), logger.WithLevel(logger.WarnLevel))
+
+	logger.Get("api").Info("api started")
+	logger.Get("worker").Info("worker started")
+	logger.Get("worker").Warn("worker lagging")
+
+	assert.Contains(t, api.String(), `"Logger":"api"`)
+	assert.NotContains(t, worker.String(), "worker started")
+	assert.Contains(t, worker.String(), "worker lagging")
+	assert.NotNil(t, logger.Get("cron"))
+
+	var replaced closeWriter
+	logger.Register("cron", logger.WithWriter(&replaced))
+	logger.Register("cron", logger.WithWriter(io.Discard))
+	assert.Equal(t, 1, replaced.closes)
+}
+
+func TestPackageHelpers(t *testing.T) {
+	var first, second bytes.Buffer
+	old := logger.Default()
+	defer logger.SetDefault(old)
+
+	logger.SetDefault(logger.New(logger.WithWriter(&first)))
+	logger.Infow("first", "n", 1)
+	logger.Warn("first warning")
+
+	var entry map[string]any
+	dec := json.NewDecoder(&first)
+	assert.NoError(t, dec.Decode(&entry))
+	assert.Equal(t, "first", entry["msg"])
+	assert.Contains(t, entry["caller"], "logging_test.go")
+	assert.NoError(t, dec.Decode(&entry))
+	assert.Contains(t, entry["caller"], "logging_test.go")
+
+	// the helpers follow the default logger when it's replaced.
+	logger.SetDefault(logger.New(logger.WithWriter(&second)))
+	logger.Errorf("second %d", 2)
+	assert.Zero(t, first.Len())
+	assert.NoError(t, json.Unmarshal(second.Bytes(), &entry))
+	assert.Equal(t, "second 2", entry["msg"])
+	assert.Contains(t, entry["caller"], "logging_test.go")
+}
+
+func TestSetDefault(t *testing.T) {
+	old := logger.Default()
+	defer logger.SetDefault(old)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Infow("concurrent", "j", j)
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		logger.SetDefault(logger.New(logger.WithWriter(io.Discard)))
+	}
+	wg.Wait()
+
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf))
+	logger.SetDefault(l)
+	assert.Equal(t, logger.Logger(l), logger.Default())
+	logger.Info("after swap")
+	assert.Contains(t, buf.String(), "after swap")
+
+	// a logger assigned to the deprecated DefaultLogger still takes effect.
+	buf.Reset()
+	prev := logger.DefaultLogger
+	defer func() { logger.DefaultLogger = prev }()
+	assigned := logger.New(logger.WithWriter(&buf))
+	logger.DefaultLogger = assigned
+	assert.Equal(t, logger.Logger(assigned), logger.Default())
+
+	// SetDefault replaces it again, without updating the write-only var.
+	logger.SetDefault(l)
+	assert.Equal(t, logger.Logger(l), logger.Default())
+	assert.Equal(t, logger.Logger(assigned), logger.DefaultLogger)
+}
+
+func TestSwapDefault(t *testing.T) {
+	old := logger.Default()
+	defer logger.SetDefault(old)
+
+	// every swapped in logger is returned once, by the swap replacing it.
+	const n = 100
+	loggers := make([]logger.Logger, n)
+	replaced := make(chan logger.Logger, n)
+	var wg sync.WaitGroup
+	for i := range loggers {
+		loggers[i] = logger.New(logger.WithWriter(io.Discard))
+		wg.Add(1)
+		go func(l logger.Logger) {
+			defer wg.Done()
+			replaced <- logger.SwapDefault(l)
+		}(loggers[i])
+	}
+	wg.Wait()
+	close(replaced)
+
+	seen := map[logger.Logger]bool{logger.Default(): true}
+	for l := range replaced {
+		assert.False(t, seen[l])
+		seen[l] = true
+	}
+	assert.True(t, seen[old])
+	for _, l := range loggers {
+		assert.True(t, seen[l])
+	}
+}
+
+func TestSetLevelRules(t *testing.T) {
+	var buf bytes.Buffer
+	old := logger.DefaultLogger
+	logger.DefaultLogger = logger.New(logger.WithWriter(&buf))
+	defer func() {
+		logger.DefaultLogger = old
+		_ = logger.SetLevelRules("")
+	}()
+
+	assert.NoError(t, logger.SetLevelRules("github.com/acme/svc/db/*=debug, *=warn"))
+	logger.Get("github.com/acme/svc/db/users").Debug("db debug")
+	logger.Get("github.com/acme/svc/http").Info("http info")
+	logger.DefaultLogger.Info("default info")
+
+	assert.Contains(t, buf.String(), "db debug")
+	assert.NotContains(t, buf.String(), "http info")
+	assert.Contains(t, buf.String(), "default info")
+
+	_, err := logger.ParseLevelRules("*=verbose")
+	assert.Error(t, err)
+	_, err = logger.ParseLevelRules("debug")
+	assert.Error(t, err)
+}
+
+func TestNewWithError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(file, nil, 0o600))
+
+	l, err := logger.NewWithError(logger.WithMode(logger.FileMode), logger.WithPath(file))
+	assert.Error(t, err)
+	assert.Nil(t, l)
+
+	assert.Panics(t, func() {
+		logger.New(logger.WithMode(logger.FileMode), logger.WithPath(file), logger.WithFilename("app.log"))
+	})
+
+	l, err = logger.NewWithError(logger.WithMode(logger.FileMode), logger.WithPath(t.TempDir()))
+	assert.NoError(t, err)
+	assert.NoError(t, l.Sync())
+}
+
+func TestNewWithErrorInvalidOptions(t *testing.T) {
+	_, err := logger.NewWithError(
+		logger.WithMode(logger.FileMode),
+		logger.WithPath(""),
+		logger.WithRotation("size"),
+		logger.WithWriter(io.Discard),
+		logger.WithFilename("app.log"),
+	)
+	assert.ErrorIs(t, err, logger.ErrInvalidOptions)
+	assert.Contains(t, err.Error(), "size rotation requires a positive max size")
+	assert.Contains(t, err.Error(), "writer and filename are mutually exclusive")
+
+	_, err = logger.NewWithError(logger.WithMode(logger.FileMode), logger.WithPath(""))
+	assert.ErrorIs(t, err, logger.ErrInvalidOptions)
+	assert.Contains(t, err.Error(), "log path must be set")
+
+	_, err = logger.NewWithError(logger.WithEncoder("xml"))
+	assert.ErrorIs(t, err, logger.ErrInvalidOptions)
+
+	_, err = logger.NewWithError(logger.WithArchiveEncryption([]byte("short")))
+	assert.ErrorIs(t, err, logger.ErrInvalidOptions)
+	assert.Contains(t, err.Error(), "archive encryption key must be 16, 24 or 32 bytes")
+}
+
+type failingSyncer struct {
+	err error
+}
+
+func (f failingSyncer) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (f failingSyncer) Sync() error {
+	return f.err
+}
+
+func TestLogging_SyncErrors(t *testing.T) {
+	stdout := logger.New(logger.WithWriter(os.Stdout))
+	assert.NoError(t, stdout.Sync())
+
+	errSync := errors.New("disk failure")
+	failing := logger.New(logger.WithWriter(failingSyncer{err: errSync}))
+	assert.ErrorIs(t, failing.Sync(), errSync)
+
+	// only the console handles can't be synced, a file failing is reported.
+	errFile := &os.PathError{Op: "sync", Path: filepath.Join(t.TempDir(), "app.log"), Err: syscall.EINVAL}
+	file := logger.New(logger.WithWriter(failingSyncer{err: errFile}))
+	assert.ErrorIs(t, file.Sync(), syscall.EINVAL)
+}
+
+func TestWithMaxFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf), logger.WithMaxFields(3))
+
+	headers := make(map[string]any)
+	for i := 0; i < 100; i++ {
+		headers[fmt.Sprintf("h%d", i)] = i
+	}
+	l.WithFields(headers).Infow("request", "path", "/")
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.EqualValues(t, 98, entry["_truncated_fields"])
+	assert.Len(t, entry, 3+5) // level, ts, caller, msg and the count
+
+	buf.Reset()
+	l.Infow("request", "path", "/", "status", 200)
+	assert.NotContains(t, buf.String(), "_truncated_fields")
+}
+
+func TestLevelText(t *testing.T) {
+	for _, lv := range []logger.Level{logger.DebugLevel, logger.InfoLevel, logger.WarnLevel, logger.ErrorLevel, logger.FatalLevel} {
+		text, err := lv.MarshalText()
+		assert.NoError(t, err)
+
+		assert.Equal(t, strings.ToLower(lv.String()), string(text))
+
+		var got logger.Level
+		assert.NoError(t, got.UnmarshalText(text))
+		assert.Equal(t, lv, got)
+	}
+
+	// the zero Level has a text too, which reads back.
+	var unset logger.Level
+	text, err := unset.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "unset", string(text))
+	assert.NoError(t, unset.UnmarshalText(text))
+	assert.Equal(t, logger.Level(0), unset)
+	_, err = logger.Level(9).MarshalText()
+	assert.Error(t, err)
+
+	var cfg struct {
+		Level logger.Level `json:"level"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"level":"WARN"}`), &cfg))
+	assert.Equal(t, logger.Level(logger.WarnLevel), cfg.Level)
+	assert.Error(t, json.Unmarshal([]byte(`{"level":"verbose"}`), &cfg))
+
+	var lv logger.Level
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&lv, "level", "logging level")
+	assert.NoError(t, fs.Parse([]string{"-level", "error"}))
+	assert.Equal(t, logger.Level(logger.ErrorLevel), lv)
+	assert.Equal(t, "level", lv.Type())
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf), logger.WithLevel(logger.InfoLevel))
+
+	l.Log(logger.DebugLevel, "dropped")
+	assert.Zero(t, buf.Len())
+
+	for _, lv := range []logger.Level{logger.InfoLevel, logger.WarnLevel, logger.ErrorLevel} {
+		buf.Reset()
+		l.Logw(lv, "served", "status", 200)
+
+		var entry map[string]any
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, strings.ToLower(lv.String()), entry["level"])
+		assert.EqualValues(t, 200, entry["status"])
+		assert.Contains(t, entry["caller"], "logging_test.go")
+	}
+
+	buf.Reset()
+	l.Logf(logger.WarnLevel, "served %d", 200)
+	assert.Contains(t, buf.String(), `"msg":"served 200"`)
+}
+
+func TestV(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf), logger.WithLevel(logger.DebugLevel), logger.WithVerbosity(2))
+
+	l.V(3).Info("too verbose")
+	assert.Zero(t, buf.Len())
+
+	l.V(2).Info("verbose")
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "debug", entry["level"])
+	assert.EqualValues(t, 2, entry["v"])
+	assert.Contains(t, entry["caller"], "logging_test.go")
+
+	buf.Reset()
+	l.V(1).Error("failed")
+	assert.Contains(t, buf.String(), `"level":"error"`)
+
+	buf.Reset()
+	l.SetLevel(logger.InfoLevel)
+	l.V(1).Info("verbose")
+	assert.Zero(t, buf.Len())
+}
+
+func TestWithDebugCapture(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf), logger.WithLevel(logger.InfoLevel))
+
+	ok := logger.WithDebugCapture(context.Background(), 2)
+	l.WithContext(ok).Debug("discarded")
+	l.WithContext(ok).Info("served")
+	assert.NotContains(t, buf.String(), "discarded")
+	assert.Contains(t, buf.String(), "served")
+
+	buf.Reset()
+	failed := logger.WithDebugCapture(context.Background(), 2)
+	rl := l.WithContext(failed)
+	rl.Debug("step 1")
+	rl.WithFields(map[string]any{"step": 2}).Debug("step 2")
+	rl.Debug("step 3")
+	assert.Zero(t, buf.Len())
+
+	rl.Error("failed")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"msg":"step 2"`)
+	assert.Contains(t, lines[0], `"step":2`)
+	assert.Contains(t, lines[0], `"level":"debug"`)
+	assert.Contains(t, lines[0], "logging_test.go")
+	assert.Contains(t, lines[1], `"msg":"step 3"`)
+	assert.Contains(t, lines[2], `"msg":"failed"`)
+
+	buf.Reset()
+	rl.Error("failed again")
+	assert.Equal(t, 1, strings.Count(buf.String(), "\n"))
+}
+
+func TestDebugCaptureSinkLevels(t *testing.T) {
+	bodies := make(chan []byte, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer srv.Close()
+	alerts := make(chan []logger.Entry, 1)
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf), logger.WithLevel(logger.InfoLevel),
+		logger.WithWebhook(srv.URL, logger.FatalLevel, logger.WithWebhookRateLimit(0)),
+		logger.WithErrorAlert(2, time.Minute, func(sample []logger.Entry) { alerts <- sample }))
+
+	rl := l.WithContext(logger.WithDebugCapture(context.Background(), 2))
+	rl.Debug("step 1")
+	rl.Debug("step 2")
+	rl.Error("failed")
+	assert.NoError(t, l.Sync())
+
+	// the captured entries reach the writer, but neither the webhook nor the
+	// alert, whose levels are above them.
+	assert.Equal(t, 3, strings.Count(buf.String(), "\n"))
+	select {
+	case b := <-bodies:
+		t.Fatalf("entries below the webhook level posted: %s", b)
+	case sample := <-alerts:
+		t.Fatalf("alert on entries below the error level: %v", sample)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestFlightRecorder(t *testing.T) {
+	logger.EnableFlightRecorder(3)
+	t.Cleanup(func() { logger.EnableFlightRecorder(0) })
+
+	l := logger.New(logger.WithWriter(io.Discard), logger.WithLevel(logger.InfoLevel))
+	l.Info("first")
+	l.Debug("second")
+	l.WithFields(map[string]any{"user_id": 42}).Infow("third", "path", "/")
+	l.Warnf("fourth %d", 4)
+
+	var buf bytes.Buffer
+	assert.NoError(t, logger.DumpRecent(&buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"msg":"second"`)
+	assert.Contains(t, lines[0], `"level":"debug"`)
+	assert.Contains(t, lines[1], `"user_id":42`)
+	assert.Contains(t, lines[1], `"path":"/"`)
+	assert.Contains(t, lines[2], `"msg":"fourth 4"`)
+}
+
+func TestStreamHandler(t *testing.T) {
+	srv := httptest.NewServer(logger.StreamHandler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?level=warn&field=user_id:42")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	l := logger.New(logger.WithWriter(io.Discard))
+	events := make(chan string)
+	go func() {
+		r := bufio.NewReader(resp.Body)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(events)
+				return
+			}
+			if strings.HasPrefix(line, "data: ") {
+				events <- strings.TrimPrefix(strings.TrimSpace(line), "data: ")
+			}
+		}
+	}()
+
+	// the client subscribes once the headers are sent, keep logging until it does.
+	var event string
+	assert.Eventually(t, func() bool {
+		l.Info("info is filtered")
+		l.WithFields(map[string]any{"user_id": 7}).Warn("other user is filtered")
+		l.WithFields(map[string]any{"user_id": 42}).Warn("streamed")
+		select {
+		case event = <-events:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+	assert.Contains(t, event, `"msg":"streamed"`)
+	assert.Contains(t, event, `"user_id":42`)
+
+	resp, err = http.Get(srv.URL + "?level=verbose")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHumanValues(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf), logger.WithDurationMillis(), logger.WithHumanBytes("size"))
+	l.With("timeout", 2*time.Second).Infow("served", "latency", 1500*time.Microsecond, "size", 1258291, "count", 3)
+
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.Equal(t, "1.5ms", m["latency"])
+	assert.Equal(t, 1.5, m["latency_ms"])
+	assert.Equal(t, 2000.0, m["timeout_ms"])
+	assert.Equal(t, 1258291.0, m["size"])
+	assert.Equal(t, "1.2MB", m["size_human"])
+	assert.NotContains(t, m, "count_human")
+}
+
+func TestWithoutTimestampsAndCaller(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf), logger.WithTimestamps(false), logger.WithCaller(false))
+	l.Info("piped")
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, map[string]any{"level": "info", "msg": "piped"}, entry)
+
+	buf.Reset()
+	l = logger.New(logger.WithWriter(&buf), logger.WithEncoder(logger.ConsoleEncoder), logger.WithTimestamps(false))
+	l.Info("piped")
+	assert.True(t, strings.HasPrefix(buf.String(), "info\t"), buf.String())
+	assert.Contains(t, buf.String(), "logging_test.go")
+}
+
+func TestWithLevelFormat(t *testing.T) {
+	tests := []struct {
+		format logger.LevelFormat
+		want   []string
+	}{
+		{logger.LevelLowercase, []string{"info", "warn", "error"}},
+		{logger.LevelCapital, []string{"INFO", "WARN", "ERROR"}},
+		{logger.LevelShort, []string{"INF", "WRN", "ERR"}},
+		{logger.LevelCustom(map[logger.Level]string{logger.WarnLevel: "AVERTISSEMENT", logger.ErrorLevel: "ERREUR"}), []string{"info", "AVERTISSEMENT", "ERREUR"}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		l := logger.New(logger.WithWriter(&buf), logger.WithLevelFormat(tt.format))
+		l.Info("i")
+		l.Warn("w")
+		l.Error("e")
+
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var entry struct{ Level string }
+			assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+			got = append(got, entry.Level)
+		}
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestLogging_WithLevel(t *testing.T) {
+	var buf bytes.Buffer
+	parent := logger.New(logger.WithWriter(&buf), logger.WithLevel(logger.InfoLevel))
+	quiet := parent.WithLevel(logger.WarnLevel).With("component", "db")
+
+	quiet.Info("quieted")
+	quiet.Warn("warn")
+	parent.Info("parent info")
+	assert.NotContains(t, buf.String(), "quieted")
+	assert.Contains(t, buf.String(), `"msg":"warn"`)
+	assert.Contains(t, buf.String(), "parent info")
+
+	// the parent level still applies, and SetLevel changes the own level only.
+	quiet.SetLevel(logger.DebugLevel)
+	quiet.Debug("below the parent")
+	quiet.Info("own level lowered")
+	assert.NotContains(t, buf.String(), "below the parent")
+	assert.Contains(t, buf.String(), "own level lowered")
+	assert.Equal(t, logger.Level(logger.InfoLevel), quiet.(*logger.Logging).Level())
+
+	parent.SetLevel(logger.ErrorLevel)
+	quiet.Warn("parent raised")
+	assert.NotContains(t, buf.String(), "parent raised")
+	assert.Equal(t, logger.Level(logger.ErrorLevel), quiet.(*logger.Logging).Level())
+	assert.Equal(t, logger.Level(logger.ErrorLevel), parent.Level())
+}
+
+func TestLogging_SetLevelDerived(t *testing.T) {
+	var buf bytes.Buffer
+	root := logger.New(logger.WithWriter(&buf))
+	request := root.WithFields(map[string]any{"request_id": "r1"})
+	sibling := root.With("request_id", "r2")
+
+	request.SetLevel(logger.DebugLevel)
+	request.Debug("request debug")
+	root.Debug("root debug")
+	sibling.Debug("sibling debug")
+	assert.Contains(t, buf.String(), "request debug")
+	assert.NotContains(t, buf.String(), "root debug")
+	assert.NotContains(t, buf.String(), "sibling debug")
+	assert.Equal(t, logger.Level(logger.InfoLevel), root.Level())
+
+	// the global level reaches the loggers without a level of their own.
+	sibling.SetGlobalLevel(logger.WarnLevel)
+	root.Info("root info")
+	sibling.Info("sibling info")
+	request.Info("request info")
+	assert.NotContains(t, buf.String(), "root info")
+	assert.NotContains(t, buf.String(), "sibling info")
+	assert.Contains(t, buf.String(), "request info")
+	assert.Equal(t, logger.Level(logger.WarnLevel), root.Level())
+}
+
+func TestLogging_SetLevelDerivedConcurrent(t *testing.T) {
+	root := logger.New(logger.WithWriter(io.Discard), logger.WithLevel(logger.InfoLevel))
+	for name, derived := range map[string]logger.Logger{
+		"with":       root.With("k", "v"),
+		"fields":     root.WithFields(map[string]any{"k": "v"}),
+		"with level": root.WithLevel(logger.WarnLevel).WithContext(context.Background()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						derived.Info("request served")
+						_ = derived.WithFields(map[string]any{"n": j})
+					}
+				}()
+				go func(lv logger.Level) {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						derived.SetLevel(lv)
+					}
+				}(logger.Level(logger.DebugLevel + i%2))
+			}
+			wg.Wait()
+
+			derived.SetLevel(logger.ErrorLevel)
+			assert.Equal(t, logger.Level(logger.ErrorLevel), derived.(*logger.Logging).Level())
+			assert.Equal(t, logger.Level(logger.InfoLevel), root.Level())
+		})
+	}
+}
+
+// logVia logs msg through one more frame, as a wrapper library would.
+func logVia(l logger.Logger, msg string) {
+	l.Info(msg)
+}
+
+func TestLogging_CallDepth(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf))
+	caller := func() string {
+		var entry map[string]any
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		buf.Reset()
+		return entry["caller"].(string)
+	}
+
+	wrapped := l.WithCallDepth(1).WithCallDepthDelta(1)
+	assert.Equal(t, 2, wrapped.CallDepth())
+	undone := wrapped.WithCallDepthDelta(-1)
+	assert.Equal(t, 1, undone.CallDepth())
+	logVia(undone, "attributed to the caller of logVia")
+	_, file, line, _ := runtime.Caller(0)
+	assert.Equal(t, fmt.Sprintf("%s:%d", filepath.Base(file), line-1), filepath.Base(caller()))
+
+	direct := wrapped.WithCallDepthSet(0)
+	assert.Equal(t, 0, direct.CallDepth())
+	direct.Info("direct")
+	assert.Contains(t, caller(), "logging_test.go")
+
+	assert.Equal(t, 0, l.WithCallDepthDelta(-3).CallDepth())
+	assert.Equal(t, 64, l.WithCallDepthSet(1000).CallDepth())
+}
+
+func TestWithConsoleBuffer(t *testing.T) {
+	var buf syncBuffer
+	l := logger.New(logger.WithWriter(&buf), logger.WithConsoleBuffer(1<<10, time.Hour))
+
+	l.Info("buffered")
+	assert.Empty(t, buf.String())
+	assert.NoError(t, l.Sync())
+	assert.Contains(t, buf.String(), "buffered")
+
+	l.Info(strings.Repeat("x", 1<<10))
+	assert.Contains(t, buf.String(), "xxx", "a full buffer is written")
+
+	l = logger.New(logger.WithWriter(&buf), logger.WithConsoleBuffer(0, 10*time.Millisecond))
+	l.Info("flushed by interval")
+	assert.Eventually(t, func() bool { return strings.Contains(buf.String(), "flushed by interval") }, time.Second, 5*time.Millisecond)
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	cfg := logger.Config{Level: "warn", Mode: logger.FileMode, Path: t.TempDir(), Filename: "app.log", Compress: true}
+	l := logger.New(cfg.Options()...)
+	defer l.Sync()
+	l.SetLevel(logger.ErrorLevel)
+
+	want := cfg
+	want.Level = "error"
+	want.Encoder = "json"
+	want.Color = logger.ColorNever
+	want.Rotation = "daily"
+	want.RollingBackend = logger.RollingBackendDefault
+	assert.Equal(t, want, l.EffectiveConfig())
+
+	logger.Register("config-handler", logger.WithWriter(io.Discard), logger.WithLevel(logger.DebugLevel))
+	srv := httptest.NewServer(logger.ConfigHandler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "?name=config-handler")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	var got logger.Config
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	assert.Equal(t, "debug", got.Level)
+	assert.Equal(t, logger.ConsoleMode, got.Mode)
+}
+
+func TestNop(t *testing.T) {
+	l := logger.Nop()
+	assert.Equal(t, l, l.WithFields(map[string]any{"a": 1}).WithContext(context.Background()))
+
+	l.Infow("request served", "path", "/")
+	assert.NoError(t, l.Sync())
+}
+
+func TestNonColorable(t *testing.T) {
+	var buf bytes.Buffer
+	w := logger.NewNonColorable(zapcore.AddSync(&buf))
+
+	for _, chunk := range []string{"\x1b[31mred\x1b", "[0m plain \x1b[1", ";32mgreen\x1b[0m\n"} {
+		n, err := w.Write([]byte(chunk))
+		assert.NoError(t, err)
+		assert.Equal(t, len(chunk), n)
+	}
+	assert.Equal(t, "red plain green\n", buf.String())
+}
+
+func BenchmarkLogging_WithContext(b *testing.B) {
+	logging := logger.New(logger.WithWriter(io.Discard))
+	ctx, span := otel.Tracer("gokit").Start(context.Background(), "bench")
+	defer span.End()
+
+	b.Run("with span", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logging.WithContext(ctx).Info("request served")
+		}
+	})
+	b.Run("without span", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logging.WithContext(context.Background()).Info("request served")
+		}
+	})
+	b.Run("with fields", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logging.WithFields(map[string]any{"user_id": 42}).Info("request served")
+		}
+	})
+}
+
+func BenchmarkLogging_Files(b *testing.B) {
+	logging := logger.New(logger.WithMode(logger.FileMode), logger.WithPath(b.TempDir()),
+		logger.Fields(map[string]any{"app": "bench"}))
+	defer logging.Sync()
+
+	b.Run("info", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logging.Info("request served")
+		}
+	})
+	b.Run("with fields", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logging.WithFields(map[string]any{"user_id": 42}).Info("request served")
+		}
+	})
+	b.Run("levels", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logging.Debug("request served")
+			logging.Warn("request served")
+			logging.Error("request served")
+		}
+	})
+}
+
+func BenchmarkNop(b *testing.B) {
+	l := logger.Nop()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Infow("request served", "path", "/")
+	}
+}
+
+// BenchmarkLogging_Infow compares Infow with 8 key-value pairs against zap's
+// sugared logger, both writing JSON with the caller to a rolling file. The
+// values are boxed without allocating, as constants and small integers are.
+func BenchmarkLogging_Infow(b *testing.B) {
+	logging := logger.New(logger.WithMode(logger.FileMode), logger.WithPath(b.TempDir()), logger.WithFilename("bench.log"))
+	defer logging.Sync()
+
+	filename := filepath.Join(b.TempDir(), "zap.log")
+	rl, err := logger.NewRotateLogger(filename, logger.DefaultRotateRule(filename, "-", 1, false), false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer rl.Close()
+	sugar := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), rl, zapcore.InfoLevel),
+		zap.AddCaller()).Sugar()
+
+	b.Run("logger", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logging.Infow("request served", "path", "/api/users", "method", "GET", "status", 200, "bytes", 512,
+				"latency", 15*time.Millisecond, "attempt", i%3, "cached", i%2 == 0, "ratio", 0.5)
+		}
+	})
+	b.Run("zap sugar", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sugar.Infow("request served", "path", "/api/users", "method", "GET", "status", 200, "bytes", 512,
+				"latency", 15*time.Millisecond, "attempt", i%3, "cached", i%2 == 0, "ratio", 0.5)
+		}
+	})
+}
+
+func TestWithRollingBackend(t *testing.T) {
+	dir := t.TempDir()
+	l := logger.New(logger.WithMode(logger.FileMode), logger.WithPath(dir), logger.WithFilename("app.log"),
+		logger.WithRollingBackend(logger.RollingBackendLumberjack), logger.WithMaxSize(1), logger.WithMaxBackups(1))
+	payload := strings.Repeat("x", 1024)
+	for i := 0; i < 1100; i++ {
+		l.Infow("entry", "payload", payload)
+	}
+	l.Info("last")
+	assert.NoError(t, l.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"last"`)
+	// lumberjack rotated the file at 1MB, naming the backup with the time.
+	backups, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	assert.NoError(t, err)
+	assert.Len(t, backups, 1)
+
+	_, err = logger.NewWithError(logger.WithRollingBackend("logrotate"))
+	assert.ErrorContains(t, err, `unknown rolling backend "logrotate"`)
+	_, err = logger.NewWithError(logger.WithMode(logger.FileMode), logger.WithPath(t.TempDir()),
+		logger.WithRollingBackend(logger.RollingBackendLumberjack), logger.WithExperimentalMmap())
+	assert.ErrorContains(t, err, "lumberjack backend doesn't support")
+}
@@ -0,0 +1,281 @@
+// Package logread reads the entries of a log file written by the logger in
+// json encoding, together with its rotated and gzipped backups, in time order.
+//
+//	r, err := logread.Open("logs/error.log", logread.WithSince(start), logread.WithField("user_id", "42"))
+//	if err != nil {
+//		return err
+//	}
+//	defer r.Close()
+//	for {
+//		e, err := r.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//	}
+package logread
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nextmicro/logger"
+)
+
+const (
+	backupDelimiter = "-"
+	gzipExt         = ".gz"
+	digestExt       = ".digest"
+	encryptedExt    = ".enc"
+	checksumExt     = ".sha256"
+)
+
+// timeLayouts are the layouts of the time encoders of the logger, tried in order.
+var timeLayouts = []string{
+	"2006-01-02T15:04:05.000Z0700",
+	time.RFC3339Nano,
+}
+
+// Entry is a decoded log entry.
+type Entry struct {
+	Time    time.Time
+	Level   logger.Level
+	Message string
+	// Fields are all the keys of the entry, including time, level and message.
+	Fields map[string]interface{}
+	// File is the file the entry was read from.
+	File string
+}
+
+// Reader iterates the entries of a log file and its backups.
+type Reader struct {
+	opt   options
+	files []string
+
+	f   io.ReadCloser
+	buf *bufio.Reader
+	cur string
+}
+
+// Open returns a Reader of filename and its backups, oldest entries first.
+// Lines which aren't json entries are skipped.
+func Open(filename string, opts ...Option) (*Reader, error) {
+	opt := newOptions(opts...)
+	files, err := Files(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+	}
+	return &Reader{opt: opt, files: files}, nil
+}
+
+// Files returns filename and its rotated backups, gzipped or not, ordered by
+// the time of their first entry. filename is left out if it doesn't exist.
+// Encrypted backups are left out, decrypt them with logger.DecryptArchive.
+func Files(filename string) ([]string, error) {
+	ext := filepath.Ext(filename)
+	patterns := []string{
+		// daily and hourly rotation: app.log-2006-01-02
+		filename + backupDelimiter + "*",
+		// size rotation: app-2006-01-02T15:04:05Z.log
+		strings.TrimSuffix(filename, ext) + backupDelimiter + "*" + ext,
+		strings.TrimSuffix(filename, ext) + backupDelimiter + "*" + ext + gzipExt,
+	}
+
+	seen := make(map[string]struct{})
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if _, ok := seen[m]; ok || strings.HasSuffix(m, digestExt) || strings.HasSuffix(m, encryptedExt) ||
+				strings.HasSuffix(m, checksumExt) {
+				continue
+			}
+			seen[m] = struct{}{}
+			files = append(files, m)
+		}
+	}
+
+	firsts := make(map[string]time.Time, len(files)+1)
+	for _, file := range files {
+		t, err := firstTime(file)
+		if err != nil {
+			return nil, err
+		}
+		firsts[file] = t
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return firsts[files[i]].Before(firsts[files[j]])
+	})
+
+	// the current file is always the newest.
+	if _, err := os.Stat(filename); err == nil {
+		files = append(files, filename)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return files, nil
+}
+
+// Next returns the next entry matching the filters, or io.EOF when there
+// are no more entries.
+func (r *Reader) Next() (Entry, error) {
+	for {
+		if r.buf == nil {
+			if len(r.files) == 0 {
+				return Entry{}, io.EOF
+			}
+			if err := r.open(r.files[0]); err != nil {
+				return Entry{}, err
+			}
+			r.files = r.files[1:]
+		}
+
+		line, err := r.buf.ReadBytes('\n')
+		if len(line) > 0 {
+			if e, ok := parseEntry(line); ok && r.opt.match(e) {
+				e.File = r.cur
+				return e, nil
+			}
+		}
+		if err == io.EOF {
+			if err = r.closeFile(); err != nil {
+				return Entry{}, err
+			}
+			continue
+		}
+		if err != nil {
+			return Entry{}, fmt.Errorf("read %s: %w", r.cur, err)
+		}
+	}
+}
+
+// Close closes the file being read.
+func (r *Reader) Close() error {
+	r.files = nil
+	return r.closeFile()
+}
+
+func (r *Reader) open(file string) error {
+	f, err := openFile(file)
+	if err != nil {
+		return err
+	}
+	r.f, r.buf, r.cur = f, bufio.NewReader(f), file
+	return nil
+}
+
+func (r *Reader) closeFile() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f, r.buf, r.cur = nil, nil, ""
+	return err
+}
+
+// firstTime returns the time of the first entry of file, the zero time if it
+// has none.
+func firstTime(file string) (time.Time, error) {
+	f, err := openFile(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	buf := bufio.NewReader(f)
+	for {
+		line, err := buf.ReadBytes('\n')
+		if e, ok := parseEntry(line); ok {
+			return e.Time, nil
+		}
+		if err == io.EOF {
+			return time.Time{}, nil
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+}
+
+// openFile opens file, decompressing gzipped files.
+func openFile(file string) (io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(file, gzipExt) {
+		return f, nil
+	}
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read %s: %w", file, err)
+	}
+	return &gzipFile{Reader: zr, f: f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	return errors.Join(g.Reader.Close(), g.f.Close())
+}
+
+func parseEntry(line []byte) (Entry, bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return Entry{}, false
+	}
+
+	var fields map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return Entry{}, false
+	}
+
+	e := Entry{Fields: fields}
+	e.Time = parseTime(fields["ts"])
+	if lv, ok := fields["level"].(string); ok {
+		_ = e.Level.UnmarshalText([]byte(lv))
+	}
+	e.Message, _ = fields["msg"].(string)
+	return e, true
+}
+
+func parseTime(v interface{}) time.Time {
+	switch ts := v.(type) {
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, ts); err == nil {
+				return t
+			}
+		}
+	case json.Number:
+		// epoch encoders write seconds as a float.
+		if f, err := ts.Float64(); err == nil {
+			sec := int64(f)
+			return time.Unix(sec, int64((f-float64(sec))*float64(time.Second)))
+		}
+	}
+	return time.Time{}
+}
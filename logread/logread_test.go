@@ -0,0 +1,103 @@
+package logread_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nextmicro/logger"
+	"github.com/nextmicro/logger/logread"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, file string, gz bool, lines ...string) {
+	f, err := os.Create(file)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var w io.Writer = f
+	if gz {
+		zw := gzip.NewWriter(f)
+		defer zw.Close()
+		w = zw
+	}
+	for _, line := range lines {
+		_, err = io.WriteString(w, line+"\n")
+		assert.NoError(t, err)
+	}
+}
+
+func readAll(t *testing.T, r *logread.Reader) []logread.Entry {
+	defer r.Close()
+	var entries []logread.Entry
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return entries
+		}
+		assert.NoError(t, err)
+		entries = append(entries, e)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.log")
+	writeFile(t, file+"-2024-01-02", false,
+		`{"level":"warn","ts":"2024-01-02T10:00:00.000Z","msg":"second","user_id":42}`,
+		`not json`,
+	)
+	writeFile(t, file+"-2024-01-01.gz", true,
+		`{"level":"info","ts":"2024-01-01T10:00:00.000Z","msg":"first","user_id":42}`,
+	)
+	writeFile(t, file+"-2024-01-01.digest", false, "abc")
+	writeFile(t, file, false,
+		`{"level":"error","ts":"2024-01-03T10:00:00.000Z","msg":"third","user_id":7}`,
+	)
+
+	r, err := logread.Open(file)
+	assert.NoError(t, err)
+	entries := readAll(t, r)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "first", entries[0].Message)
+		assert.Equal(t, file+"-2024-01-01.gz", entries[0].File)
+		assert.Equal(t, "second", entries[1].Message)
+		assert.Equal(t, logger.Level(logger.WarnLevel), entries[1].Level)
+		assert.Equal(t, time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), entries[1].Time.UTC())
+		assert.Equal(t, "third", entries[2].Message)
+	}
+
+	r, err = logread.Open(file,
+		logread.WithLevel(logger.WarnLevel),
+		logread.WithSince(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)),
+		logread.WithField("user_id", "42"),
+	)
+	assert.NoError(t, err)
+	entries = readAll(t, r)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "second", entries[0].Message)
+	}
+
+	_, err = logread.Open(filepath.Join(dir, "missing.log"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestOpenLoggerFile(t *testing.T) {
+	dir := t.TempDir()
+	l := logger.New(logger.WithMode(logger.FileMode), logger.WithPath(dir), logger.WithFilename("app.log"))
+	l.Infow("served", "path", "/")
+	l.Errorw("failed", "path", "/login")
+	assert.NoError(t, l.Sync())
+
+	r, err := logread.Open(filepath.Join(dir, "app.log"), logread.WithField("path", "/login"))
+	assert.NoError(t, err)
+	entries := readAll(t, r)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "failed", entries[0].Message)
+		assert.Equal(t, logger.Level(logger.ErrorLevel), entries[0].Level)
+		assert.WithinDuration(t, time.Now(), entries[0].Time, time.Minute)
+	}
+}
@@ -0,0 +1,76 @@
+package logread
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nextmicro/logger"
+)
+
+type Option func(o *options)
+
+type options struct {
+	// level is the lowest level read, 0 reads all levels.
+	level logger.Level
+	// since and until bound the entry times, zero values are unbounded.
+	since, until time.Time
+	// fields are the values entries must have, compared as fmt.Sprint.
+	fields map[string]string
+}
+
+func newOptions(opts ...Option) options {
+	opt := options{fields: make(map[string]string)}
+	for _, o := range opts {
+		o(&opt)
+	}
+	return opt
+}
+
+// match reports whether e passes the filters.
+func (o options) match(e Entry) bool {
+	if o.level != 0 && e.Level < o.level {
+		return false
+	}
+	if !o.since.IsZero() && e.Time.Before(o.since) {
+		return false
+	}
+	if !o.until.IsZero() && !e.Time.Before(o.until) {
+		return false
+	}
+	for k, v := range o.fields {
+		fv, ok := e.Fields[k]
+		if !ok || fmt.Sprint(fv) != v {
+			return false
+		}
+	}
+	return true
+}
+
+// WithLevel Setter function to read entries at level or above.
+func WithLevel(level logger.Level) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+// WithSince Setter function to read entries logged at or after t.
+func WithSince(t time.Time) Option {
+	return func(o *options) {
+		o.since = t
+	}
+}
+
+// WithUntil Setter function to read entries logged before t.
+func WithUntil(t time.Time) Option {
+	return func(o *options) {
+		o.until = t
+	}
+}
+
+// WithField Setter function to read entries whose key field has value. It can
+// be given several times, entries must match all of them.
+func WithField(key, value string) Option {
+	return func(o *options) {
+		o.fields[key] = value
+	}
+}
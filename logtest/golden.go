@@ -0,0 +1,97 @@
+package logtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// UpdateGoldenEnv is the environment variable which, when set, makes Golden
+// write the golden files instead of comparing against them.
+const UpdateGoldenEnv = "LOGTEST_UPDATE_GOLDEN"
+
+// A Normalizer rewrites a decoded entry before it is compared, to remove
+// what changes between runs.
+type Normalizer func(entry map[string]interface{})
+
+// DropFields returns a Normalizer removing keys from the entries.
+func DropFields(keys ...string) Normalizer {
+	return func(entry map[string]interface{}) {
+		for _, key := range keys {
+			delete(entry, key)
+		}
+	}
+}
+
+// ReplaceField returns a Normalizer setting key to value in the entries which
+// have it, e.g. to keep that a duration was logged but not its value.
+func ReplaceField(key string, value interface{}) Normalizer {
+	return func(entry map[string]interface{}) {
+		if _, ok := entry[key]; ok {
+			entry[key] = value
+		}
+	}
+}
+
+// defaultNormalizers strip the fields which change on every run.
+var defaultNormalizers = []Normalizer{DropFields("ts", "caller", "trace_id", "span_id")}
+
+// Golden compares the entries collected in b with the golden file at path, a
+// JSON array of the entries. The ts, caller, trace_id and span_id fields are
+// stripped, then normalizers are applied to every entry. With the
+// UpdateGoldenEnv environment variable set, the golden file is written instead.
+func (b *Buffer) Golden(t testing.TB, path string, normalizers ...Normalizer) {
+	t.Helper()
+
+	got, err := b.normalized(append(append([]Normalizer{}, defaultNormalizers...), normalizers...))
+	if err != nil {
+		t.Fatalf("logtest: decode entries: %s", err)
+	}
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err = os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+			err = os.WriteFile(path, got, 0o644)
+		}
+		if err != nil {
+			t.Fatalf("logtest: update golden file: %s", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("logtest: read golden file: %s, set %s=1 to create it", err, UpdateGoldenEnv)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("logtest: entries differ from %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// normalized returns the collected entries, normalized, as an indented JSON
+// array with sorted keys.
+func (b *Buffer) normalized(normalizers []Normalizer) ([]byte, error) {
+	entries := make([]map[string]interface{}, 0)
+	dec := json.NewDecoder(bytes.NewReader(b.buf.Bytes()))
+	dec.UseNumber()
+	for dec.More() {
+		var entry map[string]interface{}
+		if err := dec.Decode(&entry); err != nil {
+			return nil, err
+		}
+		for _, normalize := range normalizers {
+			normalize(entry)
+		}
+		entries = append(entries, entry)
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(entries); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
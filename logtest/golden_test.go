@@ -0,0 +1,36 @@
+package logtest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nextmicro/logger"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestBufferGolden(t *testing.T) {
+	buf := NewCollector(t)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	}))
+	logger.WithContext(ctx).Infow("request served", "path", "/", "latency", "12ms")
+	logger.Warnw("slow request", "latency", "3s")
+
+	buf.Golden(t, "testdata/golden.json", ReplaceField("latency", "<duration>"))
+}
+
+func TestBufferGoldenMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "golden.json")
+	assert.Nil(t, os.WriteFile(path, []byte("[]\n"), 0o644))
+
+	buf := NewCollector(t)
+	logger.Info("not in the golden file")
+
+	tb := &fakeTB{}
+	buf.Golden(tb, path)
+	assert.True(t, tb.failed)
+}
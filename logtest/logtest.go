@@ -3,7 +3,6 @@ package logtest
 import (
 	"bytes"
 	"encoding/json"
-	"io"
 	"testing"
 
 	"github.com/nextmicro/logger"
@@ -14,13 +13,40 @@ type Buffer struct {
 	t   *testing.T
 }
 
+// SwapForTest sets the default logger to l for the duration of t, the
+// previous default logger is restored when t and its subtests complete.
+func SwapForTest(t testing.TB, l logger.Logger) {
+	t.Helper()
+	prev := logger.SwapDefault(l)
+	t.Cleanup(func() {
+		logger.SwapDefault(prev)
+	})
+}
+
+// Entries decodes the JSON entries written one per line in data, failing t
+// if one can't be decoded. It returns nil if data is empty.
+func Entries(t testing.TB, data []byte) []map[string]interface{} {
+	t.Helper()
+
+	var entries []map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var entry map[string]interface{}
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatalf("logtest: decode entries: %s", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
 func Discard(t *testing.T) {
-	logger.DefaultLogger = logger.New(logger.WithWriter(io.Discard))
+	SwapForTest(t, logger.Nop())
 }
 
 func NewCollector(t *testing.T) *Buffer {
 	var buf bytes.Buffer
-	logger.DefaultLogger = logger.New(logger.WithWriter(&buf))
+	SwapForTest(t, logger.New(logger.WithWriter(&buf)))
 
 	t.Cleanup(func() {
 		logger.Sync()
@@ -0,0 +1,35 @@
+package logtest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nextmicro/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwapForTest(t *testing.T) {
+	before := logger.Default()
+
+	var buf bytes.Buffer
+	t.Run("swapped", func(t *testing.T) {
+		SwapForTest(t, logger.New(logger.WithWriter(&buf)))
+		logger.Info("inside the test")
+	})
+
+	assert.Contains(t, buf.String(), "inside the test")
+	assert.Equal(t, before, logger.Default())
+}
+
+func TestEntries(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf))
+	assert.Nil(t, Entries(t, buf.Bytes()))
+
+	l.Info("first")
+	l.Infow("second", "n", 2)
+	entries := Entries(t, buf.Bytes())
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "first", entries[0]["msg"])
+	assert.Equal(t, float64(2), entries[1]["n"])
+}
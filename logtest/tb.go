@@ -0,0 +1,122 @@
+package logtest
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/nextmicro/logger"
+	"go.uber.org/zap/zapcore"
+)
+
+type TBOption func(o *tbOptions)
+
+type tbOptions struct {
+	level       logger.Level
+	failOnError bool
+}
+
+// WithLevel Setter function to set the lowest level written to the test
+// output, default is DebugLevel.
+func WithLevel(level logger.Level) TBOption {
+	return func(o *tbOptions) {
+		o.level = level
+	}
+}
+
+// WithFailOnError Setter function to fail the test on error entries, and to
+// stop it with t.FailNow on fatal ones. Like t.FailNow, fatal entries must
+// then be logged from the test goroutine.
+func WithFailOnError() TBOption {
+	return func(o *tbOptions) {
+		o.failOnError = true
+	}
+}
+
+// NewTB returns a logger writing its entries to t.Logf, one line per entry
+// prefixed with its level, so the code under test logs into the test output.
+// Fatal entries don't exit the process. Entries logged after the test ended
+// are dropped.
+func NewTB(t testing.TB, opts ...TBOption) logger.Logger {
+	o := tbOptions{level: logger.DebugLevel}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	w := &tbWriter{t: t, failOnError: o.failOnError}
+	t.Cleanup(w.stop)
+
+	var hook zapcore.CheckWriteHook = tbFatalHook{}
+	if o.failOnError {
+		hook = tbFatalHook{t: t}
+	}
+	return logger.New(
+		logger.WithLevel(o.level),
+		logger.WithMode(logger.FileMode),
+		logger.WithWriter(w),
+		logger.WithEncoder(logger.ConsoleEncoder),
+		logger.WithEncoderConfig(zapcore.EncoderConfig{
+			MessageKey:     "msg",
+			LevelKey:       "level",
+			CallerKey:      "caller",
+			StacktraceKey:  "stack",
+			LineEnding:     zapcore.DefaultLineEnding,
+			NameKey:        "Logger",
+			EncodeCaller:   zapcore.ShortCallerEncoder,
+			EncodeLevel:    zapcore.CapitalLevelEncoder,
+			EncodeDuration: zapcore.StringDurationEncoder,
+			EncodeName:     zapcore.FullNameEncoder,
+		}),
+		logger.WithFatalHook(hook),
+	)
+}
+
+// tbWriter writes the console encoded entries to t.Logf. Without a time key
+// every entry starts with its level.
+type tbWriter struct {
+	mu          sync.Mutex
+	t           testing.TB
+	failOnError bool
+	stopped     bool
+}
+
+func (w *tbWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return len(p), nil
+	}
+
+	w.t.Helper()
+	line := bytes.TrimRight(p, "\n")
+	w.t.Logf("%s", line)
+
+	if w.failOnError {
+		var level zapcore.Level
+		prefix, _, _ := bytes.Cut(line, []byte("\t"))
+		if level.UnmarshalText(prefix) == nil && level >= zapcore.ErrorLevel {
+			w.t.Fail()
+		}
+	}
+	return len(p), nil
+}
+
+// stop drops the entries from now on, logging to t after the test completed
+// panics.
+func (w *tbWriter) stop() {
+	w.mu.Lock()
+	w.stopped = true
+	w.mu.Unlock()
+}
+
+// tbFatalHook stops the test after a fatal entry if t is set, otherwise it
+// lets the logger return.
+type tbFatalHook struct {
+	t testing.TB
+}
+
+func (h tbFatalHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {
+	if h.t != nil {
+		h.t.FailNow()
+	}
+}
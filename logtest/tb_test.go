@@ -0,0 +1,73 @@
+package logtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTB records what the logger does to the test.
+type fakeTB struct {
+	testing.TB
+	lines    []string
+	failed   bool
+	stopped  bool
+	cleanups []func()
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Logf(format string, args ...interface{}) {
+	f.lines = append(f.lines, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Fail() {
+	f.failed = true
+}
+
+func (f *fakeTB) FailNow() {
+	f.failed = true
+	f.stopped = true
+}
+
+func (f *fakeTB) Cleanup(fn func()) {
+	f.cleanups = append(f.cleanups, fn)
+}
+
+func TestNewTB(t *testing.T) {
+	tb := &fakeTB{}
+	l := NewTB(tb)
+	l.Debugw("debug msg", "user", 42)
+	l.Error("error msg")
+	l.Fatal("fatal msg")
+
+	assert.Len(t, tb.lines, 3)
+	assert.Regexp(t, `^DEBUG\tlogtest/tb_test.go:\d+\tdebug msg\t{"user": 42}$`, tb.lines[0])
+	assert.Regexp(t, `^ERROR\t.*\terror msg$`, tb.lines[1])
+	assert.Regexp(t, `^FATAL\t.*\tfatal msg$`, tb.lines[2])
+	assert.False(t, tb.failed)
+
+	for _, fn := range tb.cleanups {
+		fn()
+	}
+	l.Info("after the test")
+	assert.Len(t, tb.lines, 3)
+}
+
+func TestNewTBFailOnError(t *testing.T) {
+	tb := &fakeTB{}
+	l := NewTB(tb, WithFailOnError())
+	l.Warn("warn msg")
+	assert.False(t, tb.failed)
+	l.Error("error msg")
+	assert.True(t, tb.failed)
+	assert.False(t, tb.stopped)
+	l.Fatal("fatal msg")
+	assert.True(t, tb.stopped)
+}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.Logf(format, args...)
+	f.Fail()
+}
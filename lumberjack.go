@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// RollingBackendDefault rotates the files with RotateLogger.
+	RollingBackendDefault = "default"
+	// RollingBackendLumberjack rotates the files with lumberjack.Logger.
+	RollingBackendLumberjack = "lumberjack"
+)
+
+// createLumberjackOutput returns the output of filename for the lumberjack
+// backend. lumberjack rotates by size only, at maxSize or 100MB, and names
+// the backups with the local time; the rotation rule is ignored.
+func (l *Logging) createLumberjackOutput(filename string) zapcore.WriteSyncer {
+	w := &lumberjack.Logger{
+		Filename:   filename,
+		MaxSize:    l.opt.maxSize,
+		MaxAge:     (l.opt.retentionHours() + hoursPerDay - 1) / hoursPerDay,
+		MaxBackups: l.opt.maxBackups,
+		LocalTime:  true,
+		Compress:   l.opt.compress,
+	}
+	l._lumberjacks = append(l._lumberjacks, w)
+	if !l.opt.encoder.IsConsole() {
+		return zapcore.AddSync(w)
+	}
+	return zapcore.AddSync(NewNonColorable(zapcore.AddSync(w)))
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"net"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+const (
+	hostnameKey       = "hostname"
+	pidKey            = "pid"
+	ipKey             = "ip"
+	serviceNameKey    = "service.name"
+	serviceVersionKey = "service.version"
+	serviceEnvKey     = "service.environment"
+	vcsRevisionKey    = "vcs.revision"
+	vcsTimeKey        = "vcs.time"
+	goVersionKey      = "go.version"
+	moduleVersionKey  = "module.version"
+)
+
+// hostFields returns the hostname, pid and ip key-value pairs of the current process.
+func hostFields() []interface{} {
+	fields := make([]interface{}, 0, 6)
+	if hostname, err := os.Hostname(); err == nil {
+		fields = append(fields, hostnameKey, hostname)
+	}
+	fields = append(fields, pidKey, os.Getpid())
+	if ip := localIP(); ip != "" {
+		fields = append(fields, ipKey, ip)
+	}
+	return fields
+}
+
+// expandPlaceholders replaces the {pid} and {hostname} placeholders of a path
+// or filename, so instances on one host write distinct files.
+func expandPlaceholders(s string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	return strings.NewReplacer(
+		"{"+pidKey+"}", strconv.Itoa(os.Getpid()),
+		"{"+hostnameKey+"}", hostname,
+	).Replace(s)
+}
+
+// serviceFields returns the service.* key-value pairs, skipping empty values.
+func serviceFields(name, version, env string) []interface{} {
+	fields := make([]interface{}, 0, 6)
+	if name != "" {
+		fields = append(fields, serviceNameKey, name)
+	}
+	if version != "" {
+		fields = append(fields, serviceVersionKey, version)
+	}
+	if env != "" {
+		fields = append(fields, serviceEnvKey, env)
+	}
+	return fields
+}
+
+// buildFields returns the vcs.revision, vcs.time, go.version and module.version
+// key-value pairs read from the binary's embedded build information.
+func buildFields() []interface{} {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+
+	fields := make([]interface{}, 0, 8)
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case vcsRevisionKey, vcsTimeKey:
+			fields = append(fields, setting.Key, setting.Value)
+		}
+	}
+	fields = append(fields, goVersionKey, info.GoVersion)
+	if info.Main.Version != "" {
+		fields = append(fields, moduleVersionKey, info.Main.Version)
+	}
+	return fields
+}
+
+// localIP returns the first non-loopback IPv4 address of the host.
+func localIP() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() {
+			continue
+		}
+		if ip := ipNet.IP.To4(); ip != nil {
+			return ip.String()
+		}
+	}
+	return ""
+}
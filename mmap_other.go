@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package logger
+
+import "os"
+
+// openMmapFile opens name for appending with writes, the memory mapping is
+// only implemented for linux, darwin and the BSDs.
+func openMmapFile(name string, perm os.FileMode, _ internalLogger) (logFile, error) {
+	f, err := openLogFile(name, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,46 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateLoggerMmap(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.log")
+	// a crashed process left its mapping padded with zeros.
+	assert.Nil(t, os.WriteFile(filename, append([]byte("crashed\n"), make([]byte, 4096)...), defaultFileMode))
+
+	rule := DefaultRotateRule(filename, backupFileDelimiter, 1, false)
+	logger, err := newRotateLogger(filename, rule, rotateConfig{pool: bpool, mmap: true})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len("crashed\n")), logger.currentSize)
+
+	big := bytes.Repeat([]byte("x"), mmapChunkSize)
+	big[len(big)-1] = '\n'
+	_, err = logger.Write([]byte("first\n"))
+	assert.Nil(t, err)
+	_, err = logger.Write(big)
+	assert.Nil(t, err)
+	assert.Nil(t, logger.Sync())
+	data, err := os.ReadFile(filename)
+	assert.Nil(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("crashed\nfirst\n")))
+	assert.Nil(t, logger.Close())
+
+	logger, err = newRotateLogger(filename, rule, rotateConfig{pool: bpool, mmap: true})
+	assert.Nil(t, err)
+	_, err = logger.Write([]byte("reopened\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, logger.Close())
+
+	data, err = os.ReadFile(filename)
+	assert.Nil(t, err)
+	want := append(append([]byte("crashed\nfirst\n"), big...), "reopened\n"...)
+	assert.True(t, bytes.Equal(want, data), "file holds %d bytes, want %d", len(data), len(want))
+}
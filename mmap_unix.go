@@ -0,0 +1,147 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package logger
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"syscall"
+)
+
+// mmapChunkSize is the size by which an mmapFile grows its file and mapping.
+const mmapChunkSize = 4 << 20
+
+// mmapFile appends to a file through a shared memory mapping, growing the
+// file a chunk at a time, so entries are written without a syscall each.
+// The file is truncated to the bytes written when closed, until then its
+// end is padded with zeros. If mapping fails it writes to the file.
+type mmapFile struct {
+	f *os.File
+	// data is the mapped region, from the offset base of the file.
+	data []byte
+	base int64
+	// size is the number of bytes written to the file.
+	size int64
+	// unmapped is set once mapping failed, writes go to the file then.
+	unmapped bool
+	// internal logs the mapping failures.
+	internal internalLogger
+}
+
+// openMmapFile opens name for appending through a mapping, creating it if
+// needed. The zeros left past the entries by a process that didn't close
+// the file are removed.
+func openMmapFile(name string, perm os.FileMode, internal internalLogger) (logFile, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, perm)
+	if err != nil {
+		return nil, err
+	}
+	size, err := writtenSize(f)
+	if err == nil {
+		err = f.Truncate(size)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &mmapFile{f: f, size: size, internal: internal}, nil
+}
+
+// writtenSize returns the size of f without the zeros padding its end, it
+// looks back a chunk at most.
+func writtenSize(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	size := info.Size()
+	buf := make([]byte, 64<<10)
+	for limit := size - mmapChunkSize; size > 0 && size > limit; {
+		n := int64(len(buf))
+		if n > size {
+			n = size
+		}
+		if _, err = f.ReadAt(buf[:n], size-n); err != nil && err != io.EOF {
+			return 0, err
+		}
+		trimmed := bytes.TrimRight(buf[:n], "\x00")
+		size -= n - int64(len(trimmed))
+		if len(trimmed) > 0 {
+			break
+		}
+	}
+	return size, nil
+}
+
+func (m *mmapFile) Write(p []byte) (int, error) {
+	if !m.unmapped && m.size+int64(len(p)) > m.base+int64(len(m.data)) {
+		if err := m.remap(len(p)); err != nil {
+			m.internal.warn("failed to remap file, writing to the file", "file", m.f.Name(), "error", err)
+			m.unmapped = true
+		}
+	}
+	if m.unmapped {
+		n, err := m.f.WriteAt(p, m.size)
+		m.size += int64(n)
+		return n, err
+	}
+
+	n := copy(m.data[m.size-m.base:], p)
+	m.size += int64(n)
+	return n, nil
+}
+
+// remap grows the file and maps the region from the page of its end, with
+// room for n more bytes.
+func (m *mmapFile) remap(n int) error {
+	if err := m.unmap(); err != nil {
+		return err
+	}
+
+	page := int64(os.Getpagesize())
+	base := m.size / page * page
+	length := (m.size - base + int64(n) + mmapChunkSize - 1) / mmapChunkSize * mmapChunkSize
+	if err := m.f.Truncate(base + length); err != nil {
+		return err
+	}
+	data, err := syscall.Mmap(int(m.f.Fd()), base, int(length), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	m.data, m.base = data, base
+	return nil
+}
+
+func (m *mmapFile) unmap() error {
+	if m.data == nil {
+		return nil
+	}
+	err := syscall.Munmap(m.data)
+	m.data = nil
+	return err
+}
+
+// Sync syncs the file, the mapped pages with it.
+func (m *mmapFile) Sync() error {
+	return m.f.Sync()
+}
+
+// Stat returns the info of the file, os.SameFile needs it as it is. Its
+// size counts the padding once written to.
+func (m *mmapFile) Stat() (os.FileInfo, error) {
+	return m.f.Stat()
+}
+
+// Close unmaps the file and truncates it to the bytes written.
+func (m *mmapFile) Close() error {
+	err := m.unmap()
+	if e := m.f.Truncate(m.size); err == nil {
+		err = e
+	}
+	if e := m.f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,561 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// msgpackPool recycles the buffers of the encoded entries.
+var msgpackPool = buffer.NewPool()
+
+// msgpackLevel is a map being encoded, the root one or an open namespace.
+type msgpackLevel struct {
+	key string
+	buf []byte
+	n   int
+}
+
+// msgpackEncoder encodes entries as MessagePack maps, one per entry without
+// a line ending, the stream of maps fluentd reads with its msgpack format.
+// Maps need their size upfront, so the entries of every open map are
+// buffered and counted until it is closed.
+type msgpackEncoder struct {
+	cfg *zapcore.EncoderConfig
+	// levels are the open maps, the root one first.
+	levels []msgpackLevel
+}
+
+func newMsgpackEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &msgpackEncoder{cfg: &cfg, levels: make([]msgpackLevel, 1)}
+}
+
+func (enc *msgpackEncoder) cur() *msgpackLevel {
+	return &enc.levels[len(enc.levels)-1]
+}
+
+func (enc *msgpackEncoder) addKey(key string) {
+	l := enc.cur()
+	l.n++
+	l.buf = appendMsgpackString(l.buf, key)
+}
+
+func (enc *msgpackEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	enc.addKey(key)
+	l := enc.cur()
+	var err error
+	l.buf, err = appendMsgpackArray(l.buf, enc.cfg, arr)
+	return err
+}
+
+func (enc *msgpackEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	enc.addKey(key)
+	l := enc.cur()
+	var err error
+	l.buf, err = appendMsgpackObject(l.buf, enc.cfg, obj)
+	return err
+}
+
+func (enc *msgpackEncoder) AddBinary(key string, value []byte) {
+	enc.addKey(key)
+	enc.cur().buf = appendMsgpackBinary(enc.cur().buf, value)
+}
+
+func (enc *msgpackEncoder) AddByteString(key string, value []byte) {
+	enc.addKey(key)
+	enc.cur().buf = appendMsgpackString(enc.cur().buf, string(value))
+}
+
+func (enc *msgpackEncoder) AddBool(key string, value bool) {
+	enc.addKey(key)
+	enc.cur().buf = appendMsgpackBool(enc.cur().buf, value)
+}
+
+func (enc *msgpackEncoder) AddComplex128(key string, value complex128) {
+	enc.addKey(key)
+	enc.cur().buf = appendMsgpackString(enc.cur().buf, formatComplex(value))
+}
+
+func (enc *msgpackEncoder) AddComplex64(key string, value complex64) {
+	enc.AddComplex128(key, complex128(value))
+}
+
+func (enc *msgpackEncoder) AddDuration(key string, value time.Duration) {
+	enc.addKey(key)
+	enc.cur().buf = appendMsgpackDuration(enc.cur().buf, enc.cfg, value)
+}
+
+func (enc *msgpackEncoder) AddFloat64(key string, value float64) {
+	enc.addKey(key)
+	enc.cur().buf = appendMsgpackFloat64(enc.cur().buf, value)
+}
+
+func (enc *msgpackEncoder) AddFloat32(key string, value float32) {
+	enc.addKey(key)
+	enc.cur().buf = appendMsgpackFloat32(enc.cur().buf, value)
+}
+
+func (enc *msgpackEncoder) AddInt(key string, value int)     { enc.AddInt64(key, int64(value)) }
+func (enc *msgpackEncoder) AddInt32(key string, value int32) { enc.AddInt64(key, int64(value)) }
+func (enc *msgpackEncoder) AddInt16(key string, value int16) { enc.AddInt64(key, int64(value)) }
+func (enc *msgpackEncoder) AddInt8(key string, value int8)   { enc.AddInt64(key, int64(value)) }
+
+func (enc *msgpackEncoder) AddInt64(key string, value int64) {
+	enc.addKey(key)
+	enc.cur().buf = appendMsgpackInt(enc.cur().buf, value)
+}
+
+func (enc *msgpackEncoder) AddString(key, value string) {
+	enc.addKey(key)
+	enc.cur().buf = appendMsgpackString(enc.cur().buf, value)
+}
+
+func (enc *msgpackEncoder) AddTime(key string, value time.Time) {
+	enc.addKey(key)
+	enc.cur().buf = appendMsgpackTime(enc.cur().buf, enc.cfg, value)
+}
+
+func (enc *msgpackEncoder) AddUint(key string, value uint)       { enc.AddUint64(key, uint64(value)) }
+func (enc *msgpackEncoder) AddUint32(key string, value uint32)   { enc.AddUint64(key, uint64(value)) }
+func (enc *msgpackEncoder) AddUint16(key string, value uint16)   { enc.AddUint64(key, uint64(value)) }
+func (enc *msgpackEncoder) AddUint8(key string, value uint8)     { enc.AddUint64(key, uint64(value)) }
+func (enc *msgpackEncoder) AddUintptr(key string, value uintptr) { enc.AddUint64(key, uint64(value)) }
+
+func (enc *msgpackEncoder) AddUint64(key string, value uint64) {
+	enc.addKey(key)
+	enc.cur().buf = appendMsgpackUint(enc.cur().buf, value)
+}
+
+func (enc *msgpackEncoder) AddReflected(key string, value interface{}) error {
+	enc.addKey(key)
+	l := enc.cur()
+	var err error
+	l.buf, err = appendMsgpackReflected(l.buf, value)
+	return err
+}
+
+func (enc *msgpackEncoder) OpenNamespace(key string) {
+	enc.levels = append(enc.levels, msgpackLevel{key: key})
+}
+
+func (enc *msgpackEncoder) Clone() zapcore.Encoder {
+	return enc.clone()
+}
+
+func (enc *msgpackEncoder) clone() *msgpackEncoder {
+	levels := make([]msgpackLevel, len(enc.levels))
+	for i, l := range enc.levels {
+		levels[i] = msgpackLevel{key: l.key, buf: append([]byte(nil), l.buf...), n: l.n}
+	}
+	return &msgpackEncoder{cfg: enc.cfg, levels: levels}
+}
+
+// closeNamespaces closes the open namespaces, each becoming an entry of its
+// parent map.
+func (enc *msgpackEncoder) closeNamespaces() {
+	for len(enc.levels) > 1 {
+		inner := enc.levels[len(enc.levels)-1]
+		enc.levels = enc.levels[:len(enc.levels)-1]
+		enc.addKey(inner.key)
+		l := enc.cur()
+		l.buf = append(appendMsgpackMapHeader(l.buf, inner.n), inner.buf...)
+	}
+}
+
+func (enc *msgpackEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	// the entry metadata comes first, before the context fields.
+	meta := &msgpackEncoder{cfg: enc.cfg, levels: make([]msgpackLevel, 1)}
+	cfg := enc.cfg
+	if cfg.TimeKey != "" {
+		meta.addKey(cfg.TimeKey)
+		meta.cur().buf = appendMsgpackTime(meta.cur().buf, cfg, ent.Time)
+	}
+	if cfg.LevelKey != "" {
+		meta.addKey(cfg.LevelKey)
+		meta.cur().buf = appendMsgpackEncoded(meta.cur().buf, func(arr zapcore.PrimitiveArrayEncoder) bool {
+			if cfg.EncodeLevel == nil {
+				return false
+			}
+			cfg.EncodeLevel(ent.Level, arr)
+			return true
+		}, func(b []byte) []byte {
+			return appendMsgpackString(b, ent.Level.String())
+		})
+	}
+	if ent.LoggerName != "" && cfg.NameKey != "" {
+		meta.addKey(cfg.NameKey)
+		meta.cur().buf = appendMsgpackEncoded(meta.cur().buf, func(arr zapcore.PrimitiveArrayEncoder) bool {
+			if cfg.EncodeName == nil {
+				return false
+			}
+			cfg.EncodeName(ent.LoggerName, arr)
+			return true
+		}, func(b []byte) []byte {
+			return appendMsgpackString(b, ent.LoggerName)
+		})
+	}
+	if ent.Caller.Defined {
+		if cfg.CallerKey != "" {
+			meta.addKey(cfg.CallerKey)
+			meta.cur().buf = appendMsgpackEncoded(meta.cur().buf, func(arr zapcore.PrimitiveArrayEncoder) bool {
+				if cfg.EncodeCaller == nil {
+					return false
+				}
+				cfg.EncodeCaller(ent.Caller, arr)
+				return true
+			}, func(b []byte) []byte {
+				return appendMsgpackString(b, ent.Caller.String())
+			})
+		}
+		if cfg.FunctionKey != "" {
+			meta.AddString(cfg.FunctionKey, ent.Caller.Function)
+		}
+	}
+	if cfg.MessageKey != "" {
+		meta.AddString(cfg.MessageKey, ent.Message)
+	}
+
+	final := enc.clone()
+	root := &final.levels[0]
+	root.buf = append(meta.levels[0].buf, root.buf...)
+	root.n += meta.levels[0].n
+	for i := range fields {
+		fields[i].AddTo(final)
+	}
+	final.closeNamespaces()
+	if ent.Stack != "" && cfg.StacktraceKey != "" {
+		final.AddString(cfg.StacktraceKey, ent.Stack)
+	}
+
+	out := msgpackPool.Get()
+	root = &final.levels[0]
+	out.Write(appendMsgpackMapHeader(nil, root.n))
+	out.Write(root.buf)
+	return out, nil
+}
+
+// msgpackArray encodes the elements of an array, counting them.
+type msgpackArray struct {
+	cfg *zapcore.EncoderConfig
+	buf []byte
+	n   int
+}
+
+func (a *msgpackArray) AppendArray(arr zapcore.ArrayMarshaler) error {
+	a.n++
+	var err error
+	a.buf, err = appendMsgpackArray(a.buf, a.cfg, arr)
+	return err
+}
+
+func (a *msgpackArray) AppendObject(obj zapcore.ObjectMarshaler) error {
+	a.n++
+	var err error
+	a.buf, err = appendMsgpackObject(a.buf, a.cfg, obj)
+	return err
+}
+
+func (a *msgpackArray) AppendReflected(value interface{}) error {
+	a.n++
+	var err error
+	a.buf, err = appendMsgpackReflected(a.buf, value)
+	return err
+}
+
+func (a *msgpackArray) AppendBool(value bool) {
+	a.n++
+	a.buf = appendMsgpackBool(a.buf, value)
+}
+
+func (a *msgpackArray) AppendByteString(value []byte) {
+	a.n++
+	a.buf = appendMsgpackString(a.buf, string(value))
+}
+
+func (a *msgpackArray) AppendComplex128(value complex128) {
+	a.n++
+	a.buf = appendMsgpackString(a.buf, formatComplex(value))
+}
+
+func (a *msgpackArray) AppendComplex64(value complex64) { a.AppendComplex128(complex128(value)) }
+
+func (a *msgpackArray) AppendDuration(value time.Duration) {
+	a.n++
+	a.buf = appendMsgpackDuration(a.buf, a.cfg, value)
+}
+
+func (a *msgpackArray) AppendFloat64(value float64) {
+	a.n++
+	a.buf = appendMsgpackFloat64(a.buf, value)
+}
+
+func (a *msgpackArray) AppendFloat32(value float32) {
+	a.n++
+	a.buf = appendMsgpackFloat32(a.buf, value)
+}
+
+func (a *msgpackArray) AppendInt(value int)     { a.AppendInt64(int64(value)) }
+func (a *msgpackArray) AppendInt32(value int32) { a.AppendInt64(int64(value)) }
+func (a *msgpackArray) AppendInt16(value int16) { a.AppendInt64(int64(value)) }
+func (a *msgpackArray) AppendInt8(value int8)   { a.AppendInt64(int64(value)) }
+
+func (a *msgpackArray) AppendInt64(value int64) {
+	a.n++
+	a.buf = appendMsgpackInt(a.buf, value)
+}
+
+func (a *msgpackArray) AppendString(value string) {
+	a.n++
+	a.buf = appendMsgpackString(a.buf, value)
+}
+
+func (a *msgpackArray) AppendTime(value time.Time) {
+	a.n++
+	a.buf = appendMsgpackTime(a.buf, a.cfg, value)
+}
+
+func (a *msgpackArray) AppendUint(value uint)       { a.AppendUint64(uint64(value)) }
+func (a *msgpackArray) AppendUint32(value uint32)   { a.AppendUint64(uint64(value)) }
+func (a *msgpackArray) AppendUint16(value uint16)   { a.AppendUint64(uint64(value)) }
+func (a *msgpackArray) AppendUint8(value uint8)     { a.AppendUint64(uint64(value)) }
+func (a *msgpackArray) AppendUintptr(value uintptr) { a.AppendUint64(uint64(value)) }
+
+func (a *msgpackArray) AppendUint64(value uint64) {
+	a.n++
+	a.buf = appendMsgpackUint(a.buf, value)
+}
+
+func appendMsgpackArray(b []byte, cfg *zapcore.EncoderConfig, arr zapcore.ArrayMarshaler) ([]byte, error) {
+	a := &msgpackArray{cfg: cfg}
+	err := arr.MarshalLogArray(a)
+	return append(appendMsgpackArrayHeader(b, a.n), a.buf...), err
+}
+
+func appendMsgpackObject(b []byte, cfg *zapcore.EncoderConfig, obj zapcore.ObjectMarshaler) ([]byte, error) {
+	enc := &msgpackEncoder{cfg: cfg, levels: make([]msgpackLevel, 1)}
+	err := obj.MarshalLogObject(enc)
+	enc.closeNamespaces()
+	return append(appendMsgpackMapHeader(b, enc.levels[0].n), enc.levels[0].buf...), err
+}
+
+// appendMsgpackEncoded appends the one value encode appends, like the time or
+// the level encoders of the config. It appends the value of fallback if
+// encode appends none, and an array if it appends several.
+func appendMsgpackEncoded(b []byte, encode func(zapcore.PrimitiveArrayEncoder) bool, fallback func([]byte) []byte) []byte {
+	a := &msgpackArray{}
+	if !encode(a) || a.n == 0 {
+		return fallback(b)
+	}
+	if a.n == 1 {
+		return append(b, a.buf...)
+	}
+	return append(appendMsgpackArrayHeader(b, a.n), a.buf...)
+}
+
+func appendMsgpackDuration(b []byte, cfg *zapcore.EncoderConfig, d time.Duration) []byte {
+	return appendMsgpackEncoded(b, func(arr zapcore.PrimitiveArrayEncoder) bool {
+		if cfg == nil || cfg.EncodeDuration == nil {
+			return false
+		}
+		cfg.EncodeDuration(d, arr)
+		return true
+	}, func(b []byte) []byte {
+		return appendMsgpackInt(b, int64(d))
+	})
+}
+
+func appendMsgpackTime(b []byte, cfg *zapcore.EncoderConfig, t time.Time) []byte {
+	return appendMsgpackEncoded(b, func(arr zapcore.PrimitiveArrayEncoder) bool {
+		if cfg == nil || cfg.EncodeTime == nil {
+			return false
+		}
+		cfg.EncodeTime(t, arr)
+		return true
+	}, func(b []byte) []byte {
+		return appendMsgpackTimestamp(b, t)
+	})
+}
+
+// appendMsgpackReflected appends value as its JSON form does, numbers kept
+// as integers when they are.
+func appendMsgpackReflected(b []byte, value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return appendMsgpackString(b, fmt.Sprintf("%v", value)), err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err = dec.Decode(&v); err != nil {
+		return appendMsgpackString(b, string(data)), err
+	}
+	return appendMsgpackValue(b, v), nil
+}
+
+func appendMsgpackValue(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		return appendMsgpackBool(b, v)
+	case string:
+		return appendMsgpackString(b, v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return appendMsgpackInt(b, i)
+		}
+		if f, err := v.Float64(); err == nil {
+			return appendMsgpackFloat64(b, f)
+		}
+		return appendMsgpackString(b, v.String())
+	case []interface{}:
+		b = appendMsgpackArrayHeader(b, len(v))
+		for _, e := range v {
+			b = appendMsgpackValue(b, e)
+		}
+		return b
+	case map[string]interface{}:
+		b = appendMsgpackMapHeader(b, len(v))
+		for k, e := range v {
+			b = appendMsgpackValue(appendMsgpackString(b, k), e)
+		}
+		return b
+	default:
+		return appendMsgpackString(b, fmt.Sprint(v))
+	}
+}
+
+// formatComplex formats c like the JSON encoder, e.g. 1+2i.
+func formatComplex(c complex128) string {
+	s := fmt.Sprint(c)
+	return s[1 : len(s)-1]
+}
+
+func appendMsgpackBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendMsgpackUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return appendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return appendUint32(append(b, 0xd2), uint32(v))
+	default:
+		return appendUint64(append(b, 0xd3), uint64(v))
+	}
+}
+
+func appendMsgpackUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= math.MaxInt8:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return appendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return appendUint32(append(b, 0xce), uint32(v))
+	default:
+		return appendUint64(append(b, 0xcf), v)
+	}
+}
+
+func appendMsgpackFloat32(b []byte, v float32) []byte {
+	return appendUint32(append(b, 0xca), math.Float32bits(v))
+}
+
+func appendMsgpackFloat64(b []byte, v float64) []byte {
+	return appendUint64(append(b, 0xcb), math.Float64bits(v))
+}
+
+// appendMsgpackString appends s as a str, invalid UTF-8 replaced as the
+// format requires valid strings.
+func appendMsgpackString(b []byte, s string) []byte {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackBinary(b []byte, v []byte) []byte {
+	n := len(v)
+	switch {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xc6), uint32(n))
+	}
+	return append(b, v...)
+}
+
+func appendMsgpackArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, 0xdc), uint16(n))
+	default:
+		return appendUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+func appendMsgpackMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, 0xde), uint16(n))
+	default:
+		return appendUint32(append(b, 0xdf), uint32(n))
+	}
+}
+
+// appendMsgpackTimestamp appends t as the timestamp extension type, in its
+// 96-bit form which holds any time.
+func appendMsgpackTimestamp(b []byte, t time.Time) []byte {
+	b = append(b, 0xc7, 12, 0xff)
+	b = appendUint32(b, uint32(t.Nanosecond()))
+	return appendUint64(b, uint64(t.Unix()))
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
@@ -0,0 +1,215 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// decodeMsgpack decodes the value at the start of r, as encoding/json would
+// decode its JSON form, with integers as int64 and timestamps as time.Time.
+func decodeMsgpack(r *bytes.Reader) (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	next := func(n int) []byte {
+		b := make([]byte, n)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return make([]byte, n)
+		}
+		return b
+	}
+	size := func(n int) int {
+		b := next(n)
+		switch n {
+		case 1:
+			return int(b[0])
+		case 2:
+			return int(binary.BigEndian.Uint16(b))
+		default:
+			return int(binary.BigEndian.Uint32(b))
+		}
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return string(next(int(c & 0x1f))), err
+	case c&0xf0 == 0x90:
+		return decodeMsgpackArray(r, int(c&0x0f))
+	case c&0xf0 == 0x80:
+		return decodeMsgpackMap(r, int(c&0x0f))
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2, 0xc3:
+		return c == 0xc3, nil
+	case 0xc4, 0xc5, 0xc6:
+		return next(size(1 << (c - 0xc4))), err
+	case 0xc7:
+		n := size(1)
+		if next(1)[0] != 0xff || n != 12 {
+			return nil, errors.New("unexpected extension")
+		}
+		b := next(12)
+		return time.Unix(int64(binary.BigEndian.Uint64(b[4:])), int64(binary.BigEndian.Uint32(b[:4]))), err
+	case 0xca:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(next(4)))), err
+	case 0xcb:
+		return math.Float64frombits(binary.BigEndian.Uint64(next(8))), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		b := next(1 << (c - 0xcc))
+		var v uint64
+		for _, x := range b {
+			v = v<<8 | uint64(x)
+		}
+		if v > math.MaxInt64 {
+			return v, err
+		}
+		return int64(v), err
+	case 0xd0:
+		return int64(int8(next(1)[0])), err
+	case 0xd1:
+		return int64(int16(binary.BigEndian.Uint16(next(2)))), err
+	case 0xd2:
+		return int64(int32(binary.BigEndian.Uint32(next(4)))), err
+	case 0xd3:
+		return int64(binary.BigEndian.Uint64(next(8))), err
+	case 0xd9, 0xda, 0xdb:
+		return string(next(size(1 << (c - 0xd9)))), err
+	case 0xdc, 0xdd:
+		return decodeMsgpackArray(r, size(2<<(c-0xdc)))
+	case 0xde, 0xdf:
+		return decodeMsgpackMap(r, size(2<<(c-0xde)))
+	}
+	return nil, errors.New("unexpected type")
+}
+
+func decodeMsgpackArray(r *bytes.Reader, n int) (interface{}, error) {
+	values := make([]interface{}, n)
+	for i := range values {
+		v, err := decodeMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func decodeMsgpackMap(r *bytes.Reader, n int) (interface{}, error) {
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := decodeMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := decodeMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		m[k.(string)] = v
+	}
+	return m, nil
+}
+
+type msgpackUser struct {
+	Name string
+	Tags []string
+}
+
+func (u msgpackUser) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", u.Name)
+	return enc.AddArray("tags", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, t := range u.Tags {
+			arr.AppendString(t)
+		}
+		return nil
+	}))
+}
+
+func TestMsgpackEncoder(t *testing.T) {
+	cfg := newOptions().encoderConfig
+	cfg.EncodeTime = nil
+	enc := newMsgpackEncoder(cfg)
+	enc.AddString("app", "test")
+	enc.OpenNamespace("ctx")
+	enc.AddInt("depth", 1)
+
+	now := time.Unix(1700000000, 123)
+	buf, err := enc.EncodeEntry(zapcore.Entry{
+		Level:   zapcore.WarnLevel,
+		Time:    now,
+		Message: "something happened",
+		Caller:  zapcore.NewEntryCaller(0, "/src/app/main.go", 42, true),
+		Stack:   "main.main()",
+	}, []zapcore.Field{
+		zap.Int64("small", -5),
+		zap.Int64("big", math.MinInt64),
+		zap.Uint64("huge", math.MaxUint64),
+		zap.Float64("ratio", 0.5),
+		zap.Bool("ok", true),
+		zap.Binary("raw", []byte{1, 2}),
+		zap.Duration("took", 1500*time.Millisecond),
+		zap.Object("user", msgpackUser{Name: "alice", Tags: []string{"a", "b"}}),
+		zap.Any("meta", map[string]int{"n": 1}),
+		zap.String("long", string(bytes.Repeat([]byte("x"), 300))),
+	})
+	assert.Nil(t, err)
+	defer buf.Free()
+
+	r := bytes.NewReader(buf.Bytes())
+	v, err := decodeMsgpack(r)
+	assert.Nil(t, err)
+	assert.Zero(t, r.Len())
+	assert.Equal(t, map[string]interface{}{
+		"ts":     now,
+		"level":  "warn",
+		"caller": "app/main.go:42",
+		"msg":    "something happened",
+		"app":    "test",
+		"ctx": map[string]interface{}{
+			"depth": int64(1),
+			"small": int64(-5),
+			"big":   int64(math.MinInt64),
+			"huge":  uint64(math.MaxUint64),
+			"ratio": 0.5,
+			"ok":    true,
+			"raw":   []byte{1, 2},
+			"took":  "1.5s",
+			"user":  map[string]interface{}{"name": "alice", "tags": []interface{}{"a", "b"}},
+			"meta":  map[string]interface{}{"n": int64(1)},
+			"long":  string(bytes.Repeat([]byte("x"), 300)),
+		},
+		"stack": "main.main()",
+	}, v)
+}
+
+func TestMsgpackEncoderOption(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(WithWriter(&buf), WithEncoder(MsgpackEncoder))
+	l.Infow("first", "n", 1)
+	l.Infow("second", "n", 2)
+
+	r := bytes.NewReader(buf.Bytes())
+	for _, msg := range []string{"first", "second"} {
+		v, err := decodeMsgpack(r)
+		assert.Nil(t, err)
+		assert.Equal(t, msg, v.(map[string]interface{})["msg"])
+	}
+	assert.Zero(t, r.Len())
+	assert.Nil(t, newOptions(WithEncoder(MsgpackEncoder)).validate())
+}
@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"context"
+	"os"
+)
+
+// nopLogger is a Logger that discards every entry.
+type nopLogger struct{}
+
+var nop Logger = nopLogger{}
+
+// Nop returns a Logger that discards every entry without encoding it, for
+// library defaults and benchmarks. Fatal, Fatalf and Fatalw still exit.
+func Nop() Logger {
+	return nop
+}
+
+func (nopLogger) SetLevel(Level) {}
+
+func (nopLogger) SetGlobalLevel(Level) {}
+
+func (n nopLogger) WithContext(context.Context) Logger { return n }
+
+func (n nopLogger) WithFields(map[string]any) Logger { return n }
+
+func (n nopLogger) With(...any) Logger { return n }
+
+func (n nopLogger) Namespace(string) Logger { return n }
+
+func (n nopLogger) WithTrace(string, string) Logger { return n }
+
+func (n nopLogger) WithoutTrace() Logger { return n }
+
+func (n nopLogger) WithLevel(Level) Logger { return n }
+
+func (n nopLogger) WithCallDepth(int) Logger { return n }
+
+func (n nopLogger) WithCallDepthDelta(int) Logger { return n }
+
+func (n nopLogger) WithCallDepthSet(int) Logger { return n }
+
+func (n nopLogger) CallDepth() int { return 0 }
+
+func (n nopLogger) V(int) Logger { return n }
+
+func (nopLogger) Debug(...interface{}) {}
+
+func (nopLogger) Info(...interface{}) {}
+
+func (nopLogger) Warn(...interface{}) {}
+
+func (nopLogger) Error(...interface{}) {}
+
+func (nopLogger) Fatal(...interface{}) { os.Exit(1) }
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+
+func (nopLogger) Infof(string, ...interface{}) {}
+
+func (nopLogger) Warnf(string, ...interface{}) {}
+
+func (nopLogger) Errorf(string, ...interface{}) {}
+
+func (nopLogger) Fatalf(string, ...interface{}) { os.Exit(1) }
+
+func (nopLogger) Debugw(string, ...interface{}) {}
+
+func (nopLogger) Infow(string, ...interface{}) {}
+
+func (nopLogger) Warnw(string, ...interface{}) {}
+
+func (nopLogger) Errorw(string, ...interface{}) {}
+
+func (nopLogger) Fatalw(string, ...interface{}) { os.Exit(1) }
+
+func (nopLogger) Log(level Level, _ ...interface{}) { nopExit(level) }
+
+func (nopLogger) Logf(level Level, _ string, _ ...interface{}) { nopExit(level) }
+
+func (nopLogger) Logw(level Level, _ string, _ ...interface{}) { nopExit(level) }
+
+func (nopLogger) Sync() error { return nil }
+
+func nopExit(level Level) {
+	if level == FatalLevel {
+		os.Exit(1)
+	}
+}
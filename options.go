@@ -1,27 +1,48 @@
 package logger
 
 import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
 	"io"
+	"os"
+	"strings"
+	"time"
 
 	"go.uber.org/zap/zapcore"
 )
 
 const (
-	spanKey  = "span_id"
-	traceKey = "trace_id"
+	spanKey   = "span_id"
+	traceKey  = "trace_id"
+	loggerKey = "logger"
 
 	callerSkipOffset = 1
+	// maxCallDepth bounds the depth of WithCallDepth, deeper is a wrapper
+	// adding its depth on every call.
+	maxCallDepth = 64
+	// defaultConsoleFlushInterval is the flush interval of WithConsoleBuffer.
+	defaultConsoleFlushInterval = time.Second
 
 	FileMode    = "file"
 	ConsoleMode = "console"
 )
 
 const (
-	debugFilename = "debug.log"
-	infoFilename  = "info.log"
-	warnFilename  = "warn.log"
-	errorFilename = "error.log"
-	fatalFilename = "fatal.log"
+	debugFilename  = "debug.log"
+	infoFilename   = "info.log"
+	warnFilename   = "warn.log"
+	errorFilename  = "error.log"
+	fatalFilename  = "fatal.log"
+	statFilename   = "stat.log"
+	slowFilename   = "slow.log"
+	severeFilename = "severe.log"
+
+	// truncatedFieldsKey is the key counting the fields dropped by WithMaxFields.
+	truncatedFieldsKey = "_truncated_fields"
+	// verbosityKey is the key of the V-level of verbose entries.
+	verbosityKey = "v"
 )
 
 type Option func(o *Options)
@@ -33,6 +54,8 @@ type Options struct {
 	callerSkip int
 	// namespace is the namespace of logger.
 	namespace string
+	// name is the name of logger.
+	name string
 	// fields is the fields of logger.
 	fields map[string]any
 	// encoder is the encoder of logger.
@@ -47,35 +70,137 @@ type Options struct {
 	// filename is the log filename. default is `""`
 	filename string
 	// maxSize represents how much space the writing log file takes up. 0 means no limit. The unit is `MB`.
-	// Only take effect when RotationRuleType is `size`
+	// Only take effect when RotationRuleType is `size`, or with rotateOnMaxSize.
 	maxSize int
+	// rotateOnMaxSize rotates the daily and hourly files early once they reach maxSize.
+	rotateOnMaxSize bool
 	// keepDays represents how many days the log files will be kept. Default to keep all files.
-	// Only take effect when Mode is `file` or `volume`, both work when Rotation is `daily` or `size`.
+	// Only take effect when Mode is `file` or `volume`, with every rotation.
 	keepDays int
 	// keepHours represents how many hours the log files will be kept. Default to keep all files.
-	// Only take effect when Mode is `file` or `volume`, both work when Rotation is `daily` or `size`.
+	// Only take effect when Mode is `file` or `volume`, with every rotation. It conflicts with keepDays.
 	keepHours int
 	// maxBackups represents how many backup log files will be kept. 0 means all files will be kept forever.
-	// Only take effect when RotationRuleType is `size`.
+	// Only take effect when Mode is `file` or `volume`, with every rotation.
 	// Even though `MaxBackups` sets 0, log files will still be removed
 	// if the `KeepDays` limitation is reached.
 	maxBackups int
 	// compress is the compression type for old logs. disabled by default.
 	compress bool
+	// backupDelimiter separates the filename and the time in the backup
+	// names, default is `-`.
+	backupDelimiter string
+	// compressionWorkers and compressionBacklog bound the backups archived
+	// at a time and waiting, 0 shares the default compressor of the process.
+	compressionWorkers int
+	compressionBacklog int
+	// compressionLevel is the gzip level of the backups, 0 for the default.
+	compressionLevel int
+	// compressionRate limits the bytes read per second when compressing.
+	compressionRate int
 	// rotation represents the type of log rotation rule. Default is `daily`.
 	// daily: daily rotation.
 	// size: size limited rotation.
 	rotation string
 	// writer is the writer of logger.
 	writer io.Writer
+	// metaFields are the process and service metadata attached to every entry.
+	metaFields []interface{}
+	// audit enables hash chained entries and digest finalized files.
+	audit bool
+	// auditHead is the prev_hash the audit chain continues from, when it
+	// can't be read from the files.
+	auditHead string
+	// unsampled exempts the logger from SetSampling, for the entries that
+	// must all be kept such as the audit, stat and severe ones.
+	unsampled bool
+	// flushOnExit syncs and closes the outputs on SIGINT or SIGTERM.
+	flushOnExit bool
+	// internal logs the failures of the logger itself, the output of
+	// SetInternalOutput if zero.
+	internal internalLogger
+	// panicFlush syncs the outputs when FlushOnPanic catches a panic.
+	panicFlush bool
+	// bufferPoolSize is the number of idle buffers the rolling files retain. default is 500.
+	bufferPoolSize int
+	// maxBufferCapacity is the capacity in bytes above which buffers aren't reused. default is 256KB.
+	maxBufferCapacity int
+	// maxFields is the number of context and call site fields kept per entry. 0 means no limit.
+	maxFields int
+	// verbosity is the highest V-level logged by V. default is 0, verbose entries are discarded.
+	verbosity int
+	// sampleBudget is the entries per second above which debug and info entries are sampled. 0 disables sampling.
+	sampleBudget int
+	// levelSampling keeps one of every rate entries of the levels it has.
+	levelSampling map[Level]int
+	// archiveKey is the AES key encrypting the compressed backups, nil disables encryption.
+	archiveKey []byte
+	// checksum writes a `.sha256` sidecar for every backup.
+	checksum bool
+	// pathLayout is the time layout of the directory files are written to under path, e.g. `2006/01/02`.
+	pathLayout string
+	// fatalHook runs after a fatal entry is written, nil exits the process.
+	fatalHook zapcore.CheckWriteHook
+	// sortedFields emits the fields of maps in key order instead of map iteration order.
+	sortedFields bool
+	// correlationIDs makes WithContext log a correlation ID as the trace id of untraced contexts.
+	correlationIDs bool
+	// alertThreshold, alertWindow and alertFn raise an alert when alertThreshold errors are logged within alertWindow.
+	alertThreshold int
+	alertWindow    time.Duration
+	alertFn        func(sample []Entry)
+	// webhooks post the entries from their level to a url.
+	webhooks []webhook
+	// sqlSinks insert the entries from their level into a database.
+	sqlSinks []sqlSinkConfig
+	// writeRetry retries the failed writes of the custom writer and the
+	// network sinks, then reports them.
+	writeRetry writeRetry
+	// tenantRouting writes the entries of the tenants set with WithTenant to
+	// their own files.
+	tenantRouting bool
+	// sinkCtx is the base context of the network sinks, nil is
+	// context.Background().
+	sinkCtx context.Context
+	// durationMillis adds the milliseconds of the durations next to them.
+	durationMillis bool
+	// byteKeys are the keys of the byte counts logged with their human value.
+	byteKeys []string
+	// color is whether the console output is colored, `auto`, `always` or `never`. default is `never`.
+	color string
+	// columns are the columns of the csv and tsv encoders, nil is the time, level, caller and message.
+	columns []string
+	// stats publishes the counters of the loggers under the logger.stats expvar.
+	stats bool
+	// noTimestamps and noCaller drop the time and the caller of the entries.
+	noTimestamps bool
+	noCaller     bool
+	// levelFormat encodes the levels, nil keeps the EncodeLevel of the encoder config.
+	levelFormat LevelFormat
+	// consoleBuffer buffers the console output, up to consoleBufferSize bytes
+	// or consoleFlushInterval.
+	consoleBuffer        bool
+	consoleBufferSize    int
+	consoleFlushInterval time.Duration
+	// mmap appends to the rolling files through a memory mapping.
+	mmap bool
+	// rollingBackend rotates the files, RotateLogger unless lumberjack.
+	rollingBackend string
+	// filePerm and dirPerm are the permissions of the rolling files and their
+	// directories when created, 0666 and 0755 before the umask if 0.
+	filePerm os.FileMode
+	dirPerm  os.FileMode
+	// onRotateError is called when a rolling file fails to rotate.
+	onRotateError func(filename string, err error)
 }
 
 func newOptions(opts ...Option) Options {
 	opt := Options{
-		level:      InfoLevel,
-		mode:       ConsoleMode,
-		path:       "./logs",
-		callerSkip: callerSkipOffset,
+		level:           InfoLevel,
+		mode:            ConsoleMode,
+		path:            "./logs",
+		callerSkip:      callerSkipOffset,
+		backupDelimiter: backupFileDelimiter,
 		encoderConfig: zapcore.EncoderConfig{
 			TimeKey:        "ts",
 			MessageKey:     "msg",
@@ -84,10 +209,10 @@ func newOptions(opts ...Option) Options {
 			StacktraceKey:  "stack",
 			LineEnding:     zapcore.DefaultLineEnding,
 			NameKey:        "Logger",
-			EncodeCaller:   zapcore.ShortCallerEncoder,
+			EncodeCaller:   shortCallerEncoder,
 			EncodeLevel:    zapcore.LowercaseLevelEncoder,
 			EncodeTime:     zapcore.ISO8601TimeEncoder, // 日期格式改为"ISO8601"，例如："2020-12-16T19:12:48.771+0800"
-			EncodeDuration: zapcore.StringDurationEncoder,
+			EncodeDuration: stringDurationEncoder,
 			EncodeName:     zapcore.FullNameEncoder,
 		},
 		fields:  make(map[string]any),
@@ -97,10 +222,134 @@ func newOptions(opts ...Option) Options {
 	for _, o := range opts {
 		o(&opt)
 	}
+	if opt.noTimestamps {
+		opt.encoderConfig.TimeKey = ""
+	}
+	if opt.noCaller {
+		opt.encoderConfig.CallerKey = ""
+	}
+	if opt.levelFormat != nil {
+		opt.encoderConfig.EncodeLevel = zapcore.LevelEncoder(opt.levelFormat)
+	}
 
 	return opt
 }
 
+// validate checks o for incompatible options, listing every offending option.
+func (o Options) validate() error {
+	var problems []string
+	if o.mode == FileMode && o.writer == nil && o.path == "" {
+		problems = append(problems, ErrLogPathNotSet.Error()+" in file mode")
+	}
+	if o.writer != nil && o.filename != "" {
+		problems = append(problems, "writer and filename are mutually exclusive")
+	}
+	switch o.rotation {
+	case "", dayRotationRule, dailyRotationRule, hourRotationRule:
+	case sizeRotationRule:
+		if o.maxSize <= 0 {
+			problems = append(problems, "size rotation requires a positive max size")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown rotation %q", o.rotation))
+	}
+	if !o.encoder.IsJson() && !o.encoder.IsConsole() && !o.encoder.IsMsgpack() && !o.encoder.IsCBOR() && !o.encoder.IsOTLP() &&
+		!o.encoder.IsCSV() && !o.encoder.IsTSV() {
+		problems = append(problems, fmt.Sprintf("unknown encoder %q", o.encoder))
+	}
+	if o.auditHead != "" && !o.audit {
+		problems = append(problems, "audit head requires the audit logger")
+	}
+	if o.audit && !o.encoder.IsJson() && !o.encoder.IsConsole() {
+		problems = append(problems, fmt.Sprintf("audit requires the json or console encoder, not %s", o.encoder))
+	}
+	if o.alertFn != nil && (o.alertThreshold <= 0 || o.alertWindow <= 0) {
+		problems = append(problems, "error alert requires a positive threshold and window")
+	}
+	for _, hook := range o.webhooks {
+		if hook.url == "" {
+			problems = append(problems, "webhook requires a url")
+		}
+	}
+	for _, sink := range o.sqlSinks {
+		if sink.db == nil {
+			problems = append(problems, "sql sink requires a database")
+		}
+	}
+	if o.tenantRouting && (o.mode != FileMode || o.writer != nil) {
+		problems = append(problems, "tenant routing requires file mode")
+	}
+	if o.writeRetry.attempts < 0 || o.writeRetry.backoff < 0 {
+		problems = append(problems, "write retry requires non-negative attempts and backoff")
+	}
+	switch o.rollingBackend {
+	case "", RollingBackendDefault:
+	case RollingBackendLumberjack:
+		if o.archiveKey != nil || o.audit || o.checksum || o.pathLayout != "" || o.mmap {
+			problems = append(problems, "the lumberjack backend doesn't support encryption, audit, checksums, path layouts or mmap")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown rolling backend %q", o.rollingBackend))
+	}
+	switch o.color {
+	case "", ColorAuto, ColorAlways, ColorNever:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown color %q", o.color))
+	}
+	if len(o.columns) > 0 && !o.encoder.IsCSV() && !o.encoder.IsTSV() {
+		problems = append(problems, "columns require the csv or tsv encoder")
+	}
+	if o.archiveKey != nil {
+		if n := len(o.archiveKey); n != 16 && n != 24 && n != 32 {
+			problems = append(problems, "archive encryption key must be 16, 24 or 32 bytes")
+		}
+		if !o.compress {
+			problems = append(problems, "archive encryption requires compress")
+		}
+	}
+	if o.sampleBudget < 0 {
+		problems = append(problems, "sampling budget must not be negative")
+	}
+	for lv, rate := range o.levelSampling {
+		if !lv.valid() {
+			problems = append(problems, fmt.Sprintf("sampling of unknown level %d", lv))
+		} else if lv >= WarnLevel {
+			problems = append(problems, fmt.Sprintf("sampling of %s: warn and above are never sampled", lv))
+		} else if rate < 0 {
+			problems = append(problems, fmt.Sprintf("sampling rate of %s must not be negative", lv))
+		}
+	}
+	if o.verbosity < 0 {
+		problems = append(problems, "verbosity must not be negative")
+	}
+	if o.maxFields < 0 {
+		problems = append(problems, "max fields must not be negative")
+	}
+	if o.maxSize < 0 || o.maxBackups < 0 || o.keepDays < 0 || o.keepHours < 0 {
+		problems = append(problems, "max size, max backups, keep days and keep hours must not be negative")
+	}
+	if o.keepDays > 0 && o.keepHours > 0 {
+		problems = append(problems, "keep days and keep hours conflict, set one of them")
+	}
+	if o.backupDelimiter == "" || strings.ContainsAny(o.backupDelimiter, `/\*?[`) {
+		problems = append(problems, fmt.Sprintf("invalid backup delimiter %q", o.backupDelimiter))
+	}
+	if o.compressionWorkers < 0 || o.compressionBacklog < 0 || o.compressionRate < 0 {
+		problems = append(problems, "compression workers, backlog and rate must not be negative")
+	}
+	if o.compressionLevel < gzip.HuffmanOnly || o.compressionLevel > gzip.BestCompression {
+		problems = append(problems, fmt.Sprintf("invalid compression level %d", o.compressionLevel))
+	}
+	if o.rotateOnMaxSize && o.maxSize <= 0 {
+		problems = append(problems, "rotating on max size requires a positive max size")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidOptions, strings.Join(problems, "; "))
+}
+
 type Encoder string
 
 func (e Encoder) String() string {
@@ -117,9 +366,53 @@ func (e Encoder) IsConsole() bool {
 	return e.String() == ConsoleEncoder.String()
 }
 
+// IsMsgpack Whether msgpack encoder.
+func (e Encoder) IsMsgpack() bool {
+	return e.String() == MsgpackEncoder.String()
+}
+
+// IsCBOR Whether cbor encoder.
+func (e Encoder) IsCBOR() bool {
+	return e.String() == CBOREncoder.String()
+}
+
+// IsOTLP Whether otlp encoder.
+func (e Encoder) IsOTLP() bool {
+	return e.String() == OTLPEncoder.String()
+}
+
+// IsCSV Whether csv encoder.
+func (e Encoder) IsCSV() bool {
+	return e.String() == CSVEncoder.String()
+}
+
+// IsTSV Whether tsv encoder.
+func (e Encoder) IsTSV() bool {
+	return e.String() == TSVEncoder.String()
+}
+
 const (
 	JsonEncoder    Encoder = "json"
 	ConsoleEncoder Encoder = "console"
+	// MsgpackEncoder writes every entry as a MessagePack map, without a line
+	// ending, e.g. for fluentd. It is more compact and cheaper than JSON.
+	MsgpackEncoder Encoder = "msgpack"
+	// CBOREncoder writes every entry as a CBOR map, RFC 8949, without a line
+	// ending. Unlike JSON it keeps 64-bit integers and byte strings as they
+	// are, and big.Int values, logged with zap.Reflect or in structs, as
+	// bignums.
+	CBOREncoder Encoder = "cbor"
+	// OTLPEncoder writes every entry as an OpenTelemetry LogRecord protobuf
+	// prefixed with its varint length, so the files can be replayed into an
+	// OTLP pipeline. The level is the severity number and the trace_id and
+	// span_id fields the trace context of the record.
+	OTLPEncoder Encoder = "otlp"
+	// CSVEncoder writes every entry as a row of the columns set by
+	// WithColumns, and the rolling files start with a header row. Nested
+	// keys are joined with dots and the fields without a column are dropped.
+	CSVEncoder Encoder = "csv"
+	// TSVEncoder is the CSVEncoder with tabs separating the columns.
+	TSVEncoder Encoder = "tsv"
 )
 
 // WithLevel Setter function to set the logging level.
@@ -136,14 +429,17 @@ func WithMode(mode string) Option {
 	}
 }
 
-// WithPath Setter function to set the log path.
+// WithPath Setter function to set the log path. The {pid} and {hostname}
+// placeholders are replaced by those of the process.
 func WithPath(path string) Option {
 	return func(o *Options) {
 		o.path = path
 	}
 }
 
-// WithFilename Setter function to set the log filename.
+// WithFilename Setter function to set the log filename. The {pid} and
+// {hostname} placeholders are replaced by those of the process, e.g.
+// `app-{pid}.log`.
 func WithFilename(filename string) Option {
 	return func(o *Options) {
 		o.filename = filename
@@ -157,6 +453,16 @@ func WithMaxSize(maxSize int) Option {
 	}
 }
 
+// WithRotateOnMaxSize Setter function to set whether the daily and hourly
+// rotations also rotate a file early once it reaches the size of WithMaxSize,
+// default is false. The backups of a period after the first get a `.1`,
+// `.2`, ... suffix.
+func WithRotateOnMaxSize(enabled bool) Option {
+	return func(o *Options) {
+		o.rotateOnMaxSize = enabled
+	}
+}
+
 // WithMaxBackups Setter function to set the maximum number of log backups.
 func WithMaxBackups(maxBackups int) Option {
 	return func(o *Options) {
@@ -171,6 +477,42 @@ func WithCompress(compress bool) Option {
 	}
 }
 
+// WithBackupDelimiter Setter function to set what separates the filename and
+// the time in the backup names, default is `-`, e.g. `.` for app.log.2006-01-02.
+func WithBackupDelimiter(delimiter string) Option {
+	return func(o *Options) {
+		o.backupDelimiter = delimiter
+	}
+}
+
+// WithCompressionWorkers Setter function to set how many backups of the
+// logger are compressed at a time, and how many rotated backups may wait.
+// The backups that don't fit are compressed by the hourly retention. By
+// default the loggers of the process share a single worker and a backlog
+// of 64.
+func WithCompressionWorkers(workers, backlog int) Option {
+	return func(o *Options) {
+		o.compressionWorkers, o.compressionBacklog = workers, backlog
+	}
+}
+
+// WithCompressionLevel Setter function to set the gzip level of the backups,
+// e.g. gzip.BestSpeed to spend less CPU on large files. 0 keeps the default.
+func WithCompressionLevel(level int) Option {
+	return func(o *Options) {
+		o.compressionLevel = level
+	}
+}
+
+// WithCompressionRate Setter function to limit the bytes read per second
+// when compressing a backup, so compression doesn't saturate the disk.
+// 0 means no limit.
+func WithCompressionRate(bytesPerSecond int) Option {
+	return func(o *Options) {
+		o.compressionRate = bytesPerSecond
+	}
+}
+
 // WithCallerSkip Setter function to set the caller skip value.
 func WithCallerSkip(callerSkip int) Option {
 	return func(o *Options) {
@@ -178,6 +520,33 @@ func WithCallerSkip(callerSkip int) Option {
 	}
 }
 
+// WithCaller Setter function to set whether the entries have the caller,
+// default is true. Without it the call site isn't looked up at all, and
+// the CallerKey of WithEncoderConfig is ignored.
+func WithCaller(enabled bool) Option {
+	return func(o *Options) {
+		o.noCaller = !enabled
+	}
+}
+
+// WithTimestamps Setter function to set whether the entries have the time,
+// default is true. Disable it where the output is timestamped already, e.g.
+// by journald under systemd. It holds over WithEncoderConfig.
+func WithTimestamps(enabled bool) Option {
+	return func(o *Options) {
+		o.noTimestamps = !enabled
+	}
+}
+
+// WithLevelFormat Setter function to set how the levels are encoded, one of
+// LevelLowercase, LevelCapital, LevelShort or LevelCustom, e.g. `WRN` with
+// LevelShort. It holds over WithEncoderConfig.
+func WithLevelFormat(format LevelFormat) Option {
+	return func(o *Options) {
+		o.levelFormat = format
+	}
+}
+
 // WithNamespace Setter function to set the namespace.
 func WithNamespace(namespace string) Option {
 	return func(o *Options) {
@@ -185,6 +554,13 @@ func WithNamespace(namespace string) Option {
 	}
 }
 
+// WithName Setter function to set the logger name.
+func WithName(name string) Option {
+	return func(o *Options) {
+		o.name = name
+	}
+}
+
 // Fields Setter function to set the logger fields.
 func Fields(fields map[string]any) Option {
 	return func(o *Options) {
@@ -206,12 +582,33 @@ func WithEncoderConfig(encoderConfig zapcore.EncoderConfig) Option {
 	}
 }
 
+// sinkContext returns the base context of the network sinks.
+func (o Options) sinkContext() context.Context {
+	if o.sinkCtx == nil {
+		return context.Background()
+	}
+	return o.sinkCtx
+}
+
+// retentionHours returns how many hours the backups are kept, keepHours or
+// else keepDays, 0 keeps them.
+func (o Options) retentionHours() int {
+	if o.keepHours > 0 {
+		return o.keepHours
+	}
+	return o.keepDays * hoursPerDay
+}
+
+// WithKeepHours Setter function to set how many hours the backups are kept,
+// with every rotation. It conflicts with WithKeepDays.
 func WithKeepHours(keepHours int) Option {
 	return func(o *Options) {
 		o.keepHours = keepHours
 	}
 }
 
+// WithKeepDays Setter function to set how many days the backups are kept,
+// with every rotation. It conflicts with WithKeepHours.
 func WithKeepDays(keepDays int) Option {
 	return func(o *Options) {
 		o.keepDays = keepDays
@@ -229,3 +626,369 @@ func WithWriter(w io.Writer) Option {
 		o.writer = w
 	}
 }
+
+// WithHostFields Setter function to attach hostname, pid and ip to every entry.
+func WithHostFields() Option {
+	return func(o *Options) {
+		o.metaFields = append(o.metaFields, hostFields()...)
+	}
+}
+
+// WithServiceInfo Setter function to attach service.name, service.version and
+// service.environment to every entry. Empty values are skipped.
+func WithServiceInfo(name, version, env string) Option {
+	return func(o *Options) {
+		o.metaFields = append(o.metaFields, serviceFields(name, version, env)...)
+	}
+}
+
+// WithBuildInfo Setter function to attach vcs.revision, vcs.time, go.version and
+// module version from debug.ReadBuildInfo to every entry.
+func WithBuildInfo() Option {
+	return func(o *Options) {
+		o.metaFields = append(o.metaFields, buildFields()...)
+	}
+}
+
+// WithFlushOnExit Setter function to close the logger when the process
+// receives SIGINT or SIGTERM, so buffered entries aren't lost on shutdown.
+// The signal is then delivered again so the process exits as it would have.
+// An application handling these signals itself calls SetExitSignalsHandled:
+// the logger is then only synced and stays open, the signal isn't delivered
+// again, and the application calls Shutdown at the end of its own shutdown,
+// which also closes the logger.
+func WithFlushOnExit() Option {
+	return func(o *Options) {
+		o.flushOnExit = true
+	}
+}
+
+// WithInternalWriter Setter function to write the diagnostics of the logger
+// itself, such as failed rotations, compression results and dropped webhook
+// entries, to w rather than to the output of SetInternalOutput, stderr by
+// default. They are rate limited JSON lines, a nil w discards them.
+func WithInternalWriter(w io.Writer) Option {
+	return func(o *Options) {
+		o.internal = internalLogger{lg: newInternalLogger(w, defaultInternalRate)}
+	}
+}
+
+// WithPanicFlush Setter function to sync the logger when FlushOnPanic,
+// deferred in main, catches a panic, so the entries explaining a crash are
+// written before the process dies.
+func WithPanicFlush() Option {
+	return func(o *Options) {
+		o.panicFlush = true
+	}
+}
+
+// WithConsoleBuffer Setter function to buffer the console output, written
+// once size bytes are buffered or every flushInterval, for jobs logging so
+// much that the writes to stdout dominate. size defaults to 256KB and
+// flushInterval to 1s. Sync flushes the buffer, entries above ErrorLevel
+// are written right away.
+func WithConsoleBuffer(size int, flushInterval time.Duration) Option {
+	return func(o *Options) {
+		o.consoleBuffer = true
+		o.consoleBufferSize, o.consoleFlushInterval = size, flushInterval
+	}
+}
+
+// WithExperimentalMmap Setter function to append to the rolling files through
+// a memory mapping grown a few MB at a time, saving a write syscall per page
+// for very high volumes. It's experimental and meant for files written by a
+// single process: until a file is closed its end is padded with zeros, which
+// readers of the active file see. Writes go to the file where mapping fails.
+func WithExperimentalMmap() Option {
+	return func(o *Options) {
+		o.mmap = true
+	}
+}
+
+// WithRollingBackend Setter function to set what rotates the files in file
+// mode: RollingBackendDefault, the RotateLogger of the package, or
+// RollingBackendLumberjack, a lumberjack.Logger configured from the same
+// options for those relying on its semantics. lumberjack rotates by size
+// only, at WithMaxSize or 100MB whatever the rotation, removes the backups
+// older than the retention in days and beyond WithMaxBackups, and names them
+// with the local time. It doesn't support encryption, audit, checksums,
+// path layouts or mmap.
+func WithRollingBackend(backend string) Option {
+	return func(o *Options) {
+		o.rollingBackend = backend
+	}
+}
+
+// WithFilePerm Setter function to set the permission of the rolling files
+// when they are created, default is 0666 before the umask. The permission of
+// existing files is left alone.
+func WithFilePerm(perm os.FileMode) Option {
+	return func(o *Options) {
+		o.filePerm = perm
+	}
+}
+
+// WithDirPerm Setter function to set the permission of the directories of
+// the rolling files when they are created, default is 0755 before the umask.
+func WithDirPerm(perm os.FileMode) Option {
+	return func(o *Options) {
+		o.dirPerm = perm
+	}
+}
+
+// WithRotateErrorHandler Setter function to call fn when a rolling file
+// fails to rotate, e.g. to alert operators. Renaming the file to its backup
+// is retried with a backoff, then the file is copied and truncated. If that
+// fails too, fn is called and entries go on to the file until the rotation
+// is retried a minute later. fn runs on the goroutine writing the file.
+func WithRotateErrorHandler(fn func(filename string, err error)) Option {
+	return func(o *Options) {
+		o.onRotateError = fn
+	}
+}
+
+// WithBufferPoolSize Setter function to set the number of idle buffers retained
+// for the rolling files.
+func WithBufferPoolSize(size int) Option {
+	return func(o *Options) {
+		o.bufferPoolSize = size
+	}
+}
+
+// WithMaxBufferCapacity Setter function to set the capacity in bytes above
+// which buffers are released instead of reused.
+func WithMaxBufferCapacity(capacity int) Option {
+	return func(o *Options) {
+		o.maxBufferCapacity = capacity
+	}
+}
+
+// WithMaxFields Setter function to limit the number of structured fields per
+// entry, counting the fields of WithFields, WithContext and the call site.
+// Extra fields are dropped and counted in a `_truncated_fields` field. 0 means
+// no limit.
+func WithMaxFields(limit int) Option {
+	return func(o *Options) {
+		o.maxFields = limit
+	}
+}
+
+// WithVerbosity Setter function to set the highest V-level logged by V, like
+// the -v flag of glog and klog.
+func WithVerbosity(v int) Option {
+	return func(o *Options) {
+		o.verbosity = v
+	}
+}
+
+// WithAdaptiveSampling Setter function to keep the entries per second within
+// budget. When a second exceeds it, debug and info entries of the next second
+// are sampled in proportion, warn and above are always written.
+func WithAdaptiveSampling(budget int) Option {
+	return func(o *Options) {
+		o.sampleBudget = budget
+	}
+}
+
+// WithLevelSampling Setter function to keep one of every rate entries of a
+// level, e.g. {DebugLevel: 1000, InfoLevel: 100}. The levels without a rate
+// or with a rate <= 1 are never sampled. Warn and above can't be given a
+// rate, so warnings and errors stay visible however much debug and info is
+// dropped. Each level is counted separately. It combines with
+// WithAdaptiveSampling, applied to the entries it kept.
+func WithLevelSampling(rates map[Level]int) Option {
+	return func(o *Options) {
+		o.levelSampling = rates
+	}
+}
+
+// WithArchiveEncryption Setter function to encrypt the backups with AES-GCM
+// when they are compressed, so log files on shared volumes are protected at
+// rest. key must be 16, 24 or 32 bytes. It enables compress, encrypted backups
+// are named `.gz.enc` and read with DecryptArchive.
+func WithArchiveEncryption(key []byte) Option {
+	return func(o *Options) {
+		o.archiveKey = key
+		o.compress = true
+	}
+}
+
+// WithAuditHead Setter function to continue the chain of NewAudit from head,
+// the AuditHead anchored by the previous run, when the previous entries can't
+// be read: with WithWriter, or when the audit files were removed.
+func WithAuditHead(head string) Option {
+	return func(o *Options) {
+		o.auditHead = head
+	}
+}
+
+// WithChecksum Setter function to write a `.sha256` sidecar in sha256sum format
+// for every backup once it's rotated and compressed, so shipped archives can
+// be checked with Verify.
+func WithChecksum() Option {
+	return func(o *Options) {
+		o.checksum = true
+	}
+}
+
+// WithPathLayout Setter function to write the files to a directory under the
+// log path formatted from the current time with layout, e.g. `2006/01/02`
+// writes `logs/2024/06/01/app.log`. Files move to the next directory when the
+// formatted time changes, and the directories older than the keep days or
+// keep hours are removed.
+func WithPathLayout(layout string) Option {
+	return func(o *Options) {
+		o.pathLayout = layout
+	}
+}
+
+// WithFatalHook Setter function to set what runs after a fatal entry is
+// written, instead of exiting the process, e.g. zapcore.WriteThenGoexit.
+func WithFatalHook(hook zapcore.CheckWriteHook) Option {
+	return func(o *Options) {
+		o.fatalHook = hook
+	}
+}
+
+// WithColumns Setter function to set the columns of the csv and tsv encoders,
+// in order, e.g. `ts`, `msg` and `user.id` for the id field of the user
+// object. Default is the time, level, caller and message.
+func WithColumns(columns ...string) Option {
+	return func(o *Options) {
+		o.columns = columns
+	}
+}
+
+// WithColor Setter function to color the levels and durations of the console
+// encoder in console mode: ColorAuto when the output is a terminal and the
+// NO_COLOR environment variable is empty, ColorAlways or ColorNever.
+func WithColor(color string) Option {
+	return func(o *Options) {
+		o.color = color
+	}
+}
+
+// WithSortedFields Setter function to emit the fields of the maps given to
+// WithFields and Fields sorted by key rather than in map iteration order, so
+// the entries are stable for diffs and golden tests. Fields added with With
+// keep their order.
+func WithSortedFields() Option {
+	return func(o *Options) {
+		o.sortedFields = true
+	}
+}
+
+// WithCorrelationIDs Setter function to make WithContext log a correlation ID
+// as the trace_id when the context has no span: the ID carried by the context
+// from ContextWithCorrelationID, otherwise a random one for the logger
+// returned, so even untraced requests can be followed across their lines.
+func WithCorrelationIDs() Option {
+	return func(o *Options) {
+		o.correlationIDs = true
+	}
+}
+
+// WithDurationMillis Setter function to log the durations in milliseconds
+// under their key with an `_ms` suffix, next to their human value, e.g.
+// `"latency":"1.5s","latency_ms":1500`, so they stay easy to read and to
+// query. Like every option, it applies to the logger it is set on, so a
+// channel such as the slow logs can set it on its own.
+func WithDurationMillis() Option {
+	return func(o *Options) {
+		o.durationMillis = true
+	}
+}
+
+// WithHumanBytes Setter function to log the integer fields named keys, byte
+// counts, with their human value under their key with a `_human` suffix,
+// e.g. `"size":1258291,"size_human":"1.2MB"`.
+func WithHumanBytes(keys ...string) Option {
+	return func(o *Options) {
+		o.byteKeys = append(o.byteKeys, keys...)
+	}
+}
+
+// WithSinkContext Setter function to set the base context of the network
+// sinks, the webhooks and the sql sinks: once ctx is done, they stop sending
+// and retrying, e.g. when the service shuts down. Close and Shutdown still
+// send the queued entries a last time, bounded by the context of Shutdown.
+func WithSinkContext(ctx context.Context) Option {
+	return func(o *Options) {
+		o.sinkCtx = ctx
+	}
+}
+
+// WithTenantRouting Setter function to write the entries of the loggers from
+// WithContext of a context with a tenant, set by WithTenant, to rolling files
+// of their own in a directory of the tenant under the log path, for tenants
+// requiring their logs isolated, named after the tenant ID with its other
+// bytes than letters, digits, '-', '_' and '.' escaped as %XX. The files of
+// a tenant are created on first use, with the rotation of the logger, and
+// the files of the least recently used tenants are closed beyond 64 open
+// tenants, until their next entry. Webhooks, sql sinks and error alerts don't
+// get the entries of the tenants. It requires the file mode.
+func WithTenantRouting() Option {
+	return func(o *Options) {
+		o.tenantRouting = true
+	}
+}
+
+// WithErrorAlert Setter function to call fn when n entries of ErrorLevel or
+// above are logged within window, e.g. to page through a webhook. fn gets
+// the n entries and runs in its own goroutine, then the count starts over.
+func WithErrorAlert(n int, window time.Duration, fn func(sample []Entry)) Option {
+	return func(o *Options) {
+		o.alertThreshold, o.alertWindow, o.alertFn = n, window, fn
+	}
+}
+
+// WithWebhook Setter function to post the entries of minLevel and above to
+// url as JSON, in batches and rate limited, e.g. for chat notifications on
+// errors. Entries above ErrorLevel are posted right away. It can be set
+// more than once.
+func WithWebhook(url string, minLevel Level, opts ...WebhookOption) Option {
+	return func(o *Options) {
+		o.webhooks = append(o.webhooks, webhook{url: url, level: minLevel, opts: opts})
+	}
+}
+
+// WithSQLSink Setter function to insert the entries of minLevel and above into
+// a table of db, in batches, so recent logs can be queried with SQL on hosts
+// without a central stack. The table, indexed on ts, level and trace_id, is
+// created if it doesn't exist; db is opened with the driver of the dialect,
+// SQLite by default. It can be set more than once.
+func WithSQLSink(db *sql.DB, minLevel Level, opts ...SQLSinkOption) Option {
+	return func(o *Options) {
+		o.sqlSinks = append(o.sqlSinks, sqlSinkConfig{db: db, level: minLevel, opts: opts})
+	}
+}
+
+// WithWriteErrorHandler Setter function to call fn when a write to the
+// custom writer, a webhook or a sql sink fails, once the retries set with
+// WithWriteRetry are exhausted, so a transient sink error doesn't lose the
+// entry silently. entry is the encoded entry, or the batch for the sinks.
+func WithWriteErrorHandler(fn func(err error, entry []byte)) Option {
+	return func(o *Options) {
+		o.writeRetry.onError = fn
+	}
+}
+
+// WithWriteRetry Setter function to try the writes to the custom writer, a
+// webhook or a sql sink up to attempts times, waiting backoff before the
+// first retry and twice as long before every next one. The default is a
+// single attempt.
+func WithWriteRetry(attempts int, backoff time.Duration) Option {
+	return func(o *Options) {
+		o.writeRetry.attempts, o.writeRetry.backoff = attempts, backoff
+	}
+}
+
+// WithStats Setter function to publish the counters of the loggers under the
+// `logger.stats` expvar map, so debug endpoints serving expvar show them:
+// the entries per level of this logger, and the bytes written, rotations,
+// drops and compression time of every logger once one has it set.
+func WithStats() Option {
+	return func(o *Options) {
+		o.stats = true
+	}
+}
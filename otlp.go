@@ -0,0 +1,456 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// Field numbers of opentelemetry.proto.logs.v1.LogRecord and of the common
+// AnyValue, ArrayValue, KeyValueList and KeyValue messages.
+const (
+	logRecordTime           = 1
+	logRecordSeverityNumber = 2
+	logRecordSeverityText   = 3
+	logRecordBody           = 5
+	logRecordAttributes     = 6
+	logRecordTraceID        = 9
+	logRecordSpanID         = 10
+
+	anyValueString = 1
+	anyValueBool   = 2
+	anyValueInt    = 3
+	anyValueDouble = 4
+	anyValueArray  = 5
+	anyValueKvlist = 6
+	anyValueBytes  = 7
+
+	// listValues is the field of the values of ArrayValue and KeyValueList.
+	listValues    = 1
+	keyValueKey   = 1
+	keyValueValue = 2
+)
+
+// The attributes of the caller, from the OpenTelemetry semantic conventions.
+const (
+	codeFilepathKey = "code.filepath"
+	codeLinenoKey   = "code.lineno"
+	codeFunctionKey = "code.function"
+)
+
+// otlpPool recycles the buffers of the encoded entries.
+var otlpPool = buffer.NewPool()
+
+// otlpLevel holds the KeyValue messages of an attribute map being encoded,
+// the root one or an open namespace.
+type otlpLevel struct {
+	key string
+	buf []byte
+}
+
+// otlpEncoder encodes entries as OTLP LogRecord protobufs, each prefixed
+// with its varint length, like protobuf's delimited streams. The message is
+// the body, the fields are attributes, with namespaces and objects as
+// key-value lists, and the trace_id and span_id fields added by WithContext
+// fill the trace context of the record. Durations are integer nanoseconds
+// and times integer unix nanoseconds.
+type otlpEncoder struct {
+	cfg *zapcore.EncoderConfig
+	// field is the number of the repeated KeyValue field of the root map,
+	// the attributes of a record or the values of a key-value list.
+	field  int
+	levels []otlpLevel
+	// traceID and spanID are the trace context of the record.
+	traceID, spanID []byte
+}
+
+func newOTLPEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &otlpEncoder{cfg: &cfg, field: logRecordAttributes, levels: make([]otlpLevel, 1)}
+}
+
+// add adds the attribute key with the AnyValue message value.
+func (enc *otlpEncoder) add(key string, value []byte) {
+	field := enc.field
+	if len(enc.levels) > 1 {
+		field = listValues
+	}
+	l := &enc.levels[len(enc.levels)-1]
+	l.buf = appendProtoBytes(l.buf, field, appendKeyValue(nil, key, value))
+}
+
+func (enc *otlpEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	value, err := otlpArrayValue(enc.cfg, arr)
+	enc.add(key, value)
+	return err
+}
+
+func (enc *otlpEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	value, err := otlpObjectValue(enc.cfg, obj)
+	enc.add(key, value)
+	return err
+}
+
+func (enc *otlpEncoder) AddBinary(key string, value []byte) {
+	enc.add(key, appendProtoBytes(nil, anyValueBytes, value))
+}
+
+func (enc *otlpEncoder) AddByteString(key string, value []byte) {
+	enc.add(key, otlpString(string(value)))
+}
+
+func (enc *otlpEncoder) AddBool(key string, value bool) {
+	enc.add(key, otlpBool(value))
+}
+
+func (enc *otlpEncoder) AddComplex128(key string, value complex128) {
+	enc.add(key, otlpString(formatComplex(value)))
+}
+
+func (enc *otlpEncoder) AddComplex64(key string, value complex64) {
+	enc.AddComplex128(key, complex128(value))
+}
+
+func (enc *otlpEncoder) AddDuration(key string, value time.Duration) {
+	enc.add(key, otlpInt(int64(value)))
+}
+
+func (enc *otlpEncoder) AddFloat64(key string, value float64) {
+	enc.add(key, otlpDouble(value))
+}
+
+func (enc *otlpEncoder) AddFloat32(key string, value float32) {
+	enc.add(key, otlpDouble(float64(value)))
+}
+
+func (enc *otlpEncoder) AddInt(key string, value int)     { enc.AddInt64(key, int64(value)) }
+func (enc *otlpEncoder) AddInt32(key string, value int32) { enc.AddInt64(key, int64(value)) }
+func (enc *otlpEncoder) AddInt16(key string, value int16) { enc.AddInt64(key, int64(value)) }
+func (enc *otlpEncoder) AddInt8(key string, value int8)   { enc.AddInt64(key, int64(value)) }
+
+func (enc *otlpEncoder) AddInt64(key string, value int64) {
+	enc.add(key, otlpInt(value))
+}
+
+// AddString adds the attribute key, or the trace context for the trace_id and
+// span_id fields at the root of a record.
+func (enc *otlpEncoder) AddString(key, value string) {
+	if enc.field == logRecordAttributes && len(enc.levels) == 1 {
+		switch key {
+		case traceKey:
+			if id, err := hex.DecodeString(value); err == nil && len(id) == 16 {
+				enc.traceID = id
+				return
+			}
+		case spanKey:
+			if id, err := hex.DecodeString(value); err == nil && len(id) == 8 {
+				enc.spanID = id
+				return
+			}
+		}
+	}
+	enc.add(key, otlpString(value))
+}
+
+func (enc *otlpEncoder) AddTime(key string, value time.Time) {
+	enc.add(key, otlpInt(value.UnixNano()))
+}
+
+func (enc *otlpEncoder) AddUint(key string, value uint)       { enc.AddUint64(key, uint64(value)) }
+func (enc *otlpEncoder) AddUint32(key string, value uint32)   { enc.AddUint64(key, uint64(value)) }
+func (enc *otlpEncoder) AddUint16(key string, value uint16)   { enc.AddUint64(key, uint64(value)) }
+func (enc *otlpEncoder) AddUint8(key string, value uint8)     { enc.AddUint64(key, uint64(value)) }
+func (enc *otlpEncoder) AddUintptr(key string, value uintptr) { enc.AddUint64(key, uint64(value)) }
+
+func (enc *otlpEncoder) AddUint64(key string, value uint64) {
+	enc.add(key, otlpUint(value))
+}
+
+func (enc *otlpEncoder) AddReflected(key string, value interface{}) error {
+	v, err := otlpReflectedValue(value)
+	enc.add(key, v)
+	return err
+}
+
+func (enc *otlpEncoder) OpenNamespace(key string) {
+	enc.levels = append(enc.levels, otlpLevel{key: key})
+}
+
+// closeNamespaces closes the open namespaces, each becoming a key-value list
+// attribute of its parent.
+func (enc *otlpEncoder) closeNamespaces() {
+	for len(enc.levels) > 1 {
+		inner := enc.levels[len(enc.levels)-1]
+		enc.levels = enc.levels[:len(enc.levels)-1]
+		enc.add(inner.key, appendProtoBytes(nil, anyValueKvlist, inner.buf))
+	}
+}
+
+func (enc *otlpEncoder) Clone() zapcore.Encoder {
+	return enc.clone()
+}
+
+func (enc *otlpEncoder) clone() *otlpEncoder {
+	levels := make([]otlpLevel, len(enc.levels))
+	for i, l := range enc.levels {
+		levels[i] = otlpLevel{key: l.key, buf: append([]byte(nil), l.buf...)}
+	}
+	return &otlpEncoder{cfg: enc.cfg, field: enc.field, levels: levels, traceID: enc.traceID, spanID: enc.spanID}
+}
+
+func (enc *otlpEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := enc.clone()
+	for i := range fields {
+		fields[i].AddTo(final)
+	}
+	final.closeNamespaces()
+
+	rec := appendProtoFixed64(nil, logRecordTime, uint64(ent.Time.UnixNano()))
+	rec = appendProtoVarint(rec, logRecordSeverityNumber, uint64(severityNumber(ent.Level)))
+	rec = appendProtoBytes(rec, logRecordSeverityText, []byte(ent.Level.CapitalString()))
+	rec = appendProtoBytes(rec, logRecordBody, otlpString(ent.Message))
+
+	// the entry metadata, then the context and the entry fields.
+	attrs := &otlpEncoder{cfg: enc.cfg, field: logRecordAttributes, levels: make([]otlpLevel, 1)}
+	if ent.LoggerName != "" && enc.cfg.NameKey != "" {
+		attrs.add(enc.cfg.NameKey, otlpString(ent.LoggerName))
+	}
+	if ent.Caller.Defined {
+		attrs.add(codeFilepathKey, otlpString(ent.Caller.File))
+		attrs.add(codeLinenoKey, otlpInt(int64(ent.Caller.Line)))
+		if ent.Caller.Function != "" {
+			attrs.add(codeFunctionKey, otlpString(ent.Caller.Function))
+		}
+	}
+	if ent.Stack != "" && enc.cfg.StacktraceKey != "" {
+		attrs.add(enc.cfg.StacktraceKey, otlpString(ent.Stack))
+	}
+	rec = append(rec, attrs.levels[0].buf...)
+	rec = append(rec, final.levels[0].buf...)
+
+	if final.traceID != nil {
+		rec = appendProtoBytes(rec, logRecordTraceID, final.traceID)
+	}
+	if final.spanID != nil {
+		rec = appendProtoBytes(rec, logRecordSpanID, final.spanID)
+	}
+
+	out := otlpPool.Get()
+	out.Write(appendUvarint(nil, uint64(len(rec))))
+	out.Write(rec)
+	return out, nil
+}
+
+// severityNumber returns the OTLP SeverityNumber of lvl.
+func severityNumber(lvl zapcore.Level) int {
+	switch lvl {
+	case zapcore.DebugLevel:
+		return 5
+	case zapcore.InfoLevel:
+		return 9
+	case zapcore.WarnLevel:
+		return 13
+	case zapcore.ErrorLevel:
+		return 17
+	case zapcore.DPanicLevel:
+		return 18
+	case zapcore.PanicLevel:
+		return 19
+	case zapcore.FatalLevel:
+		return 21
+	default:
+		return 0
+	}
+}
+
+// otlpArray encodes the elements of an ArrayValue.
+type otlpArray struct {
+	cfg *zapcore.EncoderConfig
+	buf []byte
+}
+
+func (a *otlpArray) append(value []byte) {
+	a.buf = appendProtoBytes(a.buf, listValues, value)
+}
+
+func (a *otlpArray) AppendArray(arr zapcore.ArrayMarshaler) error {
+	value, err := otlpArrayValue(a.cfg, arr)
+	a.append(value)
+	return err
+}
+
+func (a *otlpArray) AppendObject(obj zapcore.ObjectMarshaler) error {
+	value, err := otlpObjectValue(a.cfg, obj)
+	a.append(value)
+	return err
+}
+
+func (a *otlpArray) AppendReflected(value interface{}) error {
+	v, err := otlpReflectedValue(value)
+	a.append(v)
+	return err
+}
+
+func (a *otlpArray) AppendBool(value bool)              { a.append(otlpBool(value)) }
+func (a *otlpArray) AppendByteString(value []byte)      { a.append(otlpString(string(value))) }
+func (a *otlpArray) AppendComplex128(value complex128)  { a.append(otlpString(formatComplex(value))) }
+func (a *otlpArray) AppendComplex64(value complex64)    { a.AppendComplex128(complex128(value)) }
+func (a *otlpArray) AppendDuration(value time.Duration) { a.append(otlpInt(int64(value))) }
+func (a *otlpArray) AppendFloat64(value float64)        { a.append(otlpDouble(value)) }
+func (a *otlpArray) AppendFloat32(value float32)        { a.append(otlpDouble(float64(value))) }
+func (a *otlpArray) AppendInt(value int)                { a.append(otlpInt(int64(value))) }
+func (a *otlpArray) AppendInt64(value int64)            { a.append(otlpInt(value)) }
+func (a *otlpArray) AppendInt32(value int32)            { a.append(otlpInt(int64(value))) }
+func (a *otlpArray) AppendInt16(value int16)            { a.append(otlpInt(int64(value))) }
+func (a *otlpArray) AppendInt8(value int8)              { a.append(otlpInt(int64(value))) }
+func (a *otlpArray) AppendString(value string)          { a.append(otlpString(value)) }
+func (a *otlpArray) AppendTime(value time.Time)         { a.append(otlpInt(value.UnixNano())) }
+func (a *otlpArray) AppendUint(value uint)              { a.append(otlpUint(uint64(value))) }
+func (a *otlpArray) AppendUint64(value uint64)          { a.append(otlpUint(value)) }
+func (a *otlpArray) AppendUint32(value uint32)          { a.append(otlpUint(uint64(value))) }
+func (a *otlpArray) AppendUint16(value uint16)          { a.append(otlpUint(uint64(value))) }
+func (a *otlpArray) AppendUint8(value uint8)            { a.append(otlpUint(uint64(value))) }
+func (a *otlpArray) AppendUintptr(value uintptr)        { a.append(otlpUint(uint64(value))) }
+
+func otlpArrayValue(cfg *zapcore.EncoderConfig, arr zapcore.ArrayMarshaler) ([]byte, error) {
+	a := &otlpArray{cfg: cfg}
+	err := arr.MarshalLogArray(a)
+	return appendProtoBytes(nil, anyValueArray, a.buf), err
+}
+
+func otlpObjectValue(cfg *zapcore.EncoderConfig, obj zapcore.ObjectMarshaler) ([]byte, error) {
+	enc := &otlpEncoder{cfg: cfg, field: listValues, levels: make([]otlpLevel, 1)}
+	err := obj.MarshalLogObject(enc)
+	enc.closeNamespaces()
+	return appendProtoBytes(nil, anyValueKvlist, enc.levels[0].buf), err
+}
+
+// otlpReflectedValue returns the AnyValue of the JSON form of value, numbers
+// kept as integers when they are.
+func otlpReflectedValue(value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return otlpString(fmt.Sprintf("%v", value)), err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err = dec.Decode(&v); err != nil {
+		return otlpString(string(data)), err
+	}
+	return otlpValue(v), nil
+}
+
+func otlpValue(v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		// an empty AnyValue is the null value.
+		return nil
+	case bool:
+		return otlpBool(v)
+	case string:
+		return otlpString(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return otlpInt(i)
+		}
+		if f, err := v.Float64(); err == nil {
+			return otlpDouble(f)
+		}
+		return otlpString(v.String())
+	case []interface{}:
+		var arr []byte
+		for _, e := range v {
+			arr = appendProtoBytes(arr, listValues, otlpValue(e))
+		}
+		return appendProtoBytes(nil, anyValueArray, arr)
+	case map[string]interface{}:
+		var list []byte
+		for k, e := range v {
+			list = appendProtoBytes(list, listValues, appendKeyValue(nil, k, otlpValue(e)))
+		}
+		return appendProtoBytes(nil, anyValueKvlist, list)
+	default:
+		return otlpString(fmt.Sprint(v))
+	}
+}
+
+func otlpString(s string) []byte {
+	if !utf8.ValidString(s) {
+		// protobuf strings must be valid UTF-8.
+		s = strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+	return appendProtoBytes(nil, anyValueString, []byte(s))
+}
+
+func otlpBool(v bool) []byte {
+	if v {
+		return appendProtoVarint(nil, anyValueBool, 1)
+	}
+	return appendProtoVarint(nil, anyValueBool, 0)
+}
+
+func otlpInt(v int64) []byte {
+	return appendProtoVarint(nil, anyValueInt, uint64(v))
+}
+
+// otlpUint returns v as an integer, or as a string above the int64 range of
+// the integer value.
+func otlpUint(v uint64) []byte {
+	if v > math.MaxInt64 {
+		return otlpString(fmt.Sprint(v))
+	}
+	return otlpInt(int64(v))
+}
+
+func otlpDouble(v float64) []byte {
+	return appendProtoFixed64(nil, anyValueDouble, math.Float64bits(v))
+}
+
+func appendKeyValue(b []byte, key string, value []byte) []byte {
+	b = appendProtoBytes(b, keyValueKey, []byte(key))
+	return appendProtoBytes(b, keyValueValue, value)
+}
+
+func appendProtoTag(b []byte, field, wireType int) []byte {
+	return appendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	return appendUvarint(appendProtoTag(b, field, wireVarint), v)
+}
+
+func appendProtoFixed64(b []byte, field int, v uint64) []byte {
+	return appendUint64LE(appendProtoTag(b, field, wireFixed64), v)
+}
+
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = appendUvarint(appendProtoTag(b, field, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendUint64LE(b []byte, v uint64) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24), byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56))
+}
@@ -0,0 +1,273 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// protoField is a decoded protobuf field, v holding varints and fixed
+// integers and b the length-delimited values.
+type protoField struct {
+	num int
+	v   uint64
+	b   []byte
+}
+
+func decodeProto(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errors.New("bad tag")
+		}
+		b = b[n:]
+		f := protoField{num: int(tag >> 3)}
+		switch tag & 7 {
+		case wireVarint:
+			f.v, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errors.New("bad varint")
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errors.New("short fixed64")
+			}
+			f.v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return nil, errors.New("bad length")
+			}
+			f.b, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return nil, errors.New("unexpected wire type")
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// decodeAnyValue decodes an AnyValue, integers as int64, arrays as slices
+// and key-value lists as maps.
+func decodeAnyValue(b []byte) (interface{}, error) {
+	fields, err := decodeProto(b)
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+	f := fields[0]
+	switch f.num {
+	case anyValueString:
+		return string(f.b), nil
+	case anyValueBool:
+		return f.v == 1, nil
+	case anyValueInt:
+		return int64(f.v), nil
+	case anyValueDouble:
+		return math.Float64frombits(f.v), nil
+	case anyValueBytes:
+		return f.b, nil
+	case anyValueArray:
+		values, err := decodeProto(f.b)
+		if err != nil {
+			return nil, err
+		}
+		arr := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			e, err := decodeAnyValue(v.b)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, e)
+		}
+		return arr, nil
+	case anyValueKvlist:
+		values, err := decodeProto(f.b)
+		if err != nil {
+			return nil, err
+		}
+		return decodeKeyValues(values)
+	}
+	return nil, errors.New("unexpected value")
+}
+
+func decodeKeyValues(values []protoField) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for _, v := range values {
+		kv, err := decodeProto(v.b)
+		if err != nil {
+			return nil, err
+		}
+		var key string
+		var value interface{}
+		for _, f := range kv {
+			switch f.num {
+			case keyValueKey:
+				key = string(f.b)
+			case keyValueValue:
+				if value, err = decodeAnyValue(f.b); err != nil {
+					return nil, err
+				}
+			}
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// otlpRecord is a decoded LogRecord.
+type otlpRecord struct {
+	time           time.Time
+	severityNumber int
+	severityText   string
+	body           interface{}
+	attributes     map[string]interface{}
+	traceID        []byte
+	spanID         []byte
+}
+
+// decodeOTLPRecord decodes the length-prefixed LogRecord at the start of r.
+func decodeOTLPRecord(r *bytes.Reader) (otlpRecord, error) {
+	var rec otlpRecord
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return rec, err
+	}
+	b := make([]byte, l)
+	if _, err = r.Read(b); err != nil {
+		return rec, err
+	}
+	fields, err := decodeProto(b)
+	if err != nil {
+		return rec, err
+	}
+
+	var attrs []protoField
+	for _, f := range fields {
+		switch f.num {
+		case logRecordTime:
+			rec.time = time.Unix(0, int64(f.v))
+		case logRecordSeverityNumber:
+			rec.severityNumber = int(f.v)
+		case logRecordSeverityText:
+			rec.severityText = string(f.b)
+		case logRecordBody:
+			if rec.body, err = decodeAnyValue(f.b); err != nil {
+				return rec, err
+			}
+		case logRecordAttributes:
+			attrs = append(attrs, f)
+		case logRecordTraceID:
+			rec.traceID = f.b
+		case logRecordSpanID:
+			rec.spanID = f.b
+		}
+	}
+	rec.attributes, err = decodeKeyValues(attrs)
+	return rec, err
+}
+
+func TestOTLPEncoder(t *testing.T) {
+	enc := newOTLPEncoder(newOptions().encoderConfig)
+	enc.AddString("app", "test")
+	enc.AddString(traceKey, "0102030405060708090a0b0c0d0e0f10")
+	enc.OpenNamespace("ctx")
+	enc.AddInt("depth", 1)
+
+	now := time.Unix(1700000000, 123456789)
+	buf, err := enc.EncodeEntry(zapcore.Entry{
+		Level:   zapcore.WarnLevel,
+		Time:    now,
+		Message: "something happened",
+		Caller:  zapcore.NewEntryCaller(0, "/src/app/main.go", 42, true),
+		Stack:   "main.main()",
+	}, []zapcore.Field{
+		zap.String(spanKey, "not a span id"),
+		zap.Int64("min", math.MinInt64),
+		zap.Uint64("max", math.MaxUint64),
+		zap.Float64("ratio", 0.5),
+		zap.Bool("ok", true),
+		zap.Binary("raw", []byte{1, 2}),
+		zap.Duration("took", 1500*time.Millisecond),
+		zap.Object("user", msgpackUser{Name: "alice", Tags: []string{"a", "b"}}),
+		zap.Any("meta", map[string]int{"n": 1}),
+		zap.Time("at", now),
+	})
+	assert.Nil(t, err)
+	defer buf.Free()
+
+	r := bytes.NewReader(buf.Bytes())
+	rec, err := decodeOTLPRecord(r)
+	assert.Nil(t, err)
+	assert.Zero(t, r.Len())
+	assert.Equal(t, now, rec.time)
+	assert.Equal(t, 13, rec.severityNumber)
+	assert.Equal(t, "WARN", rec.severityText)
+	assert.Equal(t, "something happened", rec.body)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}, rec.traceID)
+	assert.Nil(t, rec.spanID)
+	assert.Equal(t, map[string]interface{}{
+		codeFilepathKey: "/src/app/main.go",
+		codeLinenoKey:   int64(42),
+		"stack":         "main.main()",
+		"app":           "test",
+		"ctx": map[string]interface{}{
+			"depth": int64(1),
+			spanKey: "not a span id",
+			"min":   int64(math.MinInt64),
+			"max":   "18446744073709551615",
+			"ratio": 0.5,
+			"ok":    true,
+			"raw":   []byte{1, 2},
+			"took":  int64(1500 * time.Millisecond),
+			"user":  map[string]interface{}{"name": "alice", "tags": []interface{}{"a", "b"}},
+			"meta":  map[string]interface{}{"n": int64(1)},
+			"at":    now.UnixNano(),
+		},
+	}, rec.attributes)
+}
+
+func TestOTLPEncoderOption(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(WithWriter(&buf), WithEncoder(OTLPEncoder))
+
+	traceID := trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	spanID := trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	l.WithContext(ctx).Infow("first", "n", 1)
+	l.Errorw("second", "n", 2)
+
+	r := bytes.NewReader(buf.Bytes())
+	rec, err := decodeOTLPRecord(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "first", rec.body)
+	assert.Equal(t, 9, rec.severityNumber)
+	assert.Equal(t, traceID[:], rec.traceID)
+	assert.Equal(t, spanID[:], rec.spanID)
+	assert.Equal(t, int64(1), rec.attributes["n"])
+
+	rec, err = decodeOTLPRecord(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "second", rec.body)
+	assert.Equal(t, 17, rec.severityNumber)
+	assert.Nil(t, rec.traceID)
+	assert.Zero(t, r.Len())
+	assert.Nil(t, newOptions(WithEncoder(OTLPEncoder)).validate())
+	o := newOptions(WithEncoder(OTLPEncoder))
+	o.audit = true
+	assert.ErrorIs(t, o.validate(), ErrInvalidOptions)
+}
@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"bytes"
+	"runtime"
+	"sync/atomic"
+)
+
+const (
+	// logPageSize is the size of the pages writers fill concurrently.
+	logPageSize = 8 * logPageCacheByteSize // 32KB
+	// pageSealed is added to page.reserved to fail every later reservation.
+	pageSealed = int64(1) << 62
+)
+
+// page is a fixed size buffer filled concurrently by writers without locking:
+// a writer reserves a region with an atomic add on reserved, copies its entry
+// into the region and adds its length to committed. A page is sealed once it
+// overflows or is flushed, and written to the file when every region reserved
+// before the seal is committed.
+type page struct {
+	b   *bytes.Buffer
+	buf []byte
+	// reserved is the number of bytes reserved, including failed reservations.
+	reserved int64
+	// committed is the number of bytes copied into the page.
+	committed int64
+	// tail is 1 + the offset of the reservation straddling the end of the
+	// page, 0 if none. The page data ends there.
+	tail int64
+}
+
+func newPage(pool *bufferPool) *page {
+	b := pool.get()
+	b.Grow(logPageSize)
+	return &page{b: b, buf: b.Bytes()[:logPageSize]}
+}
+
+// newFullPage returns a sealed page holding data, used for entries larger
+// than a page.
+func newFullPage(pool *bufferPool, data []byte) *page {
+	b := pool.get()
+	b.Write(data)
+	n := int64(b.Len())
+	return &page{b: b, buf: b.Bytes(), reserved: n, committed: n}
+}
+
+// write copies data into the page, it returns false if the page is full or
+// sealed.
+func (p *page) write(data []byte) bool {
+	n := int64(len(data))
+	end := atomic.AddInt64(&p.reserved, n)
+	off := end - n
+	size := int64(len(p.buf))
+	if end <= size {
+		copy(p.buf[off:end], data)
+		atomic.AddInt64(&p.committed, n)
+		return true
+	}
+
+	// the reservation straddling the end marks where the data ends, and
+	// commits the unused tail so the page can be completed.
+	if off < size {
+		atomic.StoreInt64(&p.tail, off+1)
+		atomic.AddInt64(&p.committed, size-off)
+	}
+	return false
+}
+
+// seal fails every later reservation and waits for the reserved regions to be
+// committed, it returns the page data.
+func (p *page) seal() []byte {
+	reserved := atomic.AddInt64(&p.reserved, pageSealed) - pageSealed
+	size := int64(len(p.buf))
+	if reserved > size {
+		reserved = size
+	}
+	for atomic.LoadInt64(&p.committed) < reserved {
+		runtime.Gosched()
+	}
+	if tail := atomic.LoadInt64(&p.tail); tail > 0 {
+		reserved = tail - 1
+	}
+	return p.buf[:reserved]
+}
@@ -2,40 +2,62 @@ package logger
 
 import (
 	"bytes"
-	"sync"
-	"sync/atomic"
 )
 
-var bpool = newBufferPool(500)
+const (
+	defaultBufferPoolSize    = 500
+	defaultMaxBufferCapacity = 256 << 10 // 256KB
+)
+
+var bpool = newBufferPool(defaultBufferPoolSize, defaultMaxBufferCapacity)
 
+// bufferPool is a bounded free list of buffers. It retains at most size idle
+// buffers and drops buffers grown beyond maxCap, so a single huge entry
+// doesn't pin its memory forever.
 type bufferPool struct {
-	p     sync.Pool
-	count int64
-	size  int
+	free   chan *bytes.Buffer
+	maxCap int
 }
 
-func newBufferPool(size int) *bufferPool {
-	p := &bufferPool{}
-	p.p.New = func() interface{} { return bytes.NewBuffer(nil) }
-	p.size = size
-	return p
+func newBufferPool(size, maxCap int) *bufferPool {
+	if size <= 0 {
+		size = defaultBufferPoolSize
+	}
+	if maxCap <= 0 {
+		maxCap = defaultMaxBufferCapacity
+	}
+	return &bufferPool{
+		free:   make(chan *bytes.Buffer, size),
+		maxCap: maxCap,
+	}
+}
+
+// get returns an empty buffer, allocating one when the pool is exhausted.
+func (p *bufferPool) get() *bytes.Buffer {
+	select {
+	case b := <-p.free:
+		b.Reset()
+		return b
+	default:
+		return bytes.NewBuffer(make([]byte, 0, logPageCacheByteSize))
+	}
+}
+
+// put returns b to the pool, dropping it if oversized or the pool is full.
+func (p *bufferPool) put(b *bytes.Buffer) {
+	if b == nil || b.Cap() > p.maxCap {
+		return
+	}
+	select {
+	case p.free <- b:
+	default:
+	}
 }
 
 func putBuffer(b *bytes.Buffer) {
-	bpool.p.Put(b)
-	atomic.AddInt64(&bpool.count, -1)
+	bpool.put(b)
 }
 
 func getBuffer() *bytes.Buffer {
-	for {
-		c := atomic.LoadInt64(&bpool.count)
-		if c > int64(bpool.size) {
-			return nil
-		}
-		if atomic.CompareAndSwapInt64(&bpool.count, c, c+1) {
-			b := bpool.p.Get().(*bytes.Buffer)
-			b.Reset()
-			return b
-		}
-	}
+	return bpool.get()
 }
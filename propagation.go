@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The trace headers of B3 and Jaeger propagation.
+const (
+	b3Header        = "b3"
+	b3TraceIDHeader = "X-B3-TraceId"
+	b3SpanIDHeader  = "X-B3-SpanId"
+	b3SampledHeader = "X-B3-Sampled"
+	b3FlagsHeader   = "X-B3-Flags"
+	uberTraceHeader = "uber-trace-id"
+)
+
+// ContextFromRequest returns the context of r carrying the trace context of
+// its B3 or Jaeger headers, see ContextFromHeaders.
+func ContextFromRequest(r *http.Request) context.Context {
+	return ContextFromHeaders(r.Context(), r.Header.Get)
+}
+
+// ContextFromMetadata returns ctx carrying the trace context of the B3 or
+// Jaeger headers of md, e.g. gRPC metadata, whose keys are lower case.
+func ContextFromMetadata(ctx context.Context, md map[string][]string) context.Context {
+	return ContextFromHeaders(ctx, func(key string) string {
+		if v := md[strings.ToLower(key)]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	})
+}
+
+// ContextFromHeaders returns ctx carrying the remote span context read from
+// the headers returned by get: the single b3 header, the X-B3-* headers or the
+// uber-trace-id header of Jaeger, in that order. Loggers from WithContext
+// then log its trace_id and span_id, for services propagating those headers
+// without the OpenTelemetry SDK. ctx is returned as is if it already has a
+// span or the headers carry no valid trace context.
+func ContextFromHeaders(ctx context.Context, get func(key string) string) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	for _, extract := range []func(func(string) string) (trace.SpanContext, bool){extractB3Single, extractB3Multi, extractJaeger} {
+		if sc, ok := extract(get); ok {
+			return trace.ContextWithRemoteSpanContext(ctx, sc)
+		}
+	}
+	return ctx
+}
+
+// extractB3Single reads `{TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}`,
+// the last two being optional.
+func extractB3Single(get func(string) string) (trace.SpanContext, bool) {
+	parts := strings.Split(get(b3Header), "-")
+	if len(parts) < 2 {
+		return trace.SpanContext{}, false
+	}
+	var sampled string
+	if len(parts) > 2 {
+		sampled = parts[2]
+	}
+	return newRemoteSpanContext(parts[0], parts[1], sampled == "1" || sampled == "d")
+}
+
+func extractB3Multi(get func(string) string) (trace.SpanContext, bool) {
+	sampled := get(b3SampledHeader)
+	return newRemoteSpanContext(get(b3TraceIDHeader), get(b3SpanIDHeader),
+		sampled == "1" || sampled == "true" || get(b3FlagsHeader) == "1")
+}
+
+// extractJaeger reads `{trace-id}:{span-id}:{parent-span-id}:{flags}`, maybe
+// URL encoded.
+func extractJaeger(get func(string) string) (trace.SpanContext, bool) {
+	value := get(uberTraceHeader)
+	if unescaped, err := url.QueryUnescape(value); err == nil {
+		value = unescaped
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != 4 {
+		return trace.SpanContext{}, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	return newRemoteSpanContext(parts[0], parts[1], flags&1 == 1)
+}
+
+// newRemoteSpanContext returns the span context of the hex IDs, the 64-bit
+// trace IDs and the IDs without leading zeros padded with zeros.
+func newRemoteSpanContext(traceID, spanID string, sampled bool) (trace.SpanContext, bool) {
+	if traceID == "" || len(traceID) > 32 || spanID == "" || len(spanID) > 16 {
+		return trace.SpanContext{}, false
+	}
+	tid, err := trace.TraceIDFromHex(strings.Repeat("0", 32-len(traceID)) + strings.ToLower(traceID))
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	sid, err := trace.SpanIDFromHex(strings.Repeat("0", 16-len(spanID)) + strings.ToLower(spanID))
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+
+	var flags trace.TraceFlags
+	if sampled {
+		flags = trace.FlagsSampled
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid, SpanID: sid, TraceFlags: flags, Remote: true})
+	return sc, sc.IsValid()
+}
@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestContextFromHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		traceID string
+		spanID  string
+		sampled bool
+	}{
+		{
+			name:    "b3 single",
+			headers: map[string]string{"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90"},
+			traceID: "80f198ee56343ba864fe8b2a57d3eff7",
+			spanID:  "e457b5a2e4d86bd1",
+			sampled: true,
+		},
+		{
+			name: "b3 multi",
+			headers: map[string]string{
+				"X-B3-TraceId": "463ac35c9f6413ad",
+				"X-B3-SpanId":  "a2fb4a1d1a96d312",
+				"X-B3-Sampled": "0",
+			},
+			traceID: "0000000000000000463ac35c9f6413ad",
+			spanID:  "a2fb4a1d1a96d312",
+		},
+		{
+			name:    "jaeger",
+			headers: map[string]string{"uber-trace-id": "463ac35c9f6413ad%3Aa2fb4a1d1a96d312%3A0%3A1"},
+			traceID: "0000000000000000463ac35c9f6413ad",
+			spanID:  "a2fb4a1d1a96d312",
+			sampled: true,
+		},
+		{
+			name:    "invalid",
+			headers: map[string]string{"b3": "1", "uber-trace-id": "x:y:0:1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			md := make(map[string][]string)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+				md[k] = []string{v}
+			}
+
+			for _, ctx := range []context.Context{ContextFromRequest(r), ContextFromMetadata(context.Background(), lowerKeys(md))} {
+				sc := trace.SpanContextFromContext(ctx)
+				if tt.traceID == "" {
+					assert.False(t, sc.IsValid())
+					continue
+				}
+				assert.Equal(t, tt.traceID, sc.TraceID().String())
+				assert.Equal(t, tt.spanID, sc.SpanID().String())
+				assert.Equal(t, tt.sampled, sc.IsSampled())
+				assert.True(t, sc.IsRemote())
+			}
+		})
+	}
+}
+
+func TestContextFromHeadersKeepsSpan(t *testing.T) {
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	}))
+	got := ContextFromHeaders(ctx, func(string) string { return "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1" })
+	assert.Equal(t, ctx, got)
+}
+
+func lowerKeys(md map[string][]string) map[string][]string {
+	lower := make(map[string][]string, len(md))
+	for k, v := range md {
+		lower[strings.ToLower(k)] = v
+	}
+	return lower
+}
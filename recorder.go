@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// recorder is the flight recorder, nil while disabled.
+var recorder atomic.Pointer[flightRecorder]
+
+// EnableFlightRecorder keeps the last size entries of every logger in memory,
+// at all levels, including those below the logger level. The entries are
+// written by DumpRecent, and to stderr before a fatal entry exits. A size of
+// 0 or less disables the recorder.
+func EnableFlightRecorder(size int) {
+	if size <= 0 {
+		recorder.Store(nil)
+		return
+	}
+	recorder.Store(&flightRecorder{slots: make([]atomic.Pointer[recordedEntry], size)})
+}
+
+// DumpRecent writes the entries kept by the flight recorder to w, oldest
+// first, as JSON lines.
+func DumpRecent(w io.Writer) error {
+	r := recorder.Load()
+	if r == nil {
+		return nil
+	}
+	return r.dump(w)
+}
+
+// DumpOnPanic writes the entries kept by the flight recorder to stderr when
+// the goroutine is panicking, then panics again. It must be deferred.
+func DumpOnPanic() {
+	if r := recover(); r != nil {
+		_ = DumpRecent(os.Stderr)
+		panic(r)
+	}
+}
+
+type recordedEntry struct {
+	seq    uint64
+	opt    *Options
+	ent    zapcore.Entry
+	fields []interface{}
+}
+
+// flightRecorder is a lock-free ring of the last recorded entries. Writers
+// claim a sequence number and store the entry in its slot, overwriting the
+// oldest one.
+type flightRecorder struct {
+	slots []atomic.Pointer[recordedEntry]
+	next  atomic.Uint64
+}
+
+func (r *flightRecorder) record(opt *Options, lvl zapcore.Level, msg string, keysAndValues []interface{}) {
+	seq := r.next.Add(1) - 1
+	// copied, so keysAndValues doesn't escape to the heap when not recording.
+	fields := append([]interface{}(nil), keysAndValues...)
+	r.slots[seq%uint64(len(r.slots))].Store(&recordedEntry{
+		seq:    seq,
+		opt:    opt,
+		ent:    zapcore.Entry{Level: lvl, Time: time.Now(), Message: msg},
+		fields: fields,
+	})
+}
+
+func (r *flightRecorder) dump(w io.Writer) error {
+	end := r.next.Load()
+	var start uint64
+	if size := uint64(len(r.slots)); end > size {
+		start = end - size
+	}
+
+	var errs []error
+	for seq := start; seq < end; seq++ {
+		e := r.slots[seq%uint64(len(r.slots))].Load()
+		if e == nil || e.seq != seq {
+			// not stored yet, or already overwritten by a newer entry.
+			continue
+		}
+
+		buf, err := zapcore.NewJSONEncoder(e.opt.encoderConfig).EncodeEntry(e.ent, toZapFields(e.fields))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		_, err = w.Write(buf.Bytes())
+		buf.Free()
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// toZapFields converts sugared key-value pairs to fields. A key without a
+// value is kept under "!BADKEY", like the sugared logger does.
+func toZapFields(keysAndValues []interface{}) []zapcore.Field {
+	fields := make([]zapcore.Field, 0, len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); {
+		if f, ok := keysAndValues[i].(zapcore.Field); ok {
+			fields = append(fields, f)
+			i++
+			continue
+		}
+		if i == len(keysAndValues)-1 {
+			fields = append(fields, zap.Any("!BADKEY", keysAndValues[i]))
+			break
+		}
+		fields = append(fields, zap.Any(fmt.Sprint(keysAndValues[i]), keysAndValues[i+1]))
+		i += 2
+	}
+	return fields
+}
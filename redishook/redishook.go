@@ -0,0 +1,158 @@
+// Package redishook logs the slow and the failed commands of a go-redis client
+// through the logger package:
+//
+//	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	rdb.AddHook(redishook.New(logger.Default(), redishook.WithSlowThreshold(50*time.Millisecond)))
+//
+// Commands are logged by name and key, the other arguments are redacted. The
+// trace_id and span_id of the command context are added. It is a module of
+// its own, so the logger package doesn't depend on go-redis.
+package redishook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/nextmicro/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultSlowThreshold = 100 * time.Millisecond
+	redacted             = "?"
+)
+
+type Option func(o *options)
+
+type options struct {
+	// slowThreshold is the duration above which commands are logged, 0
+	// disables the slow command entries.
+	slowThreshold time.Duration
+	// logErrors logs the failed commands, redis.Nil isn't a failure.
+	logErrors bool
+	// redactKey rewrites the keys logged, nil logs them as they are.
+	redactKey func(key string) string
+}
+
+// WithSlowThreshold Setter function to set the duration above which commands
+// are logged as slow at WarnLevel, default is 100ms. 0 disables them.
+func WithSlowThreshold(d time.Duration) Option {
+	return func(o *options) {
+		o.slowThreshold = d
+	}
+}
+
+// WithErrors Setter function to set whether failed commands are logged at
+// ErrorLevel, default is true.
+func WithErrors(enabled bool) Option {
+	return func(o *options) {
+		o.logErrors = enabled
+	}
+}
+
+// WithKeyRedactor Setter function to set how keys are logged, e.g. to turn
+// `user:42` into the pattern `user:*`. Keys are logged as they are by default.
+func WithKeyRedactor(redact func(key string) string) Option {
+	return func(o *options) {
+		o.redactKey = redact
+	}
+}
+
+// Hook is a redis.Hook logging the slow and the failed commands.
+type Hook struct {
+	l   logger.Logger
+	opt options
+}
+
+var _ redis.Hook = (*Hook)(nil)
+
+// New returns a Hook logging to l.
+func New(l logger.Logger, opts ...Option) *Hook {
+	opt := options{slowThreshold: defaultSlowThreshold, logErrors: true}
+	for _, o := range opts {
+		o(&opt)
+	}
+	return &Hook{l: l, opt: opt}
+}
+
+func (h *Hook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if err != nil && h.opt.logErrors {
+			h.l.WithContext(ctx).Errorw("redis dial failed", "network", network, "addr", addr, "error", err.Error())
+		}
+		return conn, err
+	}
+}
+
+func (h *Hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.log(ctx, []redis.Cmder{cmd}, time.Since(start))
+		return err
+	}
+}
+
+func (h *Hook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.log(ctx, cmds, time.Since(start))
+		return err
+	}
+}
+
+// log logs cmds, run in one round trip which took duration, if slow or
+// failed. A failed command is logged once, as failed.
+func (h *Hook) log(ctx context.Context, cmds []redis.Cmder, duration time.Duration) {
+	var failed []redis.Cmder
+	if h.opt.logErrors {
+		for _, cmd := range cmds {
+			if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+				failed = append(failed, cmd)
+			}
+		}
+	}
+	slow := h.opt.slowThreshold > 0 && duration > h.opt.slowThreshold
+	if len(failed) == 0 && !slow {
+		return
+	}
+
+	lg := h.l.WithContext(ctx)
+	for _, cmd := range failed {
+		lg.Errorw("redis command failed", "cmd", h.statement(cmd), "duration", duration.String(), "error", cmd.Err().Error())
+	}
+	if slow && len(failed) == 0 {
+		statements := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			statements[i] = h.statement(cmd)
+		}
+		lg.Warnw("slow redis command", "cmd", strings.Join(statements, "; "), "duration", duration.String())
+	}
+}
+
+// statement returns cmd with its name and key, the other arguments redacted,
+// e.g. `set user:42 ? ? ?` for `set user:42 alice ex 10`.
+func (h *Hook) statement(cmd redis.Cmder) string {
+	args := cmd.Args()
+	name := cmd.FullName()
+	words := len(strings.Fields(name))
+	if words >= len(args) {
+		return name
+	}
+
+	key := fmt.Sprint(args[words])
+	if h.opt.redactKey != nil {
+		key = h.opt.redactKey(key)
+	}
+	parts := append([]string{name, key}, make([]string, len(args)-words-1)...)
+	for i := 2; i < len(parts); i++ {
+		parts[i] = redacted
+	}
+	return strings.Join(parts, " ")
+}
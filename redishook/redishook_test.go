@@ -0,0 +1,73 @@
+package redishook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/nextmicro/logger"
+	"github.com/nextmicro/logger/logtest"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// process runs cmd through the hook with a next taking duration and
+// failing with err.
+func process(h *Hook, ctx context.Context, cmd redis.Cmder, duration time.Duration, err error) error {
+	return h.ProcessHook(func(ctx context.Context, cmd redis.Cmder) error {
+		time.Sleep(duration)
+		cmd.SetErr(err)
+		return err
+	})(ctx, cmd)
+}
+
+func TestProcessHook(t *testing.T) {
+	var buf bytes.Buffer
+	h := New(logger.New(logger.WithWriter(&buf)), WithSlowThreshold(20*time.Millisecond))
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	}))
+
+	assert.Nil(t, process(h, ctx, redis.NewStatusCmd(ctx, "set", "user:42", "alice", "ex", 10), 0, nil))
+	assert.ErrorIs(t, process(h, ctx, redis.NewStringCmd(ctx, "get", "user:43"), 0, redis.Nil), redis.Nil)
+	assert.Empty(t, logtest.Entries(t, buf.Bytes()))
+
+	assert.Nil(t, process(h, ctx, redis.NewStatusCmd(ctx, "set", "user:42", "alice", "ex", 10), 30*time.Millisecond, nil))
+	failure := errors.New("READONLY You can't write against a read only replica")
+	assert.Equal(t, failure, process(h, ctx, redis.NewIntCmd(ctx, "incr", "counter"), 0, failure))
+
+	logged := logtest.Entries(t, buf.Bytes())
+	assert.Len(t, logged, 2)
+	assert.Equal(t, "warn", logged[0]["level"])
+	assert.Equal(t, "set user:42 ? ? ?", logged[0]["cmd"])
+	assert.Equal(t, trace.TraceID{1}.String(), logged[0]["trace_id"])
+	assert.Equal(t, "error", logged[1]["level"])
+	assert.Equal(t, "incr counter", logged[1]["cmd"])
+	assert.Equal(t, failure.Error(), logged[1]["error"])
+}
+
+func TestProcessPipelineHook(t *testing.T) {
+	var buf bytes.Buffer
+	pattern := regexp.MustCompile(`\d+`)
+	h := New(logger.New(logger.WithWriter(&buf)), WithSlowThreshold(time.Millisecond), WithKeyRedactor(func(key string) string {
+		return pattern.ReplaceAllString(key, "*")
+	}))
+
+	ctx := context.Background()
+	cmds := []redis.Cmder{redis.NewStringCmd(ctx, "get", "user:42"), redis.NewIntCmd(ctx, "client", "id")}
+	err := h.ProcessPipelineHook(func(ctx context.Context, cmds []redis.Cmder) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	})(ctx, cmds)
+	assert.Nil(t, err)
+
+	logged := logtest.Entries(t, buf.Bytes())
+	assert.Len(t, logged, 1)
+	assert.Equal(t, "slow redis command", logged[0]["msg"])
+	assert.Equal(t, "get user:*; client id", logged[0]["cmd"])
+}
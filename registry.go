@@ -0,0 +1,194 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var registry = &namedRegistry{loggers: make(map[string]Logger)}
+
+type namedRegistry struct {
+	mu      sync.RWMutex
+	loggers map[string]Logger
+	rules   []LevelRule
+}
+
+// LevelRule sets the level of the named loggers matching Pattern.
+type LevelRule struct {
+	// Pattern is a glob matched against logger names, `*` matches any
+	// sequence of characters including `/` and `?` matches one character.
+	Pattern string
+	Level   Level
+
+	re *regexp.Regexp
+}
+
+// Match reports whether name matches the rule pattern.
+func (r LevelRule) Match(name string) bool {
+	if r.re == nil {
+		r.re = compileGlob(r.Pattern)
+	}
+	return r.re.MatchString(name)
+}
+
+func compileGlob(pattern string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.MustCompile("^" + expr + "$")
+}
+
+// ParseLevelRules parses comma separated `pattern=level` rules,
+// e.g. "github.com/acme/svc/db/*=debug,*=info".
+func ParseLevelRules(spec string) ([]LevelRule, error) {
+	var rules []LevelRule
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		pattern, level, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid level rule %q: missing '='", item)
+		}
+		pattern = strings.TrimSpace(pattern)
+		lv, err := parseLevel(strings.TrimSpace(level))
+		if err != nil {
+			return nil, fmt.Errorf("invalid level rule %q: %w", item, err)
+		}
+		rules = append(rules, LevelRule{Pattern: pattern, Level: lv, re: compileGlob(pattern)})
+	}
+	return rules, nil
+}
+
+// SetLevelRules parses spec with ParseLevelRules and applies the rules to the
+// named loggers, the first matching rule wins. Registered loggers matching a
+// rule have their level set, loggers registered later use the rule as their
+// default level.
+func SetLevelRules(spec string) error {
+	rules, err := ParseLevelRules(spec)
+	if err != nil {
+		return err
+	}
+
+	registry.mu.Lock()
+	registry.rules = rules
+	loggers := make(map[string]Logger, len(registry.loggers))
+	for name, l := range registry.loggers {
+		loggers[name] = l
+	}
+	registry.mu.Unlock()
+
+	for name, l := range loggers {
+		if lv, ok := registry.ruleLevel(name); ok {
+			l.SetLevel(lv)
+		}
+	}
+	return nil
+}
+
+// ruleLevel returns the level of the first rule matching name.
+func (r *namedRegistry) ruleLevel(name string) (Level, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, rule := range r.rules {
+		if rule.Match(name) {
+			return rule.Level, true
+		}
+	}
+	return 0, false
+}
+
+// Register builds a logger named name with its own options, replacing any
+// logger previously registered under the same name, which is closed.
+func Register(name string, opts ...Option) Logger {
+	defaults := []Option{WithName(name)}
+	if lv, ok := registry.ruleLevel(name); ok {
+		defaults = append(defaults, WithLevel(lv))
+	}
+	l := New(append(defaults, opts...)...)
+	registry.mu.Lock()
+	prev := registry.loggers[name]
+	registry.loggers[name] = l
+	registry.mu.Unlock()
+
+	closeLogger(prev)
+	return l
+}
+
+// closeLogger closes l when replaced by another logger, so its files and
+// network sinks are released.
+func closeLogger(l Logger) {
+	_ = shutdownLogger(context.Background(), l)
+}
+
+// RegisterConfig registers a logger for every name in configs,
+// e.g. separate files for the api, worker and cron components of one binary.
+func RegisterConfig(configs map[string]Config) {
+	for name, c := range configs {
+		Register(name, c.Options()...)
+	}
+}
+
+// Get returns the logger registered under name. Unregistered names get a
+// logger derived from the default logger carrying the name, at the level of the
+// matching level rule if any.
+func Get(name string) Logger {
+	registry.mu.RLock()
+	l, ok := registry.loggers[name]
+	registry.mu.RUnlock()
+	if ok {
+		return l
+	}
+
+	if lg, ok := Default().(*Logging); ok {
+		lg = lg.named(name)
+		if lv, ok := registry.ruleLevel(name); ok {
+			lg = lg.withLevel(lv)
+		}
+		return lg
+	}
+	return Default().WithFields(map[string]any{loggerKey: name})
+}
+
+func (r *namedRegistry) sync() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var errs []error
+	for _, l := range r.loggers {
+		if err := l.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// all returns the registered loggers.
+func (r *namedRegistry) all() []Logger {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	loggers := make([]Logger, 0, len(r.loggers))
+	for _, l := range r.loggers {
+		loggers = append(loggers, l)
+	}
+	return loggers
+}
+
+func (r *namedRegistry) healthy() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var errs []error
+	for _, l := range r.loggers {
+		errs = append(errs, healthOf(l))
+	}
+	return errors.Join(errs...)
+}
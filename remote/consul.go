@@ -0,0 +1,142 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	consulIndexHeader = "X-Consul-Index"
+	defaultWaitTime   = 5 * time.Minute
+	defaultRetryDelay = time.Second
+)
+
+// Consul is a Source watching a consul KV key with blocking queries.
+type Consul struct {
+	addr   string
+	key    string
+	token  string
+	client *http.Client
+}
+
+// ConsulOption configures a Consul source.
+type ConsulOption func(c *Consul)
+
+// WithConsulToken sets the ACL token sent with every request.
+func WithConsulToken(token string) ConsulOption {
+	return func(c *Consul) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient sets the http client, its timeout must exceed the 5m blocking query wait.
+func WithHTTPClient(client *http.Client) ConsulOption {
+	return func(c *Consul) {
+		c.client = client
+	}
+}
+
+// NewConsul returns a Source watching key on the consul agent at addr, e.g. http://127.0.0.1:8500.
+func NewConsul(addr, key string, opts ...ConsulOption) *Consul {
+	c := &Consul{
+		addr:   strings.TrimRight(addr, "/"),
+		key:    strings.TrimLeft(key, "/"),
+		client: http.DefaultClient,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Watch implements Source.
+func (c *Consul) Watch(ctx context.Context) (<-chan []byte, error) {
+	value, index, err := c.get(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan []byte, 1)
+	if value != nil {
+		ch <- value
+	}
+
+	go func() {
+		defer close(ch)
+		for {
+			next, nextIndex, err := c.get(ctx, index)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				select {
+				case <-time.After(defaultRetryDelay):
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			// consul may return early with the same index, or reset it.
+			if nextIndex == index {
+				continue
+			}
+			if nextIndex < index {
+				nextIndex = 0
+			}
+			index = nextIndex
+			if next == nil {
+				continue
+			}
+
+			select {
+			case ch <- next:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// get reads the key, blocking until its modify index exceeds index. A missing
+// key returns a nil value.
+func (c *Consul) get(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	query := url.Values{"raw": []string{""}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", defaultWaitTime.String())
+	}
+	u := fmt.Sprintf("%s/v1/kv/%s?%s", c.addr, c.key, query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	newIndex, _ := strconv.ParseUint(resp.Header.Get(consulIndexHeader), 10, 64)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		return body, newIndex, err
+	case http.StatusNotFound:
+		return nil, newIndex, nil
+	default:
+		return nil, 0, fmt.Errorf("consul: unexpected status %s", resp.Status)
+	}
+}
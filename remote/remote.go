@@ -0,0 +1,129 @@
+// Package remote applies logger configuration watched from a remote key-value
+// store, such as etcd or consul, so log verbosity can be managed centrally
+// across a fleet: the level, the named logger level rules and the sampling.
+// Redaction is left out on purpose: the core logger has no redaction rules,
+// they are options of the ginmw, sqllog and redishook middlewares, fixed
+// when they are built.
+//
+// The consul source is built in. Other stores plug in by implementing Source,
+// e.g. with the etcd clientv3:
+//
+//	type etcdSource struct {
+//		cli *clientv3.Client
+//		key string
+//	}
+//
+//	func (s etcdSource) Watch(ctx context.Context) (<-chan []byte, error) {
+//		ch := make(chan []byte, 1)
+//		resp, err := s.cli.Get(ctx, s.key)
+//		if err != nil {
+//			return nil, err
+//		}
+//		if len(resp.Kvs) > 0 {
+//			ch <- resp.Kvs[0].Value
+//		}
+//		go func() {
+//			defer close(ch)
+//			for wr := range s.cli.Watch(ctx, s.key) {
+//				for _, ev := range wr.Events {
+//					ch <- ev.Kv.Value
+//				}
+//			}
+//		}()
+//		return ch, nil
+//	}
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nextmicro/logger"
+)
+
+// Source watches a remote configuration value.
+type Source interface {
+	// Watch sends the current value and then every changed value until ctx
+	// is done, after which the channel is closed.
+	Watch(ctx context.Context) (<-chan []byte, error)
+}
+
+// Config is the logger configuration stored in the remote key as JSON.
+type Config struct {
+	// Level is the level of the default logger.
+	Level string `json:"level,omitempty"`
+	// LevelRules are the named logger level rules, see logger.SetLevelRules.
+	LevelRules string `json:"level_rules,omitempty"`
+	// Sampling keeps one of every rate entries of a level, keyed by level
+	// name, e.g. {"debug": 1000}, see logger.SetSampling. An empty object
+	// stops the sampling, leaving it out keeps the current one.
+	Sampling map[string]int `json:"sampling,omitempty"`
+}
+
+// Apply applies c to the package level loggers. The whole configuration is
+// checked first, so an invalid one changes nothing.
+func (c Config) Apply() error {
+	var lv logger.Level
+	if c.Level != "" {
+		lv = logger.ParseLevel(c.Level)
+		if !strings.EqualFold(lv.String(), c.Level) {
+			return fmt.Errorf("unrecognized level: %q", c.Level)
+		}
+	}
+	if c.LevelRules != "" {
+		if _, err := logger.ParseLevelRules(c.LevelRules); err != nil {
+			return err
+		}
+	}
+	var rates map[logger.Level]int
+	if c.Sampling != nil {
+		rates = make(map[logger.Level]int, len(c.Sampling))
+		for name, rate := range c.Sampling {
+			var lv logger.Level
+			if err := lv.UnmarshalText([]byte(name)); err != nil {
+				return err
+			}
+			if rate < 0 {
+				return fmt.Errorf("negative sampling rate of %s: %d", name, rate)
+			}
+			rates[lv] = rate
+		}
+	}
+
+	if c.Level != "" {
+		logger.SetLevel(lv)
+	}
+	if c.LevelRules != "" {
+		// already parsed, it can't fail.
+		_ = logger.SetLevelRules(c.LevelRules)
+	}
+	if rates != nil {
+		logger.SetSampling(rates)
+	}
+	return nil
+}
+
+// Watch starts watching src and applies every received configuration until
+// ctx is done. Invalid configurations are logged and skipped.
+func Watch(ctx context.Context, src Source) error {
+	ch, err := src.Watch(ctx)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for data := range ch {
+			var c Config
+			if err := json.Unmarshal(data, &c); err != nil {
+				logger.Errorf("remote: invalid logger config: %v", err)
+				continue
+			}
+			if err := c.Apply(); err != nil {
+				logger.Errorf("remote: apply logger config failed: %v", err)
+			}
+		}
+	}()
+	return nil
+}
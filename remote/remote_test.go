@@ -0,0 +1,96 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nextmicro/logger"
+	"github.com/nextmicro/logger/logtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsulWatch(t *testing.T) {
+	logtest.SwapForTest(t, logger.New(logger.WithWriter(io.Discard)))
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/kv/config/logger", r.URL.Path)
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.Header().Set(consulIndexHeader, "1")
+			_, _ = w.Write([]byte(`{"level":"warn"}`))
+		case 2:
+			w.Header().Set(consulIndexHeader, "2")
+			_, _ = w.Write([]byte(`{"level":"debug"}`))
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	assert.NoError(t, Watch(ctx, NewConsul(srv.URL, "/config/logger")))
+	assert.Eventually(t, func() bool {
+		return logger.Default().(*logger.Logging).Level() == logger.DebugLevel
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestConfigApply(t *testing.T) {
+	assert.Error(t, Config{Level: "verbose"}.Apply())
+	assert.Error(t, Config{LevelRules: "*"}.Apply())
+	assert.Error(t, Config{Sampling: map[string]int{"verbose": 10}}.Apply())
+	assert.Error(t, Config{Sampling: map[string]int{"debug": -1}}.Apply())
+}
+
+func TestConfigApplyInvalid(t *testing.T) {
+	l := logger.New(logger.WithWriter(io.Discard), logger.WithLevel(logger.InfoLevel))
+	logtest.SwapForTest(t, l)
+
+	// the valid level isn't applied when the sampling is invalid.
+	assert.Error(t, Config{Level: "debug", LevelRules: "db.*=warn", Sampling: map[string]int{"verbose": 10}}.Apply())
+	assert.Equal(t, logger.Level(logger.InfoLevel), l.Level())
+	assert.Error(t, Config{Level: "debug", LevelRules: "*"}.Apply())
+	assert.Equal(t, logger.Level(logger.InfoLevel), l.Level())
+}
+
+func TestConfigApplySampling(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf), logger.WithLevel(logger.DebugLevel))
+	t.Cleanup(func() { logger.SetSampling(nil) })
+
+	var c Config
+	assert.NoError(t, json.Unmarshal([]byte(`{"sampling":{"debug":10}}`), &c))
+	assert.NoError(t, c.Apply())
+	for i := 0; i < 100; i++ {
+		l.Debug("sampled")
+		l.Warn("kept")
+	}
+	assert.Equal(t, 10, strings.Count(buf.String(), `"msg":"sampled"`))
+	assert.Equal(t, 100, strings.Count(buf.String(), `"msg":"kept"`))
+
+	// leaving sampling out keeps it, an empty object stops it.
+	assert.NoError(t, Config{Level: "debug"}.Apply())
+	buf.Reset()
+	l.Debug("sampled")
+	l.Debug("sampled")
+	assert.Equal(t, 1, strings.Count(buf.String(), `"msg":"sampled"`))
+
+	var stop Config
+	assert.NoError(t, json.Unmarshal([]byte(`{"sampling":{}}`), &stop))
+	assert.NoError(t, stop.Apply())
+	buf.Reset()
+	for i := 0; i < 10; i++ {
+		l.Debug("sampled")
+	}
+	assert.Equal(t, 10, strings.Count(buf.String(), `"msg":"sampled"`))
+}
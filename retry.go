@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// writeRetry is the retry policy and error handler of the custom writers and
+// the network sinks, set with WithWriteRetry and WithWriteErrorHandler.
+type writeRetry struct {
+	// attempts is the number of tries of a write, 0 and 1 don't retry.
+	attempts int
+	// backoff is the wait before the first retry, doubled on every retry.
+	backoff time.Duration
+	onError func(err error, entry []byte)
+}
+
+// enabled reports whether r changes how the writes fail.
+func (r writeRetry) enabled() bool {
+	return r.attempts > 1 || r.onError != nil
+}
+
+// do calls fn until it succeeds, up to the attempts of r, giving up when ctx
+// is done. When every attempt fails, the handler gets the last error and
+// entry, the bytes being written.
+func (r writeRetry) do(ctx context.Context, entry []byte, fn func(ctx context.Context) error) error {
+	backoff := r.backoff
+	err := fn(ctx)
+	for i := 1; err != nil && i < r.attempts && ctx.Err() == nil; i++ {
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+			err = fn(ctx)
+		case <-ctx.Done():
+			t.Stop()
+		}
+		backoff *= 2
+	}
+	if err != nil && r.onError != nil {
+		r.onError(err, append([]byte(nil), entry...))
+	}
+	return err
+}
+
+// retryWriter writes to w with the policy of retry.
+type retryWriter struct {
+	w     io.Writer
+	retry writeRetry
+}
+
+func (rw *retryWriter) Write(p []byte) (int, error) {
+	var written int
+	err := rw.retry.do(context.Background(), p, func(context.Context) error {
+		n, err := rw.w.Write(p[written:])
+		written += n
+		if err == nil && written < len(p) {
+			err = io.ErrShortWrite
+		}
+		return err
+	})
+	return written, err
+}
+
+// Sync syncs w when it can be synced.
+func (rw *retryWriter) Sync() error {
+	if s, ok := rw.w.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyWriter fails its first failures writes.
+type flakyWriter struct {
+	bytes.Buffer
+	failures int
+	writes   int
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes <= w.failures {
+		return 0, errors.New("connection refused")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestWithWriteRetry(t *testing.T) {
+	var failed [][]byte
+	handler := WithWriteErrorHandler(func(err error, entry []byte) {
+		failed = append(failed, entry)
+	})
+
+	w := &flakyWriter{failures: 2}
+	l := New(WithWriter(w), WithWriteRetry(3, time.Millisecond), handler)
+	l.Info("retried")
+	assert.Equal(t, 3, w.writes)
+	assert.Contains(t, w.String(), "retried")
+	assert.Empty(t, failed)
+
+	w = &flakyWriter{failures: 5}
+	l = New(WithWriter(w), WithWriteRetry(2, time.Millisecond), handler)
+	l.Info("lost")
+	assert.Equal(t, 2, w.writes)
+	assert.Len(t, failed, 1)
+	assert.Contains(t, string(failed[0]), `"msg":"lost"`)
+
+	assert.ErrorIs(t, newOptions(WithWriteRetry(-1, 0)).validate(), ErrInvalidOptions)
+}
+
+func TestWebhookWriteRetry(t *testing.T) {
+	var posts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if posts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	l := New(WithWriter(&bytes.Buffer{}), WithWriteRetry(2, time.Millisecond),
+		WithWebhook(srv.URL, ErrorLevel, WithWebhookInterval(time.Hour)))
+	l.Error("posted on retry")
+	assert.Nil(t, l.Sync())
+	assert.Equal(t, int32(2), posts.Load())
+}
@@ -1,15 +1,21 @@
 package logger
 
 import (
-	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"log"
+	"hash"
+	"io"
 	"os"
 	"path"
+	"path/filepath"
+	"runtime"
 	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,6 +26,18 @@ var (
 	// ErrClosedRollingFile is returned when the rolling file is closed.
 	ErrClosedRollingFile = errors.New("rolling file is closed")
 
+	// ErrWorkerStalled is returned by Healthy when the worker writing a
+	// rolling file stopped making progress.
+	ErrWorkerStalled = errors.New("rolling file worker stalled")
+
+	// ErrBufferFull is returned by Healthy when the pages of a rolling file
+	// stay full, as the worker can't write them.
+	ErrBufferFull = errors.New("rolling file buffer is full")
+
+	// ErrBuffer is returned when no buffer is available.
+	//
+	// Deprecated: buffers are allocated when the pool is exhausted, so it is
+	// no longer returned.
 	ErrBuffer = errors.New("buffer exceeds the limit")
 )
 
@@ -31,30 +49,69 @@ type (
 )
 
 const (
-	defaultDirMode       = 0o755
-	defaultFileMode      = 0o600
+	dateFormat      = "2006-01-02"
+	hourFormat      = "2006-01-02-15"
+	fileTimeFormat  = time.RFC3339
+	hoursPerDay     = 24
+	defaultDirMode  = 0o755
+	defaultFileMode = 0o600
+	// defaultLogFileMode is the permission of the log files, before the umask.
+	defaultLogFileMode   = 0o666
 	gzipExt              = ".gz"
+	digestExt            = ".digest"
+	lockExt              = ".lock"
 	backupFileDelimiter  = "-"
 	sizeRotationRule     = "size"
 	hourRotationRule     = "hour"
 	dayRotationRule      = "day"
+	dailyRotationRule    = "daily"
 	megaBytes            = 1 << 20
 	logPageNumber        = 2
 	logPageCacheByteSize = 4096 // 4KB
+	// maxBatchSize is the capacity above which the buffer coalescing the
+	// queued pages isn't kept, after an entry larger than a page.
+	maxBatchSize      = (logPageNumber + 1) * logPageSize
+	retentionInterval = time.Hour
+	// workerStallTimeout is how long the worker may go without a loop
+	// before Healthy reports it stalled.
+	workerStallTimeout = 10 * time.Second
+	// renameRetries is how many times renaming a file to its backup is
+	// tried, waiting renameRetryDelay, then twice as long, and so on.
+	renameRetries    = 3
+	renameRetryDelay = 10 * time.Millisecond
+	// rotateRetryInterval is how long a failed rotation waits to be retried.
+	rotateRetryInterval = time.Minute
 )
 
+// renameFile renames the rolling files to their backups, replaced in tests.
+var renameFile = os.Rename
+
 type (
 	// A RotateLogger is a Logger that can rotate log files with given rules.
+	//
+	// Writes don't take a lock: writers copy entries into a shared page, and
+	// the worker goroutine writes full pages to the file in order.
 	RotateLogger struct {
 		filename string
 		backup   string
-		fp       *os.File
+		fp       logFile
+		// mmap appends to the files through a memory mapping.
+		mmap bool
+		// delimiter separates the filename and the time in the backup names.
+		delimiter string
 
-		syncFlush  chan struct{}
-		current    *bytes.Buffer
-		fullBuffer chan *bytes.Buffer
+		syncFlush chan chan struct{}
+		current   atomic.Pointer[page]
+		pages     chan *page
+		// batch coalesces the queued pages written at once, owned by the
+		// worker.
+		batch []byte
+		// direct carries the unbuffered writes to the worker, nil when the
+		// entries are buffered.
+		direct     chan directWrite
+		syncPolicy SyncPolicy
 
-		closed   bool
+		closed   int32
 		done     chan struct{}
 		rule     RotateRule
 		compress bool
@@ -62,57 +119,254 @@ type (
 		waitGroup   sync.WaitGroup
 		closeOnce   sync.Once
 		currentSize int64
+		// digest is the running sha256 of the current file, nil if disabled.
+		digest hash.Hash
+		pool   *bufferPool
+		// archiveKey encrypts the compressed backups if not nil.
+		archiveKey []byte
+		// checksum writes a sha256sum sidecar for every archived backup.
+		checksum bool
+		// pathLayout moves the file to a directory formatted from the current
+		// time, under layoutBase, when it changes.
+		pathLayout string
+		layoutBase string
+		layoutName string
+		layoutKeep time.Duration
+		newRule    func(filename string) RotateRule
+		// retainMu serializes compressing and removing backups.
+		retainMu sync.Mutex
+		// nameMu guards filename and rule, which move with the path layout, for
+		// the readers outside the worker goroutine.
+		nameMu sync.RWMutex
+		// rotatedAt is the unix nano time of the last rotation, 0 if none.
+		rotatedAt atomic.Int64
+		// beat is the unix nano time of the last loop of the worker.
+		beat atomic.Int64
+		// writeErr and syncErr hold the error of the last write and sync of
+		// the file, nil after a success.
+		writeErr atomic.Pointer[error]
+		syncErr  atomic.Pointer[error]
+
+		// header is written at the start of every file, nil for none.
+		header []byte
+		// onRotateError is called with the errors of the rotations.
+		onRotateError func(filename string, err error)
+		// internal logs the failures of the file, such as its rotations.
+		internal internalLogger
+		// filePerm and dirPerm are the permissions of the files and the
+		// directories created, before the umask.
+		filePerm os.FileMode
+		dirPerm  os.FileMode
+		// compressor runs the archiving of the backups.
+		compressor *compressor
+		// compressLevel is the gzip level of the backups, 0 for the default.
+		compressLevel int
+		// compressRate limits the bytes read per second when compressing, 0
+		// means no limit.
+		compressRate int
+		// rotateFailedAt is the time of the last failed rotation, rotating
+		// isn't tried again before rotateRetryInterval.
+		rotateFailedAt time.Time
 
+		// mu serializes swapping and queueing pages, so they are written in order.
 		mu sync.Mutex
 	}
 )
 
 // NewRotateLogger returns a RotateLogger with given filename and rule, etc.
+// NewRotateLoggerWithOptions takes the other settings.
 func NewRotateLogger(filename string, rule RotateRule, compress bool) (*RotateLogger, error) {
+	return NewRotateLoggerWithOptions(filename, RotateLoggerOptions{Rule: rule, Compress: compress})
+}
+
+// rotateConfig holds the settings of a RotateLogger beyond its rule.
+type rotateConfig struct {
+	compress bool
+	// digest finalizes every rotated file with a sha256 digest sidecar.
+	digest bool
+	// checksum writes a sha256sum sidecar for every backup once it's archived.
+	checksum bool
+	// archiveKey encrypts the compressed backups if not nil.
+	archiveKey []byte
+	// pathLayout is the time layout of the directory of the file under the
+	// directory of filename, empty for a fixed file.
+	pathLayout string
+	// layoutKeep is how long the files in layout directories are kept, 0 keeps them.
+	layoutKeep time.Duration
+	// newRule returns the rule of the file in a layout directory.
+	newRule func(filename string) RotateRule
+	pool    *bufferPool
+	// header is written at the start of every file, nil for none.
+	header []byte
+	// mmap appends to the files through a memory mapping.
+	mmap bool
+	// unbuffered writes the entries to the file before Write returns.
+	unbuffered bool
+	syncPolicy SyncPolicy
+	// onRotateError is called with the errors of the rotations.
+	onRotateError func(filename string, err error)
+	// internal logs the failures of the file, such as its rotations.
+	internal internalLogger
+	// filePerm and dirPerm are the permissions of the files and the
+	// directories created, defaultLogFileMode and defaultDirMode if 0.
+	filePerm os.FileMode
+	dirPerm  os.FileMode
+	// delimiter separates the filename and the time in the backup names, the
+	// delimiter of the rule, backupFileDelimiter if empty.
+	delimiter string
+	// compressor runs the archiving of the backups, defaultCompressor if nil.
+	compressor *compressor
+	// compressLevel is the gzip level of the backups, 0 for the default.
+	compressLevel int
+	// compressRate limits the bytes read per second when compressing, 0
+	// means no limit.
+	compressRate int
+}
+
+// newRotateLogger returns a RotateLogger with the given settings.
+func newRotateLogger(filename string, rule RotateRule, cfg rotateConfig) (*RotateLogger, error) {
 	l := &RotateLogger{
-		filename:   filename,
-		rule:       rule,
-		compress:   compress,
-		done:       make(chan struct{}),
-		syncFlush:  make(chan struct{}),
-		fullBuffer: make(chan *bytes.Buffer, logPageNumber+1),
-		current:    getBuffer(),
+		filename:      filename,
+		rule:          rule,
+		compress:      cfg.compress,
+		checksum:      cfg.checksum,
+		archiveKey:    cfg.archiveKey,
+		done:          make(chan struct{}),
+		syncFlush:     make(chan chan struct{}),
+		pages:         make(chan *page, logPageNumber+1),
+		pool:          cfg.pool,
+		header:        cfg.header,
+		mmap:          cfg.mmap,
+		onRotateError: cfg.onRotateError,
+		internal:      cfg.internal,
+		filePerm:      cfg.filePerm,
+		dirPerm:       cfg.dirPerm,
+		compressor:    cfg.compressor,
+		compressLevel: cfg.compressLevel,
+		compressRate:  cfg.compressRate,
+		syncPolicy:    cfg.syncPolicy,
+	}
+	if cfg.unbuffered {
+		l.direct = make(chan directWrite)
+	}
+	if l.compressor == nil {
+		l.compressor = defaultCompressor
+	}
+	if l.filePerm == 0 {
+		l.filePerm = defaultLogFileMode
+	}
+	if l.dirPerm == 0 {
+		l.dirPerm = defaultDirMode
+	}
+	if l.delimiter = cfg.delimiter; l.delimiter == "" {
+		l.delimiter = backupFileDelimiter
+	}
+	l.current.Store(newPage(cfg.pool))
+	if cfg.pathLayout != "" {
+		l.pathLayout, l.layoutKeep, l.newRule = cfg.pathLayout, cfg.layoutKeep, cfg.newRule
+		l.layoutBase, l.layoutName = filepath.Dir(filename), filepath.Base(filename)
+		l.filename = l.layoutFilename(time.Now())
+		l.rule = l.newRule(l.filename)
+	}
+	if cfg.digest {
+		l.digest = sha256.New()
 	}
 	if err := l.initialize(); err != nil {
-		return nil, err
+		if l.fp != nil {
+			l.fp.Close()
+		}
+		return nil, &RotateError{Op: "open", Filename: l.filename, Err: err}
 	}
 
 	l.startWorker()
+	l.startRetention()
 	return l, nil
 }
 
-// flush flushes the buffer to the file.
-func (l *RotateLogger) flush() {
-	readyLen := len(l.fullBuffer)
-	for i := 0; i < readyLen; i++ {
-		buff := <-l.fullBuffer
-		l.writeBuffer(buff)
-		putBuffer(buff)
+// flush writes the queued pages and the current page to the file, then syncs
+// it if fsync.
+func (l *RotateLogger) flush(fsync bool) {
+	for !l.mu.TryLock() {
+		// a writer may hold the lock while waiting for room in the queue.
+		select {
+		case p := <-l.pages:
+			l.writePage(p)
+		default:
+			runtime.Gosched()
+		}
+	}
+	queued := len(l.pages)
+	current := l.current.Swap(newPage(l.pool))
+	l.mu.Unlock()
+
+	// pages queued after the swap are newer than current, leave them.
+	for queued > 0 {
+		queued -= l.writePages(<-l.pages, queued)
+	}
+	l.writePage(current)
+
+	if fsync && l.fp != nil {
+		storeErr(&l.syncErr, l.fp.Sync())
+	}
+}
+
+// writePage seals p, writes its data to the file and recycles it.
+func (l *RotateLogger) writePage(p *page) {
+	if data := p.seal(); len(data) > 0 {
+		l.writeBuffer(data)
+	}
+	l.pool.put(p.b)
+}
+
+// writePages writes p and up to max-1 pages queued after it with a single
+// write, so a burst of pages costs one syscall rather than one per page. It
+// returns the number of pages written.
+func (l *RotateLogger) writePages(p *page, max int) int {
+	if max <= 1 || len(l.pages) == 0 {
+		l.writePage(p)
+		return 1
+	}
+
+	if l.batch == nil {
+		l.batch = make([]byte, 0, maxBatchSize)
 	}
-	if l.current != nil {
-		l.writeBuffer(l.current)
-		putBuffer(l.current)
+	batch := append(l.batch[:0], p.seal()...)
+	l.pool.put(p.b)
+	n := 1
+	for ; n < max; n++ {
+		select {
+		case p = <-l.pages:
+		default:
+			l.writeBatch(batch)
+			return n
+		}
+		batch = append(batch, p.seal()...)
+		l.pool.put(p.b)
 	}
+	l.writeBatch(batch)
+	return n
+}
 
-	l.current = nil
-	if l.fp != nil {
-		l.fp.Sync()
+// writeBatch writes the pages coalesced in batch, keeping batch for the next
+// ones unless an entry larger than a page grew it.
+func (l *RotateLogger) writeBatch(batch []byte) {
+	if len(batch) > 0 {
+		l.writeBuffer(batch)
+	}
+	if cap(batch) <= maxBatchSize {
+		l.batch = batch
 	}
 }
 
 func (l *RotateLogger) startWorker() {
 	l.waitGroup.Add(1)
+	l.beat.Store(time.Now().UnixNano())
 
 	go func() {
 		defer l.waitGroup.Done()
 
 		defer func() {
-			l.flush()
+			l.flush(true)
 			if l.fp != nil {
 				l.fp.Close()
 				l.fp = nil
@@ -122,32 +376,21 @@ func (l *RotateLogger) startWorker() {
 		t := time.NewTicker(time.Millisecond * 500)
 		defer t.Stop()
 		for {
+			l.beat.Store(time.Now().UnixNano())
 			select {
-			case <-l.syncFlush:
-				l.mu.Lock()
-				l.flush()
-				l.mu.Unlock()
-				l.syncFlush <- struct{}{}
-			case buff := <-l.fullBuffer:
-				l.writeBuffer(buff)
-				putBuffer(buff)
+			case ack := <-l.syncFlush:
+				l.flush(true)
+				close(ack)
+			case p := <-l.pages:
+				l.writePages(p, cap(l.pages))
+			case w := <-l.direct:
+				w.done <- l.writeDirect(w.b)
 			case <-t.C:
-				l.mu.Lock()
-				if len(l.fullBuffer) != 0 {
-					l.mu.Unlock()
-					continue
-				}
-				// 清空buffer
-				buff := l.current
-				if buff == nil {
-					l.mu.Unlock()
+				if len(l.pages) != 0 || atomic.LoadInt64(&l.current.Load().reserved) == 0 {
 					continue
 				}
-				l.current = nil
-				l.mu.Unlock()
-
-				l.writeBuffer(buff)
-				putBuffer(buff)
+				// the idle page is written, and only synced with SyncOnFlush.
+				l.flush(l.syncPolicy == SyncOnFlush)
 			case <-l.done:
 				return
 			}
@@ -156,40 +399,45 @@ func (l *RotateLogger) startWorker() {
 }
 
 func (l *RotateLogger) Write(b []byte) (n int, err error) {
-	l.mu.Lock()
-	if l.closed {
-		l.mu.Unlock()
+	if atomic.LoadInt32(&l.closed) == 1 {
+		addStat(statDrops, 1)
 		return 0, ErrClosedRollingFile
 	}
+	if l.direct != nil {
+		return l.writeThrough(b)
+	}
 
-	// check if logger is closed
-	select {
-	case <-l.done:
-		l.mu.Unlock()
-		return 0, fmt.Errorf("logger is closed")
-	default:
+	if len(b) > logPageSize {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.queue(l.current.Swap(newPage(l.pool)))
+		l.queue(newFullPage(l.pool, b))
+		return len(b), nil
 	}
 
-	// write to buffer
-	if l.current == nil {
-		l.current = getBuffer()
-		if l.current == nil {
-			l.mu.Unlock()
-			return 0, ErrBuffer
+	for {
+		p := l.current.Load()
+		if p.write(b) {
+			return len(b), nil
 		}
-	}
 
-	// write to buffer
-	n, err = l.current.Write(b)
-	if l.current.Len() > logPageCacheByteSize {
-		buf := l.current
-		l.current = nil
-		l.mu.Unlock()
-		l.fullBuffer <- buf
-	} else {
+		// p is full, the first writer to get the lock replaces it.
+		l.mu.Lock()
+		if l.current.Load() == p {
+			l.queue(l.current.Swap(newPage(l.pool)))
+		}
 		l.mu.Unlock()
 	}
-	return
+}
+
+// queue hands p to the worker, it must be called with l.mu held. Pages
+// queued after Close started are dropped, as the worker may be gone.
+func (l *RotateLogger) queue(p *page) {
+	if atomic.LoadInt32(&l.closed) == 1 {
+		addStat(statDrops, 1)
+		return
+	}
+	l.pages <- p
 }
 
 func (l *RotateLogger) getBackupFilename() string {
@@ -203,23 +451,35 @@ func (l *RotateLogger) getBackupFilename() string {
 func (l *RotateLogger) initialize() error {
 	l.backup = l.rule.BackupFileName()
 
-	if fileInfo, err := os.Stat(l.filename); err != nil {
-		basePath := path.Dir(l.filename)
-		if _, err = os.Stat(basePath); err != nil {
-			if err = os.MkdirAll(basePath, defaultDirMode); err != nil {
-				return err
-			}
-		}
+	// the file is created or opened by a single call and then inspected
+	// through its descriptor, so the goroutines and processes starting at
+	// once don't race between checking for the file and creating it.
+	if err := os.MkdirAll(path.Dir(l.filename), l.dirPerm); err != nil {
+		return err
+	}
+	fp, err := l.openFile(l.filename)
+	if err != nil {
+		return err
+	}
+	l.fp = fp
+	// the size once opened, which removed the zeros a mapping may have left.
+	fileInfo, err := fp.Stat()
+	if err != nil {
+		return err
+	}
 
-		if l.fp, err = os.Create(l.filename); err != nil {
-			return err
+	if l.currentSize = fileInfo.Size(); l.currentSize > 0 {
+		if rule, ok := l.rule.(resumableRule); ok {
+			// resume the schedule from the last write to the file, so a file
+			// of a past period is rotated now, not a period after the restart.
+			rule.markRotatedAt(fileInfo.ModTime())
+			l.backup = rule.backupFileNameAt(fileInfo.ModTime())
 		}
-	} else {
-		if l.fp, err = os.OpenFile(l.filename, os.O_APPEND|os.O_WRONLY, defaultFileMode); err != nil {
-			return err
+		if l.digest != nil {
+			if err = hashFile(l.filename, l.digest); err != nil {
+				return err
+			}
 		}
-
-		l.currentSize = fileInfo.Size()
 	}
 
 	return nil
@@ -232,7 +492,7 @@ func (l *RotateLogger) maybeCompressFile(file string) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf(fmt.Sprintf("%s\n%s", r, string(debug.Stack())))
+			l.internal.error("rolling file maintenance panicked", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
 		}
 	}()
 
@@ -241,68 +501,410 @@ func (l *RotateLogger) maybeCompressFile(file string) {
 		return
 	}
 
-	compressLogFile(file)
+	l.compressLogFile(file)
+}
+
+// archive compresses the backup file if enabled, then writes the checksum
+// sidecar of the result, and moves the digest sidecar to it.
+func (l *RotateLogger) archive(file string) {
+	l.maybeCompressFile(file)
+	if !l.checksum && l.digest == nil {
+		return
+	}
+
+	archived := file
+	if l.compress {
+		name := file + gzipExt
+		if l.archiveKey != nil {
+			name += encryptedExt
+		}
+		if _, err := os.Stat(name); err == nil {
+			archived = name
+		}
+	}
+	if l.checksum {
+		if err := writeChecksum(archived); err != nil {
+			l.internal.error("failed to write checksum file", "file", archived+checksumExt, "error", err)
+		}
+	}
+	if l.digest != nil && archived != file {
+		l.moveDigest(file, archived)
+	}
 }
 
 func (l *RotateLogger) maybeDeleteOutdatedFiles() {
-	files := l.rule.OutdatedFiles()
+	_, rule := l.names()
+	files := rule.OutdatedFiles()
 	for _, file := range files {
-		if err := os.Remove(file); err != nil {
-			log.Printf("failed to remove outdated file: %s", file)
+		if err := removeBackup(file); err != nil {
+			l.internal.error("failed to remove outdated file", "file", file, "error", err)
 		}
 	}
+	l.deleteOutdatedLayoutFiles()
 }
 
+// removeBackup removes the backup file and its sidecars.
+func removeBackup(file string) error {
+	err := os.Remove(file)
+	for _, sidecar := range []string{file + checksumExt, file + digestExt} {
+		if e := os.Remove(sidecar); e != nil && !os.IsNotExist(e) {
+			err = errors.Join(err, e)
+		}
+	}
+	return err
+}
+
+// postRotate archives the backup file and removes the outdated backups on
+// the compressor. If its backlog is full, the retention does it later.
 func (l *RotateLogger) postRotate(file string) {
-	go func() {
-		// we cannot use threading.GoSafe here, because of import cycle.
-		l.maybeCompressFile(file)
+	queued := l.compressor.submit(func() {
+		l.retainMu.Lock()
+		defer l.retainMu.Unlock()
+		l.archive(file)
 		l.maybeDeleteOutdatedFiles()
+	})
+	if !queued {
+		l.internal.warn("compression backlog is full, leaving the file to the retention", "file", file)
+	}
+}
+
+// startRetention compresses and prunes the backups once at start and then
+// every retentionInterval, so they are maintained even if l rarely rotates,
+// e.g. when the service restarts often or logs little.
+func (l *RotateLogger) startRetention() {
+	go func() {
+		l.retain()
+
+		t := time.NewTicker(retentionInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				l.retain()
+			case <-l.done:
+				return
+			}
+		}
 	}()
 }
 
+// retain compresses the backups left uncompressed and removes the outdated ones.
+func (l *RotateLogger) retain() {
+	l.retainMu.Lock()
+	defer l.retainMu.Unlock()
+
+	if l.compress {
+		for _, file := range l.uncompressedBackups() {
+			l.archive(file)
+		}
+	}
+	l.maybeDeleteOutdatedFiles()
+}
+
+// uncompressedBackups returns the backups of l which aren't gzipped, for
+// both the date and the size rotation naming.
+func (l *RotateLogger) uncompressedBackups() []string {
+	_, pending := l.backups()
+	return pending
+}
+
+// backups returns the archived and the uncompressed backups of l, for both
+// the date and the size rotation naming.
+func (l *RotateLogger) backups() (archived, pending []string) {
+	filename, _ := l.names()
+	filename = filepath.Clean(filename)
+	ext := path.Ext(filename)
+	patterns := []string{
+		filename + l.delimiter + "*",
+		strings.TrimSuffix(filename, ext) + l.delimiter + "*" + ext,
+		strings.TrimSuffix(filename, ext) + l.delimiter + "*" + ext + gzipExt + "*",
+	}
+
+	seen := make(map[string]PlaceholderType)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			l.internal.error("failed to list backups", "pattern", pattern, "error", err)
+			continue
+		}
+		for _, file := range matches {
+			if _, ok := seen[file]; ok || !l.isBackup(file, filename) {
+				continue
+			}
+			seen[file] = Placeholder
+			if strings.HasSuffix(file, gzipExt) || strings.HasSuffix(file, encryptedExt) {
+				archived = append(archived, file)
+			} else {
+				pending = append(pending, file)
+			}
+		}
+	}
+	return archived, pending
+}
+
+// isBackup reports whether file is named like a backup of filename by the
+// date, the hour or the size rotation, archived or not. Sidecars and the
+// backups of the files whose name starts like filename aren't.
+func (l *RotateLogger) isBackup(file, filename string) bool {
+	_, ok := l.backupTime(file, filename)
+	return ok
+}
+
+// backupTime returns the time in the name of file, a backup of filename.
+func (l *RotateLogger) backupTime(file, filename string) (time.Time, bool) {
+	file = strings.TrimSuffix(strings.TrimSuffix(file, encryptedExt), gzipExt)
+	if t, _, ok := parseBackupName(file, filename+l.delimiter, "", dateFormat, hourFormat); ok {
+		return t, true
+	}
+	ext := path.Ext(filename)
+	t, _, ok := parseBackupName(file, strings.TrimSuffix(filename, ext)+l.delimiter, ext, fileTimeFormat)
+	return t, ok
+}
+
+// names returns the file and the rule of l.
+func (l *RotateLogger) names() (string, RotateRule) {
+	l.nameMu.RLock()
+	defer l.nameMu.RUnlock()
+	return l.filename, l.rule
+}
+
 // rotate 日志轮转
+//
+// Processes sharing the file rotate it under an advisory lock, the first one
+// renames it and the others reopen the new file.
 func (l *RotateLogger) rotate() error {
+	unlock, err := lockFile(l.filename + lockExt)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	rotated := l.rotatedByOther()
 	// close the current file
-	if err := l.close(); err != nil {
+	if err = l.close(); err != nil {
 		return err
 	}
 
-	_, err := os.Stat(l.filename)
+	if rotated {
+		l.backup = l.rule.BackupFileName()
+		if l.fp, err = l.openFile(l.filename); err != nil {
+			return err
+		}
+		// the other processes may have written to the new file already.
+		info, err := l.fp.Stat()
+		if err != nil {
+			return err
+		}
+		l.currentSize = info.Size()
+		return nil
+	}
+
+	_, err = os.Stat(l.filename)
 	if err == nil && len(l.backup) > 0 {
 		backupFilename := l.getBackupFilename()
-		err = os.Rename(l.filename, backupFilename)
-		if err != nil {
+		if err = renameLogFile(l.filename, backupFilename); err != nil {
+			// keep writing to the file until the rotation is retried.
+			l.fp, _ = l.openFile(l.filename)
 			return err
 		}
 
+		l.finalizeDigest(backupFilename)
 		l.postRotate(backupFilename)
 	}
 
 	l.backup = l.rule.BackupFileName()
-	l.fp, err = os.Create(l.filename)
+	l.currentSize = 0
+	l.fp, err = l.openFile(l.filename)
 	return err
 }
 
-func (l *RotateLogger) writeBuffer(buff *bytes.Buffer) (int64, error) {
-	if l.rule.ShallRotate(l.currentSize + int64(buff.Len())) {
+// renameLogFile renames the file from to its backup to, retrying with a
+// backoff, as a file can be locked for a while on Windows. It copies the
+// file and truncates it if renaming fails, e.g. across devices.
+func renameLogFile(from, to string) error {
+	var err error
+	delay := renameRetryDelay
+	for i := 0; i < renameRetries; i++ {
+		if i > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		if err = renameFile(from, to); err == nil {
+			return nil
+		}
+	}
+
+	if copyErr := copyTruncate(from, to); copyErr != nil {
+		return fmt.Errorf("failed to rotate %s: %w, copy: %v", from, err, copyErr)
+	}
+	return nil
+}
+
+// copyTruncate copies the file from to the new file to, then truncates from.
+func copyTruncate(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_CREATE|os.O_EXCL|os.O_WRONLY, defaultFileMode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(to)
+		return err
+	}
+	return os.Truncate(from, 0)
+}
+
+// rotatedByOther reports whether the file open in l was rotated by another
+// process, so its path names another file.
+func (l *RotateLogger) rotatedByOther() bool {
+	if l.fp == nil {
+		return false
+	}
+	open, err := l.fp.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(l.filename)
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(open, current)
+}
+
+// logFile is the file a RotateLogger writes to.
+type logFile interface {
+	io.Writer
+	Sync() error
+	Close() error
+	Stat() (os.FileInfo, error)
+}
+
+// openFile opens name for l to append to, creating it if needed.
+func (l *RotateLogger) openFile(name string) (logFile, error) {
+	if l.mmap {
+		return openMmapFile(name, l.filePerm, l.internal)
+	}
+	fp, err := openLogFile(name, l.filePerm)
+	if err != nil {
+		// a nil *os.File in the interface wouldn't be nil.
+		return nil, err
+	}
+	return fp, nil
+}
+
+// openLogFile opens name for appending, creating it with perm if needed.
+// Appending keeps the entries of processes sharing the file intact.
+func openLogFile(name string, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+}
+
+// finalizeDigest writes the digest of the rotated file to a sidecar file and
+// resets the running digest for the next file.
+func (l *RotateLogger) finalizeDigest(backupFilename string) {
+	if l.digest == nil {
+		return
+	}
+
+	sum := hex.EncodeToString(l.digest.Sum(nil))
+	l.digest.Reset()
+	if err := os.WriteFile(backupFilename+digestExt, []byte(sum+"\n"), defaultFileMode); err != nil {
+		l.internal.error("failed to write digest file", "file", backupFilename+digestExt, "error", err)
+	}
+}
+
+// moveDigest replaces the digest sidecar of the backup file by the one of
+// its archive, so the sidecar is named after and matches the file kept.
+func (l *RotateLogger) moveDigest(file, archived string) {
+	if _, err := os.Stat(file + digestExt); err != nil {
+		// the backup was rotated before the digest was enabled.
+		return
+	}
+
+	h := sha256.New()
+	if err := hashFile(archived, h); err != nil {
+		l.internal.error("failed to write digest file", "file", archived+digestExt, "error", err)
+		return
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if err := os.WriteFile(archived+digestExt, []byte(sum+"\n"), defaultFileMode); err != nil {
+		l.internal.error("failed to write digest file", "file", archived+digestExt, "error", err)
+		return
+	}
+	if err := os.Remove(file + digestExt); err != nil {
+		l.internal.error("failed to remove digest file", "file", file+digestExt, "error", err)
+	}
+}
+
+// write writes v to the file directly, bypassing the pages.
+func (l *RotateLogger) write(v []byte) {
+	if _, err := l.writeBuffer(v); err != nil {
+		l.internal.error("failed to write log file", "file", l.filename, "error", err)
+	}
+}
+
+func (l *RotateLogger) writeBuffer(buff []byte) (int64, error) {
+	l.maybeSwitchLayout()
+	if l.rule.ShallRotate(l.currentSize+int64(len(buff))) && time.Since(l.rotateFailedAt) >= rotateRetryInterval {
 		if err := l.rotate(); err != nil {
-			log.Println(err)
+			l.internal.error("failed to rotate log file", "file", l.filename, "error", err)
+			// kept until a write succeeds, writes are dropped without a file.
+			storeErr(&l.writeErr, err)
+			l.rotateFailedAt = time.Now()
+			if l.onRotateError != nil {
+				l.onRotateError(l.filename, err)
+			}
 		} else {
 			l.rule.MarkRotated()
-			l.currentSize = 0
+			l.rotatedAt.Store(time.Now().UnixNano())
+			addStat(statRotations, 1)
 		}
 	}
 	if l.fp == nil {
+		addStat(statDrops, 1)
 		return 0, nil
 	}
+	if l.currentSize == 0 && l.header != nil {
+		l.writeHeader()
+	}
 
-	size, err := buff.WriteTo(l.fp)
-	if err != nil {
-		return size, err
+	if l.digest != nil {
+		l.digest.Write(buff)
+	}
+	size, err := l.fp.Write(buff)
+	storeErr(&l.writeErr, err)
+	l.currentSize += int64(size)
+	addStat(statBytes, int64(size))
+	if err == nil && l.syncPolicy == SyncEveryWrite {
+		err = l.fp.Sync()
+		storeErr(&l.syncErr, err)
 	}
-	l.currentSize += size
-	return size, nil
+	return int64(size), err
+}
+
+// writeHeader writes the header at the start of the file, unless another
+// process sharing the file already wrote entries to it.
+func (l *RotateLogger) writeHeader() {
+	if info, err := l.fp.Stat(); err != nil || info.Size() > 0 {
+		return
+	}
+	if l.digest != nil {
+		l.digest.Write(l.header)
+	}
+	size, err := l.fp.Write(l.header)
+	storeErr(&l.writeErr, err)
+	l.currentSize += int64(size)
+	addStat(statBytes, int64(size))
 }
 
 // close file close the file
@@ -326,15 +928,15 @@ func (l *RotateLogger) close() (err error) {
 
 // Close closes l.
 func (l *RotateLogger) Close() (err error) {
-	l.mu.Lock()
-	if l.closed {
-		l.mu.Unlock()
+	if atomic.LoadInt32(&l.closed) == 1 {
 		return nil
 	}
-	l.mu.Unlock()
 
 	l.closeOnce.Do(func() {
-		l.closed = true
+		l.mu.Lock()
+		atomic.StoreInt32(&l.closed, 1)
+		l.mu.Unlock()
+
 		close(l.done)
 		l.waitGroup.Wait()
 		err = l.close()
@@ -343,37 +945,68 @@ func (l *RotateLogger) Close() (err error) {
 	return err
 }
 
+// Sync writes the buffered entries to the file and syncs it, a failure is a
+// *RotateError.
 func (l *RotateLogger) Sync() error {
-	l.mu.Lock()
-	if l.closed {
-		l.mu.Unlock()
+	if atomic.LoadInt32(&l.closed) == 1 {
 		return ErrClosedRollingFile
 	}
-	l.mu.Unlock()
 
-	l.syncFlush <- struct{}{}
-	<-l.syncFlush
-	return nil
+	ack := make(chan struct{})
+	select {
+	case l.syncFlush <- ack:
+		<-ack
+		if err := l.syncErr.Load(); err != nil {
+			filename, _ := l.names()
+			return &RotateError{Op: "sync", Filename: filename, Err: *err}
+		}
+		return nil
+	case <-l.done:
+		return ErrClosedRollingFile
+	}
 }
 
-func compressLogFile(file string) {
+func (l *RotateLogger) compressLogFile(file string) {
 	start := time.Now()
-	log.Printf("compressing log file: %s", file)
-	if err := gzipFile(file, fileSys); err != nil {
-		log.Printf("compress error: %s", err)
+	defer addCompressionStat(start)
+	l.internal.info("compressing log file", "file", file)
+	if err := writeArchive(file, fileSys, l.archiveKey, l.compressLevel, l.compressRate); err != nil {
+		l.internal.error("failed to compress log file", "file", file, "error", err)
 	} else {
-		log.Printf("compressed log file: %s, took %s", file, time.Since(start))
+		l.internal.info("compressed log file", "file", file, "took", time.Since(start))
+	}
+}
+
+func hashFile(file string, h hash.Hash) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
+
+	_, err = io.Copy(h, f)
+	return err
 }
 
-func gzipFile(file string, fsys FileSystem) (err error) {
+func gzipFile(file string, fsys FileSystem) error {
+	return archiveFile(file, fsys, nil)
+}
+
+// archiveFile gzips file, and encrypts it if key isn't nil, then removes it.
+func archiveFile(file string, fsys FileSystem, key []byte) error {
+	return writeArchive(file, fsys, key, 0, 0)
+}
+
+// writeArchive is archiveFile with the gzip level, 0 for the default, and
+// the bytes read per second, 0 for no limit.
+func writeArchive(file string, fsys FileSystem, key []byte, level, rate int) (err error) {
 	in, err := fsys.Open(file)
 	if err != nil {
 		return err
 	}
 	defer func() {
 		if e := fsys.Close(in); e != nil {
-			log.Printf("failed to close file: %s, error: %v", file, e)
+			internalError("failed to close file", "file", file, "error", e)
 		}
 		if err == nil {
 			// only remove the original file when compression is successful
@@ -381,7 +1014,11 @@ func gzipFile(file string, fsys FileSystem) (err error) {
 		}
 	}()
 
-	out, err := fsys.Create(fmt.Sprintf("%s%s", file, gzipExt))
+	ext := gzipExt
+	if key != nil {
+		ext += encryptedExt
+	}
+	out, err := fsys.Create(fmt.Sprintf("%s%s", file, ext))
 	if err != nil {
 		return err
 	}
@@ -392,11 +1029,45 @@ func gzipFile(file string, fsys FileSystem) (err error) {
 		}
 	}()
 
-	w := gzip.NewWriter(out)
-	if _, err = fsys.Copy(w, in); err != nil {
+	var dst io.Writer = out
+	var ew *encryptWriter
+	if key != nil {
+		if ew, err = newEncryptWriter(out, key); err != nil {
+			return err
+		}
+		dst = ew
+	}
+
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	w, err := gzip.NewWriterLevel(dst, level)
+	if err != nil {
+		return err
+	}
+	var src io.Reader = in
+	if rate > 0 {
+		src = newRateReader(in, rate)
+	}
+	if _, err = fsys.Copy(w, src); err != nil {
 		// failed to copy, no need to close w
 		return err
 	}
 
-	return fsys.Close(w)
+	if err = fsys.Close(w); err != nil || ew == nil {
+		return err
+	}
+	return fsys.Close(ew)
+}
+
+func getNowDate() string {
+	return time.Now().Format(dateFormat)
+}
+
+func getNowHour() string {
+	return time.Now().Format(hourFormat)
+}
+
+func getNowDateInRFC3339Format() string {
+	return time.Now().Format(fileTimeFormat)
 }
@@ -1,13 +1,23 @@
 package logger
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"testing"
@@ -62,6 +72,25 @@ func TempFilenameWithText(text string) (string, error) {
 	return filename, nil
 }
 
+// tempLogFile creates the file app.log with the given content in a
+// directory removed with the test, and returns its name.
+func tempLogFile(t *testing.T, text string) string {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	assert.Nil(t, os.WriteFile(filename, []byte(text), defaultFileMode))
+	return filename
+}
+
+// waitArchived closes l and waits for its backups to be compressed, so that
+// nothing is written to its directory once the test returns.
+func waitArchived(t *testing.T, l *RotateLogger) {
+	_ = l.Close()
+	assert.Eventually(t, func() bool {
+		return len(l.uncompressedBackups()) == 0
+	}, time.Second, 10*time.Millisecond)
+	l.retainMu.Lock()
+	defer l.retainMu.Unlock()
+}
+
 func TestDailyRotateRuleMarkRotated(t *testing.T) {
 	t.Run("daily rule", func(t *testing.T) {
 		var rule DailyRotateRule
@@ -89,25 +118,62 @@ func TestDailyRotateRuleOutdatedFiles(t *testing.T) {
 	})
 
 	t.Run("temp files", func(t *testing.T) {
+		dir := t.TempDir()
 		boundary := time.Now().Add(-time.Hour * time.Duration(hoursPerDay) * 2).Format(dateFormat)
-		f1, err := os.CreateTemp(os.TempDir(), "go-zero-test-"+boundary)
+		f1, err := os.Create(path.Join(dir, "go-zero-test-"+boundary))
 		assert.NoError(t, err)
 		_ = f1.Close()
-		f2, err := os.CreateTemp(os.TempDir(), "go-zero-test-"+boundary)
+		f2, err := os.Create(path.Join(dir, "go-zero-test-"+boundary+".1"))
 		assert.NoError(t, err)
 		_ = f2.Close()
-		t.Cleanup(func() {
-			_ = os.Remove(f1.Name())
-			_ = os.Remove(f2.Name())
-		})
+		// named like the backups of another file.
+		f3, err := os.Create(path.Join(dir, "go-zero-test-access.log-"+boundary))
+		assert.NoError(t, err)
+		_ = f3.Close()
 		rule := DailyRotateRule{
-			filename: path.Join(os.TempDir(), "go-zero-test-"),
+			filename: path.Join(dir, "go-zero-test-"),
 			days:     1,
 		}
-		assert.NotEmpty(t, rule.OutdatedFiles())
+		assert.Equal(t, []string{f1.Name(), f2.Name()}, rule.OutdatedFiles())
 	})
 }
 
+func TestRotateRuleGlobFailure(t *testing.T) {
+	var global lockedBuffer
+	SetInternalOutput(&global, 0)
+	defer SetInternalOutput(os.Stderr, 0)
+
+	rules := map[string]func(filename string) RotateRule{
+		"daily": func(filename string) RotateRule { return DefaultRotateRule(filename, "-", 1, false) },
+		"hour":  func(filename string) RotateRule { return NewHourRotateRule(filename, "-", 1, false) },
+		"size": func(filename string) RotateRule {
+			return NewSizeLimitRotateRule(filename, "-", 1, 1, 1, false)
+		},
+	}
+	for name, newRule := range rules {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			// an unclosed class makes the glob of the backups fail.
+			rule := newRule(filepath.Join(t.TempDir(), "[app.log"))
+			rule.(internalRule).setInternal(internalLogger{lg: newInternalLogger(&buf, defaultInternalRate)})
+
+			assert.Empty(t, rule.OutdatedFiles())
+			assert.Contains(t, buf.String(), "failed to find outdated log files")
+			assert.Contains(t, buf.String(), filepath.ErrBadPattern.Error())
+		})
+	}
+	assert.Empty(t, global.String())
+
+	// the rules of a Logging log to its internal writer.
+	var buf lockedBuffer
+	l := New(WithMode(FileMode), WithPath(t.TempDir()), WithFilename("[app.log"), WithKeepDays(1),
+		WithInternalWriter(&buf))
+	defer l.Close()
+	assert.Empty(t, l._rotateLoggers[0].rule.OutdatedFiles())
+	assert.Contains(t, buf.String(), "failed to find outdated log files")
+	assert.Empty(t, global.String())
+}
+
 func TestDailyRotateRuleShallRotate(t *testing.T) {
 	var rule DailyRotateRule
 	rule.rotatedTime = time.Now().Add(time.Hour * 24).Format(dateFormat)
@@ -154,25 +220,23 @@ func TestSizeLimitRotateRuleOutdatedFiles(t *testing.T) {
 	})
 
 	t.Run("temp files", func(t *testing.T) {
-		boundary := time.Now().Add(-time.Hour * time.Duration(hoursPerDay) * 2).Format(dateFormat)
-		f1, err := os.CreateTemp(os.TempDir(), "go-zero-test-"+boundary)
+		dir := t.TempDir()
+		boundary := time.Now().Add(-time.Hour * time.Duration(hoursPerDay) * 2)
+		f1, err := os.Create(path.Join(dir, "go-zero-test-"+boundary.Format(fileTimeFormat)))
 		assert.NoError(t, err)
-		f2, err := os.CreateTemp(os.TempDir(), "go-zero-test-"+boundary)
+		f2, err := os.Create(path.Join(dir, "go-zero-test-"+boundary.Add(-time.Second).Format(fileTimeFormat)))
 		assert.NoError(t, err)
-		boundary1 := time.Now().Add(time.Hour * time.Duration(hoursPerDay) * 2).Format(dateFormat)
-		f3, err := os.CreateTemp(os.TempDir(), "go-zero-test-"+boundary1)
+		boundary1 := time.Now().Add(time.Hour * time.Duration(hoursPerDay) * 2).Format(fileTimeFormat)
+		f3, err := os.Create(path.Join(dir, "go-zero-test-"+boundary1))
 		assert.NoError(t, err)
 		t.Cleanup(func() {
 			_ = f1.Close()
-			_ = os.Remove(f1.Name())
 			_ = f2.Close()
-			_ = os.Remove(f2.Name())
 			_ = f3.Close()
-			_ = os.Remove(f3.Name())
 		})
 		rule := SizeLimitRotateRule{
 			DailyRotateRule: DailyRotateRule{
-				filename: path.Join(os.TempDir(), "go-zero-test-"),
+				filename: path.Join(dir, "go-zero-test-"),
 				days:     1,
 			},
 			maxBackups: 3,
@@ -181,25 +245,23 @@ func TestSizeLimitRotateRuleOutdatedFiles(t *testing.T) {
 	})
 
 	t.Run("no backups", func(t *testing.T) {
-		boundary := time.Now().Add(-time.Hour * time.Duration(hoursPerDay) * 2).Format(dateFormat)
-		f1, err := os.CreateTemp(os.TempDir(), "go-zero-test-"+boundary)
+		dir := t.TempDir()
+		boundary := time.Now().Add(-time.Hour * time.Duration(hoursPerDay) * 2)
+		f1, err := os.Create(path.Join(dir, "go-zero-test-"+boundary.Format(fileTimeFormat)))
 		assert.NoError(t, err)
-		f2, err := os.CreateTemp(os.TempDir(), "go-zero-test-"+boundary)
+		f2, err := os.Create(path.Join(dir, "go-zero-test-"+boundary.Add(-time.Second).Format(fileTimeFormat)))
 		assert.NoError(t, err)
-		boundary1 := time.Now().Add(time.Hour * time.Duration(hoursPerDay) * 2).Format(dateFormat)
-		f3, err := os.CreateTemp(os.TempDir(), "go-zero-test-"+boundary1)
+		boundary1 := time.Now().Add(time.Hour * time.Duration(hoursPerDay) * 2).Format(fileTimeFormat)
+		f3, err := os.Create(path.Join(dir, "go-zero-test-"+boundary1))
 		assert.NoError(t, err)
 		t.Cleanup(func() {
 			_ = f1.Close()
-			_ = os.Remove(f1.Name())
 			_ = f2.Close()
-			_ = os.Remove(f2.Name())
 			_ = f3.Close()
-			_ = os.Remove(f3.Name())
 		})
 		rule := SizeLimitRotateRule{
 			DailyRotateRule: DailyRotateRule{
-				filename: path.Join(os.TempDir(), "go-zero-test-"),
+				filename: path.Join(dir, "go-zero-test-"),
 				days:     1,
 			},
 		}
@@ -297,29 +359,22 @@ func TestRotateLoggerMayCompressFileTrue(t *testing.T) {
 		os.Stdout = old
 	}()
 
-	filename, err := TempFilenameWithText("foo")
+	filename := tempLogFile(t, "foo")
+	logger, err := NewRotateLogger(filename, DefaultRotateRule(filename, backupFileDelimiter, 1, true), true)
 	assert.Nil(t, err)
-	logger, err := NewRotateLogger(filename, new(DailyRotateRule), true)
-	assert.Nil(t, err)
-	if len(filename) > 0 {
-		defer os.Remove(filepath.Base(logger.getBackupFilename()) + ".gz")
-	}
+	defer logger.Close()
 	logger.maybeCompressFile(filename)
 	_, err = os.Stat(filename)
 	assert.NotNil(t, err)
+	_, err = os.Stat(filename + gzipExt)
+	assert.Nil(t, err)
 }
 
 func TestRotateLoggerRotate(t *testing.T) {
-	filename, err := TempFilenameWithText("foo")
-	assert.Nil(t, err)
-	logger, err := NewRotateLogger(filename, new(DailyRotateRule), true)
+	filename := tempLogFile(t, "foo")
+	logger, err := NewRotateLogger(filename, DefaultRotateRule(filename, backupFileDelimiter, 1, true), true)
 	assert.Nil(t, err)
-	if len(filename) > 0 {
-		defer func() {
-			os.Remove(logger.getBackupFilename())
-			os.Remove(filepath.Base(logger.getBackupFilename()) + ".gz")
-		}()
-	}
+	defer waitArchived(t, logger)
 	err = logger.rotate()
 	switch v := err.(type) {
 	case *os.LinkError:
@@ -335,20 +390,14 @@ func TestRotateLoggerRotate(t *testing.T) {
 }
 
 func TestRotateLoggerWrite(t *testing.T) {
-	filename, err := TempFilenameWithText("foo")
-	assert.Nil(t, err)
-	rule := new(DailyRotateRule)
+	filename := tempLogFile(t, "foo")
+	rule := DefaultRotateRule(filename, backupFileDelimiter, 1, true)
 	logger, err := NewRotateLogger(filename, rule, true)
 	assert.Nil(t, err)
-	if len(filename) > 0 {
-		defer func() {
-			os.Remove(logger.getBackupFilename())
-			os.Remove(filepath.Base(logger.getBackupFilename()) + ".gz")
-		}()
-	}
+	defer waitArchived(t, logger)
 	// the following write calls cannot be changed to Write, because of DATA RACE.
 	logger.write([]byte(`foo`))
-	rule.rotatedTime = time.Now().Add(-time.Hour * 24).Format(dateFormat)
+	rule.(*DailyRotateRule).rotatedTime = time.Now().Add(-time.Hour * 24).Format(dateFormat)
 	logger.write([]byte(`bar`))
 	logger.Close()
 	logger.write([]byte(`baz`))
@@ -404,16 +453,15 @@ func TestRotateLoggerWithSizeLimitRotateRuleMayCompressFileTrue(t *testing.T) {
 		os.Stdout = old
 	}()
 
-	filename, err := TempFilenameWithText("foo")
-	assert.Nil(t, err)
-	logger, err := NewRotateLogger(filename, new(SizeLimitRotateRule), true)
+	filename := tempLogFile(t, "foo")
+	logger, err := NewRotateLogger(filename, NewSizeLimitRotateRule(filename, backupFileDelimiter, 1, 1, 1, true), true)
 	assert.Nil(t, err)
-	if len(filename) > 0 {
-		defer os.Remove(filepath.Base(logger.getBackupFilename()) + ".gz")
-	}
+	defer logger.Close()
 	logger.maybeCompressFile(filename)
 	_, err = os.Stat(filename)
 	assert.NotNil(t, err)
+	_, err = os.Stat(filename + gzipExt)
+	assert.Nil(t, err)
 }
 
 func TestRotateLoggerWithSizeLimitRotateRuleMayCompressFileFailed(t *testing.T) {
@@ -423,10 +471,10 @@ func TestRotateLoggerWithSizeLimitRotateRuleMayCompressFileFailed(t *testing.T)
 		os.Stdout = old
 	}()
 
-	filename := "./logs/info.log"
-	logger, err := NewRotateLogger(filename, new(SizeLimitRotateRule), true)
-	defer os.Remove(filename)
+	filename := filepath.Join(t.TempDir(), "info.log")
+	logger, err := NewRotateLogger(filename, NewSizeLimitRotateRule(filename, backupFileDelimiter, 1, 1, 1, true), true)
 	if assert.NoError(t, err) {
+		defer waitArchived(t, logger)
 		assert.NotPanics(t, func() {
 			logger.maybeCompressFile(filename)
 		})
@@ -434,16 +482,10 @@ func TestRotateLoggerWithSizeLimitRotateRuleMayCompressFileFailed(t *testing.T)
 }
 
 func TestRotateLoggerWithSizeLimitRotateRuleRotate(t *testing.T) {
-	filename, err := TempFilenameWithText("foo")
+	filename := tempLogFile(t, "foo")
+	logger, err := NewRotateLogger(filename, NewSizeLimitRotateRule(filename, backupFileDelimiter, 1, 1, 1, true), true)
 	assert.Nil(t, err)
-	logger, err := NewRotateLogger(filename, new(SizeLimitRotateRule), true)
-	assert.Nil(t, err)
-	if len(filename) > 0 {
-		defer func() {
-			os.Remove(logger.getBackupFilename())
-			os.Remove(filepath.Base(logger.getBackupFilename()) + ".gz")
-		}()
-	}
+	defer waitArchived(t, logger)
 	err = logger.rotate()
 	switch v := err.(type) {
 	case *os.LinkError:
@@ -459,20 +501,14 @@ func TestRotateLoggerWithSizeLimitRotateRuleRotate(t *testing.T) {
 }
 
 func TestRotateLoggerWithSizeLimitRotateRuleWrite(t *testing.T) {
-	filename, err := TempFilenameWithText("foo")
-	assert.Nil(t, err)
-	rule := new(SizeLimitRotateRule)
+	filename := tempLogFile(t, "foo")
+	rule := NewSizeLimitRotateRule(filename, backupFileDelimiter, 1, 1, 1, true)
 	logger, err := NewRotateLogger(filename, rule, true)
 	assert.Nil(t, err)
-	if len(filename) > 0 {
-		defer func() {
-			os.Remove(logger.getBackupFilename())
-			os.Remove(filepath.Base(logger.getBackupFilename()) + ".gz")
-		}()
-	}
+	defer waitArchived(t, logger)
 	// the following write calls cannot be changed to Write, because of DATA RACE.
 	logger.write([]byte(`foo`))
-	rule.rotatedTime = time.Now().Add(-time.Hour * 24).Format(dateFormat)
+	rule.(*SizeLimitRotateRule).rotatedTime = time.Now().Add(-time.Hour * 24).Format(fileTimeFormat)
 	logger.write([]byte(`bar`))
 	logger.Close()
 	logger.write([]byte(`baz`))
@@ -658,3 +694,748 @@ func (f *fakeFileSystem) Remove(name string) error {
 func (f *fakeFileSystem) Removed() bool {
 	return atomic.LoadInt32(&f.removed) > 0
 }
+
+func TestRotateLoggerDigest(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "audit.log")
+	rule := DefaultRotateRule(filename, backupFileDelimiter, 1, false)
+	logger, err := newRotateLogger(filename, rule, rotateConfig{digest: true, pool: bpool})
+	assert.Nil(t, err)
+	logger.write([]byte("foo\n"))
+	backup := logger.getBackupFilename()
+	assert.Nil(t, logger.rotate())
+	assert.Nil(t, logger.Close())
+
+	sum, err := os.ReadFile(backup + digestExt)
+	assert.Nil(t, err)
+	assert.Equal(t, "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c\n", string(sum))
+}
+
+func TestRotateLoggerDigestCompressed(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "audit.log")
+	rule := DefaultRotateRule(filename, backupFileDelimiter, 1, true)
+	logger, err := newRotateLogger(filename, rule, rotateConfig{compress: true, digest: true, pool: bpool})
+	assert.Nil(t, err)
+	defer logger.Close()
+
+	logger.write([]byte("foo\n"))
+	backup := logger.getBackupFilename()
+	assert.Nil(t, logger.rotate())
+
+	// the sidecar follows the backup to its archive, whose sha256 it holds.
+	archive := backup + gzipExt
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(archive + digestExt)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	waitArchived(t, logger)
+	_, err = os.Stat(backup + digestExt)
+	assert.True(t, os.IsNotExist(err))
+
+	h := sha256.New()
+	assert.Nil(t, hashFile(archive, h))
+	sum, err := os.ReadFile(archive + digestExt)
+	assert.Nil(t, err)
+	assert.Equal(t, hex.EncodeToString(h.Sum(nil))+"\n", string(sum))
+}
+
+func TestRotateLoggerRetainOnStart(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	outdated := filename + backupFileDelimiter + "2020-01-01"
+	recent := filename + backupFileDelimiter + getNowDate()
+	for _, file := range []string{outdated, recent} {
+		assert.Nil(t, os.WriteFile(file, []byte("foo\n"), defaultFileMode))
+	}
+
+	rule := DefaultRotateRule(filename, backupFileDelimiter, 1, true)
+	logger, err := newRotateLogger(filename, rule, rotateConfig{compress: true, pool: bpool})
+	assert.Nil(t, err)
+	defer logger.Close()
+
+	assert.Eventually(t, func() bool {
+		logger.retainMu.Lock()
+		defer logger.retainMu.Unlock()
+
+		files, err := filepath.Glob(filepath.Join(dir, "app.log-*"))
+		return err == nil && len(files) == 1 && files[0] == recent+gzipExt
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestArchiveEncryption(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.log-2020-01-01")
+	content := []byte(strings.Repeat("an entry which is logged\n", 10000))
+	assert.Nil(t, os.WriteFile(file, content, defaultFileMode))
+
+	key := []byte("0123456789abcdef")
+	assert.Nil(t, archiveFile(file, fileSys, key))
+	_, err := os.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+
+	archive, err := os.ReadFile(file + gzipExt + encryptedExt)
+	assert.Nil(t, err)
+	var gz bytes.Buffer
+	assert.Nil(t, DecryptArchive(&gz, bytes.NewReader(archive), key))
+	zr, err := gzip.NewReader(&gz)
+	assert.Nil(t, err)
+	plain, err := io.ReadAll(zr)
+	assert.Nil(t, err)
+	assert.Equal(t, content, plain)
+
+	assert.ErrorIs(t, DecryptArchive(io.Discard, bytes.NewReader(archive), []byte("fedcba9876543210")), ErrArchiveCorrupted)
+	assert.ErrorIs(t, DecryptArchive(io.Discard, bytes.NewReader(archive[:len(archive)-1]), key), ErrArchiveCorrupted)
+
+	rule := DefaultRotateRule(filepath.Join(dir, "app.log"), backupFileDelimiter, 1, true)
+	rule.(archiveRule).setArchiveExt(gzipExt + encryptedExt)
+	assert.Equal(t, []string{file + gzipExt + encryptedExt}, rule.OutdatedFiles())
+}
+
+func TestRotateLoggerChecksum(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	rule := DefaultRotateRule(filename, backupFileDelimiter, 1, true)
+	logger, err := newRotateLogger(filename, rule, rotateConfig{compress: true, checksum: true, pool: bpool})
+	assert.Nil(t, err)
+	defer logger.Close()
+
+	logger.write([]byte("foo\n"))
+	archive := logger.getBackupFilename() + gzipExt
+	assert.Nil(t, logger.rotate())
+
+	assert.Eventually(t, func() bool {
+		return Verify(archive) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	f, err := os.OpenFile(archive, os.O_APPEND|os.O_WRONLY, defaultFileMode)
+	assert.Nil(t, err)
+	_, err = f.WriteString("tampered")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.ErrorIs(t, Verify(archive), ErrChecksumMismatch)
+}
+
+func TestRotateLoggerSharedFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	// two loggers on one file stand for two processes sharing it.
+	l1, err := newRotateLogger(filename, DefaultRotateRule(filename, backupFileDelimiter, 1, false), rotateConfig{pool: bpool})
+	assert.Nil(t, err)
+	defer l1.Close()
+	l2, err := newRotateLogger(filename, DefaultRotateRule(filename, backupFileDelimiter, 1, false), rotateConfig{pool: bpool})
+	assert.Nil(t, err)
+	defer l2.Close()
+
+	l1.write([]byte("first\n"))
+	l2.write([]byte("second\n"))
+	backup := l1.getBackupFilename()
+	assert.Nil(t, l1.rotate())
+	l1.write([]byte("third\n"))
+	// l2 finds the file rotated and reopens it instead of renaming it again,
+	// counting the entries l1 wrote to it.
+	assert.Nil(t, l2.rotate())
+	assert.Equal(t, int64(len("third\n")), l2.currentSize)
+	l2.write([]byte("fourth\n"))
+
+	data, err := os.ReadFile(backup)
+	assert.Nil(t, err)
+	assert.Equal(t, "first\nsecond\n", string(data))
+	data, err = os.ReadFile(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, "third\nfourth\n", string(data))
+}
+
+func TestRotateLoggerStatus(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	rule := NewSizeLimitRotateRule(filename, backupFileDelimiter, 1, 0, 0, true)
+	rule.(*SizeLimitRotateRule).maxSize = 4
+	logger, err := newRotateLogger(filename, rule, rotateConfig{compress: true, pool: bpool})
+	assert.Nil(t, err)
+	defer logger.Close()
+
+	logger.write([]byte("foo\n"))
+	status := logger.Status()
+	assert.Equal(t, filename, status.Filename)
+	assert.Equal(t, int64(4), status.Size)
+	assert.True(t, status.LastRotation.IsZero())
+	assert.Equal(t, 0, status.Backups)
+
+	// hold the compression back to see the backlog.
+	logger.retainMu.Lock()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "app-2020-01-01T00:00:00Z.log.gz"), nil, defaultFileMode))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "app-2020-01-01T00:00:00Z.log.gz.sha256"), nil, defaultFileMode))
+	logger.write([]byte("bar\n"))
+	status = logger.Status()
+	logger.retainMu.Unlock()
+
+	assert.Equal(t, int64(4), status.Size)
+	assert.False(t, status.LastRotation.IsZero())
+	assert.Equal(t, 2, status.Backups)
+	assert.Equal(t, 1, status.CompressionBacklog)
+
+	assert.Eventually(t, func() bool {
+		return logger.Status().CompressionBacklog == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRotateLoggerHealthy(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	logger, err := newRotateLogger(filename, DefaultRotateRule(filename, backupFileDelimiter, 1, false), rotateConfig{pool: bpool})
+	assert.Nil(t, err)
+	assert.Nil(t, logger.Healthy())
+
+	logger.beat.Store(time.Now().Add(-2 * workerStallTimeout).UnixNano())
+	assert.ErrorIs(t, logger.Healthy(), ErrWorkerStalled)
+	logger.beat.Store(time.Now().UnixNano())
+
+	assert.Nil(t, logger.fp.Close())
+	logger.write([]byte("foo\n"))
+	assert.ErrorIs(t, logger.Healthy(), os.ErrClosed)
+
+	logger.Close()
+	assert.ErrorIs(t, logger.Healthy(), ErrClosedRollingFile)
+}
+
+func TestRotateLoggerPathLayout(t *testing.T) {
+	base := t.TempDir()
+	for _, file := range []string{"2020/01/01/app.log", "2020/01/01/app.log-2020-01-01.gz", "2020/01/01/other.log", "2020/01/02/app.log"} {
+		assert.Nil(t, os.MkdirAll(filepath.Join(base, filepath.Dir(file)), defaultDirMode))
+		assert.Nil(t, os.WriteFile(filepath.Join(base, file), []byte("foo\n"), defaultFileMode))
+	}
+
+	newRule := func(filename string) RotateRule {
+		return DefaultRotateRule(filename, backupFileDelimiter, 1, false)
+	}
+	filename := filepath.Join(base, "app.log")
+	logger, err := newRotateLogger(filename, newRule(filename), rotateConfig{
+		pathLayout: "2006/01/02",
+		layoutKeep: hoursPerDay * time.Hour,
+		newRule:    newRule,
+		pool:       bpool,
+	})
+	assert.Nil(t, err)
+	defer logger.Close()
+
+	today := filepath.Join(base, time.Now().Format("2006/01/02"), "app.log")
+	assert.Equal(t, today, logger.filename)
+
+	// pretend the logger started yesterday, the next write moves it to today.
+	yesterday := filepath.Join(base, time.Now().AddDate(0, 0, -1).Format("2006/01/02"), "app.log")
+	assert.Nil(t, logger.close())
+	assert.Nil(t, os.MkdirAll(filepath.Dir(yesterday), defaultDirMode))
+	logger.nameMu.Lock()
+	logger.filename = yesterday
+	logger.nameMu.Unlock()
+	logger.fp, err = openLogFile(yesterday, defaultLogFileMode)
+	assert.Nil(t, err)
+	_, err = logger.fp.Write([]byte("yesterday\n"))
+	assert.Nil(t, err)
+	logger.write([]byte("today\n"))
+
+	data, err := os.ReadFile(yesterday)
+	assert.Nil(t, err)
+	assert.Equal(t, "yesterday\n", string(data))
+	data, err = os.ReadFile(today)
+	assert.Nil(t, err)
+	assert.Equal(t, "today\n", string(data))
+
+	logger.retain()
+	_, err = os.Stat(filepath.Join(base, "2020/01/01/app.log"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(base, "2020/01/01/app.log-2020-01-01.gz"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(base, "2020/01/01/other.log"))
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(base, "2020/01/02"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFlushOnExit(t *testing.T) {
+	// the signal is sent to a copy of the test binary running only this
+	// test, as it may end the process.
+	if dir := os.Getenv("LOGGER_FLUSH_ON_EXIT_DIR"); dir != "" {
+		os.Exit(flushOnExitProcess(dir, os.Getenv("LOGGER_FLUSH_ON_EXIT_HANDLED") != ""))
+	}
+
+	run := func(handled bool) (string, *exec.Cmd) {
+		dir := t.TempDir()
+		cmd := exec.Command(os.Args[0], "-test.run=^TestFlushOnExit$")
+		cmd.Env = append(os.Environ(), "LOGGER_FLUSH_ON_EXIT_DIR="+dir)
+		if handled {
+			cmd.Env = append(cmd.Env, "LOGGER_FLUSH_ON_EXIT_HANDLED=1")
+		}
+		_ = cmd.Run()
+		if cmd.ProcessState.ExitCode() == 2 {
+			t.Skip("signals unsupported")
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+		assert.Nil(t, err)
+		return string(data), cmd
+	}
+
+	// without a handler of the application, the signal closes the logger
+	// and still ends the process.
+	data, cmd := run(false)
+	assert.False(t, cmd.ProcessState.Success())
+	assert.NotEqual(t, 3, cmd.ProcessState.ExitCode())
+	assert.Contains(t, data, "before exit")
+
+	// with one, it only syncs the logger and is delivered once, the
+	// application shuts down itself.
+	data, cmd = run(true)
+	assert.Equal(t, 0, cmd.ProcessState.ExitCode())
+	assert.Contains(t, data, "before exit")
+	assert.Contains(t, data, "during shutdown")
+}
+
+// flushOnExitProcess logs to dir with WithFlushOnExit and sends SIGTERM to
+// the process, handling it if handled. It returns the exit code: 2 if the
+// signal can't be sent, 3 if the process outlived an unhandled signal, 4 if
+// the logger wasn't synced and 5 if a handled signal was delivered twice.
+func flushOnExitProcess(dir string, handled bool) int {
+	SetExitSignalsHandled(handled)
+	quit := make(chan os.Signal, 2)
+	if handled {
+		signal.Notify(quit, syscall.SIGTERM)
+	}
+	l := New(WithMode(FileMode), WithPath(dir), WithFilename("app.log"), WithFlushOnExit())
+	l.Info("before exit")
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(syscall.SIGTERM)
+	}
+	if err != nil {
+		return 2
+	}
+	if !handled {
+		time.Sleep(5 * time.Second)
+		return 3
+	}
+
+	<-quit
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if data, _ := os.ReadFile(filepath.Join(dir, "app.log")); strings.Contains(string(data), "before exit") {
+			break
+		}
+		if time.Now().After(deadline) {
+			return 4
+		}
+	}
+	select {
+	case <-quit:
+		return 5
+	case <-time.After(100 * time.Millisecond):
+	}
+	l.Info("during shutdown")
+	if err := Shutdown(context.Background()); err != nil {
+		return 1
+	}
+	return 0
+}
+
+func TestShutdownFlushOnExit(t *testing.T) {
+	dir := t.TempDir()
+	// registered as by WithFlushOnExit, without installing the signal handler.
+	l := New(WithMode(FileMode), WithPath(dir), WithFilename("app.log"))
+	exitFlusher.add(l)
+	l.Info("before exit")
+
+	// a sync on exit leaves the logger usable for the shutdown of the
+	// application, Shutdown closes it.
+	exitFlusher.sync()
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "before exit")
+	l.Info("during shutdown")
+	assert.Nil(t, l.Sync())
+	data, err = os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "during shutdown")
+
+	assert.Nil(t, Shutdown(context.Background()))
+	_, err = l._rotateLoggers[0].Write([]byte("after exit"))
+	assert.ErrorIs(t, err, ErrClosedRollingFile)
+}
+
+func TestCloseFlushOnExit(t *testing.T) {
+	l := New(WithMode(FileMode), WithPath(t.TempDir()), WithFilename("app.log"))
+	exitFlusher.add(l)
+	panicFlusher.add(l)
+
+	// a closed logger is no longer kept by the flushers.
+	assert.Nil(t, l.Close())
+	assert.NotContains(t, exitFlusher.take(), l)
+	assert.NotContains(t, panicFlusher.take(), l)
+}
+
+func TestPanicFlush(t *testing.T) {
+	dir := t.TempDir()
+	l := New(WithMode(FileMode), WithPath(dir), WithFilename("app.log"), WithPanicFlush())
+	defer l.Close()
+
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		defer FlushOnPanic()
+		l.Error("before panic")
+		panic("boom")
+	}()
+	assert.Equal(t, "boom", recovered)
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "before panic")
+
+	// the logger stays usable if the panic is recovered.
+	l.Info("after panic")
+	assert.Nil(t, l.Sync())
+	data, err = os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "after panic")
+}
+
+func TestBufferPool(t *testing.T) {
+	p := newBufferPool(1, 8192)
+	b1 := p.get()
+	b2 := p.get()
+	assert.NotNil(t, b1)
+	assert.NotNil(t, b2)
+
+	b1.WriteString("foo")
+	p.put(b1)
+	p.put(b2)
+	assert.Equal(t, 1, len(p.free))
+	assert.Equal(t, 0, p.get().Len())
+
+	huge := p.get()
+	huge.Grow(16384)
+	p.put(huge)
+	assert.Equal(t, 0, len(p.free))
+}
+
+// countingFile is a logFile counting its writes.
+type countingFile struct {
+	*os.File
+	writes int
+}
+
+func (f *countingFile) Write(p []byte) (int, error) {
+	f.writes++
+	return f.File.Write(p)
+}
+
+func TestRotateLoggerConcurrentInitialize(t *testing.T) {
+	// the loggers start at once on a file and directories which don't exist.
+	filename := filepath.Join(t.TempDir(), "a", "b", "app.log")
+	const n = 8
+	loggers := make([]*RotateLogger, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rule := DefaultRotateRule(filename, backupFileDelimiter, 1, false)
+			loggers[i], errs[i] = newRotateLogger(filename, rule, rotateConfig{pool: bpool})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, l := range loggers {
+		if assert.NoError(t, errs[i]) {
+			_, err := l.Write([]byte(fmt.Sprintf("entry %d\n", i)))
+			assert.NoError(t, err)
+			assert.NoError(t, l.Close())
+		}
+	}
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	for i := 0; i < n; i++ {
+		assert.Contains(t, string(data), fmt.Sprintf("entry %d\n", i))
+	}
+}
+
+func TestRotateLoggerPerm(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix permissions")
+	}
+
+	dir := filepath.Join(t.TempDir(), "logs")
+	l := New(WithMode(FileMode), WithPath(dir), WithFilename("app.log"), WithFilePerm(0o600), WithDirPerm(0o700))
+	l.Info("created")
+	assert.NoError(t, l.Close())
+
+	info, err := os.Stat(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+	info, err = os.Stat(filepath.Join(dir, "app.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestRotateLoggerWritePages(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.log")
+	fp, err := openLogFile(filename, defaultLogFileMode)
+	assert.Nil(t, err)
+	file := &countingFile{File: fp}
+	l := &RotateLogger{
+		filename: filename,
+		rule:     DefaultRotateRule(filename, backupFileDelimiter, 1, false),
+		pool:     bpool,
+		pages:    make(chan *page, logPageNumber+1),
+		fp:       file,
+	}
+	for _, s := range []string{"second\n", "third\n"} {
+		l.pages <- newFullPage(l.pool, []byte(s))
+	}
+
+	assert.Equal(t, 3, l.writePages(newFullPage(l.pool, []byte("first\n")), cap(l.pages)))
+	assert.Equal(t, 1, file.writes)
+	assert.Equal(t, 1, l.writePages(newFullPage(l.pool, []byte("fourth\n")), cap(l.pages)))
+	assert.Equal(t, 2, file.writes)
+	assert.Nil(t, fp.Close())
+
+	data, err := os.ReadFile(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, "first\nsecond\nthird\nfourth\n", string(data))
+}
+
+func BenchmarkRotateLoggerParallel(b *testing.B) {
+	filename := filepath.Join(b.TempDir(), "test.log")
+	logger, err := NewRotateLogger(filename, DefaultRotateRule(filename, backupFileDelimiter, 1, false), false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer logger.Close()
+
+	msg := []byte(`{"level":"info","ts":"2024-06-01T12:00:00.000+0800","caller":"app/main.go:42","msg":"request served","status":200}` + "\n")
+	b.SetBytes(int64(len(msg)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := logger.Write(msg); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func TestRotateLoggerConcurrentWrite(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.log")
+	logger, err := NewRotateLogger(filename, DefaultRotateRule(filename, backupFileDelimiter, 1, false), false)
+	assert.Nil(t, err)
+
+	const (
+		writers = 8
+		lines   = 2000
+	)
+	large := strings.Repeat("x", logPageSize*2)
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				msg := fmt.Sprintf("%d %d\n", w, i)
+				if i%500 == 0 {
+					msg = fmt.Sprintf("%d %d %s\n", w, i, large)
+				}
+				_, err := logger.Write([]byte(msg))
+				assert.Nil(t, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+	assert.Nil(t, logger.Sync())
+	assert.Nil(t, logger.Close())
+
+	data, err := os.ReadFile(filename)
+	assert.Nil(t, err)
+	next := make([]int, writers)
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var w, i int
+		_, err := fmt.Sscanf(line, "%d %d", &w, &i)
+		assert.Nil(t, err)
+		assert.Equal(t, next[w], i)
+		next[w] = i + 1
+	}
+	for w := 0; w < writers; w++ {
+		assert.Equal(t, lines, next[w])
+	}
+}
+
+func TestRotateLoggerResumesSchedule(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.log")
+	yesterday := time.Now().Add(-hoursPerDay * time.Hour)
+	assert.Nil(t, os.WriteFile(filename, []byte("yesterday\n"), defaultFileMode))
+	assert.Nil(t, os.Chtimes(filename, yesterday, yesterday))
+
+	logger, err := NewRotateLogger(filename, DefaultRotateRule(filename, backupFileDelimiter, 1, false), false)
+	assert.Nil(t, err)
+	_, err = logger.Write([]byte("today\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, logger.Close())
+
+	backup, err := os.ReadFile(filename + backupFileDelimiter + yesterday.Format(dateFormat))
+	assert.Nil(t, err)
+	assert.Equal(t, "yesterday\n", string(backup))
+	data, err := os.ReadFile(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, "today\n", string(data))
+}
+
+func TestDailyRotateRuleMaxSize(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.log")
+	rule := DefaultRotateRule(filename, backupFileDelimiter, 1, false)
+	rule.(maxSizeRule).setMaxSize(10)
+	logger, err := NewRotateLogger(filename, rule, false)
+	assert.Nil(t, err)
+	for _, entry := range []string{"first\n", "second\n", "third\n"} {
+		logger.write([]byte(entry))
+	}
+	assert.Nil(t, logger.Close())
+
+	backup := filename + backupFileDelimiter + getNowDate()
+	for name, want := range map[string]string{backup: "first\n", backup + ".1": "second\n", filename: "third\n"} {
+		data, err := os.ReadFile(name)
+		assert.Nil(t, err)
+		assert.Equal(t, want, string(data))
+	}
+	assert.False(t, rule.ShallRotate(10))
+	assert.ErrorIs(t, newOptions(WithRotateOnMaxSize(true)).validate(), ErrInvalidOptions)
+}
+
+func TestRotateRuleRetention(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.log")
+	now := time.Now()
+	var hourly []string
+	for _, age := range []time.Duration{3 * hoursPerDay * time.Hour, time.Hour, 0} {
+		name := filename + backupFileDelimiter + now.Add(-age).Format(hourFormat)
+		assert.Nil(t, os.WriteFile(name, nil, defaultFileMode))
+		hourly = append(hourly, name)
+	}
+
+	hour := NewHourRotateRule(filename, backupFileDelimiter, 0, false)
+	assert.Empty(t, hour.OutdatedFiles())
+	hour.setRetention(hoursPerDay, 0)
+	assert.Equal(t, hourly[:1], hour.OutdatedFiles())
+	hour.setRetention(0, 1)
+	assert.Equal(t, hourly[:2], hour.OutdatedFiles())
+
+	filename = filepath.Join(filepath.Dir(filename), "daily.log")
+	var daily []string
+	for _, age := range []time.Duration{3 * hoursPerDay * time.Hour, hoursPerDay * time.Hour, 0} {
+		name := filename + backupFileDelimiter + now.Add(-age).Format(dateFormat)
+		assert.Nil(t, os.WriteFile(name, nil, defaultFileMode))
+		daily = append(daily, name)
+	}
+	day := DefaultRotateRule(filename, backupFileDelimiter, 0, false)
+	day.(retentionRule).setRetention(0, 2)
+	assert.Equal(t, daily[:1], day.OutdatedFiles())
+
+	assert.ErrorIs(t, newOptions(WithKeepDays(1), WithKeepHours(1)).validate(), ErrInvalidOptions)
+}
+
+func TestRotateLoggerRenameFailure(t *testing.T) {
+	renameFile = func(string, string) error { return errors.New("file locked") }
+	defer func() { renameFile = os.Rename }()
+
+	filename := filepath.Join(t.TempDir(), "test.log")
+	rule := DefaultRotateRule(filename, backupFileDelimiter, 0, false)
+	rule.(maxSizeRule).setMaxSize(10)
+	var failures []error
+	logger, err := newRotateLogger(filename, rule, rotateConfig{pool: bpool, onRotateError: func(name string, err error) {
+		assert.Equal(t, filename, name)
+		failures = append(failures, err)
+	}})
+	assert.Nil(t, err)
+
+	// renaming fails, the file is copied and truncated.
+	logger.write([]byte("first\n"))
+	logger.write([]byte("second\n"))
+	backup := filename + backupFileDelimiter + getNowDate()
+	data, err := os.ReadFile(backup)
+	assert.Nil(t, err)
+	assert.Equal(t, "first\n", string(data))
+	assert.Empty(t, failures)
+
+	// copying fails too, the entries go on to the file.
+	assert.Nil(t, os.WriteFile(backup+".1", nil, defaultFileMode))
+	logger.write([]byte("third\n"))
+	logger.write([]byte("fourth\n"))
+	assert.Nil(t, logger.Close())
+	data, err = os.ReadFile(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, "second\nthird\nfourth\n", string(data))
+	assert.Len(t, failures, 1)
+}
+
+func TestRotateLoggerBackupDelimiter(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	for _, name := range []string{"app.log.2020-01-01", "app.log.2020-01-01.1", "app.log-2020-01-01", "app.log.old"} {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), nil, defaultFileMode))
+	}
+
+	logger, err := newRotateLogger(filename, DefaultRotateRule(filename, ".", 0, false), rotateConfig{pool: bpool, delimiter: "."})
+	assert.Nil(t, err)
+	defer logger.Close()
+	_, pending := logger.backups()
+	assert.ElementsMatch(t, []string{filename + ".2020-01-01", filename + ".2020-01-01.1"}, pending)
+
+	assert.ErrorIs(t, newOptions(WithBackupDelimiter("")).validate(), ErrInvalidOptions)
+	assert.ErrorIs(t, newOptions(WithBackupDelimiter("/")).validate(), ErrInvalidOptions)
+}
+
+func TestDailyRotateRuleOutdatedBackups(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	old := time.Now().Add(-3 * hoursPerDay * time.Hour)
+	var outdated []string
+	for _, name := range []string{
+		"app.log-" + old.Format(dateFormat) + gzipExt,
+		"app.log-" + old.Format(dateFormat) + ".1",
+		// rotated hourly before.
+		"app.log-" + old.Format(hourFormat),
+		// in an unknown layout, its modification time is used.
+		"app.log-" + old.Format("20060102"),
+	} {
+		outdated = append(outdated, filepath.Join(dir, name))
+	}
+	kept := []string{
+		"app.log-" + time.Now().Format(dateFormat) + gzipExt,
+		"app.log-" + old.Format("20060102") + checksumExt,
+		"app.log-access-" + old.Format(dateFormat),
+	}
+	for _, name := range outdated {
+		assert.Nil(t, os.WriteFile(name, nil, defaultFileMode))
+		assert.Nil(t, os.Chtimes(name, old, old))
+	}
+	for _, name := range kept {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), nil, defaultFileMode))
+	}
+
+	rule := DefaultRotateRule(filename, backupFileDelimiter, 1, true)
+	assert.ElementsMatch(t, outdated, rule.OutdatedFiles())
+}
+
+func TestOutdatedFilesIgnoreCompressSetting(t *testing.T) {
+	old := time.Now().Add(-3 * hoursPerDay * time.Hour)
+	for _, compress := range []bool{false, true} {
+		dir := t.TempDir()
+		filename := filepath.Join(dir, "app.log")
+		sizeBackup := filepath.Join(dir, "app"+backupFileDelimiter+old.Format(fileTimeFormat)+".log")
+		var daily, hourly, size []string
+		for _, ext := range []string{"", gzipExt, gzipExt + encryptedExt} {
+			daily = append(daily, filename+backupFileDelimiter+old.Format(dateFormat)+ext)
+			hourly = append(hourly, filename+backupFileDelimiter+old.Format(hourFormat)+ext)
+			size = append(size, sizeBackup+ext)
+		}
+		for _, name := range append(append(daily, hourly...), size...) {
+			assert.Nil(t, os.WriteFile(name, nil, defaultFileMode))
+		}
+
+		assert.ElementsMatch(t, append(daily, hourly...), DefaultRotateRule(filename, backupFileDelimiter, 1, compress).OutdatedFiles())
+		assert.ElementsMatch(t, append(daily, hourly...), NewHourRotateRule(filename, backupFileDelimiter, hoursPerDay, compress).OutdatedFiles())
+		assert.ElementsMatch(t, size, NewSizeLimitRotateRule(filename, backupFileDelimiter, 1, 1, 0, compress).OutdatedFiles())
+	}
+}
@@ -2,9 +2,10 @@ package logger
 
 import (
 	"fmt"
-	"log"
+	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -13,6 +14,8 @@ import (
 type RotateRule interface {
 	BackupFileName() string
 	MarkRotated()
+	// OutdatedFiles returns the backups past the retention, archived or not,
+	// whatever the compression is now.
 	OutdatedFiles() []string
 	ShallRotate(size int64) bool
 }
@@ -25,6 +28,16 @@ type (
 		delimiter   string
 		days        int
 		gzip        bool
+		// archiveExt is the extension of compressed backups, default is `.gz`.
+		archiveExt string
+		// sizeLimit rotates the file early once it's larger, 0 means no limit.
+		sizeLimit int64
+		// hours is how many hours the backups are kept, overriding days.
+		hours int
+		// maxBackups is how many backups are kept, 0 keeps them all.
+		maxBackups int
+		// internal logs the failures to find the backups.
+		internal internalLogger
 	}
 
 	// HourRotateRule a rotation rule that make the log file rotated base on hour
@@ -34,6 +47,14 @@ type (
 		delimiter   string
 		hours       int
 		gzip        bool
+		// archiveExt is the extension of compressed backups, default is `.gz`.
+		archiveExt string
+		// sizeLimit rotates the file early once it's larger, 0 means no limit.
+		sizeLimit int64
+		// maxBackups is how many backups are kept, 0 keeps them all.
+		maxBackups int
+		// internal logs the failures to find the backups.
+		internal internalLogger
 	}
 
 	// SizeLimitRotateRule a rotation rule that make the log file rotated base on size
@@ -44,6 +65,221 @@ type (
 	}
 )
 
+// archiveRule is implemented by the rules whose compressed backups can have
+// another extension than `.gz`.
+type archiveRule interface {
+	setArchiveExt(ext string)
+}
+
+func (r *HourRotateRule) setArchiveExt(ext string) {
+	r.archiveExt = ext
+}
+
+func (r *DailyRotateRule) setArchiveExt(ext string) {
+	r.archiveExt = ext
+}
+
+// retentionRule is implemented by the rules that keep their backups for
+// hours, and up to a number of backups.
+type retentionRule interface {
+	setRetention(hours, maxBackups int)
+}
+
+func (r *HourRotateRule) setRetention(hours, maxBackups int) {
+	r.hours, r.maxBackups = hours, maxBackups
+}
+
+func (r *DailyRotateRule) setRetention(hours, maxBackups int) {
+	r.hours, r.maxBackups = hours, maxBackups
+}
+
+func (r *SizeLimitRotateRule) setRetention(hours, maxBackups int) {
+	r.hours, r.maxBackups = hours, maxBackups
+}
+
+// internalRule is implemented by the rules logging their failures to the
+// diagnostics of their logger, rather than to the global internal output.
+type internalRule interface {
+	setInternal(internal internalLogger)
+}
+
+func (r *HourRotateRule) setInternal(internal internalLogger) {
+	r.internal = internal
+}
+
+func (r *DailyRotateRule) setInternal(internal internalLogger) {
+	r.internal = internal
+}
+
+// backupFile is a backup of a rule, with the time in its name.
+type backupFile struct {
+	name string
+	time time.Time
+	// seq orders the backups rotated early in the same period.
+	seq int
+}
+
+// backupLayouts are the layouts of the times in the backup names, of the
+// daily, hourly and size rotations.
+var backupLayouts = []string{dateFormat, hourFormat, fileTimeFormat}
+
+// findBackups returns the backups named prefix, a time, then suffix, with or
+// without an archive extension, sorted by time. The time is parsed with the
+// layouts of the rotations, preferring layout, so the backups of another
+// rotation are found too. If none fits, the modification time is used for
+// the times starting with a digit. The other files matching prefix, like the
+// backups of a file whose name starts like the name of the file, and the
+// sidecars are left out. A failure to list the files is logged to internal,
+// never to a Logging, which may be the one rotating.
+func findBackups(internal internalLogger, prefix, suffix, layout string) []backupFile {
+	files, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		internal.error("failed to find outdated log files", "prefix", prefix, "error", err)
+		return nil
+	}
+
+	layouts := append([]string{layout}, backupLayouts...)
+	var backups []backupFile
+	for _, file := range files {
+		name := strings.TrimSuffix(strings.TrimSuffix(file, encryptedExt), gzipExt)
+		if strings.HasSuffix(name, checksumExt) || strings.HasSuffix(name, digestExt) {
+			continue
+		}
+		if t, seq, ok := parseBackupName(name, prefix, suffix, layouts...); ok {
+			backups = append(backups, backupFile{name: file, time: t, seq: seq})
+		} else if t, ok := backupModTime(file, name, prefix, suffix); ok {
+			backups = append(backups, backupFile{name: file, time: t})
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].time.Equal(backups[j].time) {
+			return backups[i].time.Before(backups[j].time)
+		}
+		if backups[i].seq != backups[j].seq {
+			return backups[i].seq < backups[j].seq
+		}
+		return backups[i].name < backups[j].name
+	})
+	return backups
+}
+
+// backupModTime returns the modification time of file, if its name is
+// prefix, a time starting with a digit in an unknown layout, then suffix.
+func backupModTime(file, name, prefix, suffix string) (time.Time, bool) {
+	if len(name) <= len(prefix)+len(suffix) || !strings.HasSuffix(name, suffix) {
+		return time.Time{}, false
+	}
+	if c := name[len(prefix)]; c < '0' || c > '9' {
+		return time.Time{}, false
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
+// parseBackupName returns the time in file between prefix and suffix, in
+// the first of layouts it fits, and the sequence of its `.1`, `.2`, ...
+// suffix if it was rotated early. ok is false if file isn't named so.
+func parseBackupName(file, prefix, suffix string, layouts ...string) (t time.Time, seq int, ok bool) {
+	if len(file) < len(prefix)+len(suffix) || !strings.HasPrefix(file, prefix) || !strings.HasSuffix(file, suffix) {
+		return t, 0, false
+	}
+
+	stamp := file[len(prefix) : len(file)-len(suffix)]
+	if i := strings.LastIndexByte(stamp, '.'); i >= 0 {
+		if n, err := strconv.Atoi(stamp[i+1:]); err == nil && n > 0 {
+			stamp, seq = stamp[:i], n
+		}
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, stamp, time.Local); err == nil {
+			return t, seq, true
+		}
+	}
+	return t, 0, false
+}
+
+// periodStart returns t truncated to the precision of layout, the time of a
+// backup named at t.
+func periodStart(t time.Time, layout string) time.Time {
+	start, err := time.ParseInLocation(layout, t.Format(layout), time.Local)
+	if err != nil {
+		return t
+	}
+	return start
+}
+
+// outdatedBackups returns the backups beyond the newest maxBackups, and the
+// ones named before boundary unless it's zero.
+func outdatedBackups(backups []backupFile, boundary time.Time, maxBackups int) []string {
+	var outdated []string
+	if maxBackups > 0 && len(backups) > maxBackups {
+		for _, backup := range backups[:len(backups)-maxBackups] {
+			outdated = append(outdated, backup.name)
+		}
+		backups = backups[len(backups)-maxBackups:]
+	}
+	if boundary.IsZero() {
+		return outdated
+	}
+	for _, backup := range backups {
+		if !backup.time.Before(boundary) {
+			break
+		}
+		outdated = append(outdated, backup.name)
+	}
+	return outdated
+}
+
+// maxSizeRule is implemented by the periodic rules that can also rotate a
+// file early once it reaches a size.
+type maxSizeRule interface {
+	setMaxSize(size int64)
+}
+
+func (r *HourRotateRule) setMaxSize(size int64) {
+	r.sizeLimit = size
+}
+
+func (r *DailyRotateRule) setMaxSize(size int64) {
+	r.sizeLimit = size
+}
+
+// resumableRule is implemented by the rules that can resume the schedule of
+// a file written before a restart.
+type resumableRule interface {
+	// markRotatedAt marks the rotated time of the rule to be t.
+	markRotatedAt(t time.Time)
+	// backupFileNameAt returns the backup filename of a file rotated at t.
+	backupFileNameAt(t time.Time) string
+}
+
+// unusedBackupFileName returns name, or name with the first `.1`, `.2`, ...
+// suffix no backup has, archived with archiveExt or not, so the files rotated
+// early in a period don't replace each other.
+func unusedBackupFileName(name, archiveExt string) string {
+	backup := name
+	for i := 1; fileExists(backup) || fileExists(backup+archiveExt); i++ {
+		backup = fmt.Sprintf("%s.%d", name, i)
+	}
+	return backup
+}
+
+func fileExists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// archiveExtOf returns ext, or `.gz` if ext is empty.
+func archiveExtOf(ext string) string {
+	if ext == "" {
+		return gzipExt
+	}
+	return ext
+}
+
 // NewHourRotateRule new a hour rotate rule
 func NewHourRotateRule(filename, delimiter string, hours int, gzip bool) *HourRotateRule {
 	return &HourRotateRule{
@@ -57,56 +293,44 @@ func NewHourRotateRule(filename, delimiter string, hours int, gzip bool) *HourRo
 
 // BackupFileName returns the backup filename on rotating.
 func (r *HourRotateRule) BackupFileName() string {
-	return fmt.Sprintf("%s%s%s", r.filename, r.delimiter, getNowHour())
+	return r.backupFileNameAt(time.Now())
+}
+
+func (r *HourRotateRule) backupFileNameAt(t time.Time) string {
+	name := fmt.Sprintf("%s%s%s", r.filename, r.delimiter, t.Format(hourFormat))
+	if r.sizeLimit > 0 {
+		name = unusedBackupFileName(name, archiveExtOf(r.archiveExt))
+	}
+	return name
 }
 
 // MarkRotated marks the rotated time of r to be the current time.
 func (r *HourRotateRule) MarkRotated() {
-	r.rotatedTime = getNowHour()
+	r.markRotatedAt(time.Now())
+}
+
+func (r *HourRotateRule) markRotatedAt(t time.Time) {
+	r.rotatedTime = t.Format(hourFormat)
 }
 
 // OutdatedFiles returns the files that exceeded the keeping hours.
 func (r *HourRotateRule) OutdatedFiles() []string {
-	if r.hours <= 0 {
+	if r.hours <= 0 && r.maxBackups <= 0 {
 		return nil
 	}
 
-	var pattern string
-	if r.gzip {
-		pattern = fmt.Sprintf("%s%s*%s", r.filename, r.delimiter, ".gz")
-	} else {
-		pattern = fmt.Sprintf("%s%s*", r.filename, r.delimiter)
-	}
-
-	files, err := filepath.Glob(pattern)
-	if err != nil {
-		fmt.Printf("failed to find outdated log files, error: %s\n", err)
-		return nil
+	var boundary time.Time
+	if r.hours > 0 {
+		boundary = periodStart(time.Now().Add(-time.Hour*time.Duration(r.hours)), hourFormat)
 	}
 
-	var buf strings.Builder
-	boundary := time.Now().Add(-time.Hour * time.Duration(r.hours)).Format(hourFormat)
-	buf.WriteString(r.filename)
-	buf.WriteString(r.delimiter)
-	buf.WriteString(boundary)
-	if r.gzip {
-		buf.WriteString(gzipExt)
-	}
-	boundaryFile := buf.String()
-
-	var outdates []string
-	for _, file := range files {
-		if file < boundaryFile {
-			outdates = append(outdates, file)
-		}
-	}
-
-	return outdates
+	backups := findBackups(r.internal, filepath.Clean(r.filename)+r.delimiter, "", hourFormat)
+	return outdatedBackups(backups, boundary, r.maxBackups)
 }
 
 // ShallRotate checks if the file should be rotated.
-func (r *HourRotateRule) ShallRotate(_ int64) bool {
-	return len(r.rotatedTime) > 0 && getNowHour() != r.rotatedTime
+func (r *HourRotateRule) ShallRotate(size int64) bool {
+	return len(r.rotatedTime) > 0 && getNowHour() != r.rotatedTime || r.sizeLimit > 0 && r.sizeLimit < size
 }
 
 // DefaultRotateRule is a default log rotating rule, currently DailyRotateRule.
@@ -122,56 +346,53 @@ func DefaultRotateRule(filename, delimiter string, days int, gzip bool) RotateRu
 
 // BackupFileName returns the backup filename on rotating.
 func (r *DailyRotateRule) BackupFileName() string {
-	return fmt.Sprintf("%s%s%s", r.filename, r.delimiter, getNowDate())
+	return r.backupFileNameAt(time.Now())
+}
+
+func (r *DailyRotateRule) backupFileNameAt(t time.Time) string {
+	name := fmt.Sprintf("%s%s%s", r.filename, r.delimiter, t.Format(dateFormat))
+	if r.sizeLimit > 0 {
+		name = unusedBackupFileName(name, archiveExtOf(r.archiveExt))
+	}
+	return name
 }
 
 // MarkRotated marks the rotated time of r to be the current time.
 func (r *DailyRotateRule) MarkRotated() {
-	r.rotatedTime = getNowDate()
+	r.markRotatedAt(time.Now())
+}
+
+func (r *DailyRotateRule) markRotatedAt(t time.Time) {
+	r.rotatedTime = t.Format(dateFormat)
 }
 
 // OutdatedFiles returns the files that exceeded the keeping days.
 func (r *DailyRotateRule) OutdatedFiles() []string {
-	if r.days <= 0 {
+	keep := r.keep()
+	if keep <= 0 && r.maxBackups <= 0 {
 		return nil
 	}
 
-	var pattern string
-	if r.gzip {
-		pattern = fmt.Sprintf("%s%s*%s", r.filename, r.delimiter, gzipExt)
-	} else {
-		pattern = fmt.Sprintf("%s%s*", r.filename, r.delimiter)
-	}
-
-	files, err := filepath.Glob(pattern)
-	if err != nil {
-		Errorf("failed to delete outdated log files, error: %s", err)
-		return nil
+	var boundary time.Time
+	if keep > 0 {
+		boundary = periodStart(time.Now().Add(-keep), dateFormat)
 	}
 
-	var buf strings.Builder
-	boundary := time.Now().Add(-time.Hour * time.Duration(hoursPerDay*r.days)).Format(dateFormat)
-	buf.WriteString(r.filename)
-	buf.WriteString(r.delimiter)
-	buf.WriteString(boundary)
-	if r.gzip {
-		buf.WriteString(gzipExt)
-	}
-	boundaryFile := buf.String()
+	backups := findBackups(r.internal, filepath.Clean(r.filename)+r.delimiter, "", dateFormat)
+	return outdatedBackups(backups, boundary, r.maxBackups)
+}
 
-	var outdates []string
-	for _, file := range files {
-		if file < boundaryFile {
-			outdates = append(outdates, file)
-		}
+// keep returns how long the backups are kept, 0 keeps them.
+func (r *DailyRotateRule) keep() time.Duration {
+	if r.hours > 0 {
+		return time.Hour * time.Duration(r.hours)
 	}
-
-	return outdates
+	return time.Hour * time.Duration(hoursPerDay*r.days)
 }
 
 // ShallRotate checks if the file should be rotated.
-func (r *DailyRotateRule) ShallRotate(_ int64) bool {
-	return len(r.rotatedTime) > 0 && getNowDate() != r.rotatedTime
+func (r *DailyRotateRule) ShallRotate(size int64) bool {
+	return len(r.rotatedTime) > 0 && getNowDate() != r.rotatedTime || r.sizeLimit > 0 && r.sizeLimit < size
 }
 
 // NewSizeLimitRotateRule returns the rotation rule with size limit
@@ -190,67 +411,35 @@ func NewSizeLimitRotateRule(filename, delimiter string, days, maxSize, maxBackup
 }
 
 func (r *SizeLimitRotateRule) BackupFileName() string {
+	return r.backupFileNameAt(time.Now())
+}
+
+func (r *SizeLimitRotateRule) backupFileNameAt(t time.Time) string {
 	dir := filepath.Dir(r.filename)
 	prefix, ext := r.parseFilename()
-	timestamp := getNowDateInRFC3339Format()
+	timestamp := t.Format(fileTimeFormat)
 	return filepath.Join(dir, fmt.Sprintf("%s%s%s%s", prefix, r.delimiter, timestamp, ext))
 }
 
 func (r *SizeLimitRotateRule) MarkRotated() {
-	r.rotatedTime = getNowDateInRFC3339Format()
+	r.markRotatedAt(time.Now())
+}
+
+func (r *SizeLimitRotateRule) markRotatedAt(t time.Time) {
+	r.rotatedTime = t.Format(fileTimeFormat)
 }
 
 func (r *SizeLimitRotateRule) OutdatedFiles() []string {
 	dir := filepath.Dir(r.filename)
 	prefix, ext := r.parseFilename()
 
-	var pattern string
-	if r.gzip {
-		pattern = fmt.Sprintf("%s%s%s%s*%s%s", dir, string(filepath.Separator),
-			prefix, r.delimiter, ext, gzipExt)
-	} else {
-		pattern = fmt.Sprintf("%s%s%s%s*%s", dir, string(filepath.Separator),
-			prefix, r.delimiter, ext)
-	}
-
-	files, err := filepath.Glob(pattern)
-	if err != nil {
-		log.Printf("failed to delete outdated log files, error: %s\n", err)
-		return nil
+	var boundary time.Time
+	if keep := r.keep(); keep > 0 {
+		boundary = periodStart(time.Now().Add(-keep), fileTimeFormat)
 	}
 
-	sort.Strings(files)
-
-	outdated := make(map[string]PlaceholderType)
-
-	// test if too many backups
-	if r.maxBackups > 0 && len(files) > r.maxBackups {
-		for _, f := range files[:len(files)-r.maxBackups] {
-			outdated[f] = Placeholder
-		}
-		files = files[len(files)-r.maxBackups:]
-	}
-
-	// test if any too old backups
-	if r.days > 0 {
-		boundary := time.Now().Add(-time.Hour * time.Duration(hoursPerDay*r.days)).Format(fileTimeFormat)
-		boundaryFile := filepath.Join(dir, fmt.Sprintf("%s%s%s%s", prefix, r.delimiter, boundary, ext))
-		if r.gzip {
-			boundaryFile += gzipExt
-		}
-		for _, f := range files {
-			if f >= boundaryFile {
-				break
-			}
-			outdated[f] = Placeholder
-		}
-	}
-
-	var result []string
-	for k := range outdated {
-		result = append(result, k)
-	}
-	return result
+	backups := findBackups(r.internal, filepath.Join(dir, prefix+r.delimiter), ext, fileTimeFormat)
+	return outdatedBackups(backups, boundary, r.maxBackups)
 }
 
 func (r *SizeLimitRotateRule) ShallRotate(size int64) bool {
@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var _ io.WriteCloser = (*RotateLogger)(nil)
+
+// SyncPolicy tells when a RotateLogger syncs its file to the storage.
+type SyncPolicy int
+
+const (
+	// SyncNever leaves syncing the file to the OS, except on Sync and Close.
+	// It's the default.
+	SyncNever SyncPolicy = iota
+	// SyncOnFlush also syncs the file whenever the buffered entries are
+	// written, at most every 500ms.
+	SyncOnFlush
+	// SyncEveryWrite syncs the file after every write to it, the most
+	// durable and the slowest.
+	SyncEveryWrite
+)
+
+// RotateLoggerOptions configures a RotateLogger built by
+// NewRotateLoggerWithOptions. The zero value rotates the file daily, keeps
+// every backup and buffers the entries.
+type RotateLoggerOptions struct {
+	// Rule decides when the file rotates and which backups are outdated, a
+	// daily rule keeping every backup if nil.
+	Rule RotateRule
+	// Compress gzips the backups.
+	Compress bool
+	// Unbuffered writes every entry to the file before Write returns, which
+	// then returns the error of the write. Buffered entries are copied into
+	// pages written by a goroutine, which is much faster but only reports
+	// the failures through Healthy.
+	Unbuffered bool
+	// SyncPolicy tells when the file is synced, SyncNever by default.
+	SyncPolicy SyncPolicy
+	// FilePerm and DirPerm are the permissions of the file and its directory
+	// when created, 0666 and 0755 before the umask if 0.
+	FilePerm os.FileMode
+	DirPerm  os.FileMode
+	// OnRotateError is called when the file fails to rotate.
+	OnRotateError func(filename string, err error)
+	// InternalWriter receives the diagnostics of the file, such as failed
+	// rotations, the output of SetInternalOutput if nil.
+	InternalWriter io.Writer
+}
+
+// RotateError is the error of an operation on the file of a RotateLogger.
+type RotateError struct {
+	// Op is the operation which failed: open, write or sync.
+	Op       string
+	Filename string
+	Err      error
+}
+
+func (e *RotateError) Error() string {
+	return fmt.Sprintf("rolling file %s %s: %v", e.Op, e.Filename, e.Err)
+}
+
+func (e *RotateError) Unwrap() error {
+	return e.Err
+}
+
+// NewRotateLoggerWithOptions returns a RotateLogger writing to filename, an
+// io.WriteCloser usable as the output of any logger, e.g. the log package:
+//
+//	w, err := logger.NewRotateLoggerWithOptions("/var/log/app.log", logger.RotateLoggerOptions{
+//		Rule: logger.NewSizeLimitRotateRule("/var/log/app.log", "-", 7, 100, 10, true),
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//	log.SetOutput(w)
+//
+// The errors opening the file are *RotateError.
+func NewRotateLoggerWithOptions(filename string, opts RotateLoggerOptions) (*RotateLogger, error) {
+	if filename == "" {
+		return nil, &RotateError{Op: "open", Err: errors.New("empty filename")}
+	}
+	rule := opts.Rule
+	if rule == nil {
+		rule = DefaultRotateRule(filename, backupFileDelimiter, 0, opts.Compress)
+	}
+	cfg := rotateConfig{
+		compress:      opts.Compress,
+		pool:          bpool,
+		unbuffered:    opts.Unbuffered,
+		syncPolicy:    opts.SyncPolicy,
+		filePerm:      opts.FilePerm,
+		dirPerm:       opts.DirPerm,
+		onRotateError: opts.OnRotateError,
+	}
+	if opts.InternalWriter != nil {
+		cfg.internal = internalLogger{lg: newInternalLogger(opts.InternalWriter, defaultInternalRate)}
+	}
+	return newRotateLogger(filename, rule, cfg)
+}
+
+// directWrite is an unbuffered write, done receives its error.
+type directWrite struct {
+	b    []byte
+	done chan error
+}
+
+// writeThrough has the worker write b to the file, and waits for it.
+func (l *RotateLogger) writeThrough(b []byte) (int, error) {
+	w := directWrite{b: b, done: make(chan error, 1)}
+	select {
+	case l.direct <- w:
+	case <-l.done:
+		addStat(statDrops, 1)
+		return 0, ErrClosedRollingFile
+	}
+	if err := <-w.done; err != nil {
+		filename, _ := l.names()
+		return 0, &RotateError{Op: "write", Filename: filename, Err: err}
+	}
+	return len(b), nil
+}
+
+// writeDirect writes b to the file for writeThrough.
+func (l *RotateLogger) writeDirect(b []byte) error {
+	if _, err := l.writeBuffer(b); err != nil {
+		return err
+	}
+	if l.fp == nil {
+		// the entry was dropped, without a file since a failed rotation.
+		if err := l.writeErr.Load(); err != nil {
+			return *err
+		}
+	}
+	return nil
+}
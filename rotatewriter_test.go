@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRotateLoggerWithOptions(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewRotateLoggerWithOptions(filename, RotateLoggerOptions{
+		Unbuffered: true,
+		SyncPolicy: SyncEveryWrite,
+	})
+	assert.NoError(t, err)
+
+	// a third-party logger writing to the file.
+	std := log.New(w, "", 0)
+	std.Print("first")
+	std.Print("second")
+	// unbuffered entries are in the file once written.
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(data))
+
+	assert.NoError(t, w.Sync())
+	assert.NoError(t, w.Close())
+	_, err = w.Write([]byte("closed\n"))
+	assert.ErrorIs(t, err, ErrClosedRollingFile)
+}
+
+func TestNewRotateLoggerWithOptionsErrors(t *testing.T) {
+	_, err := NewRotateLoggerWithOptions("", RotateLoggerOptions{})
+	var rerr *RotateError
+	assert.True(t, errors.As(err, &rerr))
+
+	// the directory can't be created under a file.
+	file := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(file, nil, defaultFileMode))
+	filename := filepath.Join(file, "app.log")
+	_, err = NewRotateLoggerWithOptions(filename, RotateLoggerOptions{})
+	if assert.True(t, errors.As(err, &rerr)) {
+		assert.Equal(t, "open", rerr.Op)
+		assert.Equal(t, filename, rerr.Filename)
+		assert.NotNil(t, errors.Unwrap(err))
+	}
+}
+
+func TestRotateLoggerSyncNever(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewRotateLoggerWithOptions(filename, RotateLoggerOptions{SyncPolicy: SyncNever})
+	assert.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("buffered\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Sync())
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "buffered\n", string(data))
+}
@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// adaptiveSampler keeps the entries per second within a budget by sampling
+// debug and info entries. The rate of each one second window follows the
+// throughput of the previous one, so sampling increases under sustained load
+// and relaxes as soon as traffic drops.
+type adaptiveSampler struct {
+	budget int64
+	// window is the unix second being counted.
+	window atomic.Int64
+	// count is the number of entries in window.
+	count atomic.Int64
+	// rate keeps one of every rate sampled entries, 1 keeps all of them.
+	rate atomic.Int64
+	seq  atomic.Int64
+}
+
+func newAdaptiveSampler(budget int) *adaptiveSampler {
+	s := &adaptiveSampler{budget: int64(budget)}
+	s.rate.Store(1)
+	return s
+}
+
+// allow counts ent and reports whether it should be written.
+func (s *adaptiveSampler) allow(ent zapcore.Entry) bool {
+	now := ent.Time.Unix()
+	if w := s.window.Load(); now > w && s.window.CompareAndSwap(w, now) {
+		n := s.count.Swap(0)
+		if now > w+1 {
+			// no entries in the previous window.
+			n = 0
+		}
+		rate := (n + s.budget - 1) / s.budget
+		if rate < 1 {
+			rate = 1
+		}
+		s.rate.Store(rate)
+	}
+	s.count.Add(1)
+
+	if ent.Level >= zapcore.WarnLevel {
+		return true
+	}
+	rate := s.rate.Load()
+	return rate == 1 || s.seq.Add(1)%rate == 0
+}
+
+// levelSampler keeps one of every rate entries of the levels with a rate,
+// counted per level, so verbose levels are thinned without hiding the
+// others.
+type levelSampler struct {
+	rates map[zapcore.Level]int64
+	seqs  map[zapcore.Level]*atomic.Int64
+}
+
+func newLevelSampler(rates map[Level]int) *levelSampler {
+	s := &levelSampler{rates: make(map[zapcore.Level]int64), seqs: make(map[zapcore.Level]*atomic.Int64)}
+	for lv, rate := range rates {
+		if rate > 1 {
+			zl := lv.unmarshalZapLevel()
+			s.rates[zl], s.seqs[zl] = int64(rate), new(atomic.Int64)
+		}
+	}
+	return s
+}
+
+// allow reports whether ent should be written, the first entry of every
+// rate is.
+func (s *levelSampler) allow(ent zapcore.Entry) bool {
+	rate, ok := s.rates[ent.Level]
+	return !ok || (s.seqs[ent.Level].Add(1)-1)%rate == 0
+}
+
+// globalSampling is the sampling set with SetSampling, nil when unset.
+var globalSampling atomic.Pointer[levelSampler]
+
+// SetSampling keeps one of every rate entries of a level in every logger,
+// e.g. {DebugLevel: 1000}, to thin the logs at runtime on top of
+// WithLevelSampling, see the remote package. The audit, stat and severe
+// loggers keep all their entries. Empty rates stop it.
+func SetSampling(rates map[Level]int) {
+	s := newLevelSampler(rates)
+	if len(s.rates) == 0 {
+		s = nil
+	}
+	globalSampling.Store(s)
+}
+
+// withoutSampling exempts the logger from SetSampling.
+func withoutSampling() Option {
+	return func(o *Options) {
+		o.unsampled = true
+	}
+}
+
+// globalSampler is the sampler of SetSampling, allowing every entry when
+// unset.
+type globalSampler struct{}
+
+func (globalSampler) allow(ent zapcore.Entry) bool {
+	s := globalSampling.Load()
+	return s == nil || s.allow(ent)
+}
+
+// sampler decides which entries are written.
+type sampler interface {
+	allow(ent zapcore.Entry) bool
+}
+
+// samplingCore drops the entries its sampler doesn't allow.
+type samplingCore struct {
+	zapcore.Core
+	sampler sampler
+}
+
+func newSamplingCore(core zapcore.Core, s sampler) zapcore.Core {
+	return &samplingCore{Core: core, sampler: s}
+}
+
+func (c *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &samplingCore{Core: c.Core.With(fields), sampler: c.sampler}
+}
+
+func (c *samplingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	if !c.sampler.allow(ent) {
+		addStat(statDrops, 1)
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAdaptiveSampler(t *testing.T) {
+	s := newAdaptiveSampler(100)
+	start := time.Unix(1700000000, 0)
+
+	allowed := func(sec int64, n int, lvl zapcore.Level) int {
+		var count int
+		for i := 0; i < n; i++ {
+			if s.allow(zapcore.Entry{Level: lvl, Time: start.Add(time.Duration(sec) * time.Second)}) {
+				count++
+			}
+		}
+		return count
+	}
+
+	// nothing is sampled until a window exceeds the budget.
+	assert.Equal(t, 1000, allowed(0, 1000, zapcore.InfoLevel))
+	// 1000 entries against a budget of 100 keep one of ten.
+	assert.Equal(t, 100, allowed(1, 1000, zapcore.InfoLevel))
+	assert.Equal(t, 50, allowed(1, 50, zapcore.WarnLevel))
+	// the load continues at 1050 entries per second.
+	assert.Equal(t, 91, allowed(2, 1000, zapcore.DebugLevel))
+	// traffic dropped to the budget, sampling relaxes from the next second.
+	assert.Equal(t, 10, allowed(3, 100, zapcore.InfoLevel))
+	assert.Equal(t, 100, allowed(4, 100, zapcore.InfoLevel))
+	// an idle second resets the rate.
+	assert.Equal(t, 2000, allowed(4, 1900, zapcore.InfoLevel)+allowed(6, 100, zapcore.InfoLevel))
+}
+
+func TestLevelSampler(t *testing.T) {
+	s := newLevelSampler(map[Level]int{DebugLevel: 100, InfoLevel: 10, WarnLevel: 1})
+
+	allowed := func(n int, lvl zapcore.Level) int {
+		var count int
+		for i := 0; i < n; i++ {
+			if s.allow(zapcore.Entry{Level: lvl}) {
+				count++
+			}
+		}
+		return count
+	}
+	assert.Equal(t, 10, allowed(1000, zapcore.DebugLevel))
+	assert.Equal(t, 100, allowed(1000, zapcore.InfoLevel))
+	// a rate of 1 and the levels without a rate are never sampled.
+	assert.Equal(t, 1000, allowed(1000, zapcore.WarnLevel))
+	assert.Equal(t, 1000, allowed(1000, zapcore.ErrorLevel))
+}
+
+func TestWithLevelSampling(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(WithWriter(&buf), WithLevel(DebugLevel), WithLevelSampling(map[Level]int{DebugLevel: 50, InfoLevel: 5}))
+	for i := 0; i < 100; i++ {
+		l.Debug("debug")
+		l.Info("info")
+		l.Warn("warn")
+	}
+	assert.NoError(t, l.Sync())
+	out := buf.String()
+	assert.Equal(t, 2, strings.Count(out, `"msg":"debug"`))
+	assert.Equal(t, 20, strings.Count(out, `"msg":"info"`))
+	assert.Equal(t, 100, strings.Count(out, `"msg":"warn"`))
+
+	cfg := Config{Sampling: map[string]int{"info": 100}}
+	assert.Equal(t, map[string]int{"info": 100}, New(append(cfg.Options(), WithWriter(&buf))...).EffectiveConfig().Sampling)
+
+	_, err := NewWithError(WithLevelSampling(map[Level]int{InfoLevel: -1}))
+	assert.ErrorContains(t, err, "sampling rate of INFO must not be negative")
+	_, err = NewWithError(Config{Sampling: map[string]int{"verbose": 10}}.Options()...)
+	assert.ErrorContains(t, err, "sampling of unknown level")
+	_, err = NewWithError(WithLevelSampling(map[Level]int{ErrorLevel: 10, FatalLevel: 2}))
+	assert.ErrorContains(t, err, "sampling of ERROR: warn and above are never sampled")
+	assert.ErrorContains(t, err, "sampling of FATAL")
+	_, err = NewWithError(Config{Sampling: map[string]int{"warn": 10}}.Options()...)
+	assert.ErrorContains(t, err, "sampling of WARN")
+}
+
+func TestSetSampling(t *testing.T) {
+	SetSampling(map[Level]int{InfoLevel: 10, WarnLevel: 10})
+	defer SetSampling(nil)
+
+	var buf, stat, slow bytes.Buffer
+	l := New(WithWriter(&buf))
+	InitStat(WithWriter(&stat))
+	defer SetStatLogger(nil)
+	InitSlow(WithWriter(&slow))
+	defer SetSlowLogger(nil)
+	dir := t.TempDir()
+	audit := NewAudit(WithPath(dir))
+	for i := 0; i < 100; i++ {
+		l.Info("sampled")
+		Stat("kept")
+		Sloww("kept")
+		audit.Info("kept")
+	}
+	assert.Nil(t, audit.Close())
+
+	assert.Equal(t, 10, strings.Count(buf.String(), "sampled"))
+	// the stat, slow and audit entries are all kept.
+	assert.Equal(t, 100, strings.Count(stat.String(), "kept"))
+	assert.Equal(t, 100, strings.Count(slow.String(), "kept"))
+	data, err := os.ReadFile(filepath.Join(dir, auditFilename))
+	assert.Nil(t, err)
+	assert.Equal(t, 100, strings.Count(string(data), "kept"))
+}
@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// SevereCallback is invoked for every severe entry, e.g. to page on-call.
+type SevereCallback func(msg string, keysAndValues ...interface{})
+
+var (
+	severeChannel  = newChannel(WithMode(FileMode), WithFilename(severeFilename), withoutSampling())
+	severeCallback atomic.Value
+)
+
+// InitSevere rebuilds the severe logger and closes the previous one. opts are
+// applied over the defaults of file mode and `severe.log`.
+func InitSevere(opts ...Option) {
+	severeChannel.init(opts...)
+}
+
+// SetSevereLogger replaces the severe logger with l, which stays the caller's
+// to close. The previous logger is closed if InitSevere or the default built
+// it. A nil l restores the default severe logger, built on first use.
+func SetSevereLogger(l Logger) {
+	severeChannel.set(l)
+}
+
+// SetSevereCallback sets the callback invoked after every severe entry is logged,
+// nil removes it.
+func SetSevereCallback(fn SevereCallback) {
+	severeCallback.Store(fn)
+}
+
+// Severe uses fmt.Sprint to construct and log a paging-worthy message.
+func Severe(args ...interface{}) {
+	severeChannel.skipped().Error(args...)
+	notifySevere(fmt.Sprint(args...))
+}
+
+// Severef uses fmt.Sprintf to log a templated paging-worthy message.
+func Severef(template string, args ...interface{}) {
+	severeChannel.skipped().Errorf(template, args...)
+	notifySevere(fmt.Sprintf(template, args...))
+}
+
+// Severew logs a paging-worthy message with some additional context.
+func Severew(msg string, keysAndValues ...interface{}) {
+	severeChannel.skipped().Errorw(msg, keysAndValues...)
+	notifySevere(msg, keysAndValues...)
+}
+
+func notifySevere(msg string, keysAndValues ...interface{}) {
+	if fn, ok := severeCallback.Load().(SevereCallback); ok && fn != nil {
+		fn(msg, keysAndValues...)
+	}
+}
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// EnableSignalLevelControl changes the level of the default logger at runtime
+// on signals, e.g. SIGUSR1 and SIGUSR2 on Unix: sigUp raises the level one
+// step towards FatalLevel (less verbose), sigDown lowers it one step towards
+// DebugLevel (more verbose). The returned function stops the control, it can
+// be called more than once.
+func EnableSignalLevelControl(sigUp, sigDown os.Signal) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigUp, sigDown)
+
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				lv := currentLevel()
+				if sig == sigUp && lv < FatalLevel {
+					lv++
+				} else if sig == sigDown && lv > DebugLevel {
+					lv--
+				}
+				SetLevel(lv)
+				internalInfo("level changed by signal", "level", lv.String(), "signal", sig.String())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// currentLevel returns the level of the default logger.
+func currentLevel() Level {
+	if l, ok := Default().(interface{ Level() Level }); ok {
+		return l.Level()
+	}
+	return InfoLevel
+}
@@ -0,0 +1,42 @@
+//go:build !windows
+
+package logger
+
+import (
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnableSignalLevelControl(t *testing.T) {
+	old := DefaultLogger
+	DefaultLogger = New(WithWriter(io.Discard))
+	defer func() {
+		DefaultLogger = old
+	}()
+
+	stop := EnableSignalLevelControl(syscall.SIGUSR1, syscall.SIGUSR2)
+	defer stop()
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	assert.Eventually(t, func() bool {
+		return currentLevel() == WarnLevel
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	assert.Eventually(t, func() bool {
+		return currentLevel() == InfoLevel
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	assert.Eventually(t, func() bool {
+		return currentLevel() == DebugLevel
+	}, time.Second, 10*time.Millisecond)
+
+	// stopping again, e.g. by the deferred stop, doesn't panic.
+	stop()
+	assert.NotPanics(t, stop)
+}
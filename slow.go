@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	durationKey          = "duration"
+	defaultSlowThreshold = 500 * time.Millisecond
+)
+
+var (
+	slowChannel   = newChannel(WithMode(FileMode), WithFilename(slowFilename), withoutSampling())
+	slowThreshold = int64(defaultSlowThreshold)
+)
+
+// InitSlow rebuilds the slow logger and closes the previous one. opts are
+// applied over the defaults of file mode and `slow.log`.
+func InitSlow(opts ...Option) {
+	slowChannel.init(opts...)
+}
+
+// SetSlowLogger replaces the slow logger with l, which stays the caller's
+// to close. The previous logger is closed if InitSlow or the default built
+// it. A nil l restores the default slow logger, built on first use.
+func SetSlowLogger(l Logger) {
+	slowChannel.set(l)
+}
+
+// SetSlowThreshold sets the elapsed time above which Timing logs, default is 500ms.
+func SetSlowThreshold(threshold time.Duration) {
+	atomic.StoreInt64(&slowThreshold, int64(threshold))
+}
+
+// Slowf uses fmt.Sprintf to log a templated message to the slow logger.
+func Slowf(template string, args ...interface{}) {
+	slowChannel.skipped().Warnf(template, args...)
+}
+
+// Sloww logs a message with some additional context to the slow logger.
+func Sloww(msg string, keysAndValues ...interface{}) {
+	slowChannel.skipped().Warnw(msg, keysAndValues...)
+}
+
+// Timing logs name to the slow logger when the time elapsed since start exceeds
+// the slow threshold, typically deferred around a DB or RPC call:
+//
+//	defer logger.Timing(ctx, "query users", time.Now())
+func Timing(ctx context.Context, name string, start time.Time) {
+	elapsed := time.Since(start)
+	if elapsed <= time.Duration(atomic.LoadInt64(&slowThreshold)) {
+		return
+	}
+
+	slowChannel.skipped().WithContext(ctx).Warnw(name, durationKey, elapsed)
+}
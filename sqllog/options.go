@@ -0,0 +1,58 @@
+package sqllog
+
+import (
+	"database/sql/driver"
+	"time"
+
+	"github.com/nextmicro/logger"
+)
+
+const defaultSlowThreshold = 200 * time.Millisecond
+
+type Option func(o *options)
+
+type options struct {
+	// level is the level of the queries neither slow nor failed.
+	level logger.Level
+	// slowThreshold is the duration above which queries are logged at
+	// WarnLevel, 0 disables it.
+	slowThreshold time.Duration
+	// logArgs logs the query arguments, through redact if not nil.
+	logArgs bool
+	redact  func(ordinal int, name string, value driver.Value) interface{}
+}
+
+func newOptions(opts ...Option) options {
+	opt := options{level: logger.DebugLevel, slowThreshold: defaultSlowThreshold}
+	for _, o := range opts {
+		o(&opt)
+	}
+	return opt
+}
+
+// WithLevel Setter function to set the level of the queries neither slow nor
+// failed, default is DebugLevel.
+func WithLevel(level logger.Level) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+// WithSlowThreshold Setter function to set the duration above which queries
+// are logged at WarnLevel, default is 200ms. 0 disables it.
+func WithSlowThreshold(d time.Duration) Option {
+	return func(o *options) {
+		o.slowThreshold = d
+	}
+}
+
+// WithArgs Setter function to log the query arguments, which aren't logged by
+// default. Each argument is logged as returned by redact, given its 1-based
+// ordinal, its name for named arguments, and its value, e.g. to mask
+// passwords. A nil redact logs the values as they are.
+func WithArgs(redact func(ordinal int, name string, value driver.Value) interface{}) Option {
+	return func(o *options) {
+		o.logArgs = true
+		o.redact = redact
+	}
+}
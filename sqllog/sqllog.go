@@ -0,0 +1,126 @@
+// Package sqllog wraps a database/sql driver to log its queries through the
+// logger package, with their duration, error and, optionally, redacted
+// arguments:
+//
+//	sql.Register("mysql-logged", sqllog.Wrap(&mysql.MySQLDriver{}, logger.Default()))
+//	db, err := sql.Open("mysql-logged", dsn)
+//
+// or, with a connector:
+//
+//	db := sql.OpenDB(sqllog.WrapConnector(connector, logger.Default()))
+//
+// Queries are logged at DebugLevel, slow ones at WarnLevel and failed ones at
+// ErrorLevel, with the trace_id and span_id of the query context.
+package sqllog
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/nextmicro/logger"
+)
+
+// Wrap returns a driver logging the queries of d to l.
+func Wrap(d driver.Driver, l logger.Logger, opts ...Option) driver.Driver {
+	lg := &queryLogger{l: l, opt: newOptions(opts...)}
+	if dc, ok := d.(driver.DriverContext); ok {
+		return &wrappedDriverContext{wrappedDriver{Driver: d, lg: lg}, dc}
+	}
+	return &wrappedDriver{Driver: d, lg: lg}
+}
+
+// WrapConnector returns a connector logging the queries of c to l, for
+// sql.OpenDB.
+func WrapConnector(c driver.Connector, l logger.Logger, opts ...Option) driver.Connector {
+	lg := &queryLogger{l: l, opt: newOptions(opts...)}
+	return &wrappedConnector{Connector: c, d: &wrappedDriver{Driver: c.Driver(), lg: lg}}
+}
+
+// queryLogger logs the queries.
+type queryLogger struct {
+	l   logger.Logger
+	opt options
+}
+
+// log logs query, run with args from start, which failed with err if not nil.
+func (q *queryLogger) log(ctx context.Context, query string, args []driver.NamedValue, start time.Time, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		// the query is run again another way, and logged then.
+		return
+	}
+
+	duration := time.Since(start)
+	level, msg := q.opt.level, "sql query"
+	switch {
+	case err != nil:
+		level, msg = logger.ErrorLevel, "sql query failed"
+	case q.opt.slowThreshold > 0 && duration > q.opt.slowThreshold:
+		level, msg = logger.WarnLevel, "slow sql query"
+	}
+
+	keysAndValues := []interface{}{"query", query, "duration", duration.String()}
+	if q.opt.logArgs && len(args) > 0 {
+		keysAndValues = append(keysAndValues, "args", q.args(args))
+	}
+	if err != nil {
+		keysAndValues = append(keysAndValues, "error", err.Error())
+	}
+	q.l.WithContext(ctx).Logw(level, msg, keysAndValues...)
+}
+
+func (q *queryLogger) args(args []driver.NamedValue) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		if q.opt.redact != nil {
+			values[i] = q.opt.redact(arg.Ordinal, arg.Name, arg.Value)
+		} else {
+			values[i] = arg.Value
+		}
+	}
+	return values
+}
+
+type wrappedDriver struct {
+	driver.Driver
+	lg *queryLogger
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{Conn: conn, lg: d.lg}, nil
+}
+
+type wrappedDriverContext struct {
+	wrappedDriver
+	dc driver.DriverContext
+}
+
+func (d *wrappedDriverContext) OpenConnector(name string) (driver.Connector, error) {
+	c, err := d.dc.OpenConnector(name)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConnector{Connector: c, d: &d.wrappedDriver}, nil
+}
+
+type wrappedConnector struct {
+	driver.Connector
+	d *wrappedDriver
+}
+
+func (c *wrappedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{Conn: conn, lg: c.d.lg}, nil
+}
+
+func (c *wrappedConnector) Driver() driver.Driver {
+	return c.d
+}
@@ -0,0 +1,163 @@
+package sqllog
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nextmicro/logger"
+	"github.com/nextmicro/logger/logtest"
+	"github.com/stretchr/testify/assert"
+)
+
+var errFailed = errors.New("syntax error")
+
+// fakeDriver runs exec on the connection and queries through prepared
+// statements, failing the statements containing "fail" and sleeping for
+// those containing "sleep".
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{query: query}, nil
+}
+
+func (fakeConn) Close() error {
+	return nil
+}
+
+func (fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("unsupported")
+}
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := run(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type fakeStmt struct {
+	query string
+}
+
+func (fakeStmt) Close() error {
+	return nil
+}
+
+func (fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("unexpected")
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	if err := run(s.query); err != nil {
+		return nil, err
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeRows struct {
+	done bool
+}
+
+func (*fakeRows) Columns() []string {
+	return []string{"id"}
+}
+
+func (*fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func run(query string) error {
+	if strings.Contains(query, "sleep") {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if strings.Contains(query, "fail") {
+		return errFailed
+	}
+	return nil
+}
+
+func TestWrap(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithWriter(&buf), logger.WithLevel(logger.DebugLevel))
+	sql.Register("sqllog-test", Wrap(fakeDriver{}, l,
+		WithSlowThreshold(10*time.Millisecond),
+		WithArgs(func(ordinal int, name string, value driver.Value) interface{} {
+			if ordinal == 2 {
+				return "***"
+			}
+			return value
+		}),
+	))
+	db, err := sql.Open("sqllog-test", "")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "update users set password = ? where id = ?", "secret", 42)
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "fail")
+	assert.ErrorIs(t, err, errFailed)
+	var id int
+	assert.Nil(t, db.QueryRowContext(ctx, "select id from users where name = ? and sleep", "alice").Scan(&id))
+	assert.Equal(t, 1, id)
+
+	logged := logtest.Entries(t, buf.Bytes())
+	assert.Len(t, logged, 3)
+	assert.Equal(t, "debug", logged[0]["level"])
+	assert.Equal(t, "sql query", logged[0]["msg"])
+	assert.Equal(t, []interface{}{"secret", "***"}, logged[0]["args"])
+	assert.Equal(t, "error", logged[1]["level"])
+	assert.Equal(t, errFailed.Error(), logged[1]["error"])
+	assert.Equal(t, "warn", logged[2]["level"])
+	assert.Equal(t, "select id from users where name = ? and sleep", logged[2]["query"])
+}
+
+func TestWrapConnector(t *testing.T) {
+	var buf bytes.Buffer
+	db := sql.OpenDB(WrapConnector(connector{}, logger.New(logger.WithWriter(&buf)), WithLevel(logger.InfoLevel)))
+	defer db.Close()
+
+	_, err := db.Exec("delete from sessions where expired")
+	assert.Nil(t, err)
+
+	logged := logtest.Entries(t, buf.Bytes())
+	assert.Len(t, logged, 1)
+	assert.Equal(t, "info", logged[0]["level"])
+	assert.Nil(t, logged[0]["args"])
+}
+
+type connector struct{}
+
+func (connector) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+func (connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
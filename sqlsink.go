@@ -0,0 +1,359 @@
+package logger
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultSQLTable      = "logs"
+	defaultSQLBatchSize  = 100
+	defaultSQLInterval   = time.Second
+	defaultSQLMaxPending = 10000
+	// sqliteTimeFormat is the fixed width UTC time of the SQLite rows, so
+	// they sort as text and the SQLite date functions read them.
+	sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z"
+)
+
+// SQLDialect is the database a SQL sink writes to.
+type SQLDialect int
+
+const (
+	// SQLite stores the entries in a table indexed on ts, level and trace_id.
+	SQLite SQLDialect = iota
+	// ClickHouse stores the entries in a MergeTree table ordered by level and
+	// ts, with a bloom filter index on trace_id.
+	ClickHouse
+)
+
+// schema returns the statements creating table and its indexes.
+func (d SQLDialect) schema(table string) []string {
+	if d == ClickHouse {
+		return []string{fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	ts DateTime64(9),
+	level LowCardinality(String),
+	msg String,
+	caller String,
+	trace_id String,
+	span_id String,
+	fields String,
+	INDEX %[1]s_trace_id trace_id TYPE bloom_filter GRANULARITY 4
+) ENGINE = MergeTree ORDER BY (level, ts)`, table)}
+	}
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	ts TEXT NOT NULL,
+	level TEXT NOT NULL,
+	msg TEXT NOT NULL,
+	caller TEXT,
+	trace_id TEXT,
+	span_id TEXT,
+	fields TEXT
+)`, table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_ts ON %[1]s (ts)", table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_level ON %[1]s (level, ts)", table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_trace_id ON %[1]s (trace_id)", table),
+	}
+}
+
+// timeValue returns the value of the ts column for t.
+func (d SQLDialect) timeValue(t time.Time) interface{} {
+	if d == ClickHouse {
+		return t
+	}
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// SQLSinkOption configures a SQL sink set with WithSQLSink.
+type SQLSinkOption func(o *sqlSinkOptions)
+
+type sqlSinkOptions struct {
+	dialect    SQLDialect
+	table      string
+	batchSize  int
+	interval   time.Duration
+	maxPending int
+	retry      writeRetry
+	ctx        context.Context
+	internal   internalLogger
+}
+
+// WithSQLDialect Setter function to set the database written to, default is
+// SQLite.
+func WithSQLDialect(dialect SQLDialect) SQLSinkOption {
+	return func(o *sqlSinkOptions) {
+		o.dialect = dialect
+	}
+}
+
+// WithSQLTable Setter function to set the table of the entries, created if
+// it doesn't exist, default is `logs`.
+func WithSQLTable(table string) SQLSinkOption {
+	return func(o *sqlSinkOptions) {
+		o.table = table
+	}
+}
+
+// WithSQLBatchSize Setter function to set the number of entries inserted in
+// a transaction, default is 100.
+func WithSQLBatchSize(size int) SQLSinkOption {
+	return func(o *sqlSinkOptions) {
+		o.batchSize = size
+	}
+}
+
+// WithSQLInterval Setter function to set how long entries wait for a batch
+// to fill before they are inserted, default is 1s.
+func WithSQLInterval(interval time.Duration) SQLSinkOption {
+	return func(o *sqlSinkOptions) {
+		o.interval = interval
+	}
+}
+
+// WithSQLMaxPending Setter function to set the number of entries queued above
+// which new entries are dropped, default is 10000.
+func WithSQLMaxPending(n int) SQLSinkOption {
+	return func(o *sqlSinkOptions) {
+		o.maxPending = n
+	}
+}
+
+// sqlSinkConfig is a SQL sink set with WithSQLSink.
+type sqlSinkConfig struct {
+	db    *sql.DB
+	level Level
+	opts  []SQLSinkOption
+}
+
+// sqlSink inserts the entries in batches, from its own goroutine.
+type sqlSink struct {
+	db   *sql.DB
+	opts sqlSinkOptions
+	// insert is the statement inserting a row.
+	insert string
+	// ctx is canceled on shutdown, stopping the inserts and their retries.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	pending []Entry
+	dropped int
+	// writeMu serializes the batches.
+	writeMu sync.Mutex
+	full    chan struct{}
+}
+
+// newSQLSink returns a sqlSink writing to db, once the table is created.
+func newSQLSink(db *sql.DB, opts ...SQLSinkOption) (*sqlSink, error) {
+	o := sqlSinkOptions{
+		table:      defaultSQLTable,
+		batchSize:  defaultSQLBatchSize,
+		interval:   defaultSQLInterval,
+		maxPending: defaultSQLMaxPending,
+		ctx:        context.Background(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	for _, stmt := range o.dialect.schema(o.table) {
+		if _, err := db.ExecContext(o.ctx, stmt); err != nil {
+			return nil, fmt.Errorf("sql sink: %w", err)
+		}
+	}
+
+	s := &sqlSink{
+		db:     db,
+		opts:   o,
+		insert: fmt.Sprintf("INSERT INTO %s (ts, level, msg, caller, trace_id, span_id, fields) VALUES (?, ?, ?, ?, ?, ?, ?)", o.table),
+		full:   make(chan struct{}, 1),
+	}
+	s.ctx, s.cancel = context.WithCancel(o.ctx)
+	go s.run()
+	return s, nil
+}
+
+// add queues e, dropping it if too many entries are queued.
+func (s *sqlSink) add(e Entry) {
+	s.mu.Lock()
+	if len(s.pending) >= s.opts.maxPending {
+		s.dropped++
+		s.mu.Unlock()
+		addStat(statDrops, 1)
+		return
+	}
+	s.pending = append(s.pending, e)
+	n := len(s.pending)
+	s.mu.Unlock()
+
+	if n >= s.opts.batchSize {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *sqlSink) run() {
+	t := time.NewTicker(s.opts.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.full:
+		case <-t.C:
+		case <-s.ctx.Done():
+			return
+		}
+		_ = s.flush()
+	}
+}
+
+// flush inserts every queued entry, a batch at a time.
+func (s *sqlSink) flush() error {
+	return s.flushContext(s.ctx)
+}
+
+// shutdown stops the inserts in flight and their retries, then inserts the
+// queued entries a last time, until ctx is done.
+func (s *sqlSink) shutdown(ctx context.Context) error {
+	s.cancel()
+	return s.flushContext(ctx)
+}
+
+func (s *sqlSink) flushContext(ctx context.Context) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	for {
+		s.mu.Lock()
+		n := len(s.pending)
+		if n > s.opts.batchSize {
+			n = s.opts.batchSize
+		}
+		batch := append([]Entry(nil), s.pending[:n]...)
+		s.pending = append(s.pending[:0], s.pending[n:]...)
+		dropped := s.dropped
+		s.dropped = 0
+		s.mu.Unlock()
+
+		if dropped > 0 {
+			s.opts.internal.warn("sql sink dropped entries", "table", s.opts.table, "dropped", dropped)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.writeBatch(ctx, batch); err != nil {
+			s.opts.internal.error("sql sink write failed", "table", s.opts.table, "error", err)
+			addStat(statDrops, int64(len(batch)))
+			return err
+		}
+	}
+}
+
+// writeBatch inserts batch with the retry policy of s, the error handler gets
+// the batch in JSON.
+func (s *sqlSink) writeBatch(ctx context.Context, batch []Entry) error {
+	if !s.opts.retry.enabled() {
+		return s.write(ctx, batch)
+	}
+	entries, _ := json.Marshal(batch)
+	return s.opts.retry.do(ctx, entries, func(ctx context.Context) error {
+		return s.write(ctx, batch)
+	})
+}
+
+// write inserts batch in a transaction.
+func (s *sqlSink) write(ctx context.Context, batch []Entry) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, s.insert)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	for _, e := range batch {
+		traceID, spanID, fields, err := s.columns(e)
+		if err == nil {
+			_, err = stmt.ExecContext(ctx, s.opts.dialect.timeValue(e.Time), e.Level.String(), e.Message, e.Caller, traceID, spanID, fields)
+		}
+		if err != nil {
+			_ = stmt.Close()
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err = stmt.Close(); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// columns returns the trace and span of e, and its other fields in JSON.
+func (s *sqlSink) columns(e Entry) (traceID, spanID, fields string, err error) {
+	rest := make(map[string]interface{}, len(e.Fields))
+	for k, v := range e.Fields {
+		switch str, ok := v.(string); {
+		case ok && k == traceKey:
+			traceID = str
+		case ok && k == spanKey:
+			spanID = str
+		default:
+			rest[k] = v
+		}
+	}
+	if len(rest) == 0 {
+		return traceID, spanID, "", nil
+	}
+	b, err := json.Marshal(rest)
+	return traceID, spanID, string(b), err
+}
+
+// sqlCore feeds the entries from a level to a sqlSink.
+type sqlCore struct {
+	zapcore.LevelEnabler
+	sink   *sqlSink
+	fields []zapcore.Field
+}
+
+func newSQLCore(ctx context.Context, cfg sqlSinkConfig, retry writeRetry, internal internalLogger) (*sqlCore, error) {
+	opts := append(cfg.opts[:len(cfg.opts):len(cfg.opts)], func(o *sqlSinkOptions) { o.retry, o.ctx, o.internal = retry, ctx, internal })
+	sink, err := newSQLSink(cfg.db, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlCore{LevelEnabler: cfg.level.unmarshalZapLevel(), sink: sink}, nil
+}
+
+func (c *sqlCore) With(fields []zapcore.Field) zapcore.Core {
+	return &sqlCore{LevelEnabler: c.LevelEnabler, sink: c.sink, fields: append(c.fields[:len(c.fields):len(c.fields)], fields...)}
+}
+
+func (c *sqlCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write queues the entry, entries above ErrorLevel are inserted before Write
+// returns, as the process may exit next.
+func (c *sqlCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.sink.add(newEntry(ent, c.fields, fields))
+	if ent.Level > zapcore.ErrorLevel {
+		return c.sink.flush()
+	}
+	return nil
+}
+
+func (c *sqlCore) Sync() error {
+	return c.sink.flush()
+}
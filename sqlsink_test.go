@@ -0,0 +1,132 @@
+package logger
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordDriver is a database/sql driver recording the statements run and
+// the rows inserted, as no SQL driver is a dependency.
+type recordDriver struct {
+	mu      sync.Mutex
+	execs   []string
+	rows    [][]driver.Value
+	commits int
+}
+
+func (d *recordDriver) Open(string) (driver.Conn, error) { return &recordConn{d}, nil }
+
+func (d *recordDriver) committed() ([][]driver.Value, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([][]driver.Value(nil), d.rows...), d.commits
+}
+
+type recordConn struct{ d *recordDriver }
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordStmt{d: c.d, query: query}, nil
+}
+func (c *recordConn) Close() error              { return nil }
+func (c *recordConn) Begin() (driver.Tx, error) { return &recordTx{c.d}, nil }
+
+type recordTx struct{ d *recordDriver }
+
+func (tx *recordTx) Commit() error {
+	tx.d.mu.Lock()
+	tx.d.commits++
+	tx.d.mu.Unlock()
+	return nil
+}
+func (tx *recordTx) Rollback() error { return errors.New("unexpected rollback") }
+
+type recordStmt struct {
+	d     *recordDriver
+	query string
+}
+
+func (s *recordStmt) Close() error  { return nil }
+func (s *recordStmt) NumInput() int { return strings.Count(s.query, "?") }
+func (s *recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	if strings.HasPrefix(s.query, "INSERT") {
+		s.d.rows = append(s.d.rows, args)
+	} else {
+		s.d.execs = append(s.d.execs, s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+func (s *recordStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+// recordDrivers numbers the registered drivers, as a name registers once.
+var recordDrivers atomic.Int32
+
+func openRecordDB(t *testing.T) (*sql.DB, *recordDriver) {
+	d := &recordDriver{}
+	name := fmt.Sprintf("record%d", recordDrivers.Add(1))
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db, d
+}
+
+func TestWithSQLSink(t *testing.T) {
+	db, d := openRecordDB(t)
+	l := New(WithWriter(io.Discard), WithSQLSink(db, InfoLevel,
+		WithSQLTable("app_logs"),
+		WithSQLBatchSize(2),
+		WithSQLInterval(time.Hour),
+	))
+
+	assert.Len(t, d.execs, 4)
+	assert.Contains(t, d.execs[0], "CREATE TABLE IF NOT EXISTS app_logs")
+	assert.Contains(t, d.execs[3], "ON app_logs (trace_id)")
+
+	l.Debug("not inserted")
+	l.With(traceKey, "4bf92f3577b34da6a3ce929d0e0e4736").Infow("request served", "status", 200)
+	l.Warn("slow request")
+
+	var rows [][]driver.Value
+	assert.Eventually(t, func() bool {
+		rows, _ = d.committed()
+		return len(rows) == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, "INFO", rows[0][1])
+	assert.Equal(t, "request served", rows[0][2])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", rows[0][4])
+	assert.Equal(t, `{"status":200}`, rows[0][6])
+	assert.Equal(t, "WARN", rows[1][1])
+	assert.Len(t, rows[1][0], len(sqliteTimeFormat))
+
+	// entries not filling a batch are inserted on sync.
+	l.Error("query failed")
+	assert.Nil(t, l.Sync())
+	rows, commits := d.committed()
+	assert.Len(t, rows, 3)
+	assert.Equal(t, 2, commits)
+
+	assert.ErrorIs(t, newOptions(WithSQLSink(nil, InfoLevel)).validate(), ErrInvalidOptions)
+}
+
+func TestSQLSinkClickHouse(t *testing.T) {
+	db, d := openRecordDB(t)
+	_, err := newSQLSink(db, WithSQLDialect(ClickHouse))
+	assert.Nil(t, err)
+	assert.Len(t, d.execs, 1)
+	assert.Contains(t, d.execs[0], "ENGINE = MergeTree ORDER BY (level, ts)")
+	assert.Contains(t, d.execs[0], "TYPE bloom_filter")
+}
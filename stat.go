@@ -0,0 +1,27 @@
+package logger
+
+var statChannel = newChannel(WithMode(FileMode), WithFilename(statFilename), withoutSampling())
+
+// InitStat rebuilds the stat logger and closes the previous one. opts are
+// applied over the defaults of file mode and `stat.log`, so the rotation
+// settings are independent of the application logs.
+func InitStat(opts ...Option) {
+	statChannel.init(opts...)
+}
+
+// SetStatLogger replaces the stat logger with l, which stays the caller's
+// to close. The previous logger is closed if InitStat or the default built
+// it. A nil l restores the default stat logger, built on first use.
+func SetStatLogger(l Logger) {
+	statChannel.set(l)
+}
+
+// Stat logs a metrics message with some additional context to the stat logger.
+func Stat(msg string, keysAndValues ...interface{}) {
+	statChannel.skipped().Infow(msg, keysAndValues...)
+}
+
+// Statf uses fmt.Sprintf to log a templated message to the stat logger.
+func Statf(template string, args ...interface{}) {
+	statChannel.skipped().Infof(template, args...)
+}
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"os"
+	"time"
+)
+
+// FileStatus is the state of a rolling log file.
+type FileStatus struct {
+	// Filename is the path of the file written to.
+	Filename string
+	// Size is the size of the file in bytes.
+	Size int64
+	// LastRotation is the time of the last rotation, zero if the file
+	// wasn't rotated since the logger started.
+	LastRotation time.Time
+	// Backups is the number of rotated files kept, archived or not.
+	Backups int
+	// CompressionBacklog is the number of rotated files waiting to be
+	// compressed, always 0 without compression.
+	CompressionBacklog int
+}
+
+// Status returns the state of the file of l.
+func (l *RotateLogger) Status() FileStatus {
+	filename, _ := l.names()
+	status := FileStatus{Filename: filename}
+	if info, err := os.Stat(filename); err == nil {
+		status.Size = info.Size()
+	}
+	if at := l.rotatedAt.Load(); at > 0 {
+		status.LastRotation = time.Unix(0, at)
+	}
+
+	archived, pending := l.backups()
+	status.Backups = len(archived) + len(pending)
+	if l.compress {
+		status.CompressionBacklog = len(pending)
+	}
+	return status
+}
+
+// FileStatus returns the state of every rolling file of l, one per level
+// file, or one with a single file. It is empty when l logs to the console
+// only.
+func (l *Logging) FileStatus() []FileStatus {
+	statuses := make([]FileStatus, 0, len(l._rotateLoggers))
+	for _, rl := range l._rotateLoggers {
+		statuses = append(statuses, rl.Status())
+	}
+	return statuses
+}
@@ -0,0 +1,195 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// streamBufferSize is the number of entries buffered per stream client,
+// entries are dropped for clients that fall further behind.
+const streamBufferSize = 256
+
+// streams is the hub of the clients connected to StreamHandler.
+var streams = &streamHub{clients: make(map[*streamClient]struct{})}
+
+// StreamHandler returns an http.Handler streaming the entries of every logger
+// to its clients as server-sent events, one JSON entry per event. Clients can
+// filter with the `level` query parameter, the lowest level streamed, and
+// any number of `field` parameters in `key:value` form, all of which must
+// match. Only entries enabled by the logger level are streamed.
+//
+//	curl -N 'localhost:8080/debug/logs?level=warn&field=user_id:42'
+func StreamHandler() http.Handler {
+	return http.HandlerFunc(serveStream)
+}
+
+func serveStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	c := &streamClient{level: zapcore.DebugLevel, entries: make(chan []byte, streamBufferSize)}
+	query := r.URL.Query()
+	if lv := query.Get("level"); lv != "" {
+		level, err := parseLevel(lv)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.level = level.unmarshalZapLevel()
+	}
+	for _, field := range query["field"] {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok {
+			http.Error(w, fmt.Sprintf("invalid field filter %q: missing ':'", field), http.StatusBadRequest)
+			return
+		}
+		c.fields = append(c.fields, [2]string{key, value})
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	streams.subscribe(c)
+	defer streams.unsubscribe(c)
+
+	for {
+		select {
+		case entry := <-c.entries:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", entry); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+type streamClient struct {
+	level   zapcore.Level
+	fields  [][2]string
+	entries chan []byte
+}
+
+// match reports whether an entry of lvl with the given fields passes the
+// filters of c.
+func (c *streamClient) match(lvl zapcore.Level, fields map[string]interface{}) bool {
+	if lvl < c.level {
+		return false
+	}
+	for _, f := range c.fields {
+		v, ok := fields[f[0]]
+		if !ok || fmt.Sprint(v) != f[1] {
+			return false
+		}
+	}
+	return true
+}
+
+type streamHub struct {
+	mu      sync.RWMutex
+	clients map[*streamClient]struct{}
+	// active is the number of clients, read without the lock on the log path.
+	active int32
+}
+
+func (h *streamHub) subscribe(c *streamClient) {
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	atomic.StoreInt32(&h.active, int32(len(h.clients)))
+	h.mu.Unlock()
+}
+
+func (h *streamHub) unsubscribe(c *streamClient) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	atomic.StoreInt32(&h.active, int32(len(h.clients)))
+	h.mu.Unlock()
+}
+
+func (h *streamHub) hasClients() bool {
+	return atomic.LoadInt32(&h.active) > 0
+}
+
+// publish sends entry to the clients whose filters match, without blocking.
+func (h *streamHub) publish(lvl zapcore.Level, fields map[string]interface{}, entry []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for c := range h.clients {
+		if !c.match(lvl, fields) {
+			continue
+		}
+		select {
+		case c.entries <- entry:
+		default:
+		}
+	}
+}
+
+// streamCore publishes entries to the stream clients. It is part of every
+// logger and does nothing while no client is connected.
+type streamCore struct {
+	enc  zapcore.Encoder
+	with []zapcore.Field
+}
+
+func newStreamCore(cfg zapcore.EncoderConfig) zapcore.Core {
+	return &streamCore{enc: zapcore.NewJSONEncoder(cfg)}
+}
+
+func (c *streamCore) Enabled(zapcore.Level) bool {
+	return streams.hasClients()
+}
+
+func (c *streamCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &streamCore{enc: c.enc.Clone(), with: make([]zapcore.Field, 0, len(c.with)+len(fields))}
+	clone.with = append(append(clone.with, c.with...), fields...)
+	for i := range fields {
+		fields[i].AddTo(clone.enc)
+	}
+	return clone
+}
+
+func (c *streamCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if streams.hasClients() {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *streamCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	entry := bytes.TrimRight(buf.Bytes(), "\n")
+	entry = append([]byte(nil), entry...)
+	buf.Free()
+
+	m := zapcore.NewMapObjectEncoder()
+	for _, f := range c.with {
+		f.AddTo(m)
+	}
+	for _, f := range fields {
+		f.AddTo(m)
+	}
+	streams.publish(ent.Level, m.Fields, entry)
+	return nil
+}
+
+func (c *streamCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,156 @@
+package logger
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// pooledFields is the capacity of the pooled field slices, the fields
+	// of 8 key-value pairs and the context of a traced logger fit.
+	pooledFields = 16
+	// maxPooledFields is the capacity above which field slices aren't reused.
+	maxPooledFields = 64
+
+	oddNumberErrMsg    = "Ignored key without a value."
+	nonStringKeyErrMsg = "Ignored key-value pairs with non-string keys."
+)
+
+var (
+	fieldsPool = sync.Pool{New: func() any {
+		fields := make([]zapcore.Field, 0, pooledFields)
+		return &fields
+	}}
+	// encodePool holds the buffers of the encoders appending byte strings.
+	encodePool = buffer.NewPool()
+	// errorOutput receives the errors of the cores, like zap's default.
+	errorOutput = zapcore.Lock(os.Stderr)
+)
+
+// getFields returns an empty field slice from the pool.
+func getFields() *[]zapcore.Field {
+	return fieldsPool.Get().(*[]zapcore.Field)
+}
+
+// putFields clears the fields of p, so their values can be collected, and
+// returns it to the pool.
+func putFields(p *[]zapcore.Field) {
+	if cap(*p) > maxPooledFields {
+		return
+	}
+	fields := (*p)[:cap(*p)]
+	for i := range fields {
+		fields[i] = zapcore.Field{}
+	}
+	*p = fields[:0]
+	fieldsPool.Put(p)
+}
+
+// sweetenFields appends the fields of keysAndValues to fields like zap's
+// sugared logger: zap Fields as they are, and the other elements as a key
+// followed by its value. It returns the elements that aren't, which the
+// sugared logger reports in an error entry.
+func sweetenFields(fields []zapcore.Field, keysAndValues []interface{}) (_ []zapcore.Field, dangling interface{}, invalid []interface{}) {
+	for i := 0; i < len(keysAndValues); {
+		if f, ok := keysAndValues[i].(zapcore.Field); ok {
+			fields = append(fields, f)
+			i++
+			continue
+		}
+		if i == len(keysAndValues)-1 {
+			dangling = keysAndValues[i]
+			break
+		}
+
+		key, val := keysAndValues[i], keysAndValues[i+1]
+		if keyStr, ok := key.(string); ok {
+			fields = append(fields, zap.Any(keyStr, val))
+		} else {
+			invalid = append(invalid, key, val)
+		}
+		i += 2
+	}
+	return fields, dangling, invalid
+}
+
+// logFields writes an entry of lvl with msg and fields to lg without its
+// sugared logger, so logging allocates nothing beyond the values boxed by
+// the caller. The caller is looked up skip frames above the caller of
+// logFields. It handles the levels below DPanicLevel only, the others run
+// the hooks of lg.
+func logFields(lg *zap.Logger, lvl zapcore.Level, msg string, fields []zapcore.Field, skip int, caller bool) {
+	ent := zapcore.Entry{LoggerName: lg.Name(), Time: time.Now(), Level: lvl, Message: msg}
+	ce := lg.Core().Check(ent, nil)
+	if ce == nil {
+		return
+	}
+	ce.ErrorOutput = errorOutput
+	if caller {
+		ce.Caller = entryCaller(skip + 1)
+	}
+	ce.Write(fields...)
+}
+
+// entryCaller returns the caller skip frames above the caller of entryCaller.
+// It resolves the frame with FuncForPC, unlike runtime.Caller and
+// CallersFrames it doesn't allocate, except for inlined callers.
+func entryCaller(skip int) zapcore.EntryCaller {
+	var pcs [1]uintptr
+	// +2 skips runtime.Callers and entryCaller.
+	if runtime.Callers(skip+2, pcs[:]) == 0 {
+		return zapcore.EntryCaller{}
+	}
+	// pcs holds the return address, pc-1 is in the call instruction.
+	pc := pcs[0] - 1
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return zapcore.EntryCaller{}
+	}
+	file, line := fn.FileLine(pc)
+	return zapcore.EntryCaller{Defined: true, PC: pc, File: file, Line: line, Function: fn.Name()}
+}
+
+// shortCallerEncoder encodes the caller like zapcore.ShortCallerEncoder, as
+// `package/file:line`, without allocating the string.
+func shortCallerEncoder(caller zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
+	if !caller.Defined {
+		enc.AppendString("undefined")
+		return
+	}
+	buf := encodePool.Get()
+	buf.AppendString(trimCallerPath(caller.File))
+	buf.AppendByte(':')
+	buf.AppendInt(int64(caller.Line))
+	enc.AppendByteString(buf.Bytes())
+	buf.Free()
+}
+
+// stringDurationEncoder encodes d like zapcore.StringDurationEncoder, e.g.
+// `1.5s`, without allocating the string.
+func stringDurationEncoder(d time.Duration, enc zapcore.PrimitiveArrayEncoder) {
+	buf := encodePool.Get()
+	buf.AppendString(d.String())
+	enc.AppendByteString(buf.Bytes())
+	buf.Free()
+}
+
+// trimCallerPath keeps the package and the file name of file, like
+// zapcore.EntryCaller.TrimmedPath.
+func trimCallerPath(file string) string {
+	idx := strings.LastIndexByte(file, '/')
+	if idx == -1 {
+		return file
+	}
+	idx = strings.LastIndexByte(file[:idx], '/')
+	if idx == -1 {
+		return file
+	}
+	return file[idx+1:]
+}
@@ -0,0 +1,302 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const tenantKey = "tenant_id"
+
+type tenantCtxKey struct{}
+
+// WithTenant returns a copy of ctx carrying tenantID. Loggers from
+// WithContext(ctx) tag every entry with it as tenant_id, and write it to the
+// files of the tenant when built with WithTenantRouting.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant ID of ctx, or "" if it has none.
+func TenantFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(tenantCtxKey{}).(string)
+	return id
+}
+
+// maxTenantLoggers is the number of tenants whose files are kept open, the
+// files of the least recently used tenant are closed beyond it.
+const maxTenantLoggers = 64
+
+// tenantDir returns the directory of the files of tenant id, with the bytes
+// other than letters, digits, '-', '_' and '.' escaped as %XX, so a tenant
+// ID can't point out of the log path and two IDs don't share a directory.
+func tenantDir(id string) string {
+	// the dots of "." and ".." are escaped too.
+	dots := strings.Trim(id, ".") == ""
+	var b strings.Builder
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.' && !dots:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// tenantLoggers are the loggers writing the entries of each tenant to their
+// own files, built on first use and closed once more than max tenants are
+// open.
+type tenantLoggers struct {
+	// opt are the options of the logger routing the tenants.
+	opt *Options
+	// level is the level of that logger, shared by the tenant loggers.
+	level      zap.AtomicLevel
+	compressor *compressor
+	max        int
+
+	mu      sync.Mutex
+	loggers map[string]*tenantLogger
+	// uses orders the tenant loggers by last use.
+	uses uint64
+	// closed is set once the routing logger is closed, as the tenant
+	// loggers aren't built again then.
+	closed bool
+}
+
+type tenantLogger struct {
+	*Logging
+	lastUse uint64
+	// refs counts the entries being written by the logger, an evicted
+	// logger is closed once the last of them is written.
+	refs    int
+	evicted bool
+}
+
+func newTenantLoggers(l *Logging) *tenantLoggers {
+	return &tenantLoggers{
+		opt:        l.opt,
+		level:      l.atomicLevel,
+		compressor: l.compressor,
+		max:        maxTenantLoggers,
+		loggers:    make(map[string]*tenantLogger),
+	}
+}
+
+// acquire returns the logger writing the entries of tenant id under a
+// directory of its own in the log path, to be released once the entry is
+// written. Sinks other than the files, such as webhooks, don't get the
+// entries of the tenants.
+func (t *tenantLoggers) acquire(id string) (*tenantLogger, error) {
+	dir := tenantDir(id)
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, ErrClosedRollingFile
+	}
+	t.uses++
+	if tl, ok := t.loggers[dir]; ok {
+		tl.lastUse = t.uses
+		tl.refs++
+		t.mu.Unlock()
+		return tl, nil
+	}
+
+	opt := *t.opt
+	opt.path = filepath.Join(opt.path, dir)
+	opt.tenantRouting, opt.flushOnExit, opt.panicFlush = false, false, false
+	opt.webhooks, opt.sqlSinks, opt.alertFn = nil, nil, nil
+	l := &Logging{
+		opt:         &opt,
+		atomicLevel: t.level,
+		sharedLevel: t.level,
+		compressor:  t.compressor,
+		closer:      &outputCloser{},
+	}
+	if err := l.build(); err != nil {
+		t.mu.Unlock()
+		_ = l.closeRotateLoggers()
+		return nil, err
+	}
+	tl := &tenantLogger{Logging: l, lastUse: t.uses, refs: 1}
+	t.loggers[dir] = tl
+	evicted := t.evict()
+	t.mu.Unlock()
+
+	t.closeEvicted(evicted)
+	return tl, nil
+}
+
+// release ends an entry of tl, closing tl if it was the last one of an
+// evicted logger.
+func (t *tenantLoggers) release(tl *tenantLogger) {
+	t.mu.Lock()
+	tl.refs--
+	closing := tl.evicted && tl.refs == 0
+	t.mu.Unlock()
+
+	if closing {
+		t.closeEvicted([]*tenantLogger{tl})
+	}
+}
+
+// evict removes the least recently used tenant loggers beyond max, and
+// returns those without entries being written for the caller to close
+// them. The others are closed by the release of their last entry.
+func (t *tenantLoggers) evict() []*tenantLogger {
+	var evicted []*tenantLogger
+	for len(t.loggers) > t.max {
+		var (
+			oldest string
+			tl     *tenantLogger
+		)
+		for dir, l := range t.loggers {
+			if tl == nil || l.lastUse < tl.lastUse {
+				oldest, tl = dir, l
+			}
+		}
+		delete(t.loggers, oldest)
+		tl.evicted = true
+		if tl.refs == 0 {
+			evicted = append(evicted, tl)
+		}
+	}
+	return evicted
+}
+
+// closeEvicted closes the evicted tenant loggers.
+func (t *tenantLoggers) closeEvicted(evicted []*tenantLogger) {
+	for _, e := range evicted {
+		if err := e.Close(); err != nil {
+			t.opt.internal.error("failed to close tenant logger", "path", e.opt.path, "error", err)
+		}
+	}
+}
+
+// tenantCore routes the entries to the tenant logger of id, built again if
+// it was closed to open another tenant, or to fallback if it can't be built.
+type tenantCore struct {
+	tenants  *tenantLoggers
+	id       string
+	fallback zapcore.Core
+	fields   []zapcore.Field
+	// noGate lifts the level gate of the tenant logger, see ungated.
+	noGate bool
+	// derived caches the core of the tenant logger with fields.
+	derived *atomic.Pointer[tenantDerived]
+}
+
+// tenantDerived is the core of a tenant logger with the fields of a
+// tenantCore.
+type tenantDerived struct {
+	tl   *tenantLogger
+	core zapcore.Core
+}
+
+func (c *tenantCore) Enabled(lvl zapcore.Level) bool {
+	return c.noGate || c.tenants.level.Enabled(lvl)
+}
+
+func (c *tenantCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fallback = c.fallback.With(fields)
+	clone.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	clone.derived = new(atomic.Pointer[tenantDerived])
+	return &clone
+}
+
+func (c *tenantCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write checks and writes the entry with the tenant logger, which is kept
+// open until the entry is written.
+func (c *tenantCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	tl, err := c.tenants.acquire(c.id)
+	if err != nil {
+		if !errors.Is(err, ErrClosedRollingFile) {
+			c.tenants.opt.internal.error("failed to build tenant logger", "tenant", c.id, "error", err)
+		}
+		if ce := c.fallback.Check(ent, nil); ce != nil {
+			ce.Write(fields...)
+		}
+		return nil
+	}
+	defer c.tenants.release(tl)
+
+	if ce := c.core(tl).Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+// core returns the core of tl with the fields of c, derived once per tenant
+// logger.
+func (c *tenantCore) core(tl *tenantLogger) zapcore.Core {
+	if d := c.derived.Load(); d != nil && d.tl == tl {
+		return d.core
+	}
+	core := tl.lg.Desugar().Core()
+	if c.noGate {
+		core = ungate(core)
+	}
+	if len(c.fields) > 0 {
+		core = core.With(c.fields)
+	}
+	c.derived.Store(&tenantDerived{tl: tl, core: core})
+	return core
+}
+
+func (c *tenantCore) ungated() zapcore.Core {
+	clone := *c
+	clone.fallback = ungate(c.fallback)
+	clone.noGate = true
+	clone.derived = new(atomic.Pointer[tenantDerived])
+	return &clone
+}
+
+func (c *tenantCore) Sync() error {
+	return c.fallback.Sync()
+}
+
+// withTenant returns l writing to the files of tenant id, or to its own if
+// they can't be created. The entries are still gated by the level of l,
+// which the tenant loggers don't share when l has a level of its own.
+func (l *Logging) withTenant(id string) *Logging {
+	return l.derive(l.sugar().WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		tc := &tenantCore{tenants: l.tenants, id: id, fallback: core, derived: new(atomic.Pointer[tenantDerived])}
+		return newLevelFilterCore(tc, l.levelEnabler())
+	})), l.fields)
+}
+
+func (t *tenantLoggers) sync() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var errs []error
+	for _, tl := range t.loggers {
+		errs = append(errs, tl.Sync())
+	}
+	return errors.Join(errs...)
+}
+
+func (t *tenantLoggers) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	var errs []error
+	for _, tl := range t.loggers {
+		errs = append(errs, tl.Close())
+	}
+	return errors.Join(errs...)
+}
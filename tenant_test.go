@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantDir(t *testing.T) {
+	assert.Equal(t, "acme-1.eu_west", tenantDir("acme-1.eu_west"))
+	assert.Equal(t, "%2E%2E", tenantDir(".."))
+	assert.Equal(t, "..%2Fetc", tenantDir("../etc"))
+
+	dirs := map[string]string{}
+	for _, id := range []string{"acme/1", "acme_1", "acme:1", "acme%2F1", "acme%3A1", ".", "%2E"} {
+		dir := tenantDir(id)
+		assert.NotContains(t, dirs, dir, "%s and %s", id, dirs[dir])
+		dirs[dir] = id
+	}
+}
+
+func TestTenantEviction(t *testing.T) {
+	dir := t.TempDir()
+	l := New(WithMode(FileMode), WithPath(dir), WithFilename("app.log"), WithTenantRouting())
+	l.tenants.max = 2
+
+	ctx := func(id string) context.Context { return WithTenant(context.Background(), id) }
+	acme := l.WithContext(ctx("acme"))
+	acme.Info("first")
+	l.WithContext(ctx("globex")).Info("globex")
+	acme.Info("second")
+	// initech evicts globex, the least recently used tenant.
+	l.WithContext(ctx("initech")).Info("initech")
+	assert.Len(t, l.tenants.loggers, 2)
+	assert.Contains(t, l.tenants.loggers, "acme")
+	assert.Contains(t, l.tenants.loggers, "initech")
+
+	// the loggers of an evicted tenant reopen its files.
+	l.WithContext(ctx("globex")).Info("globex again")
+	assert.Len(t, l.tenants.loggers, 2)
+	acme.Info("third")
+	assert.Nil(t, l.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "acme", "app.log"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, strings.Count(string(data), "acme"))
+	data, err = os.ReadFile(filepath.Join(dir, "globex", "app.log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"msg":"globex"`)
+	assert.Contains(t, string(data), `"msg":"globex again"`)
+
+	// the entries logged once closed don't reopen the files of the tenants.
+	l.WithContext(ctx("umbrella")).Info("closed")
+	_, err = os.Stat(filepath.Join(dir, "umbrella"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTenantEvictionInFlight(t *testing.T) {
+	dir := t.TempDir()
+	l := New(WithMode(FileMode), WithPath(dir), WithFilename("app.log"), WithTenantRouting())
+	defer l.Close()
+	l.tenants.max = 1
+
+	// acme is evicted while an entry of it is being written, and only
+	// closed once that entry is written.
+	acme, err := l.tenants.acquire("acme")
+	assert.Nil(t, err)
+	l.WithContext(WithTenant(context.Background(), "globex")).Info("globex")
+	assert.NotContains(t, l.tenants.loggers, "acme")
+	acme.Info("in flight")
+	assert.Nil(t, acme.Sync())
+	l.tenants.release(acme)
+	_, err = acme._rotateLoggers[0].Write([]byte("after release"))
+	assert.ErrorIs(t, err, ErrClosedRollingFile)
+
+	data, err := os.ReadFile(filepath.Join(dir, "acme", "app.log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"msg":"in flight"`)
+}
+
+func TestTenantCoreCache(t *testing.T) {
+	l := New(WithMode(FileMode), WithPath(t.TempDir()), WithFilename("app.log"), WithTenantRouting())
+	defer l.Close()
+
+	acme := l.WithContext(WithTenant(context.Background(), "acme")).With("component", "db")
+	acme.Info("first")
+	core := acme.(*Logging).lg.Desugar().Core().(*levelFilterCore).Core.(*tenantCore)
+	derived := core.derived.Load()
+	assert.NotNil(t, derived)
+
+	// the core with the fields is derived once per tenant logger.
+	acme.Info("second")
+	assert.Same(t, derived, core.derived.Load())
+}
+
+func TestTenantOwnLevel(t *testing.T) {
+	dir := t.TempDir()
+	l := New(WithMode(FileMode), WithPath(dir), WithFilename("app.log"), WithTenantRouting())
+
+	ctx := WithTenant(context.Background(), "acme")
+	l.WithLevel(ErrorLevel).WithContext(ctx).Info("dropped by level")
+	derived := l.With("component", "db").(*Logging)
+	derived.SetLevel(WarnLevel)
+	derived.WithContext(ctx).Info("dropped by set level")
+	l.WithContext(ctx).Info("kept")
+	assert.Nil(t, l.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "acme", "app.log"))
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "dropped")
+	assert.Contains(t, string(data), `"msg":"kept"`)
+}
@@ -0,0 +1,290 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultWebhookBatchSize   = 20
+	defaultWebhookInterval    = 5 * time.Second
+	defaultWebhookMinInterval = time.Second
+	defaultWebhookMaxPending  = 1000
+	defaultWebhookTimeout     = 10 * time.Second
+)
+
+// WebhookOption configures a webhook set with WithWebhook.
+type WebhookOption func(o *webhookOptions)
+
+type webhookOptions struct {
+	batchSize   int
+	interval    time.Duration
+	minInterval time.Duration
+	maxPending  int
+	client      *http.Client
+	format      func(entries []Entry) ([]byte, error)
+	retry       writeRetry
+	ctx         context.Context
+	internal    internalLogger
+}
+
+// WithWebhookBatchSize Setter function to set the number of entries posted
+// at once, default is 20.
+func WithWebhookBatchSize(size int) WebhookOption {
+	return func(o *webhookOptions) {
+		o.batchSize = size
+	}
+}
+
+// WithWebhookInterval Setter function to set how long entries wait for a
+// batch to fill before they are posted, default is 5s.
+func WithWebhookInterval(interval time.Duration) WebhookOption {
+	return func(o *webhookOptions) {
+		o.interval = interval
+	}
+}
+
+// WithWebhookRateLimit Setter function to set the least time between two
+// posts, default is 1s. Entries queue meanwhile, up to the max pending.
+func WithWebhookRateLimit(minInterval time.Duration) WebhookOption {
+	return func(o *webhookOptions) {
+		o.minInterval = minInterval
+	}
+}
+
+// WithWebhookMaxPending Setter function to set the number of entries queued
+// above which new entries are dropped, default is 1000.
+func WithWebhookMaxPending(n int) WebhookOption {
+	return func(o *webhookOptions) {
+		o.maxPending = n
+	}
+}
+
+// WithWebhookClient Setter function to set the http client posting the
+// entries, default has a 10s timeout.
+func WithWebhookClient(client *http.Client) WebhookOption {
+	return func(o *webhookOptions) {
+		o.client = client
+	}
+}
+
+// WithWebhookFormat Setter function to set the body of the posts, e.g. the
+// `{"text": "..."}` of Slack. Default is `{"entries": [...]}`, the entries in
+// JSON.
+func WithWebhookFormat(format func(entries []Entry) ([]byte, error)) WebhookOption {
+	return func(o *webhookOptions) {
+		o.format = format
+	}
+}
+
+// webhook is a webhook set with WithWebhook.
+type webhook struct {
+	url   string
+	level Level
+	opts  []WebhookOption
+}
+
+func formatWebhookEntries(entries []Entry) ([]byte, error) {
+	return json.Marshal(struct {
+		Entries []Entry `json:"entries"`
+	}{entries})
+}
+
+// webhookSink posts the entries in batches, from its own goroutine.
+type webhookSink struct {
+	url  string
+	opts webhookOptions
+	// ctx is canceled on shutdown, stopping the posts and their retries.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	pending []Entry
+	dropped int
+	// postMu serializes the posts, lastPost is the time of the last one.
+	postMu   sync.Mutex
+	lastPost time.Time
+	full     chan struct{}
+}
+
+func newWebhookSink(url string, opts ...WebhookOption) *webhookSink {
+	o := webhookOptions{
+		batchSize:   defaultWebhookBatchSize,
+		interval:    defaultWebhookInterval,
+		minInterval: defaultWebhookMinInterval,
+		maxPending:  defaultWebhookMaxPending,
+		client:      &http.Client{Timeout: defaultWebhookTimeout},
+		format:      formatWebhookEntries,
+		ctx:         context.Background(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s := &webhookSink{url: url, opts: o, full: make(chan struct{}, 1)}
+	s.ctx, s.cancel = context.WithCancel(o.ctx)
+	go s.run()
+	return s
+}
+
+// add queues e, dropping it if too many entries are queued.
+func (s *webhookSink) add(e Entry) {
+	s.mu.Lock()
+	if len(s.pending) >= s.opts.maxPending {
+		s.dropped++
+		s.mu.Unlock()
+		addStat(statDrops, 1)
+		return
+	}
+	s.pending = append(s.pending, e)
+	n := len(s.pending)
+	s.mu.Unlock()
+
+	if n >= s.opts.batchSize {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *webhookSink) run() {
+	t := time.NewTicker(s.opts.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.full:
+		case <-t.C:
+		case <-s.ctx.Done():
+			return
+		}
+
+		s.postMu.Lock()
+		if wait := s.opts.minInterval - time.Since(s.lastPost); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-s.ctx.Done():
+			}
+		}
+		s.postBatches(s.ctx, false)
+		s.postMu.Unlock()
+	}
+}
+
+// flush posts every queued entry now, ignoring the rate limit.
+func (s *webhookSink) flush() error {
+	s.postMu.Lock()
+	defer s.postMu.Unlock()
+	return s.postBatches(s.ctx, true)
+}
+
+// shutdown stops the posts in flight and their retries, then posts the
+// queued entries a last time, until ctx is done.
+func (s *webhookSink) shutdown(ctx context.Context) error {
+	s.cancel()
+	s.postMu.Lock()
+	defer s.postMu.Unlock()
+	return s.postBatches(ctx, true)
+}
+
+// postBatches posts the queued entries, a batch at a time, only the first
+// one unless all is set. It must be called with postMu held.
+func (s *webhookSink) postBatches(ctx context.Context, all bool) error {
+	for {
+		s.mu.Lock()
+		n := len(s.pending)
+		if n > s.opts.batchSize {
+			n = s.opts.batchSize
+		}
+		batch := append([]Entry(nil), s.pending[:n]...)
+		s.pending = append(s.pending[:0], s.pending[n:]...)
+		dropped := s.dropped
+		s.dropped = 0
+		s.mu.Unlock()
+
+		if dropped > 0 {
+			s.opts.internal.warn("webhook dropped entries", "url", s.url, "dropped", dropped)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		err := s.post(ctx, batch)
+		s.lastPost = time.Now()
+		if err != nil {
+			s.opts.internal.error("webhook post failed", "url", s.url, "error", err)
+			return err
+		}
+		if !all {
+			return nil
+		}
+	}
+}
+
+func (s *webhookSink) post(ctx context.Context, batch []Entry) error {
+	body, err := s.opts.format(batch)
+	if err != nil {
+		return err
+	}
+	return s.opts.retry.do(ctx, body, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := s.opts.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil
+	})
+}
+
+// webhookCore feeds the entries from a level to a webhookSink.
+type webhookCore struct {
+	zapcore.LevelEnabler
+	sink   *webhookSink
+	fields []zapcore.Field
+}
+
+func newWebhookCore(ctx context.Context, hook webhook, retry writeRetry, internal internalLogger) *webhookCore {
+	opts := append(hook.opts[:len(hook.opts):len(hook.opts)], func(o *webhookOptions) { o.retry, o.ctx, o.internal = retry, ctx, internal })
+	return &webhookCore{LevelEnabler: hook.level.unmarshalZapLevel(), sink: newWebhookSink(hook.url, opts...)}
+}
+
+func (c *webhookCore) With(fields []zapcore.Field) zapcore.Core {
+	return &webhookCore{LevelEnabler: c.LevelEnabler, sink: c.sink, fields: append(c.fields[:len(c.fields):len(c.fields)], fields...)}
+}
+
+func (c *webhookCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write queues the entry, entries above ErrorLevel are posted before Write
+// returns, as the process may exit next.
+func (c *webhookCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.sink.add(newEntry(ent, c.fields, fields))
+	if ent.Level > zapcore.ErrorLevel {
+		return c.sink.flush()
+	}
+	return nil
+}
+
+func (c *webhookCore) Sync() error {
+	return c.sink.flush()
+}
@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newWebhookServer(t *testing.T) (*httptest.Server, chan []byte) {
+	bodies := make(chan []byte, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	t.Cleanup(srv.Close)
+	return srv, bodies
+}
+
+func TestWithWebhook(t *testing.T) {
+	srv, bodies := newWebhookServer(t)
+	l := New(WithWriter(io.Discard), WithWebhook(srv.URL, ErrorLevel,
+		WithWebhookBatchSize(2),
+		WithWebhookInterval(time.Hour),
+		WithWebhookRateLimit(0),
+	))
+
+	l.Info("not posted")
+	l.With("component", "db").Errorw("query failed", "attempt", 1)
+	l.Error("second failure")
+
+	var body struct {
+		Entries []Entry `json:"entries"`
+	}
+	select {
+	case b := <-bodies:
+		assert.NoError(t, json.Unmarshal(b, &body))
+	case <-time.After(time.Second):
+		t.Fatal("full batch not posted")
+	}
+	assert.Len(t, body.Entries, 2)
+	assert.Equal(t, Level(ErrorLevel), body.Entries[0].Level)
+	assert.Equal(t, "query failed", body.Entries[0].Message)
+	assert.Equal(t, "db", body.Entries[0].Fields["component"])
+	assert.Equal(t, "second failure", body.Entries[1].Message)
+
+	// Sync posts what's queued.
+	l.Error("queued")
+	assert.NoError(t, l.Sync())
+	select {
+	case b := <-bodies:
+		assert.Contains(t, string(b), `"msg":"queued"`)
+	default:
+		t.Fatal("queued entry not posted on sync")
+	}
+}
+
+func TestWebhookSinkLimits(t *testing.T) {
+	srv, bodies := newWebhookServer(t)
+	s := newWebhookSink(srv.URL,
+		WithWebhookInterval(time.Hour),
+		WithWebhookMaxPending(1),
+		WithWebhookFormat(func(entries []Entry) ([]byte, error) {
+			return json.Marshal(map[string]string{"text": entries[0].Message})
+		}),
+	)
+
+	s.add(Entry{Message: "kept"})
+	s.add(Entry{Message: "dropped"})
+	assert.NoError(t, s.flush())
+	assert.JSONEq(t, `{"text":"kept"}`, string(<-bodies))
+	assert.Zero(t, s.dropped)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	s = newWebhookSink(failing.URL, WithWebhookInterval(time.Hour))
+	s.add(Entry{Message: "lost"})
+	assert.Error(t, s.flush())
+
+	assert.ErrorIs(t, newOptions(WithWebhook("", ErrorLevel)).validate(), ErrInvalidOptions)
+}
+
+func TestWebhookShutdown(t *testing.T) {
+	var posts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	base, cancel := context.WithCancel(context.Background())
+	l, err := NewWithError(WithWriter(io.Discard), WithSinkContext(base), WithWriteRetry(100, time.Hour),
+		WithWebhook(srv.URL, ErrorLevel, WithWebhookInterval(time.Hour)))
+	assert.Nil(t, err)
+
+	// the base context stops the retries of the posts.
+	l.Error("unreachable")
+	cancel()
+	start := time.Now()
+	assert.NotNil(t, l.Sync())
+	assert.Less(t, time.Since(start), time.Second)
+
+	// shutdown posts the queued entries a last time, within its deadline.
+	l.Error("queued")
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShutdown()
+	start = time.Now()
+	assert.NotNil(t, l.Shutdown(ctx))
+	assert.Less(t, time.Since(start), time.Second)
+	// only the last post reached the server, the canceled one wasn't sent.
+	assert.Equal(t, int32(1), posts.Load())
+}